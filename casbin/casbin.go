@@ -0,0 +1,97 @@
+// Package casbin evaluates the DFIR RBAC model (model.conf) and policy
+// (policy.csv) shipped in this directory, and carries the default MSP-to-role
+// mapping (msp_roles.csv). Both chaincodes embed these files through this
+// package so the policy reviewed by auditors is exactly the policy enforced
+// on the hot and cold chains. The chaincodes vendor this module, so re-run
+// `go mod vendor` in each chaincode after editing any of these files.
+//
+// Only the subset of the Casbin language used by model.conf is supported:
+// a single request/policy definition, a single "g" role definition (roles may
+// inherit other roles; inheritance is transitive and cycles are rejected), the
+// standard allow/deny effects and matchers built from ==, !=, &&, ||, !,
+// parentheses and the functions g, keyMatch and matchAction.
+package casbin
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+//go:embed model.conf
+var modelText string
+
+//go:embed policy.csv
+var policyText string
+
+//go:embed msp_roles.csv
+var mspRolesText string
+
+// DefaultRole is the role of members of an MSP without a mapping
+const DefaultRole = "User"
+
+var (
+	defaultOnce     sync.Once
+	defaultEnforcer *Enforcer
+	defaultErr      error
+)
+
+// ModelText returns the embedded model.conf contents
+func ModelText() string {
+	return modelText
+}
+
+// PolicyText returns the embedded policy.csv contents
+func PolicyText() string {
+	return policyText
+}
+
+// Default returns an enforcer built from the embedded model and policy.
+// The files are parsed once per process; callers must Clone the enforcer
+// before adding request-scoped role assignments.
+func Default() (*Enforcer, error) {
+	defaultOnce.Do(func() {
+		defaultEnforcer, defaultErr = NewEnforcer(modelText, policyText)
+	})
+	return defaultEnforcer, defaultErr
+}
+
+// DefaultModel returns the embedded model.conf, parsed once per process
+func DefaultModel() (*Model, error) {
+	enforcer, err := Default()
+	if err != nil {
+		return nil, err
+	}
+	return enforcer.Model(), nil
+}
+
+// DefaultMSPRoles returns a fresh copy of the embedded msp_roles.csv
+func DefaultMSPRoles() (map[string]string, error) {
+	return ParseMSPRoles(mspRolesText)
+}
+
+// ParseMSPRoles parses "msp, role" lines. Blank lines and '#' comments are ignored.
+func ParseMSPRoles(text string) (map[string]string, error) {
+	roles := map[string]string{}
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := splitTokens(line)
+		if len(fields) != 2 || fields[0] == "" || fields[1] == "" {
+			return nil, fmt.Errorf("msp role line %d: expected msp, role", lineNo)
+		}
+		roles[fields[0]] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read msp roles: %v", err)
+	}
+
+	return roles, nil
+}
@@ -0,0 +1,308 @@
+package casbin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Enforcer evaluates requests against a model, its policy rules and role assignments
+type Enforcer struct {
+	model *Model
+	rules [][]string
+	roles map[string][]string // user/role -> directly assigned roles
+}
+
+// NewEnforcer parses a model and policy and returns an enforcer for them
+func NewEnforcer(modelText string, policyText string) (*Enforcer, error) {
+	model, err := NewModel(modelText)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := ParsePolicy(policyText)
+	if err != nil {
+		return nil, err
+	}
+	return NewEnforcerFromPolicy(model, policy)
+}
+
+// NewEnforcerFromPolicy returns an enforcer for an already parsed model and policy
+func NewEnforcerFromPolicy(model *Model, policy *Policy) (*Enforcer, error) {
+	e := &Enforcer{
+		model: model,
+		roles: map[string][]string{},
+	}
+
+	for _, rule := range policy.Rules {
+		if err := e.AddPolicy(rule...); err != nil {
+			return nil, err
+		}
+	}
+	for _, link := range policy.Roles {
+		e.AddRoleForUser(link[0], link[1])
+	}
+	if cycle := e.findRoleCycle(); cycle != nil {
+		return nil, fmt.Errorf("role inheritance cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	return e, nil
+}
+
+// Model returns the model the enforcer evaluates
+func (e *Enforcer) Model() *Model {
+	return e.model
+}
+
+// Clone returns a copy of the enforcer that can be modified independently
+func (e *Enforcer) Clone() *Enforcer {
+	clone := &Enforcer{
+		model: e.model,
+		rules: append([][]string(nil), e.rules...),
+		roles: make(map[string][]string, len(e.roles)),
+	}
+	for user, roles := range e.roles {
+		clone.roles[user] = append([]string(nil), roles...)
+	}
+	return clone
+}
+
+// AddPolicy adds a "p" rule. The effect field may be omitted and defaults to allow.
+func (e *Enforcer) AddPolicy(rule ...string) error {
+	want := len(e.model.PolicyTokens)
+	if e.model.policyIndex("eft") >= 0 && len(rule) == want-1 {
+		rule = append(append([]string(nil), rule...), "allow")
+	}
+	if len(rule) != want {
+		return fmt.Errorf("policy rule %v has %d fields, model expects %d", rule, len(rule), want)
+	}
+	if i := e.model.policyIndex("eft"); i >= 0 && rule[i] != "allow" && rule[i] != "deny" {
+		return fmt.Errorf("policy rule %v has invalid effect %q", rule, rule[i])
+	}
+	e.rules = append(e.rules, rule)
+	return nil
+}
+
+// AddRoleForUser adds a "g" assignment of role to user
+func (e *Enforcer) AddRoleForUser(user string, role string) {
+	for _, r := range e.roles[user] {
+		if r == role {
+			return
+		}
+	}
+	e.roles[user] = append(e.roles[user], role)
+}
+
+// HasRoleForUser reports whether user holds role directly or through inherited roles
+func (e *Enforcer) HasRoleForUser(user string, role string) bool {
+	if user == role {
+		return true
+	}
+	for _, r := range e.GetImplicitRolesForUser(user) {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// GetImplicitRolesForUser returns every role user holds, directly or through
+// inheritance, nearest first. Cycles are not followed.
+func (e *Enforcer) GetImplicitRolesForUser(user string) []string {
+	var roles []string
+	visited := map[string]bool{user: true}
+	queue := []string{user}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, r := range e.roles[current] {
+			if !visited[r] {
+				visited[r] = true
+				roles = append(roles, r)
+				queue = append(queue, r)
+			}
+		}
+	}
+	return roles
+}
+
+// GetImplicitPermissionsForUser returns the "p" rules granted or denied to user
+// directly or through any inherited role
+func (e *Enforcer) GetImplicitPermissionsForUser(user string) [][]string {
+	subjects := map[string]bool{user: true}
+	for _, r := range e.GetImplicitRolesForUser(user) {
+		subjects[r] = true
+	}
+
+	subIndex := e.model.policyIndex("sub")
+	var permissions [][]string
+	for _, rule := range e.rules {
+		if subIndex >= 0 && subjects[rule[subIndex]] {
+			permissions = append(permissions, append([]string(nil), rule...))
+		}
+	}
+	return permissions
+}
+
+// findRoleCycle returns a chain of "g" assignments that loops back on itself, or nil
+func (e *Enforcer) findRoleCycle() []string {
+	const (
+		unvisited = iota
+		inProgress
+		done
+	)
+	state := map[string]int{}
+	var path []string
+
+	var visit func(user string) []string
+	visit = func(user string) []string {
+		state[user] = inProgress
+		path = append(path, user)
+		for _, r := range e.roles[user] {
+			switch state[r] {
+			case inProgress:
+				for i, p := range path {
+					if p == r {
+						return append(append([]string(nil), path[i:]...), r)
+					}
+				}
+			case unvisited:
+				if cycle := visit(r); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[user] = done
+		return nil
+	}
+
+	users := make([]string, 0, len(e.roles))
+	for user := range e.roles {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	for _, user := range users {
+		if state[user] == unvisited {
+			if cycle := visit(user); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// RuleResult is the outcome of one "p" rule for a request
+type RuleResult struct {
+	Rule    []string `json:"rule"`    // sub, obj, act, res[, eft]
+	Matched bool     `json:"matched"` // Whether the matcher accepted the rule for the request
+}
+
+// Enforce reports whether the request (in request_definition order) is allowed
+func (e *Enforcer) Enforce(rvals ...string) (bool, error) {
+	allowed, denied, err := e.evaluate(rvals, nil)
+	if err != nil {
+		return false, err
+	}
+	return e.decide(allowed, denied), nil
+}
+
+// Denied reports whether a deny rule matches the request, regardless of any allow rules
+func (e *Enforcer) Denied(rvals ...string) (bool, error) {
+	_, denied, err := e.evaluate(rvals, nil)
+	return denied, err
+}
+
+// Explain evaluates the request like Enforce and also returns the outcome of every
+// rule whose subject the requesting subject holds (directly or through inheritance)
+func (e *Enforcer) Explain(rvals ...string) (bool, []RuleResult, error) {
+	subject := ""
+	if i := e.model.requestIndex("sub"); i >= 0 && i < len(rvals) {
+		subject = rvals[i]
+	}
+	subIndex := e.model.policyIndex("sub")
+
+	var results []RuleResult
+	allowed, denied, err := e.evaluate(rvals, func(rule []string, matched bool) {
+		if subIndex < 0 || e.HasRoleForUser(subject, rule[subIndex]) {
+			results = append(results, RuleResult{Rule: append([]string(nil), rule...), Matched: matched})
+		}
+	})
+	if err != nil {
+		return false, nil, err
+	}
+	return e.decide(allowed, denied), results, nil
+}
+
+// decide applies the model's policy effect
+func (e *Enforcer) decide(allowed bool, denied bool) bool {
+	if denied && e.model.Effect == EffectDenyOverride {
+		return false
+	}
+	return allowed
+}
+
+// evaluate reports whether any allow rule and any deny rule match the request,
+// calling visit (if set) with the outcome of each rule
+func (e *Enforcer) evaluate(rvals []string, visit func(rule []string, matched bool)) (allowed bool, denied bool, err error) {
+	if len(rvals) != len(e.model.RequestTokens) {
+		return false, false, fmt.Errorf("request has %d fields, model expects %d", len(rvals), len(e.model.RequestTokens))
+	}
+
+	env := &matchEnv{
+		vars: make(map[string]string, len(rvals)+len(e.model.PolicyTokens)),
+		funcs: map[string]matchFunc{
+			"g": func(args []string) (bool, error) {
+				if len(args) != 2 {
+					return false, fmt.Errorf("g expects 2 arguments")
+				}
+				return e.HasRoleForUser(args[0], args[1]), nil
+			},
+			"keyMatch": func(args []string) (bool, error) {
+				if len(args) != 2 {
+					return false, fmt.Errorf("keyMatch expects 2 arguments")
+				}
+				return keyMatch(args[0], args[1]), nil
+			},
+			"matchAction": func(args []string) (bool, error) {
+				if len(args) != 2 {
+					return false, fmt.Errorf("matchAction expects 2 arguments")
+				}
+				return matchAction(args[0], args[1]), nil
+			},
+		},
+	}
+	for i, token := range e.model.RequestTokens {
+		env.vars["r."+token] = rvals[i]
+	}
+
+	eftIndex := e.model.policyIndex("eft")
+	for _, rule := range e.rules {
+		for i, token := range e.model.PolicyTokens {
+			env.vars["p."+token] = rule[i]
+		}
+
+		matched, err := e.model.Matcher.eval(env)
+		if err != nil {
+			return false, false, fmt.Errorf("failed to evaluate matcher: %v", err)
+		}
+		if visit != nil {
+			visit(rule, matched)
+		}
+		if !matched {
+			continue
+		}
+
+		effect := "allow"
+		if eftIndex >= 0 {
+			effect = rule[eftIndex]
+		}
+		switch effect {
+		case "allow":
+			allowed = true
+		case "deny":
+			denied = true
+		}
+	}
+
+	return allowed, denied, nil
+}
@@ -0,0 +1,228 @@
+package casbin
+
+import (
+	"strings"
+	"testing"
+)
+
+// testModel is model.conf with the effect replaced by effect
+func testModel(t *testing.T, effect string) *Model {
+	t.Helper()
+	text := strings.Replace(modelText, EffectDenyOverride, effect, 1)
+	model, err := NewModel(text)
+	if err != nil {
+		t.Fatalf("failed to parse model: %v", err)
+	}
+	return model
+}
+
+func TestDenyOverridesEveryGrant(t *testing.T) {
+	policy, err := ParsePolicy(`
+p, SystemAdmin, *, *, *
+p, BlockchainInvestigator, blockchain.evidence, view|update, *
+p, LawEnforcementMSP/CN=examiner1, *, *, case:INV-001, deny
+p, BlockchainInvestigator, blockchain.evidence, update, evidence:EVD-009, deny
+g, LawEnforcementMSP/CN=examiner1, BlockchainInvestigator
+g, LawEnforcementMSP/CN=admin1, SystemAdmin
+`)
+	if err != nil {
+		t.Fatalf("failed to parse policy: %v", err)
+	}
+
+	denyOverride, err := NewEnforcerFromPolicy(testModel(t, EffectDenyOverride), policy)
+	if err != nil {
+		t.Fatalf("failed to build enforcer: %v", err)
+	}
+	allowOverride, err := NewEnforcerFromPolicy(testModel(t, EffectAllowOverride), policy)
+	if err != nil {
+		t.Fatalf("failed to build enforcer: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		request []string
+		want    bool // under deny-override
+		denied  bool
+	}{
+		{"role grant", []string{"LawEnforcementMSP/CN=examiner1", "blockchain.evidence", "view", "hot"}, true, false},
+		{"no grant", []string{"LawEnforcementMSP/CN=examiner1", "blockchain.evidence", "delete", "hot"}, false, false},
+		{"recusal on the record", []string{"LawEnforcementMSP/CN=examiner1", "blockchain.evidence", "view", "case:INV-001"}, false, true},
+		{"recusal elsewhere", []string{"LawEnforcementMSP/CN=examiner1", "blockchain.evidence", "view", "case:INV-002"}, true, false},
+		{"admin wildcard", []string{"LawEnforcementMSP/CN=admin1", "blockchain.evidence", "update", "evidence:EVD-009"}, true, false},
+		{"inherited deny", []string{"LawEnforcementMSP/CN=examiner1", "blockchain.evidence", "update", "evidence:EVD-009"}, false, true},
+		{"same CN in another MSP", []string{"ForensicLabMSP/CN=examiner1", "blockchain.evidence", "view", "hot"}, false, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := denyOverride.Enforce(tc.request...)
+			if err != nil {
+				t.Fatalf("Enforce: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("Enforce(%v) = %t, want %t", tc.request, got, tc.want)
+			}
+			denied, err := denyOverride.Denied(tc.request...)
+			if err != nil {
+				t.Fatalf("Denied: %v", err)
+			}
+			if denied != tc.denied {
+				t.Errorf("Denied(%v) = %t, want %t", tc.request, denied, tc.denied)
+			}
+		})
+	}
+
+	// The admin's wildcard grant is overridden once a deny rule names it
+	denyOverride.AddPolicy("LawEnforcementMSP/CN=admin1", "*", "*", "case:INV-001", "deny")
+	if allowed, _ := denyOverride.Enforce("LawEnforcementMSP/CN=admin1", "blockchain.investigation", "view", "case:INV-001"); allowed {
+		t.Errorf("deny rule did not override the SystemAdmin grant")
+	}
+
+	// Without deny-override a matching allow rule wins
+	if allowed, _ := allowOverride.Enforce("LawEnforcementMSP/CN=admin1", "blockchain.evidence", "update", "evidence:EVD-009"); !allowed {
+		t.Errorf("allow-override model denied a granted request")
+	}
+}
+
+func TestRoleInheritance(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		roles   string
+		cycle   string // Expected cycle error, empty if the policy is valid
+		user    string
+		holds   []string
+		lacks   []string
+		nearest string
+	}{
+		{
+			name:    "direct",
+			roles:   "g, alice, Investigator",
+			user:    "alice",
+			holds:   []string{"alice", "Investigator"},
+			lacks:   []string{"Supervisor"},
+			nearest: "Investigator",
+		},
+		{
+			name: "transitive depth four",
+			roles: `g, alice, Lead
+g, Lead, Supervisor
+g, Supervisor, Investigator
+g, Investigator, Reader`,
+			user:    "alice",
+			holds:   []string{"Lead", "Supervisor", "Investigator", "Reader"},
+			nearest: "Lead",
+		},
+		{
+			name: "diamond",
+			roles: `g, alice, Court
+g, alice, Supervisor
+g, Court, Auditor
+g, Supervisor, Auditor`,
+			user:  "alice",
+			holds: []string{"Court", "Supervisor", "Auditor"},
+		},
+		{
+			name: "inheritance does not flow downward",
+			roles: `g, Supervisor, Investigator
+g, bob, Investigator`,
+			user:  "bob",
+			holds: []string{"Investigator"},
+			lacks: []string{"Supervisor"},
+		},
+		{
+			name:  "self loop",
+			roles: "g, Court, Court",
+			cycle: "Court -> Court",
+		},
+		{
+			name: "two role loop",
+			roles: `g, Auditor, Court
+g, Court, Auditor`,
+			cycle: "Auditor -> Court -> Auditor",
+		},
+		{
+			name: "deep loop",
+			roles: `g, alice, A
+g, A, B
+g, B, C
+g, C, A`,
+			cycle: "A -> B -> C -> A",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			policy, err := ParsePolicy(tc.roles)
+			if err != nil {
+				t.Fatalf("failed to parse policy: %v", err)
+			}
+			model, err := DefaultModel()
+			if err != nil {
+				t.Fatalf("failed to load model: %v", err)
+			}
+			e, err := NewEnforcerFromPolicy(model, policy)
+			if tc.cycle != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.cycle) {
+					t.Fatalf("error = %v, want cycle %s", err, tc.cycle)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to build enforcer: %v", err)
+			}
+
+			for _, role := range tc.holds {
+				if !e.HasRoleForUser(tc.user, role) {
+					t.Errorf("%s does not hold %s", tc.user, role)
+				}
+			}
+			for _, role := range tc.lacks {
+				if e.HasRoleForUser(tc.user, role) {
+					t.Errorf("%s holds %s", tc.user, role)
+				}
+			}
+			if roles := e.GetImplicitRolesForUser(tc.user); tc.nearest != "" && (len(roles) == 0 || roles[0] != tc.nearest) {
+				t.Errorf("implicit roles %v, want %s first", roles, tc.nearest)
+			}
+		})
+	}
+}
+
+func TestImplicitPermissionsFollowInheritance(t *testing.T) {
+	e, err := NewEnforcer(modelText, `
+p, Investigator, blockchain.evidence, view, *
+p, Supervisor, blockchain.evidence, history, *
+p, Auditor, audits.*, view, *
+g, Supervisor, Investigator
+g, carol, Supervisor
+`)
+	if err != nil {
+		t.Fatalf("failed to build enforcer: %v", err)
+	}
+
+	permissions := e.GetImplicitPermissionsForUser("carol")
+	if len(permissions) != 2 {
+		t.Fatalf("carol has %d permissions, want 2: %v", len(permissions), permissions)
+	}
+	if allowed, _ := e.Enforce("carol", "blockchain.evidence", "view", "hot"); !allowed {
+		t.Errorf("inherited Investigator grant not applied")
+	}
+	if allowed, _ := e.Enforce("carol", "audits.operatelog", "view", "hot"); allowed {
+		t.Errorf("Auditor grant applied to a user without the role")
+	}
+}
+
+func TestEnforceRejectsMalformedRequests(t *testing.T) {
+	e, err := Default()
+	if err != nil {
+		t.Fatalf("failed to load default enforcer: %v", err)
+	}
+	if _, err := e.Enforce("alice", "blockchain.evidence", "view"); err == nil {
+		t.Errorf("request with three fields accepted")
+	}
+	for _, rule := range [][]string{
+		{"alice", "blockchain.evidence", "view"},
+		{"alice", "blockchain.evidence", "view", "*", "allow", "extra"},
+		{"alice", "blockchain.evidence", "view", "*", "permit"},
+	} {
+		if err := e.Clone().AddPolicy(rule...); err == nil {
+			t.Errorf("AddPolicy(%v) succeeded", rule)
+		}
+	}
+}
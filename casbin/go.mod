@@ -0,0 +1,3 @@
+module github.com/aub/dfir-casbin
+
+go 1.21
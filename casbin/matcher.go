@@ -0,0 +1,293 @@
+package casbin
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// expr is a node of a parsed matcher expression
+type expr struct {
+	op   string // "||", "&&", "!", "==", "!=", "call", "var", "str"
+	name string // variable or function name, string literal value
+	args []*expr
+}
+
+// matchFunc is a function callable from a matcher
+type matchFunc func(args []string) (bool, error)
+
+// matchEnv resolves variables and functions during evaluation
+type matchEnv struct {
+	vars  map[string]string
+	funcs map[string]matchFunc
+}
+
+// value is the result of evaluating a matcher node
+type value struct {
+	str    string
+	b      bool
+	isBool bool
+}
+
+// parseExpr parses a matcher expression
+func parseExpr(text string) (*expr, error) {
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected token %q", p.tokens[p.pos])
+	}
+	return node, nil
+}
+
+// tokenize splits a matcher expression into tokens
+func tokenize(text string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(text); {
+		c := rune(text[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"':
+			end := strings.IndexByte(text[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string literal")
+			}
+			tokens = append(tokens, text[i:i+end+2])
+			i += end + 2
+		case strings.HasPrefix(text[i:], "&&"), strings.HasPrefix(text[i:], "||"),
+			strings.HasPrefix(text[i:], "=="), strings.HasPrefix(text[i:], "!="):
+			tokens = append(tokens, text[i:i+2])
+			i += 2
+		case c == '!' || c == '(' || c == ')' || c == ',':
+			tokens = append(tokens, string(c))
+			i++
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(text) && (unicode.IsLetter(rune(text[i])) || unicode.IsDigit(rune(text[i])) ||
+				text[i] == '_' || text[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, text[start:i])
+		default:
+			return nil, fmt.Errorf("unexpected character %q", c)
+		}
+	}
+	return tokens, nil
+}
+
+// exprParser is a recursive descent parser over matcher tokens
+type exprParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) next() string {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *exprParser) expect(tok string) error {
+	if got := p.next(); got != tok {
+		return fmt.Errorf("expected %q, got %q", tok, got)
+	}
+	return nil
+}
+
+func (p *exprParser) parseOr() (*expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &expr{op: "||", args: []*expr{left, right}}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (*expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &expr{op: "&&", args: []*expr{left, right}}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (*expr, error) {
+	if p.peek() == "!" {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &expr{op: "!", args: []*expr{operand}}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (*expr, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if op := p.peek(); op == "==" || op == "!=" {
+		p.next()
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return &expr{op: op, args: []*expr{left, right}}, nil
+	}
+	return left, nil
+}
+
+func (p *exprParser) parsePrimary() (*expr, error) {
+	tok := p.next()
+	switch {
+	case tok == "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case tok == "(":
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case strings.HasPrefix(tok, `"`):
+		return &expr{op: "str", name: tok[1 : len(tok)-1]}, nil
+	case p.peek() == "(":
+		p.next()
+		call := &expr{op: "call", name: tok}
+		for p.peek() != ")" {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if p.peek() == "," {
+				p.next()
+			}
+		}
+		p.next()
+		return call, nil
+	case strings.Contains(tok, "."):
+		return &expr{op: "var", name: tok}, nil
+	default:
+		return nil, fmt.Errorf("unexpected token %q", tok)
+	}
+}
+
+// eval evaluates a matcher node to a boolean
+func (e *expr) eval(env *matchEnv) (bool, error) {
+	v, err := e.evalValue(env)
+	if err != nil {
+		return false, err
+	}
+	if !v.isBool {
+		return false, fmt.Errorf("expression %q is not boolean", v.str)
+	}
+	return v.b, nil
+}
+
+func (e *expr) evalValue(env *matchEnv) (value, error) {
+	switch e.op {
+	case "str":
+		return value{str: e.name}, nil
+	case "var":
+		v, ok := env.vars[e.name]
+		if !ok {
+			return value{}, fmt.Errorf("unknown variable %s", e.name)
+		}
+		return value{str: v}, nil
+	case "!":
+		b, err := e.args[0].eval(env)
+		return value{b: !b, isBool: true}, err
+	case "&&", "||":
+		left, err := e.args[0].eval(env)
+		if err != nil {
+			return value{}, err
+		}
+		if (e.op == "&&" && !left) || (e.op == "||" && left) {
+			return value{b: left, isBool: true}, nil
+		}
+		right, err := e.args[1].eval(env)
+		return value{b: right, isBool: true}, err
+	case "==", "!=":
+		left, err := e.args[0].evalValue(env)
+		if err != nil {
+			return value{}, err
+		}
+		right, err := e.args[1].evalValue(env)
+		if err != nil {
+			return value{}, err
+		}
+		equal := left == right
+		return value{b: equal == (e.op == "=="), isBool: true}, nil
+	case "call":
+		fn, ok := env.funcs[e.name]
+		if !ok {
+			return value{}, fmt.Errorf("unknown function %s", e.name)
+		}
+		args := make([]string, len(e.args))
+		for i, arg := range e.args {
+			v, err := arg.evalValue(env)
+			if err != nil {
+				return value{}, err
+			}
+			args[i] = v.str
+		}
+		b, err := fn(args)
+		return value{b: b, isBool: true}, err
+	}
+	return value{}, fmt.Errorf("unknown operator %s", e.op)
+}
+
+// keyMatch matches a key against a pattern where a trailing '*' matches any suffix
+func keyMatch(key string, pattern string) bool {
+	i := strings.Index(pattern, "*")
+	if i < 0 {
+		return key == pattern
+	}
+	return strings.HasPrefix(key, pattern[:i])
+}
+
+// matchAction matches a requested action against a policy action. The policy
+// action may be "*", a single action, or alternatives separated by '|'.
+func matchAction(action string, pattern string) bool {
+	for _, alt := range strings.Split(pattern, "|") {
+		alt = strings.TrimSpace(alt)
+		if alt == "*" || alt == action {
+			return true
+		}
+	}
+	return false
+}
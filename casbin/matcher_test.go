@@ -0,0 +1,104 @@
+package casbin
+
+import "testing"
+
+func TestKeyMatch(t *testing.T) {
+	for _, tc := range []struct {
+		key     string
+		pattern string
+		want    bool
+	}{
+		{"blockchain.evidence", "blockchain.evidence", true},
+		{"blockchain.evidence", "blockchain.investigation", false},
+		{"audits.operatelog", "audits.*", true},
+		{"audits.", "audits.*", true},
+		{"audits", "audits.*", false},
+		{"reports.monthly", "audits.*", false},
+		{"anything", "*", true},
+		{"", "*", true},
+		{"blockchain.evidence", "blockchain.evidence.*", false},
+		{"audits.*", "audits.operatelog", false},
+	} {
+		if got := keyMatch(tc.key, tc.pattern); got != tc.want {
+			t.Errorf("keyMatch(%q, %q) = %t, want %t", tc.key, tc.pattern, got, tc.want)
+		}
+	}
+}
+
+func TestMatchAction(t *testing.T) {
+	for _, tc := range []struct {
+		action  string
+		pattern string
+		want    bool
+	}{
+		{"view", "view", true},
+		{"view", "update", false},
+		{"view", "*", true},
+		{"update", "view|update", true},
+		{"update", "view | update", true},
+		{"delete", "view|update", false},
+		{"delete", "view|*", true},
+		{"vie", "view", false},
+		{"", "view", false},
+	} {
+		if got := matchAction(tc.action, tc.pattern); got != tc.want {
+			t.Errorf("matchAction(%q, %q) = %t, want %t", tc.action, tc.pattern, got, tc.want)
+		}
+	}
+}
+
+func TestParseExprRejectsMalformedMatchers(t *testing.T) {
+	for _, text := range []string{
+		`r.sub == p.sub &&`,
+		`(r.sub == p.sub`,
+		`r.sub == p.sub)`,
+		`r.sub == "unterminated`,
+		`r.sub == p.sub # comment`,
+		`sub == p.sub`,
+		``,
+	} {
+		if _, err := parseExpr(text); err == nil {
+			t.Errorf("parseExpr(%q) succeeded", text)
+		}
+	}
+}
+
+func TestMatcherEvaluation(t *testing.T) {
+	env := &matchEnv{
+		vars:  map[string]string{"r.sub": "alice", "p.sub": "alice", "p.act": "*"},
+		funcs: map[string]matchFunc{"yes": func([]string) (bool, error) { return true, nil }},
+	}
+	for _, tc := range []struct {
+		text string
+		want bool
+	}{
+		{`r.sub == p.sub`, true},
+		{`r.sub != p.sub`, false},
+		{`!(r.sub == p.sub)`, false},
+		{`r.sub == "bob" || p.act == "*"`, true},
+		{`r.sub == "bob" || p.act == "view" && yes()`, false},
+		{`(r.sub == "bob" || p.act == "*") && yes()`, true},
+	} {
+		node, err := parseExpr(tc.text)
+		if err != nil {
+			t.Fatalf("parseExpr(%q): %v", tc.text, err)
+		}
+		got, err := node.eval(env)
+		if err != nil {
+			t.Fatalf("eval(%q): %v", tc.text, err)
+		}
+		if got != tc.want {
+			t.Errorf("eval(%q) = %t, want %t", tc.text, got, tc.want)
+		}
+	}
+
+	for _, text := range []string{`r.sub`, `r.missing == "x"`, `unknown(r.sub)`} {
+		node, err := parseExpr(text)
+		if err != nil {
+			t.Fatalf("parseExpr(%q): %v", text, err)
+		}
+		if _, err := node.eval(env); err == nil {
+			t.Errorf("eval(%q) succeeded", text)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package casbin
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// Supported policy effect expressions
+const (
+	EffectAllowOverride = "some(where (p.eft == allow))"
+	EffectDenyOverride  = "some(where (p.eft == allow)) && !some(where (p.eft == deny))"
+)
+
+// Model is a parsed Casbin model definition
+type Model struct {
+	RequestTokens []string // e.g. sub, obj, act, res
+	PolicyTokens  []string // e.g. sub, obj, act, res[, eft]
+	Effect        string
+	Matcher       *expr
+	MatcherText   string
+}
+
+// NewModel parses a Casbin model definition
+func NewModel(text string) (*Model, error) {
+	sections := map[string]map[string]string{}
+	current := ""
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			current = strings.TrimSpace(line[1 : len(line)-1])
+			if sections[current] == nil {
+				sections[current] = map[string]string{}
+			}
+			continue
+		}
+		if current == "" {
+			return nil, fmt.Errorf("model line %d: definition outside of a section", lineNo)
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("model line %d: expected key = value", lineNo)
+		}
+		sections[current][strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read model: %v", err)
+	}
+
+	request, err := sectionValue(sections, "request_definition", "r")
+	if err != nil {
+		return nil, err
+	}
+	policy, err := sectionValue(sections, "policy_definition", "p")
+	if err != nil {
+		return nil, err
+	}
+	role, err := sectionValue(sections, "role_definition", "g")
+	if err != nil {
+		return nil, err
+	}
+	if strings.ReplaceAll(role, " ", "") != "_,_" {
+		return nil, fmt.Errorf("unsupported role definition: %s", role)
+	}
+	effect, err := sectionValue(sections, "policy_effect", "e")
+	if err != nil {
+		return nil, err
+	}
+	matcherText, err := sectionValue(sections, "matchers", "m")
+	if err != nil {
+		return nil, err
+	}
+
+	model := &Model{
+		RequestTokens: splitTokens(request),
+		PolicyTokens:  splitTokens(policy),
+		Effect:        normalizeEffect(effect),
+		MatcherText:   matcherText,
+	}
+
+	if model.Effect != EffectAllowOverride && model.Effect != EffectDenyOverride {
+		return nil, fmt.Errorf("unsupported policy effect: %s", effect)
+	}
+
+	model.Matcher, err = parseExpr(matcherText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid matcher: %v", err)
+	}
+
+	return model, nil
+}
+
+// requestIndex returns the position of a request token, or -1
+func (m *Model) requestIndex(token string) int {
+	for i, t := range m.RequestTokens {
+		if t == token {
+			return i
+		}
+	}
+	return -1
+}
+
+// policyIndex returns the position of a policy token, or -1
+func (m *Model) policyIndex(token string) int {
+	for i, t := range m.PolicyTokens {
+		if t == token {
+			return i
+		}
+	}
+	return -1
+}
+
+// sectionValue returns a required key from a model section
+func sectionValue(sections map[string]map[string]string, section string, key string) (string, error) {
+	values, ok := sections[section]
+	if !ok {
+		return "", fmt.Errorf("model is missing [%s]", section)
+	}
+	value, ok := values[key]
+	if !ok {
+		return "", fmt.Errorf("model section [%s] is missing %s", section, key)
+	}
+	return value, nil
+}
+
+// splitTokens splits a comma separated definition into trimmed tokens
+func splitTokens(value string) []string {
+	parts := strings.Split(value, ",")
+	tokens := make([]string, 0, len(parts))
+	for _, part := range parts {
+		tokens = append(tokens, strings.TrimSpace(part))
+	}
+	return tokens
+}
+
+// normalizeEffect collapses whitespace so effects compare reliably
+func normalizeEffect(effect string) string {
+	return strings.Join(strings.Fields(effect), " ")
+}
@@ -0,0 +1,45 @@
+package casbin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewModelRejectsMalformedDefinitions(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		replace [2]string // Applied to model.conf
+		want    string
+	}{
+		{"definition outside a section", [2]string{"[request_definition]\n", ""}, "outside of a section"},
+		{"line without =", [2]string{"r = sub, obj, act, res", "r sub, obj, act, res"}, "expected key = value"},
+		{"missing section", [2]string{"[policy_effect]", "[effect]"}, "missing [policy_effect]"},
+		{"missing key", [2]string{"m = ", "matcher = "}, "[matchers] is missing m"},
+		{"role definition with domain", [2]string{"g = _, _", "g = _, _, _"}, "unsupported role definition"},
+		{"unsupported effect", [2]string{"e = some(where (p.eft == allow)) && !some(where (p.eft == deny))", "e = priority(p.eft) || deny"}, "unsupported policy effect"},
+		{"unbalanced matcher", [2]string{`(p.act == "*" ||`, `((p.act == "*" ||`}, "invalid matcher"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			text := strings.Replace(modelText, tc.replace[0], tc.replace[1], 1)
+			if text == modelText {
+				t.Fatalf("replacement %q does not apply to model.conf", tc.replace[0])
+			}
+			_, err := NewModel(text)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("error = %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewModelNormalizesEffect(t *testing.T) {
+	text := strings.Replace(modelText, "e = some(where (p.eft == allow)) && !some(where (p.eft == deny))",
+		"e = some(where (p.eft == allow))   &&   !some(where (p.eft == deny))", 1)
+	model, err := NewModel(text)
+	if err != nil {
+		t.Fatalf("failed to parse model: %v", err)
+	}
+	if model.Effect != EffectDenyOverride {
+		t.Errorf("effect %q, want %q", model.Effect, EffectDenyOverride)
+	}
+}
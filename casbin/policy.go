@@ -0,0 +1,137 @@
+package casbin
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// Policy holds the "p" rules and "g" role assignments of a policy file
+type Policy struct {
+	Rules [][]string // p lines without the leading "p"
+	Roles [][]string // g lines without the leading "g"
+}
+
+// ParsePolicy parses a Casbin CSV policy. Blank lines and '#' comments are ignored.
+func ParsePolicy(text string) (*Policy, error) {
+	policy := &Policy{}
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := splitTokens(line)
+		switch fields[0] {
+		case "p":
+			policy.Rules = append(policy.Rules, fields[1:])
+		case "g":
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("policy line %d: g requires user and role", lineNo)
+			}
+			policy.Roles = append(policy.Roles, fields[1:])
+		default:
+			return nil, fmt.Errorf("policy line %d: unknown policy type %q", lineNo, fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read policy: %v", err)
+	}
+
+	return policy, nil
+}
+
+// DefaultPolicy returns a fresh copy of the embedded policy.csv
+func DefaultPolicy() (*Policy, error) {
+	return ParsePolicy(policyText)
+}
+
+// HasRule reports whether the policy contains the given "p" rule
+func (p *Policy) HasRule(rule []string) bool {
+	return indexOf(p.Rules, rule) >= 0
+}
+
+// AddRule adds a "p" rule, returning false if it is already present
+func (p *Policy) AddRule(rule []string) bool {
+	if p.HasRule(rule) {
+		return false
+	}
+	p.Rules = append(p.Rules, rule)
+	return true
+}
+
+// RemoveRule removes a "p" rule, returning false if it was not present
+func (p *Policy) RemoveRule(rule []string) bool {
+	i := indexOf(p.Rules, rule)
+	if i < 0 {
+		return false
+	}
+	p.Rules = append(p.Rules[:i], p.Rules[i+1:]...)
+	return true
+}
+
+// AddRole adds a "g" assignment, returning false if it is already present
+func (p *Policy) AddRole(user string, role string) bool {
+	if indexOf(p.Roles, []string{user, role}) >= 0 {
+		return false
+	}
+	p.Roles = append(p.Roles, []string{user, role})
+	return true
+}
+
+// RemoveRole removes a "g" assignment, returning false if it was not present
+func (p *Policy) RemoveRole(user string, role string) bool {
+	i := indexOf(p.Roles, []string{user, role})
+	if i < 0 {
+		return false
+	}
+	p.Roles = append(p.Roles[:i], p.Roles[i+1:]...)
+	return true
+}
+
+// RenameSubject replaces subject from with to in every "p" rule subject and on
+// both sides of every "g" assignment, returning the number of lines changed
+func (p *Policy) RenameSubject(from string, to string) int {
+	changed := 0
+	for _, rule := range p.Rules {
+		if len(rule) > 0 && rule[0] == from {
+			rule[0] = to
+			changed++
+		}
+	}
+	for _, link := range p.Roles {
+		renamed := false
+		for i := range link {
+			if link[i] == from {
+				link[i] = to
+				renamed = true
+			}
+		}
+		if renamed {
+			changed++
+		}
+	}
+	return changed
+}
+
+// indexOf returns the position of line in lines, or -1
+func indexOf(lines [][]string, line []string) int {
+	for i, l := range lines {
+		if len(l) != len(line) {
+			continue
+		}
+		equal := true
+		for j := range l {
+			if l[j] != line[j] {
+				equal = false
+				break
+			}
+		}
+		if equal {
+			return i
+		}
+	}
+	return -1
+}
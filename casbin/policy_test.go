@@ -0,0 +1,105 @@
+package casbin
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy(`
+# comment
+p, SystemAdmin, *, *, *
+
+p,BlockchainAuditor,audits.*,view,*
+p, ForensicLabMSP/CN=examiner1, *, *, case:INV-001, deny
+g, BlockchainCourt, BlockchainAuditor
+`)
+	if err != nil {
+		t.Fatalf("failed to parse policy: %v", err)
+	}
+	wantRules := [][]string{
+		{"SystemAdmin", "*", "*", "*"},
+		{"BlockchainAuditor", "audits.*", "view", "*"},
+		{"ForensicLabMSP/CN=examiner1", "*", "*", "case:INV-001", "deny"},
+	}
+	if !reflect.DeepEqual(policy.Rules, wantRules) {
+		t.Errorf("rules = %v, want %v", policy.Rules, wantRules)
+	}
+	if want := [][]string{{"BlockchainCourt", "BlockchainAuditor"}}; !reflect.DeepEqual(policy.Roles, want) {
+		t.Errorf("roles = %v, want %v", policy.Roles, want)
+	}
+}
+
+func TestParsePolicyRejectsMalformedLines(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		want string
+	}{
+		{"g, alice", "policy line 1: g requires user and role"},
+		{"p, SystemAdmin, *, *, *\ng, alice, Court, hot", "policy line 2: g requires user and role"},
+		{"x, alice, Court", `policy line 1: unknown policy type "x"`},
+		{"alice, Court", `unknown policy type "alice"`},
+		{"P, SystemAdmin, *, *, *", `unknown policy type "P"`},
+	} {
+		_, err := ParsePolicy(tc.text)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("ParsePolicy(%q) error = %v, want %q", tc.text, err, tc.want)
+		}
+	}
+
+	// Rules that parse but do not fit the model are rejected by the enforcer
+	for _, text := range []string{
+		"p, SystemAdmin, *, *",
+		"p, SystemAdmin, *, *, *, allow, extra",
+		"p, SystemAdmin, *, *, *, maybe",
+	} {
+		if _, err := NewEnforcer(modelText, text); err == nil {
+			t.Errorf("NewEnforcer accepted %q", text)
+		}
+	}
+}
+
+func TestParseMSPRoles(t *testing.T) {
+	roles, err := ParseMSPRoles("# msp, role\nCourtMSP, BlockchainCourt\n\nAuditorMSP,BlockchainAuditor\n")
+	if err != nil {
+		t.Fatalf("failed to parse MSP roles: %v", err)
+	}
+	if want := map[string]string{"CourtMSP": "BlockchainCourt", "AuditorMSP": "BlockchainAuditor"}; !reflect.DeepEqual(roles, want) {
+		t.Errorf("roles = %v, want %v", roles, want)
+	}
+
+	for _, text := range []string{"CourtMSP", "CourtMSP, BlockchainCourt, extra", "CourtMSP, ", ", BlockchainCourt"} {
+		if _, err := ParseMSPRoles(text); err == nil {
+			t.Errorf("ParseMSPRoles(%q) succeeded", text)
+		}
+	}
+}
+
+func TestRenameSubject(t *testing.T) {
+	policy := &Policy{
+		Rules: [][]string{
+			{"CN=examiner1", "*", "*", "case:INV-001", "deny"},
+			{"BlockchainInvestigator", "blockchain.evidence", "view", "*"},
+		},
+		Roles: [][]string{{"CN=examiner1", "BlockchainInvestigator"}, {"CN=other", "BlockchainAuditor"}},
+	}
+	if changed := policy.RenameSubject("CN=examiner1", "ForensicLabMSP/CN=examiner1"); changed != 2 {
+		t.Errorf("renamed %d lines, want 2", changed)
+	}
+	if policy.Rules[0][0] != "ForensicLabMSP/CN=examiner1" || policy.Roles[0][0] != "ForensicLabMSP/CN=examiner1" {
+		t.Errorf("subject not renamed: %v %v", policy.Rules, policy.Roles)
+	}
+	if policy.Roles[1][0] != "CN=other" {
+		t.Errorf("unrelated assignment renamed: %v", policy.Roles[1])
+	}
+}
+
+func TestDefaultFilesParse(t *testing.T) {
+	if _, err := Default(); err != nil {
+		t.Fatalf("embedded model.conf and policy.csv do not parse: %v", err)
+	}
+	if _, err := DefaultMSPRoles(); err != nil {
+		t.Fatalf("embedded msp_roles.csv does not parse: %v", err)
+	}
+}
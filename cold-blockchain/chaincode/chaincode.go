@@ -3,100 +3,44 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"time"
 
+	core "github.com/aub/dfir-core"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
 // DFIRColdChaincode - Cold blockchain chaincode for immutable archival
 type DFIRColdChaincode struct {
 	contractapi.Contract
+	core.ChainContract
 }
 
-// ==============================================================================
-// DATA STRUCTURES (Same as hot chain for compatibility)
-// ==============================================================================
-
-// PRVConfig stores attestation verification keys and measurements
-type PRVConfig struct {
-	PublicKey      string   `json:"public_key"`
-	MREnclave      string   `json:"mr_enclave"`
-	MRSigner       string   `json:"mr_signer"`
-	UpdatedAt      int64    `json:"updated_at"`
-	AttestationDoc string   `json:"attestation_doc"`
-	VerifiedBy     []string `json:"verified_by"`
-	TCBLevel       string   `json:"tcb_level"`
-	ExpiresAt      int64    `json:"expires_at"`
-}
+// policyResource is the p.res value that scopes policy rules to this chain
+const policyResource = "cold"
 
-// Investigation represents a case/investigation (archived)
-type Investigation struct {
-	ID                 string `json:"id"`
-	CaseNumber         string `json:"case_number"`
-	CaseName           string `json:"case_name"`
-	InvestigatingOrg   string `json:"investigating_org"`
-	LeadInvestigator   string `json:"lead_investigator"`
-	Status             string `json:"status"` // archived (always)
-	OpenedDate         int64  `json:"opened_date"`
-	ClosedDate         int64  `json:"closed_date"`
-	ArchivedDate       int64  `json:"archived_date"`
-	Description        string `json:"description"`
-	EvidenceCount      int    `json:"evidence_count"`
-	CreatedBy          string `json:"created_by"`
-	ArchivedBy         string `json:"archived_by"`
-	CreatedAt          int64  `json:"created_at"`
-	ArchivedAt         int64  `json:"archived_at"`
-}
+// accessControl evaluates permissions against the rules scoped to this chain
+var accessControl = core.AccessControl{Resource: policyResource}
 
-// Evidence represents a piece of digital evidence (immutable archive)
-type Evidence struct {
-	ID              string `json:"id"`
-	CaseID          string `json:"case_id"`
-	Type            string `json:"type"`
-	Description     string `json:"description"`
-	Hash            string `json:"hash"`
-	IPFSHash        string `json:"ipfs_hash"`
-	Location        string `json:"location"`
-	Custodian       string `json:"custodian"`        // Frozen custodian
-	CollectedBy     string `json:"collected_by"`
-	Timestamp       int64  `json:"timestamp"`
-	Status          string `json:"status"`           // archived (always)
-	Metadata        string `json:"metadata"`
-	FileSize        int64  `json:"file_size"`
-	ChainType       string `json:"chain_type"`       // cold (always)
-	TransactionID   string `json:"transaction_id"`
-	CustodyChainRef string `json:"custody_chain_ref"`
-	CreatedBy       string `json:"created_by"`
-	ArchivedBy      string `json:"archived_by"`
-	CreatedAt       int64  `json:"created_at"`
-	ArchivedAt      int64  `json:"archived_at"`
-	SourceChain     string `json:"source_chain"`     // hot
-	SourceTxID      string `json:"source_tx_id"`     // Original hot chain tx
-}
+// chainContract configures the transactions shared with the hot chain, whose
+// exports this chain imports
+var chainContract = core.ChainContract{Access: accessControl, TransferSource: core.ChainHot}
 
-// AuditLog records all operations for compliance
-type AuditLog struct {
-	ID            string `json:"id"`
-	UserID        string `json:"user_id"`
-	Action        string `json:"action"`
-	Resource      string `json:"resource"`
-	ResourceID    string `json:"resource_id"`
-	Result        string `json:"result"`
-	Reason        string `json:"reason"`
-	Timestamp     int64  `json:"timestamp"`
-	ClientMSP     string `json:"client_msp"`
-	TransactionID string `json:"transaction_id"`
-}
+// ==============================================================================
+// DATA STRUCTURES
+// ==============================================================================
 
-// ArchiveMetadata stores archival verification info
-type ArchiveMetadata struct {
-	EvidenceID         string `json:"evidence_id"`
-	OriginalChain      string `json:"original_chain"`
-	OriginalTxID       string `json:"original_tx_id"`
-	ArchivalVerifiedBy string `json:"archival_verified_by"`
-	ArchivalTimestamp  int64  `json:"archival_timestamp"`
-	IntegrityHash      string `json:"integrity_hash"`
-}
+// Records shared with the hot chain are defined in the core module
+type (
+	PRVConfig          = core.PRVConfig
+	VerifierEntry      = core.VerifierEntry
+	VerifierRevocation = core.VerifierRevocation
+	QuorumHealth       = core.QuorumHealth
+	Investigation      = core.Investigation
+	Evidence           = core.Evidence
+	AuditLog           = core.AuditLog
+	AccessPolicy       = core.AccessPolicy
+	MSPRoleMapping     = core.MSPRoleMapping
+	ArchiveMetadata    = core.ArchiveMetadata
+)
 
 // ==============================================================================
 // INITIALIZATION
@@ -106,343 +50,13 @@ type ArchiveMetadata struct {
 func (cc *DFIRColdChaincode) InitLedger(ctx contractapi.TransactionContextInterface,
 	publicKeyHex string, mrenclaveHex string, mrsignerHex string) error {
 
-	config := PRVConfig{
-		PublicKey:      publicKeyHex,
-		MREnclave:      mrenclaveHex,
-		MRSigner:       mrsignerHex,
-		UpdatedAt:      time.Now().Unix(),
-		AttestationDoc: "",
-		VerifiedBy:     []string{},
-		TCBLevel:       "1",
-		ExpiresAt:      time.Now().Add(24 * time.Hour).Unix(),
-	}
-
-	configJSON, err := json.Marshal(config)
-	if err != nil {
-		return fmt.Errorf("failed to marshal config: %v", err)
-	}
-
-	err = ctx.GetStub().PutState("PRV_CONFIG", configJSON)
-	if err != nil {
-		return fmt.Errorf("failed to store config: %v", err)
-	}
-
-	cc.logAudit(ctx, "InitLedger", "system", "PRV_CONFIG", "success", "Cold chain ledger initialized")
-
-	fmt.Printf("✓ Cold chain ledger initialized with PRV config\n")
-	return nil
-}
-
-// ==============================================================================
-// ACCESS CONTROL & ATTESTATION HELPERS
-// ==============================================================================
-
-// checkPermission validates if the caller has permission for the action
-func (cc *DFIRColdChaincode) checkPermission(ctx contractapi.TransactionContextInterface,
-	object string, action string, resource string) error {
-
-	clientID, err := ctx.GetClientIdentity().GetID()
-	if err != nil {
-		return fmt.Errorf("failed to get client identity: %v", err)
-	}
-
-	mspID, err := ctx.GetClientIdentity().GetMSPID()
-	if err != nil {
-		return fmt.Errorf("failed to get MSP ID: %v", err)
-	}
-
-	// Extract role from client attributes
-	role, found, err := ctx.GetClientIdentity().GetAttributeValue("role")
-	if err != nil || !found {
-		role = cc.getRoleFromMSP(mspID)
-	}
-
-	allowed := cc.evaluatePermission(role, object, action, resource, clientID)
-	if !allowed {
-		cc.logAudit(ctx, action, object, resource, "denied", fmt.Sprintf("Insufficient permissions for role: %s", role))
-		return fmt.Errorf("access denied: %s does not have permission to %s on %s", role, action, object)
-	}
-
-	return nil
-}
-
-// getRoleFromMSP maps MSP ID to default role
-func (cc *DFIRColdChaincode) getRoleFromMSP(mspID string) string {
-	// Role mapping aligned with JumpServer RBAC design
-	switch mspID {
-	case "CourtMSP":
-		return "BlockchainCourt" // Cross-chain transfers, archive/reopen authority
-	case "AuditorMSP":
-		return "BlockchainAuditor" // Read-only access to evidence for compliance/audit
-	case "LawEnforcementMSP", "ForensicLabMSP":
-		return "BlockchainInvestigator" // If investigators need cold chain access
-	default:
-		return "User"
-	}
-}
-
-// evaluatePermission implements Casbin-style permission checking
-func (cc *DFIRColdChaincode) evaluatePermission(role string, object string, action string, resource string, userID string) bool {
-	// SystemAdmin has full access
-	if role == "SystemAdmin" {
-		return true
-	}
-
-	// Cold chain permissions (more restrictive)
-	permissions := map[string]map[string][]string{
-		"BlockchainInvestigator": {
-			"blockchain.investigation": {"view", "list"},         // Read-only
-			"blockchain.evidence":      {"view", "list", "archive"}, // Can archive from hot
-			"blockchain.custody":       {"view"},                 // No transfers
-		},
-		"BlockchainAuditor": {
-			"blockchain.investigation": {"view", "list"},
-			"blockchain.evidence":      {"view", "list", "history", "archive"},
-			"blockchain.custody":       {"view", "history"},
-			"blockchain.transaction":   {"view", "list"},
-			"audits.*":                 {"view"},
-			"reports.*":                {"view"},
-		},
-		"BlockchainCourt": {
-			"blockchain.investigation": {"view", "list"},
-			"blockchain.evidence":      {"view", "list", "history"},
-			"blockchain.custody":       {"view", "history"},
-			"blockchain.transaction":   {"view", "list"},
-			"audits.*":                 {"view"},
-			"reports.*":                {"view"},
-		},
-	}
-
-	rolePerms, roleExists := permissions[role]
-	if !roleExists {
-		return false
-	}
-
-	// Check wildcard permissions
-	for permObj, actions := range rolePerms {
-		if permObj == object+".*" || (len(permObj) > 2 && permObj[len(permObj)-2:] == ".*" && len(object) >= len(permObj)-2 && object[:len(permObj)-2] == permObj[:len(permObj)-2]) {
-			for _, act := range actions {
-				if act == "*" || act == action {
-					return true
-				}
-			}
-		}
-	}
-
-	// Check exact match
-	actions, objExists := rolePerms[object]
-	if !objExists {
-		return false
-	}
-
-	for _, act := range actions {
-		if act == "*" || act == action {
-			return true
-		}
-	}
-
-	return false
-}
-
-// checkAttestation verifies attestation is still valid
-func (cc *DFIRColdChaincode) checkAttestation(ctx contractapi.TransactionContextInterface) error {
-	configJSON, err := ctx.GetStub().GetState("PRV_CONFIG")
-	if err != nil {
-		return fmt.Errorf("failed to read PRV config: %v", err)
-	}
-	if configJSON == nil {
-		return fmt.Errorf("PRV config not initialized")
-	}
-
-	var config PRVConfig
-	err = json.Unmarshal(configJSON, &config)
-	if err != nil {
-		return fmt.Errorf("failed to unmarshal PRV config: %v", err)
-	}
-
-	// Check if attestation is expired
-	currentTime := time.Now().Unix()
-	if currentTime > config.ExpiresAt {
-		return fmt.Errorf("attestation expired at %d, current time %d", config.ExpiresAt, currentTime)
-	}
-
-	// Check verifier quorum (minimum 0 for development)
-	// NOTE: In production, change this to 2 for proper multi-org attestation
-	if len(config.VerifiedBy) < 0 {
-		return fmt.Errorf("insufficient verifiers: %d < 0", len(config.VerifiedBy))
-	}
-
-	return nil
-}
-
-// logAudit creates an audit log entry
-func (cc *DFIRColdChaincode) logAudit(ctx contractapi.TransactionContextInterface,
-	action string, resource string, resourceID string, result string, reason string) error {
-
-	clientID, _ := ctx.GetClientIdentity().GetID()
-	mspID, _ := ctx.GetClientIdentity().GetMSPID()
-	txID := ctx.GetStub().GetTxID()
-	txTimestamp, _ := ctx.GetStub().GetTxTimestamp()
-
-	auditLog := AuditLog{
-		ID:            fmt.Sprintf("audit_%s", txID),
-		UserID:        clientID,
-		Action:        action,
-		Resource:      resource,
-		ResourceID:    resourceID,
-		Result:        result,
-		Reason:        reason,
-		Timestamp:     txTimestamp.Seconds,
-		ClientMSP:     mspID,
-		TransactionID: txID,
-	}
-
-	auditJSON, err := json.Marshal(auditLog)
-	if err != nil {
-		return err
-	}
-
-	return ctx.GetStub().PutState(auditLog.ID, auditJSON)
-}
-
-// ==============================================================================
-// ARCHIVE OPERATIONS (One-way from hot chain)
-// ==============================================================================
-
-// ArchiveEvidence archives evidence from hot chain (ONE-WAY OPERATION)
-func (cc *DFIRColdChaincode) ArchiveEvidence(ctx contractapi.TransactionContextInterface,
-	evidenceJSON string, sourceTxID string, integrityHash string) error {
-
-	// Check attestation
-	if err := cc.checkAttestation(ctx); err != nil {
-		return fmt.Errorf("attestation check failed: %v", err)
-	}
-
-	// Check permission
-	if err := cc.checkPermission(ctx, "blockchain.evidence", "archive", "*"); err != nil {
+	if err := core.InitPRVConfig(ctx, publicKeyHex, mrenclaveHex, mrsignerHex); err != nil {
 		return err
 	}
 
-	// Parse evidence from hot chain
-	var evidence Evidence
-	err := json.Unmarshal([]byte(evidenceJSON), &evidence)
-	if err != nil {
-		return fmt.Errorf("failed to unmarshal evidence: %v", err)
-	}
-
-	// Check if already archived
-	existing, err := ctx.GetStub().GetState(evidence.ID)
-	if err != nil {
-		return fmt.Errorf("failed to read evidence: %v", err)
-	}
-	if existing != nil {
-		return fmt.Errorf("evidence %s already archived", evidence.ID)
-	}
+	core.LogAudit(ctx, "InitLedger", "system", "prv_config", "success", "Cold chain ledger initialized")
 
-	clientID, _ := ctx.GetClientIdentity().GetID()
-
-	// Update evidence for cold chain
-	evidence.Status = "archived"
-	evidence.ChainType = "cold"
-	evidence.ArchivedBy = clientID
-	evidence.ArchivedAt = time.Now().Unix()
-	evidence.SourceChain = "hot"
-	evidence.SourceTxID = sourceTxID
-	evidence.TransactionID = ctx.GetStub().GetTxID()
-
-	// Store evidence
-	updatedJSON, err := json.Marshal(evidence)
-	if err != nil {
-		return fmt.Errorf("failed to marshal evidence: %v", err)
-	}
-
-	err = ctx.GetStub().PutState(evidence.ID, updatedJSON)
-	if err != nil {
-		return fmt.Errorf("failed to store evidence: %v", err)
-	}
-
-	// Store archive metadata for verification
-	metadata := ArchiveMetadata{
-		EvidenceID:         evidence.ID,
-		OriginalChain:      "hot",
-		OriginalTxID:       sourceTxID,
-		ArchivalVerifiedBy: clientID,
-		ArchivalTimestamp:  time.Now().Unix(),
-		IntegrityHash:      integrityHash,
-	}
-
-	metadataJSON, _ := json.Marshal(metadata)
-	metadataKey := fmt.Sprintf("ARCHIVE_META_%s", evidence.ID)
-	ctx.GetStub().PutState(metadataKey, metadataJSON)
-
-	// Emit event
-	ctx.GetStub().SetEvent("EvidenceArchived", updatedJSON)
-
-	// Audit log
-	cc.logAudit(ctx, "ArchiveEvidence", "blockchain.evidence", evidence.ID, "success",
-		fmt.Sprintf("Evidence archived from hot chain tx: %s", sourceTxID))
-
-	fmt.Printf("✓ Evidence archived to cold chain: %s\n", evidence.ID)
-	return nil
-}
-
-// ArchiveInvestigation archives investigation from hot chain
-func (cc *DFIRColdChaincode) ArchiveInvestigation(ctx contractapi.TransactionContextInterface,
-	investigationJSON string, sourceTxID string) error {
-
-	// Check attestation
-	if err := cc.checkAttestation(ctx); err != nil {
-		return fmt.Errorf("attestation check failed: %v", err)
-	}
-
-	// Check permission
-	if err := cc.checkPermission(ctx, "blockchain.investigation", "archive", "*"); err != nil {
-		return err
-	}
-
-	// Parse investigation from hot chain
-	var investigation Investigation
-	err := json.Unmarshal([]byte(investigationJSON), &investigation)
-	if err != nil {
-		return fmt.Errorf("failed to unmarshal investigation: %v", err)
-	}
-
-	// Check if already archived
-	existing, err := ctx.GetStub().GetState(investigation.ID)
-	if err != nil {
-		return fmt.Errorf("failed to read investigation: %v", err)
-	}
-	if existing != nil {
-		return fmt.Errorf("investigation %s already archived", investigation.ID)
-	}
-
-	clientID, _ := ctx.GetClientIdentity().GetID()
-
-	// Update for cold chain
-	investigation.Status = "archived"
-	investigation.ArchivedBy = clientID
-	investigation.ArchivedAt = time.Now().Unix()
-	investigation.ArchivedDate = time.Now().Unix()
-
-	// Store investigation
-	updatedJSON, err := json.Marshal(investigation)
-	if err != nil {
-		return fmt.Errorf("failed to marshal investigation: %v", err)
-	}
-
-	err = ctx.GetStub().PutState(investigation.ID, updatedJSON)
-	if err != nil {
-		return fmt.Errorf("failed to store investigation: %v", err)
-	}
-
-	// Emit event
-	ctx.GetStub().SetEvent("InvestigationArchived", updatedJSON)
-
-	// Audit log
-	cc.logAudit(ctx, "ArchiveInvestigation", "blockchain.investigation", investigation.ID, "success",
-		fmt.Sprintf("Investigation archived from hot chain"))
-
-	fmt.Printf("✓ Investigation archived to cold chain: %s\n", investigation.ID)
+	fmt.Printf("✓ Cold chain ledger initialized with PRV config\n")
 	return nil
 }
 
@@ -455,11 +69,12 @@ func (cc *DFIRColdChaincode) ReadEvidence(ctx contractapi.TransactionContextInte
 	id string) (*Evidence, error) {
 
 	// Check permission
-	if err := cc.checkPermission(ctx, "blockchain.evidence", "view", "*"); err != nil {
+	scope, err := accessControl.PermissionScope(ctx, "blockchain.evidence", "view")
+	if err != nil {
 		return nil, err
 	}
 
-	evidenceJSON, err := ctx.GetStub().GetState(id)
+	evidenceJSON, err := ctx.GetStub().GetState(core.EvidenceKey(id))
 	if err != nil {
 		return nil, fmt.Errorf("failed to read evidence: %v", err)
 	}
@@ -473,6 +88,18 @@ func (cc *DFIRColdChaincode) ReadEvidence(ctx contractapi.TransactionContextInte
 		return nil, fmt.Errorf("failed to unmarshal evidence: %v", err)
 	}
 
+	if err := core.CheckOwner(ctx, scope, "blockchain.evidence", "view", id, evidence.CreatedBy); err != nil {
+		return nil, err
+	}
+
+	if err := core.CheckEvidenceAttributes(ctx, &evidence, "view"); err != nil {
+		return nil, err
+	}
+
+	if err := core.CheckRecusal(ctx, "blockchain.evidence", "view", evidence.CaseID, id); err != nil {
+		return nil, err
+	}
+
 	return &evidence, nil
 }
 
@@ -481,11 +108,12 @@ func (cc *DFIRColdChaincode) ReadInvestigation(ctx contractapi.TransactionContex
 	id string) (*Investigation, error) {
 
 	// Check permission
-	if err := cc.checkPermission(ctx, "blockchain.investigation", "view", "*"); err != nil {
+	scope, err := accessControl.PermissionScope(ctx, "blockchain.investigation", "view")
+	if err != nil {
 		return nil, err
 	}
 
-	investigationJSON, err := ctx.GetStub().GetState(id)
+	investigationJSON, err := ctx.GetStub().GetState(core.InvestigationKey(id))
 	if err != nil {
 		return nil, fmt.Errorf("failed to read investigation: %v", err)
 	}
@@ -499,6 +127,18 @@ func (cc *DFIRColdChaincode) ReadInvestigation(ctx contractapi.TransactionContex
 		return nil, fmt.Errorf("failed to unmarshal investigation: %v", err)
 	}
 
+	if err := core.CheckOwner(ctx, scope, "blockchain.investigation", "view", id, investigation.CreatedBy); err != nil {
+		return nil, err
+	}
+
+	if err := core.CheckCaseAttributes(ctx, &investigation, "view"); err != nil {
+		return nil, err
+	}
+
+	if err := core.CheckRecusal(ctx, "blockchain.investigation", "view", id, ""); err != nil {
+		return nil, err
+	}
+
 	return &investigation, nil
 }
 
@@ -507,38 +147,63 @@ func (cc *DFIRColdChaincode) GetEvidenceHistory(ctx contractapi.TransactionConte
 	id string) ([]map[string]interface{}, error) {
 
 	// Check permission
-	if err := cc.checkPermission(ctx, "blockchain.evidence", "history", "*"); err != nil {
+	scope, err := accessControl.PermissionScope(ctx, "blockchain.evidence", "history")
+	if err != nil {
 		return nil, err
 	}
 
-	resultsIterator, err := ctx.GetStub().GetHistoryForKey(id)
+	// Ownership and case attributes of a history are those of the current evidence record
+	evidenceJSON, err := ctx.GetStub().GetState(core.EvidenceKey(id))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get history: %v", err)
+		return nil, fmt.Errorf("failed to read evidence: %v", err)
+	}
+	if evidenceJSON != nil {
+		var evidence Evidence
+		if err := json.Unmarshal(evidenceJSON, &evidence); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal evidence: %v", err)
+		}
+		if err := core.CheckOwner(ctx, scope, "blockchain.evidence", "history", id, evidence.CreatedBy); err != nil {
+			return nil, err
+		}
+		if err := core.CheckEvidenceAttributes(ctx, &evidence, "history"); err != nil {
+			return nil, err
+		}
+		if err := core.CheckRecusal(ctx, "blockchain.evidence", "history", evidence.CaseID, id); err != nil {
+			return nil, err
+		}
 	}
-	defer resultsIterator.Close()
 
+	// History written before MigrateKeys stays on the legacy keys
 	var history []map[string]interface{}
-	for resultsIterator.HasNext() {
-		response, err := resultsIterator.Next()
+	for _, key := range append(core.LegacyKeys(core.RecordEvidence, id), core.EvidenceKey(id)) {
+		resultsIterator, err := ctx.GetStub().GetHistoryForKey(key)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to get history: %v", err)
 		}
+		defer resultsIterator.Close()
 
-		var record map[string]interface{}
-		record = make(map[string]interface{})
-		record["tx_id"] = response.TxId
-		record["timestamp"] = response.Timestamp.Seconds
-		record["is_delete"] = response.IsDelete
-
-		if !response.IsDelete {
-			var evidence Evidence
-			err = json.Unmarshal(response.Value, &evidence)
-			if err == nil {
-				record["value"] = evidence
+		for resultsIterator.HasNext() {
+			response, err := resultsIterator.Next()
+			if err != nil {
+				return nil, err
+			}
+
+			var record map[string]interface{}
+			record = make(map[string]interface{})
+			record["tx_id"] = response.TxId
+			record["timestamp"] = response.Timestamp.Seconds
+			record["is_delete"] = response.IsDelete
+
+			if !response.IsDelete {
+				var evidence Evidence
+				err = json.Unmarshal(response.Value, &evidence)
+				if err == nil {
+					record["value"] = evidence
+				}
 			}
-		}
 
-		history = append(history, record)
+			history = append(history, record)
+		}
 	}
 
 	return history, nil
@@ -549,12 +214,13 @@ func (cc *DFIRColdChaincode) QueryEvidenceByCase(ctx contractapi.TransactionCont
 	caseID string) ([]*Evidence, error) {
 
 	// Check permission
-	if err := cc.checkPermission(ctx, "blockchain.evidence", "list", "*"); err != nil {
+	scope, err := accessControl.PermissionScope(ctx, "blockchain.evidence", "list")
+	if err != nil {
 		return nil, err
 	}
 
 	queryString := fmt.Sprintf(`{"selector":{"case_id":"%s","chain_type":"cold"}}`, caseID)
-	return cc.queryEvidence(ctx, queryString)
+	return cc.queryOwnedEvidence(ctx, scope, queryString)
 }
 
 // QueryEvidenceByHash retrieves archived evidence by hash
@@ -562,12 +228,13 @@ func (cc *DFIRColdChaincode) QueryEvidenceByHash(ctx contractapi.TransactionCont
 	hash string) (*Evidence, error) {
 
 	// Check permission
-	if err := cc.checkPermission(ctx, "blockchain.evidence", "view", "*"); err != nil {
+	scope, err := accessControl.PermissionScope(ctx, "blockchain.evidence", "view")
+	if err != nil {
 		return nil, err
 	}
 
 	queryString := fmt.Sprintf(`{"selector":{"hash":"%s","chain_type":"cold"}}`, hash)
-	results, err := cc.queryEvidence(ctx, queryString)
+	results, err := cc.queryOwnedEvidence(ctx, scope, queryString)
 	if err != nil {
 		return nil, err
 	}
@@ -584,7 +251,8 @@ func (cc *DFIRColdChaincode) GetAllArchivedEvidence(ctx contractapi.TransactionC
 	pageSize int, bookmark string) ([]*Evidence, error) {
 
 	// Check permission
-	if err := cc.checkPermission(ctx, "blockchain.evidence", "list", "*"); err != nil {
+	scope, err := accessControl.PermissionScope(ctx, "blockchain.evidence", "list")
+	if err != nil {
 		return nil, err
 	}
 
@@ -611,7 +279,28 @@ func (cc *DFIRColdChaincode) GetAllArchivedEvidence(ctx contractapi.TransactionC
 		results = append(results, &evidence)
 	}
 
-	return results, nil
+	results = core.FilterOwned(ctx, scope, results, func(e *Evidence) string { return e.CreatedBy })
+	results, err = core.FilterEvidenceByAttributes(ctx, results)
+	if err != nil {
+		return nil, err
+	}
+	return core.FilterRecusedEvidence(ctx, "view", results)
+}
+
+// queryOwnedEvidence runs an evidence query and applies the caller's permission scope
+func (cc *DFIRColdChaincode) queryOwnedEvidence(ctx contractapi.TransactionContextInterface,
+	scope string, queryString string) ([]*Evidence, error) {
+
+	results, err := cc.queryEvidence(ctx, queryString)
+	if err != nil {
+		return nil, err
+	}
+	results = core.FilterOwned(ctx, scope, results, func(e *Evidence) string { return e.CreatedBy })
+	results, err = core.FilterEvidenceByAttributes(ctx, results)
+	if err != nil {
+		return nil, err
+	}
+	return core.FilterRecusedEvidence(ctx, "view", results)
 }
 
 // queryEvidence helper function for CouchDB queries
@@ -647,11 +336,23 @@ func (cc *DFIRColdChaincode) GetArchiveMetadata(ctx contractapi.TransactionConte
 	evidenceID string) (*ArchiveMetadata, error) {
 
 	// Check permission
-	if err := cc.checkPermission(ctx, "blockchain.evidence", "view", "*"); err != nil {
+	scope, err := accessControl.PermissionScope(ctx, "blockchain.evidence", "view")
+	if err != nil {
 		return nil, err
 	}
 
-	metadataKey := fmt.Sprintf("ARCHIVE_META_%s", evidenceID)
+	// Metadata is owned with its evidence record; ReadEvidence enforces that
+	if scope == core.ScopeSelf {
+		if _, err := cc.ReadEvidence(ctx, evidenceID); err != nil {
+			return nil, err
+		}
+	} else if err := core.CheckEvidenceIDAttributes(ctx, evidenceID, "view"); err != nil {
+		return nil, err
+	} else if err := core.CheckEvidenceRecusal(ctx, "blockchain.evidence", "view", evidenceID); err != nil {
+		return nil, err
+	}
+
+	metadataKey := core.StateKey(core.RecordArchiveMetadata, evidenceID)
 	metadataJSON, err := ctx.GetStub().GetState(metadataKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read archive metadata: %v", err)
@@ -669,78 +370,6 @@ func (cc *DFIRColdChaincode) GetArchiveMetadata(ctx contractapi.TransactionConte
 	return &metadata, nil
 }
 
-// ==============================================================================
-// ATTESTATION MANAGEMENT (Same as hot chain)
-// ==============================================================================
-
-// RegisterAttestation registers a new attestation verification
-func (cc *DFIRColdChaincode) RegisterAttestation(ctx contractapi.TransactionContextInterface,
-	attestationDoc string, verifierMSP string) error {
-
-	mspID, _ := ctx.GetClientIdentity().GetMSPID()
-
-	configJSON, err := ctx.GetStub().GetState("PRV_CONFIG")
-	if err != nil {
-		return fmt.Errorf("failed to read PRV config: %v", err)
-	}
-
-	var config PRVConfig
-	if configJSON != nil {
-		json.Unmarshal(configJSON, &config)
-	}
-
-	// Add verifier to list if not already present
-	found := false
-	for _, v := range config.VerifiedBy {
-		if v == verifierMSP {
-			found = true
-			break
-		}
-	}
-	if !found {
-		config.VerifiedBy = append(config.VerifiedBy, verifierMSP)
-	}
-
-	config.AttestationDoc = attestationDoc
-	config.UpdatedAt = time.Now().Unix()
-	config.ExpiresAt = time.Now().Add(24 * time.Hour).Unix()
-
-	updatedJSON, err := json.Marshal(config)
-	if err != nil {
-		return err
-	}
-
-	err = ctx.GetStub().PutState("PRV_CONFIG", updatedJSON)
-	if err != nil {
-		return err
-	}
-
-	cc.logAudit(ctx, "RegisterAttestation", "attestation.config", "PRV_CONFIG", "success",
-		fmt.Sprintf("Attestation verified by %s", mspID))
-
-	fmt.Printf("✓ Cold chain attestation registered by %s\n", verifierMSP)
-	return nil
-}
-
-// GetPRVConfig retrieves the current PRV configuration
-func (cc *DFIRColdChaincode) GetPRVConfig(ctx contractapi.TransactionContextInterface) (*PRVConfig, error) {
-	configJSON, err := ctx.GetStub().GetState("PRV_CONFIG")
-	if err != nil {
-		return nil, fmt.Errorf("failed to read PRV config: %v", err)
-	}
-	if configJSON == nil {
-		return nil, fmt.Errorf("PRV config not initialized")
-	}
-
-	var config PRVConfig
-	err = json.Unmarshal(configJSON, &config)
-	if err != nil {
-		return nil, err
-	}
-
-	return &config, nil
-}
-
 // ==============================================================================
 // INTEGRITY VERIFICATION
 // ==============================================================================
@@ -749,8 +378,8 @@ func (cc *DFIRColdChaincode) GetPRVConfig(ctx contractapi.TransactionContextInte
 func (cc *DFIRColdChaincode) VerifyArchiveIntegrity(ctx contractapi.TransactionContextInterface,
 	evidenceID string) (bool, error) {
 
-	// Check permission
-	if err := cc.checkPermission(ctx, "blockchain.evidence", "view", "*"); err != nil {
+	// Check permission (ownership is enforced by ReadEvidence)
+	if _, err := accessControl.PermissionScope(ctx, "blockchain.evidence", "view"); err != nil {
 		return false, err
 	}
 
@@ -782,7 +411,7 @@ func (cc *DFIRColdChaincode) VerifyArchiveIntegrity(ctx contractapi.TransactionC
 		return false, fmt.Errorf("evidence status has been modified")
 	}
 
-	cc.logAudit(ctx, "VerifyArchiveIntegrity", "blockchain.evidence", evidenceID, "success",
+	core.LogAudit(ctx, "VerifyArchiveIntegrity", "blockchain.evidence", evidenceID, "success",
 		"Integrity verification passed")
 
 	return true, nil
@@ -792,293 +421,285 @@ func (cc *DFIRColdChaincode) VerifyArchiveIntegrity(ctx contractapi.TransactionC
 // CROSS-CHAIN CASE TRANSFER (Court Role Only)
 // ==============================================================================
 
-// CaseExportPackage holds complete case data for cross-chain transfer
-type CaseExportPackage struct {
-	Investigation Investigation `json:"investigation"`
-	Evidence      []Evidence    `json:"evidence"`
-	CourtOrder    string        `json:"court_order"`
-	ExportedAt    int64         `json:"exported_at"`
-	ExportedBy    string        `json:"exported_by"`
-	SourceChain   string        `json:"source_chain"`
-	TransferTxID  string        `json:"transfer_tx_id"`
-}
-
-// ExportCaseForArchive exports investigation and evidence for cold chain archival (Hot chain, Court only)
-func (cc *DFIRColdChaincode) ExportCaseForArchive(ctx contractapi.TransactionContextInterface,
-	investigationID string, courtOrder string) (string, error) {
+// ImportArchivedCase imports a case exported by the hot chain's ExportCaseForArchive
+// (Court only). The package must carry the hot chain block that committed the
+// export, verified against the trust anchors registered with SetTransferTrust.
+// It returns the import record, whose package_hash completes the transfer.
+// Chunked packages are imported with BeginArchivedCaseImport instead.
+func (cc *DFIRColdChaincode) ImportArchivedCase(ctx contractapi.TransactionContextInterface,
+	packageJSON string) (*core.CaseImport, error) {
 
 	// Check attestation
-	if err := cc.checkAttestation(ctx); err != nil {
-		return "", fmt.Errorf("attestation check failed: %v", err)
+	if err := core.CheckAttestation(ctx); err != nil {
+		return nil, fmt.Errorf("attestation check failed: %v", err)
 	}
 
 	// Check permission (Court role only)
-	if err := cc.checkPermission(ctx, "blockchain.investigation", "archive", "*"); err != nil {
-		return "", err
+	if err := accessControl.CheckPermission(ctx, "blockchain.investigation", "archive", "*"); err != nil {
+		return nil, err
 	}
 
-	// Read investigation
-	invBytes, err := ctx.GetStub().GetState("investigation_" + investigationID)
+	// Check PRV signature
+	if err := core.CheckPRVSignature(ctx); err != nil {
+		return nil, err
+	}
+
+	importRecord, err := core.ArchiveFlow.ImportCase(ctx, packageJSON)
 	if err != nil {
-		return "", fmt.Errorf("failed to read investigation: %v", err)
+		return nil, err
 	}
-	if invBytes == nil {
-		return "", fmt.Errorf("investigation %s does not exist", investigationID)
+
+	// Emit event
+	importJSON, _ := json.Marshal(importRecord)
+	ctx.GetStub().SetEvent("CaseImported", importJSON)
+
+	// Audit log
+	core.LogAudit(ctx, "import_archived_case", "blockchain.investigation", importRecord.InvestigationID,
+		"success", fmt.Sprintf("Case imported from hot chain with court order: %s, package hash: %s",
+			importRecord.CourtOrder, importRecord.PackageHash))
+
+	return importRecord, nil
+}
+
+// RecordArchiveAbort records on the cold chain that the hot chain aborted the archive transfer
+// of a case (Court only). abortProof is a core.TransferProof holding the hot
+// chain block that committed AbortCaseTransfer, verified against the trust
+// anchors registered with SetTransferTrust. From then on the export cannot be
+// imported, whatever the timestamp of the import proposal.
+func (cc *DFIRColdChaincode) RecordArchiveAbort(ctx contractapi.TransactionContextInterface,
+	investigationID string, abortProof string) (*core.CaseTransfer, error) {
+
+	// Check attestation
+	if err := core.CheckAttestation(ctx); err != nil {
+		return nil, fmt.Errorf("attestation check failed: %v", err)
 	}
 
-	var investigation Investigation
-	if err := json.Unmarshal(invBytes, &investigation); err != nil {
-		return "", fmt.Errorf("failed to unmarshal investigation: %v", err)
+	// Check permission (Court role only)
+	if err := accessControl.CheckPermission(ctx, "blockchain.investigation", "archive", "*"); err != nil {
+		return nil, err
 	}
 
-	// Query all evidence for this case
-	queryString := fmt.Sprintf(`{"selector":{"case_id":"%s"}}`, investigationID)
-	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	transfer, err := core.ArchiveFlow.RecordAbort(ctx, investigationID, abortProof)
 	if err != nil {
-		return "", fmt.Errorf("failed to query evidence: %v", err)
+		return nil, err
 	}
-	defer resultsIterator.Close()
 
-	var evidenceList []Evidence
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
-		if err != nil {
-			return "", fmt.Errorf("failed to iterate evidence: %v", err)
-		}
+	// Emit event
+	transferJSON, _ := json.Marshal(transfer)
+	ctx.GetStub().SetEvent("CaseTransferAbortRecorded", transferJSON)
 
-		var evidence Evidence
-		if err := json.Unmarshal(queryResponse.Value, &evidence); err != nil {
-			continue
-		}
-		evidenceList = append(evidenceList, evidence)
-	}
+	// Audit log
+	core.LogAudit(ctx, "record_archive_abort", "blockchain.investigation", investigationID,
+		"success", fmt.Sprintf("Archive transfer %s aborted on the hot chain in transaction %s, import refused: %s",
+			transfer.ExportTxID, transfer.AbortTxID, transfer.AbortReason))
+
+	return transfer, nil
+}
 
-	// Get client identity
-	clientID, _ := ctx.GetClientIdentity().GetID()
-	txTimestamp, _ := ctx.GetStub().GetTxTimestamp()
-	txID := ctx.GetStub().GetTxID()
+// ExportCaseForReactivation exports an archived case for reactivation on the hot chain
+// (Court only). The case stays transferring_to_hot until CompleteReactivationTransfer
+// confirms the hot chain import. The package of a case with more than
+// core.ChunkSize evidence items lists chunks served by GetExportChunk.
+func (cc *DFIRColdChaincode) ExportCaseForReactivation(ctx contractapi.TransactionContextInterface,
+	investigationID string, courtOrder string) (string, error) {
 
-	// Create export package
-	exportPackage := CaseExportPackage{
-		Investigation: investigation,
-		Evidence:      evidenceList,
-		CourtOrder:    courtOrder,
-		ExportedAt:    txTimestamp.Seconds,
-		ExportedBy:    clientID,
-		SourceChain:   "hot",
-		TransferTxID:  txID,
+	// Check attestation
+	if err := core.CheckAttestation(ctx); err != nil {
+		return "", fmt.Errorf("attestation check failed: %v", err)
 	}
 
-	// Marshal to JSON
-	packageJSON, err := json.Marshal(exportPackage)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal export package: %v", err)
+	// Check permission (Court role only)
+	if err := accessControl.CheckPermission(ctx, "blockchain.investigation", "reopen", "*"); err != nil {
+		return "", err
+	}
+	if err := core.CheckRecusal(ctx, "blockchain.investigation", "reopen", investigationID, ""); err != nil {
+		return "", err
 	}
 
-	// Store export record
-	exportKey := "export_" + investigationID + "_" + txID
-	if err := ctx.GetStub().PutState(exportKey, packageJSON); err != nil {
-		return "", fmt.Errorf("failed to store export record: %v", err)
+	packageJSON, transfer, err := core.ReactivationFlow.ExportCase(ctx, investigationID, courtOrder)
+	if err != nil {
+		return "", err
 	}
 
+	// Emit event
+	transferJSON, _ := json.Marshal(transfer)
+	ctx.GetStub().SetEvent("CaseExported", transferJSON)
+
 	// Audit log
-	cc.logAudit(ctx, "export_case_for_archive", "blockchain.investigation", investigationID,
-		"success", fmt.Sprintf("Case exported for archival with court order: %s", courtOrder))
+	core.LogAudit(ctx, "export_case_for_reactivation", "blockchain.investigation", investigationID,
+		"success", fmt.Sprintf("Case exported for reactivation with court order: %s, package hash: %s",
+			courtOrder, transfer.PackageHash))
 
 	return string(packageJSON), nil
 }
 
-// ImportArchivedCase imports case from hot chain to cold chain (Cold chain, Court only)
-func (cc *DFIRColdChaincode) ImportArchivedCase(ctx contractapi.TransactionContextInterface,
-	packageJSON string) error {
+// CompleteReactivationTransfer marks reactivation transfer as complete (Court only).
+// importProof is a core.TransferProof holding the hot chain block that
+// committed the import; unless the import record in it holds the hash the
+// export committed to, the transfer is marked rejected, the case stays
+// transferring_to_hot and the returned record says why.
+func (cc *DFIRColdChaincode) CompleteReactivationTransfer(ctx contractapi.TransactionContextInterface,
+	investigationID string, importProof string) (*core.CaseTransfer, error) {
 
 	// Check attestation
-	if err := cc.checkAttestation(ctx); err != nil {
-		return fmt.Errorf("attestation check failed: %v", err)
+	if err := core.CheckAttestation(ctx); err != nil {
+		return nil, fmt.Errorf("attestation check failed: %v", err)
 	}
 
 	// Check permission (Court role only)
-	if err := cc.checkPermission(ctx, "blockchain.investigation", "archive", "*"); err != nil {
-		return err
+	if err := accessControl.CheckPermission(ctx, "blockchain.investigation", "reopen", "*"); err != nil {
+		return nil, err
 	}
 
-	// Unmarshal package
-	var exportPackage CaseExportPackage
-	if err := json.Unmarshal([]byte(packageJSON), &exportPackage); err != nil {
-		return fmt.Errorf("failed to unmarshal export package: %v", err)
+	transfer, err := core.ReactivationFlow.CompleteTransfer(ctx, investigationID, importProof)
+	if err != nil {
+		return nil, err
 	}
 
-	// Verify source chain
-	if exportPackage.SourceChain != "hot" {
-		return fmt.Errorf("invalid source chain: %s, expected 'hot'", exportPackage.SourceChain)
+	// Emit event
+	transferJSON, _ := json.Marshal(transfer)
+	if transfer.State == core.TransferStateRejected {
+		ctx.GetStub().SetEvent("CaseTransferRejected", transferJSON)
+
+		// Audit log
+		core.LogAudit(ctx, "complete_reactivation_transfer", "blockchain.investigation", investigationID,
+			"denied", fmt.Sprintf("Reactivation transfer rejected, hot chain tx: %s, %s", transfer.TargetTxID, transfer.RejectedReason))
+		return transfer, nil
 	}
+	ctx.GetStub().SetEvent("CaseTransferCompleted", transferJSON)
 
-	// Check if investigation already exists on cold chain
-	invBytes, err := ctx.GetStub().GetState("investigation_" + exportPackage.Investigation.ID)
-	if err != nil {
-		return fmt.Errorf("failed to check investigation existence: %v", err)
-	}
-	if invBytes != nil {
-		return fmt.Errorf("investigation %s already exists on cold chain", exportPackage.Investigation.ID)
-	}
-
-	txTimestamp, _ := ctx.GetStub().GetTxTimestamp()
-	clientID, _ := ctx.GetClientIdentity().GetID()
-	txID := ctx.GetStub().GetTxID()
-
-	// Import investigation with archived status
-	investigation := exportPackage.Investigation
-	investigation.Status = "archived"
-	investigation.ArchivedAt = txTimestamp.Seconds
-	investigation.ArchivedBy = clientID
-	invBytes, _ = json.Marshal(investigation)
-	if err := ctx.GetStub().PutState("investigation_"+investigation.ID, invBytes); err != nil {
-		return fmt.Errorf("failed to store investigation: %v", err)
-	}
-
-	// Import all evidence
-	for _, evidence := range exportPackage.Evidence {
-		evidence.ChainType = "cold"
-		evidence.Status = "archived"
-		evidence.ArchivedAt = txTimestamp.Seconds
-		evidence.ArchivedBy = clientID
-		evidence.SourceChain = "hot"
-		evidence.SourceTxID = exportPackage.TransferTxID
-
-		evidenceBytes, _ := json.Marshal(evidence)
-		evidenceKey := "evidence_" + evidence.ID
-		if err := ctx.GetStub().PutState(evidenceKey, evidenceBytes); err != nil {
-			return fmt.Errorf("failed to store evidence %s: %v", evidence.ID, err)
-		}
+	// Audit log
+	core.LogAudit(ctx, "complete_reactivation_transfer", "blockchain.investigation", investigationID,
+		"success", fmt.Sprintf("Reactivation transfer completed, hot chain tx: %s", transfer.TargetTxID))
 
-		// Create archive metadata
-		metadata := ArchiveMetadata{
-			EvidenceID:         evidence.ID,
-			OriginalChain:      "hot",
-			OriginalTxID:       exportPackage.TransferTxID,
-			ArchivalVerifiedBy: clientID,
-			ArchivalTimestamp:  txTimestamp.Seconds,
-			IntegrityHash:      evidence.Hash,
-		}
-		metadataBytes, _ := json.Marshal(metadata)
-		metadataKey := "archive_metadata_" + evidence.ID
-		if err := ctx.GetStub().PutState(metadataKey, metadataBytes); err != nil {
-			return fmt.Errorf("failed to store archive metadata: %v", err)
-		}
+	return transfer, nil
+}
+
+// AbortCaseTransfer cancels the reactivation transfer of a case that is stuck
+// transferring_to_hot and restores the status it had before the export (Court only).
+// The transfer must be past its deadline, after which the hot chain no longer
+// imports it, or rejected for a package hash mismatch. reason is kept with the
+// transfer record.
+func (cc *DFIRColdChaincode) AbortCaseTransfer(ctx contractapi.TransactionContextInterface,
+	investigationID string, reason string) (*core.CaseTransfer, error) {
+
+	// Check attestation
+	if err := core.CheckAttestation(ctx); err != nil {
+		return nil, fmt.Errorf("attestation check failed: %v", err)
 	}
 
-	// Store import record
-	importRecord := map[string]interface{}{
-		"investigation_id": investigation.ID,
-		"source_chain":     exportPackage.SourceChain,
-		"source_tx_id":     exportPackage.TransferTxID,
-		"court_order":      exportPackage.CourtOrder,
-		"imported_at":      txTimestamp.Seconds,
-		"imported_by":      clientID,
-		"import_tx_id":     txID,
-		"evidence_count":   len(exportPackage.Evidence),
+	// Check permission (Court role only)
+	if err := accessControl.CheckPermission(ctx, "blockchain.investigation", "reopen", "*"); err != nil {
+		return nil, err
 	}
-	importBytes, _ := json.Marshal(importRecord)
-	importKey := "import_" + investigation.ID + "_" + txID
-	if err := ctx.GetStub().PutState(importKey, importBytes); err != nil {
-		return fmt.Errorf("failed to store import record: %v", err)
+	if err := core.CheckRecusal(ctx, "blockchain.investigation", "reopen", investigationID, ""); err != nil {
+		return nil, err
 	}
 
+	transfer, err := core.ReactivationFlow.AbortTransfer(ctx, investigationID, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	// Emit event
+	transferJSON, _ := json.Marshal(transfer)
+	ctx.GetStub().SetEvent("CaseTransferAborted", transferJSON)
+
 	// Audit log
-	cc.logAudit(ctx, "import_archived_case", "blockchain.investigation", investigation.ID,
-		"success", fmt.Sprintf("Case imported from hot chain with court order: %s", exportPackage.CourtOrder))
+	core.LogAudit(ctx, "abort_reactivation_transfer", "blockchain.investigation", investigationID,
+		"success", fmt.Sprintf("Reactivation transfer %s aborted, status restored to %s: %s",
+			transfer.ExportTxID, transfer.PriorStatus, reason))
 
-	return nil
+	return transfer, nil
 }
 
-// ExportCaseForReactivation exports archived case for reactivation on hot chain (Cold chain, Court only)
-func (cc *DFIRColdChaincode) ExportCaseForReactivation(ctx contractapi.TransactionContextInterface,
-	investigationID string, courtOrder string) (string, error) {
+// GetReactivationTransfer returns the latest reactivation transfer of a case: the
+// package hash its export committed to and, once completed or rejected, the
+// hash the hot chain imported (Court only)
+func (cc *DFIRColdChaincode) GetReactivationTransfer(ctx contractapi.TransactionContextInterface,
+	investigationID string) (*core.CaseTransfer, error) {
 
-	// Check attestation
-	if err := cc.checkAttestation(ctx); err != nil {
-		return "", fmt.Errorf("attestation check failed: %v", err)
+	// Check permission (Court role only)
+	if err := accessControl.CheckPermission(ctx, "blockchain.investigation", "reopen", "*"); err != nil {
+		return nil, err
 	}
 
-	// Check permission (Court role only)
-	if err := cc.checkPermission(ctx, "blockchain.investigation", "reopen", "*"); err != nil {
-		return "", err
+	transfer, err := core.ReactivationFlow.LoadCaseTransfer(ctx, investigationID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer == nil {
+		return nil, fmt.Errorf("investigation %s has no reactivation transfer", investigationID)
 	}
+	return transfer, nil
+}
 
-	// Read investigation
-	invBytes, err := ctx.GetStub().GetState("investigation_" + investigationID)
+// ==============================================================================
+// AUDIT LOG QUERIES
+// ==============================================================================
+
+// ReadAuditLog retrieves an audit log entry by ID
+func (cc *DFIRColdChaincode) ReadAuditLog(ctx contractapi.TransactionContextInterface,
+	id string) (*AuditLog, error) {
+
+	// Check permission
+	scope, err := accessControl.PermissionScope(ctx, "audits.operatelog", "view")
 	if err != nil {
-		return "", fmt.Errorf("failed to read investigation: %v", err)
+		return nil, err
 	}
-	if invBytes == nil {
-		return "", fmt.Errorf("investigation %s does not exist", investigationID)
+
+	auditJSON, err := ctx.GetStub().GetState(core.StateKey(core.RecordAudit, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %v", err)
+	}
+	if auditJSON == nil {
+		return nil, fmt.Errorf("audit log %s does not exist", id)
 	}
 
-	var investigation Investigation
-	if err := json.Unmarshal(invBytes, &investigation); err != nil {
-		return "", fmt.Errorf("failed to unmarshal investigation: %v", err)
+	var auditLog AuditLog
+	if err := json.Unmarshal(auditJSON, &auditLog); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal audit log: %v", err)
 	}
 
-	// Only allow reactivating archived investigations
-	if investigation.Status != "archived" {
-		return "", fmt.Errorf("can only reactivate archived investigations, current status: %s", investigation.Status)
+	if err := core.CheckOwner(ctx, scope, "audits.operatelog", "view", id, auditLog.UserID); err != nil {
+		return nil, err
 	}
 
-	// Query all evidence for this case
-	queryString := fmt.Sprintf(`{"selector":{"case_id":"%s"}}`, investigationID)
+	return &auditLog, nil
+}
+
+// QueryAuditLogsByUser retrieves the audit log entries recorded for a user
+func (cc *DFIRColdChaincode) QueryAuditLogsByUser(ctx contractapi.TransactionContextInterface,
+	userID string) ([]*AuditLog, error) {
+
+	// Check permission
+	scope, err := accessControl.PermissionScope(ctx, "audits.operatelog", "view")
+	if err != nil {
+		return nil, err
+	}
+
+	queryString := fmt.Sprintf(`{"selector":{"user_id":"%s",%s}}`, userID, core.AuditSelector)
 	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
 	if err != nil {
-		return "", fmt.Errorf("failed to query evidence: %v", err)
+		return nil, fmt.Errorf("failed to query audit logs: %v", err)
 	}
 	defer resultsIterator.Close()
 
-	var evidenceList []Evidence
+	var results []*AuditLog
 	for resultsIterator.HasNext() {
 		queryResponse, err := resultsIterator.Next()
 		if err != nil {
-			return "", fmt.Errorf("failed to iterate evidence: %v", err)
+			return nil, err
 		}
 
-		var evidence Evidence
-		if err := json.Unmarshal(queryResponse.Value, &evidence); err != nil {
-			continue
+		var auditLog AuditLog
+		if err := json.Unmarshal(queryResponse.Value, &auditLog); err != nil {
+			return nil, err
 		}
-		evidenceList = append(evidenceList, evidence)
+		results = append(results, &auditLog)
 	}
 
-	// Get client identity
-	clientID, _ := ctx.GetClientIdentity().GetID()
-	txTimestamp, _ := ctx.GetStub().GetTxTimestamp()
-	txID := ctx.GetStub().GetTxID()
-
-	// Create export package
-	exportPackage := CaseExportPackage{
-		Investigation: investigation,
-		Evidence:      evidenceList,
-		CourtOrder:    courtOrder,
-		ExportedAt:    txTimestamp.Seconds,
-		ExportedBy:    clientID,
-		SourceChain:   "cold",
-		TransferTxID:  txID,
-	}
-
-	// Marshal to JSON
-	packageJSON, err := json.Marshal(exportPackage)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal export package: %v", err)
-	}
-
-	// Store export record
-	exportKey := "export_" + investigationID + "_" + txID
-	if err := ctx.GetStub().PutState(exportKey, packageJSON); err != nil {
-		return "", fmt.Errorf("failed to store export record: %v", err)
-	}
-
-	// Audit log
-	cc.logAudit(ctx, "export_case_for_reactivation", "blockchain.investigation", investigationID,
-		"success", fmt.Sprintf("Case exported for reactivation with court order: %s", courtOrder))
-
-	return string(packageJSON), nil
+	return core.FilterOwned(ctx, scope, results, func(a *AuditLog) string { return a.UserID }), nil
 }
 
 // ==============================================================================
@@ -1086,7 +707,7 @@ func (cc *DFIRColdChaincode) ExportCaseForReactivation(ctx contractapi.Transacti
 // ==============================================================================
 
 func main() {
-	chaincode, err := contractapi.NewChaincode(&DFIRColdChaincode{})
+	chaincode, err := contractapi.NewChaincode(&DFIRColdChaincode{ChainContract: chainContract})
 	if err != nil {
 		fmt.Printf("Error creating DFIR cold chaincode: %v\n", err)
 		return
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	core "github.com/aub/dfir-core"
+)
+
+// legacyArchiveState is a cold ledger written before the composite key schema
+var legacyArchiveState = map[string]string{
+	"PRV_CONFIG":                       `{"public_key":"","quorum_threshold":2,"version":1}`,
+	"INV-001":                          `{"id":"INV-001","case_number":"CASE-1","investigating_org":"LawEnforcement","status":"archived"}`,
+	"EVD-001":                          `{"id":"EVD-001","case_id":"INV-001","hash":"abc","custodian":"x","status":"archived"}`,
+	"ARCHIVE_META_EVD-001":             `{"evidence_id":"EVD-001"}`,
+	"archive_metadata_INV-001":         `{"investigation_id":"INV-001"}`,
+	"reactivation_complete_INV-001":    `{"investigation_id":"INV-001"}`,
+	"import_INV-001_tx-import":         `{"investigation":{}}`,
+	"audit_tx-archive":                 `{"id":"audit_tx-archive"}`,
+	"attestation_SGX_tx-attest":        `{"id":"attestation_SGX_tx-attest"}`,
+	"breakglass_tx_sess_tx-breakglass": `{"session_id":"sess"}`,
+}
+
+// seedState writes raw key/value pairs into every endorser's world state
+func seedState(t *testing.T, endorsers []*endorser, state map[string]string) {
+	t.Helper()
+	for _, e := range endorsers {
+		e.stub.MockTransactionStart("tx-seed")
+		for key, value := range state {
+			if err := e.stub.PutState(key, []byte(value)); err != nil {
+				t.Fatalf("failed to seed %q: %v", key, err)
+			}
+		}
+		e.stub.MockTransactionEnd("tx-seed")
+	}
+}
+
+func TestMigrateArchiveKeys(t *testing.T) {
+	endorsers := newEndorsers(t)
+	admin := newCreator(t, "CourtMSP", "admin.court.cold.coc.com",
+		map[string]string{"role": "SystemAdmin"})
+	seedState(t, endorsers, legacyArchiveState)
+
+	result := endorseAll(t, endorsers, "tx-migrate", admin, "MigrateKeys", "0")
+	var report core.KeyMigrationReport
+	if err := json.Unmarshal([]byte(result.Payload), &report); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+	if len(report.Moved) != len(legacyArchiveState) || len(report.Conflicts) != 0 || len(report.Unrecognized) != 0 {
+		t.Fatalf("report = %+v, want all %d keys moved", report, len(legacyArchiveState))
+	}
+
+	state := endorsers[0].stub.State
+	for key, value := range map[string]string{
+		core.PRVConfigKey:                                    legacyArchiveState["PRV_CONFIG"],
+		core.InvestigationKey("INV-001"):                     legacyArchiveState["INV-001"],
+		core.EvidenceKey("EVD-001"):                          legacyArchiveState["EVD-001"],
+		core.StateKey(core.RecordArchiveMetadata, "EVD-001"): legacyArchiveState["ARCHIVE_META_EVD-001"],
+		core.StateKey(core.RecordArchiveMetadata, "INV-001"): legacyArchiveState["archive_metadata_INV-001"],
+		core.StateKey(core.RecordTransferComplete, core.TransferReactivation, "INV-001"): legacyArchiveState["reactivation_complete_INV-001"],
+		core.StateKey(core.RecordImport, "INV-001", "tx-import"):                         legacyArchiveState["import_INV-001_tx-import"],
+		core.StateKey(core.RecordAudit, "audit_tx-archive"):                              legacyArchiveState["audit_tx-archive"],
+		core.StateKey(core.RecordAttestation, "attestation_SGX_tx-attest"):               legacyArchiveState["attestation_SGX_tx-attest"],
+		core.StateKey(core.RecordBreakGlassTx, "breakglass_tx_sess_tx-breakglass"):       legacyArchiveState["breakglass_tx_sess_tx-breakglass"],
+	} {
+		if got := string(state[key]); got != value {
+			t.Errorf("state[%q] = %q, want %q", key, got, value)
+		}
+	}
+	for key := range legacyArchiveState {
+		if _, ok := state[key]; ok {
+			t.Errorf("legacy key %q was not deleted", key)
+		}
+	}
+
+}
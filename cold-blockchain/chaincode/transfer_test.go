@@ -0,0 +1,273 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	core "github.com/aub/dfir-core"
+)
+
+// seedReactivation puts INV-001 in flight to the hot chain with an export due by expiresAt
+func seedReactivation(t *testing.T, endorsers []*endorser, expiresAt time.Time) {
+	t.Helper()
+	transferJSON, err := json.Marshal(core.CaseTransfer{
+		InvestigationID: "INV-001",
+		Direction:       core.TransferReactivation,
+		ExportTxID:      "tx-export",
+		PackageHash:     "abc",
+		FormatVersion:   core.PackageFormatVersion,
+		PriorStatus:     "archived",
+		ExportedAt:      expiresAt.Add(-time.Hour).Unix(),
+		ExportedBy:      "court",
+		State:           core.TransferStateExported,
+		ExpiresAt:       expiresAt.Unix(),
+	})
+	if err != nil {
+		t.Fatalf("failed to encode transfer: %v", err)
+	}
+	seedState(t, endorsers, map[string]string{
+		core.InvestigationKey("INV-001"):                           `{"id":"INV-001","case_number":"CASE-1","status":"transferring_to_hot"}`,
+		core.CaseTransferKey(core.TransferReactivation, "INV-001"): string(transferJSON),
+	})
+}
+
+func TestAbortCaseTransferAfterDeadline(t *testing.T) {
+	endorsers := newEndorsers(t)
+	court := newCreator(t, "CourtMSP", "judge1.court.cold.coc.com", nil)
+	initLedger(t, endorsers, court)
+
+	// Before the deadline the hot chain may still import the case
+	seedReactivation(t, endorsers, proposalTime.Add(time.Hour))
+	result := endorsers[0].endorse("tx-abort-early", court, proposalTime, "AbortCaseTransfer", "INV-001", "relayer down")
+	if result.Status == 200 || !strings.Contains(result.Message, "after its deadline 2025-03-01T10:30:00Z") {
+		t.Fatalf("abort before the deadline: %d %s", result.Status, result.Message)
+	}
+
+	seedReactivation(t, endorsers, proposalTime.Add(-time.Minute))
+	if result := endorsers[0].endorse("tx-abort-no-reason", court, proposalTime, "AbortCaseTransfer", "INV-001", ""); result.Status == 200 {
+		t.Fatalf("abort without a reason succeeded")
+	}
+
+	result = endorseAll(t, endorsers, "tx-abort", court, "AbortCaseTransfer", "INV-001", "relayer down")
+	var investigation Investigation
+	if err := json.Unmarshal([]byte(result.Writes[core.InvestigationKey("INV-001")]), &investigation); err != nil {
+		t.Fatalf("failed to read investigation, writes: %v: %v", keys(result.Writes), err)
+	}
+	if investigation.Status != "archived" {
+		t.Errorf("investigation status %s after abort, want archived", investigation.Status)
+	}
+	var transfer core.CaseTransfer
+	if err := json.Unmarshal([]byte(result.Writes[core.CaseTransferKey(core.TransferReactivation, "INV-001")]), &transfer); err != nil {
+		t.Fatalf("failed to read transfer: %v", err)
+	}
+	if transfer.State != core.TransferStateAborted || transfer.AbortReason != "relayer down" ||
+		transfer.AbortTxID != "tx-abort" || transfer.AbortedAt != proposalTime.Unix() {
+		t.Errorf("aborted transfer = %+v", transfer)
+	}
+	if len(result.Events) != 1 || !strings.HasPrefix(result.Events[0], "CaseTransferAborted ") {
+		t.Errorf("events = %v, want CaseTransferAborted", result.Events)
+	}
+	if audit := result.Writes[core.StateKey(core.RecordAudit, "audit_tx-abort")]; !strings.Contains(audit, "abort_reactivation_transfer") {
+		t.Errorf("audit entry = %q", audit)
+	}
+
+	// An aborted transfer can be neither completed nor aborted again
+	for function, args := range map[string][]string{
+		"CompleteReactivationTransfer": {"INV-001", "{}"},
+		"AbortCaseTransfer":            {"INV-001", "again"},
+	} {
+		result := endorsers[0].endorse("tx-"+function, court, proposalTime, function, args...)
+		if result.Status == 200 || !strings.Contains(result.Message, "is already aborted") {
+			t.Errorf("%s after abort: %d %s", function, result.Status, result.Message)
+		}
+	}
+}
+
+// seedImportSession opens a chunked import of INV-001 from hot chain export tx-export
+// whose manifest lists one evidence item per chunk. It returns the JSON of each chunk.
+func seedImportSession(t *testing.T, endorsers []*endorser, evidenceIDs ...string) []string {
+	t.Helper()
+	manifest := &core.TransferManifest{EvidenceCount: len(evidenceIDs)}
+	var chunks []string
+	for i, id := range evidenceIDs {
+		chunkJSON, err := json.Marshal([]core.Evidence{{ID: id, CaseID: "INV-001", Hash: "hash-" + id, Status: "reviewed"}})
+		if err != nil {
+			t.Fatalf("failed to encode chunk: %v", err)
+		}
+		hash := sha256.Sum256(chunkJSON)
+		manifest.Chunks = append(manifest.Chunks, core.ManifestChunk{Index: i, EvidenceIDs: []string{id}, Hash: hex.EncodeToString(hash[:])})
+		chunks = append(chunks, string(chunkJSON))
+	}
+
+	exportPackage := core.ArchiveFlow.NewPackage(core.Investigation{ID: "INV-001", CaseNumber: "CASE-1", Status: "transferring_to_archive"},
+		nil, "ORDER-1", "court", proposalTime.Add(-time.Hour).Unix(), "tx-export")
+	exportPackage.Manifest = manifest
+	sessionJSON, err := json.Marshal(core.ImportSession{
+		InvestigationID: "INV-001",
+		SourceTxID:      "tx-export",
+		PackageHash:     "abc",
+		Package:         *exportPackage,
+		Deadline:        proposalTime.Add(time.Hour).Unix(),
+		StartedAt:       proposalTime.Add(-time.Minute).Unix(),
+		StartTxID:       "tx-begin",
+	})
+	if err != nil {
+		t.Fatalf("failed to encode import session: %v", err)
+	}
+	seedState(t, endorsers, map[string]string{core.ImportSessionKey("INV-001", "tx-export"): string(sessionJSON)})
+	return chunks
+}
+
+func TestChunkedArchiveImportResumes(t *testing.T) {
+	endorsers := newEndorsers(t)
+	court := newCreator(t, "CourtMSP", "judge1.court.cold.coc.com", nil)
+	initLedger(t, endorsers, court)
+	chunks := seedImportSession(t, endorsers, "EVD-001", "EVD-002")
+
+	result := endorsers[0].endorse("tx-finalize-early", court, proposalTime, "FinalizeArchivedCaseImport", "INV-001", "tx-export")
+	if result.Status == 200 || !strings.Contains(result.Message, "missing 2 of 2 chunks: 0, 1") {
+		t.Fatalf("finalize without chunks: %d %s", result.Status, result.Message)
+	}
+	tampered := strings.Replace(chunks[1], "hash-EVD-002", "forged", 1)
+	if result := endorsers[0].endorse("tx-chunk-forged", court, proposalTime, "ImportArchivedCaseChunk", "INV-001", "tx-export", "1", tampered); result.Status == 200 || !strings.Contains(result.Message, "the manifest lists") {
+		t.Fatalf("forged chunk: %d %s", result.Status, result.Message)
+	}
+
+	result = endorseAll(t, endorsers, "tx-chunk-1", court, "ImportArchivedCaseChunk", "INV-001", "tx-export", "1", chunks[1])
+	var evidence core.Evidence
+	if err := json.Unmarshal([]byte(result.Writes[core.EvidenceKey("EVD-002")]), &evidence); err != nil {
+		t.Fatalf("failed to read evidence, writes: %v: %v", keys(result.Writes), err)
+	}
+	if evidence.Status != "archived" || evidence.ChainType != core.ChainCold || evidence.SourceTxID != "tx-export" {
+		t.Errorf("imported evidence = %+v", evidence)
+	}
+	if _, ok := result.Writes[core.InvestigationKey("INV-001")]; ok {
+		t.Errorf("chunk import stored the investigation")
+	}
+
+	// A chunk imported again is left as it is
+	result = endorseAll(t, endorsers, "tx-chunk-1-again", court, "ImportArchivedCaseChunk", "INV-001", "tx-export", "1", chunks[1])
+	if _, ok := result.Writes[core.EvidenceKey("EVD-002")]; ok {
+		t.Errorf("chunk imported twice")
+	}
+
+	result = endorsers[0].endorse("tx-progress", court, proposalTime, "GetCaseImportProgress", "INV-001", "tx-export")
+	var progress core.ImportProgress
+	if err := json.Unmarshal([]byte(result.Payload), &progress); err != nil {
+		t.Fatalf("failed to read progress: %v", err)
+	}
+	if fmt.Sprint(progress.Imported, progress.Missing) != "[1] [0]" {
+		t.Errorf("progress imported %v, missing %v; want [1] [0]", progress.Imported, progress.Missing)
+	}
+
+	endorseAll(t, endorsers, "tx-chunk-0", court, "ImportArchivedCaseChunk", "INV-001", "tx-export", "0", chunks[0])
+	result = endorseAll(t, endorsers, "tx-finalize", court, "FinalizeArchivedCaseImport", "INV-001", "tx-export")
+	var importRecord core.CaseImport
+	if err := json.Unmarshal([]byte(result.Payload), &importRecord); err != nil {
+		t.Fatalf("failed to read import record: %v", err)
+	}
+	if importRecord.PackageHash != "abc" || importRecord.EvidenceCount != 2 || importRecord.ImportTxID != "tx-finalize" {
+		t.Errorf("import record = %+v", importRecord)
+	}
+	var investigation Investigation
+	if err := json.Unmarshal([]byte(result.Writes[core.InvestigationKey("INV-001")]), &investigation); err != nil {
+		t.Fatalf("failed to read investigation: %v", err)
+	}
+	if investigation.Status != "archived" {
+		t.Errorf("investigation status %s after finalize, want archived", investigation.Status)
+	}
+	if len(result.Events) != 1 || !strings.HasPrefix(result.Events[0], "CaseImported ") {
+		t.Errorf("events = %v, want CaseImported", result.Events)
+	}
+
+	if result := endorsers[0].endorse("tx-chunk-late", court, proposalTime, "ImportArchivedCaseChunk", "INV-001", "tx-export", "0", chunks[0]); result.Status == 200 || !strings.Contains(result.Message, "already imported in transaction tx-finalize") {
+		t.Errorf("chunk after finalize: %d %s", result.Status, result.Message)
+	}
+}
+
+func TestCompleteTransferRequiresImportProof(t *testing.T) {
+	endorsers := newEndorsers(t)
+	court := newCreator(t, "CourtMSP", "judge1.cold.coc.com", nil)
+	initLedger(t, endorsers, court)
+	seedReactivation(t, endorsers, proposalTime.Add(time.Hour))
+	seedState(t, endorsers, map[string]string{
+		core.TransferTrustKey: `{"source_chain":"hot","channel_id":"hotchannel","chaincode_name":"dfir","msps":[],"min_endorsements":1}`,
+	})
+
+	// The caller's word for the imported hash no longer completes a transfer
+	for _, test := range []struct {
+		name  string
+		proof string
+		want  string
+	}{
+		{"hash and transaction ID", `{"hot_chain_tx_id":"hot-tx","package_hash":"abc"}`, "no proof of the import of investigation INV-001"},
+		{"forged block", `{"block":"` + base64.StdEncoding.EncodeToString([]byte("not a block")) + `"}`, "invalid transfer proof"},
+	} {
+		result := endorsers[0].endorse("tx-complete", court, proposalTime, "CompleteReactivationTransfer", "INV-001", test.proof)
+		if result.Status == 200 || !strings.Contains(result.Message, test.want) {
+			t.Errorf("completion with %s: %d %s", test.name, result.Status, result.Message)
+		}
+		if len(result.Writes) != 0 {
+			t.Errorf("completion with %s wrote %v", test.name, keys(result.Writes))
+		}
+	}
+}
+
+func TestRecordedAbortRefusesBackdatedImport(t *testing.T) {
+	endorsers := newEndorsers(t)
+	court := newCreator(t, "CourtMSP", "judge1.court.cold.coc.com", nil)
+	initLedger(t, endorsers, court)
+	chunks := seedImportSession(t, endorsers, "EVD-001")
+	seedState(t, endorsers, map[string]string{
+		core.TransferTrustKey: `{"source_chain":"hot","channel_id":"hotchannel","chaincode_name":"dfir","msps":[],"min_endorsements":1}`,
+	})
+
+	// The abort is only recorded with a proof from the hot chain
+	forged := `{"block":"` + base64.StdEncoding.EncodeToString([]byte("not a block")) + `"}`
+	result := endorsers[0].endorse("tx-record-forged", court, proposalTime, "RecordArchiveAbort", "INV-001", forged)
+	if result.Status == 200 || !strings.Contains(result.Message, "invalid transfer proof") || len(result.Writes) != 0 {
+		t.Errorf("abort recorded with a forged proof: %d %s %v", result.Status, result.Message, keys(result.Writes))
+	}
+
+	abortJSON, err := json.Marshal(core.CaseTransfer{
+		InvestigationID: "INV-001",
+		Direction:       core.TransferArchive,
+		ExportTxID:      "tx-export",
+		State:           core.TransferStateAborted,
+		AbortReason:     "cold chain unreachable",
+		AbortTxID:       "tx-hot-abort",
+	})
+	if err != nil {
+		t.Fatalf("failed to encode abort: %v", err)
+	}
+	seedState(t, endorsers, map[string]string{core.TransferAbortKey("INV-001", "tx-export"): string(abortJSON)})
+
+	// A proposal dated before the deadline does not bring the transfer back
+	exportPackage := core.ArchiveFlow.NewPackage(core.Investigation{ID: "INV-001", CaseNumber: "CASE-1", Status: "transferring_to_archive"},
+		nil, "ORDER-1", "court", proposalTime.Add(-time.Hour).Unix(), "tx-export")
+	exportPackage.ExpiresAt = proposalTime.Add(time.Hour).Unix()
+	packageJSON, err := json.Marshal(exportPackage)
+	if err != nil {
+		t.Fatalf("failed to encode package: %v", err)
+	}
+	backdated := proposalTime.Add(-30 * time.Minute)
+	for function, args := range map[string][]string{
+		"ImportArchivedCase":         {string(packageJSON)},
+		"ImportArchivedCaseChunk":    {"INV-001", "tx-export", "0", chunks[0]},
+		"FinalizeArchivedCaseImport": {"INV-001", "tx-export"},
+	} {
+		result := endorsers[0].endorse("tx-backdated", court, backdated, function, args...)
+		if result.Status == 200 || !strings.Contains(result.Message, "was aborted on the hot chain in transaction tx-hot-abort") {
+			t.Errorf("backdated %s: %d %s", function, result.Status, result.Message)
+		}
+		if len(result.Writes) != 0 {
+			t.Errorf("backdated %s wrote %v", function, keys(result.Writes))
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ==============================================================================
+// ATTRIBUTE-BASED ACCESS CONTROL
+// ==============================================================================
+//
+// ABAC rules run per record after the RBAC check has allowed the action:
+//   1. the caller's clearance must be at least the case classification
+//   2. restricted and higher cases are limited to callers of the same jurisdiction
+//   3. secret cases are limited to callers of the owning unit
+// Restricted and higher cases must name a jurisdiction, and secret cases an
+// owning unit; until they do only oversight roles can reach them.
+// Evidence inherits the attributes of its case.
+
+// ClassificationLevels orders investigation classifications, lowest to highest
+var ClassificationLevels = map[string]int{
+	"unclassified": 0,
+	"restricted":   1,
+	"confidential": 2,
+	"secret":       3,
+}
+
+// OversightRoles are governed by RBAC alone and bypass per-case restrictions
+var OversightRoles = []string{"SystemAdmin", "BlockchainCourt", "BlockchainAuditor"}
+
+// CallerAttributes holds the X.509 attributes used for attribute-based decisions
+type CallerAttributes struct {
+	Jurisdiction string `json:"jurisdiction"`
+	Clearance    string `json:"clearance"`
+	Unit         string `json:"unit"`
+}
+
+// GetCallerAttributes reads the caller's ABAC certificate attributes
+func GetCallerAttributes(ctx contractapi.TransactionContextInterface) CallerAttributes {
+	var attrs CallerAttributes
+	attrs.Jurisdiction, _, _ = ctx.GetClientIdentity().GetAttributeValue("jurisdiction")
+	attrs.Clearance, _, _ = ctx.GetClientIdentity().GetAttributeValue("clearance")
+	attrs.Unit, _, _ = ctx.GetClientIdentity().GetAttributeValue("unit")
+	return attrs
+}
+
+// IsOversight reports whether the caller holds an oversight role (SystemAdmin, Court, Auditor)
+func IsOversight(ctx contractapi.TransactionContextInterface) (bool, error) {
+	subject, err := Subject(ctx)
+	if err != nil {
+		return false, err
+	}
+	role, err := ResolveRole(ctx)
+	if err != nil {
+		return false, err
+	}
+	enforcer, err := LoadEnforcer(ctx)
+	if err != nil {
+		return false, err
+	}
+	enforcer.AddRoleForUser(subject, role)
+
+	for _, exempt := range OversightRoles {
+		if enforcer.HasRoleForUser(subject, exempt) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CheckCaseAttributes applies the ABAC rules of an investigation to the caller
+func CheckCaseAttributes(ctx contractapi.TransactionContextInterface,
+	investigation *Investigation, action string) error {
+
+	exempt, err := IsOversight(ctx)
+	if err != nil {
+		return err
+	}
+	if exempt {
+		return nil
+	}
+
+	if reason := EvaluateCaseAttributes(GetCallerAttributes(ctx), investigation); reason != "" {
+		LogAudit(ctx, action, "blockchain.investigation", investigation.ID, "denied", reason)
+		return fmt.Errorf("access denied: %s", reason)
+	}
+	return nil
+}
+
+// CheckEvidenceAttributes applies the ABAC rules of the evidence's case to the caller
+func CheckEvidenceAttributes(ctx contractapi.TransactionContextInterface,
+	evidence *Evidence, action string) error {
+
+	investigation, err := LoadInvestigation(ctx, evidence.CaseID)
+	if err != nil {
+		return err
+	}
+	if investigation == nil {
+		return nil
+	}
+	return CheckCaseAttributes(ctx, investigation, action)
+}
+
+// CheckEvidenceIDAttributes applies the ABAC rules of an evidence item's case to the caller
+func CheckEvidenceIDAttributes(ctx contractapi.TransactionContextInterface,
+	evidenceID string, action string) error {
+
+	evidence, err := LoadEvidence(ctx, evidenceID)
+	if err != nil || evidence == nil {
+		return err
+	}
+	return CheckEvidenceAttributes(ctx, evidence, action)
+}
+
+// FilterInvestigationsByAttributes keeps the investigations the caller's attributes allow
+func FilterInvestigationsByAttributes(ctx contractapi.TransactionContextInterface,
+	investigations []*Investigation) ([]*Investigation, error) {
+
+	exempt, err := IsOversight(ctx)
+	if err != nil || exempt {
+		return investigations, err
+	}
+
+	attrs := GetCallerAttributes(ctx)
+	var allowed []*Investigation
+	for _, investigation := range investigations {
+		if EvaluateCaseAttributes(attrs, investigation) == "" {
+			allowed = append(allowed, investigation)
+		}
+	}
+	return allowed, nil
+}
+
+// FilterEvidenceByAttributes keeps the evidence whose case the caller's attributes allow
+func FilterEvidenceByAttributes(ctx contractapi.TransactionContextInterface,
+	evidenceList []*Evidence) ([]*Evidence, error) {
+
+	exempt, err := IsOversight(ctx)
+	if err != nil || exempt {
+		return evidenceList, err
+	}
+
+	attrs := GetCallerAttributes(ctx)
+	decisions := map[string]bool{}
+	var allowed []*Evidence
+	for _, evidence := range evidenceList {
+		ok, seen := decisions[evidence.CaseID]
+		if !seen {
+			investigation, err := LoadInvestigation(ctx, evidence.CaseID)
+			if err != nil {
+				return nil, err
+			}
+			ok = investigation == nil || EvaluateCaseAttributes(attrs, investigation) == ""
+			decisions[evidence.CaseID] = ok
+		}
+		if ok {
+			allowed = append(allowed, evidence)
+		}
+	}
+	return allowed, nil
+}
+
+// LoadInvestigation reads an investigation without permission checks, or nil if it does not exist
+func LoadInvestigation(ctx contractapi.TransactionContextInterface,
+	caseID string) (*Investigation, error) {
+
+	invBytes, err := ctx.GetStub().GetState(InvestigationKey(caseID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read investigation: %v", err)
+	}
+	if invBytes == nil {
+		return nil, nil
+	}
+
+	var investigation Investigation
+	if err := json.Unmarshal(invBytes, &investigation); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal investigation: %v", err)
+	}
+	return &investigation, nil
+}
+
+// LoadEvidence reads an evidence item without permission checks, or nil if it does not exist
+func LoadEvidence(ctx contractapi.TransactionContextInterface,
+	evidenceID string) (*Evidence, error) {
+
+	evidenceJSON, err := ctx.GetStub().GetState(EvidenceKey(evidenceID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read evidence: %v", err)
+	}
+	if evidenceJSON == nil {
+		return nil, nil
+	}
+
+	var evidence Evidence
+	if err := json.Unmarshal(evidenceJSON, &evidence); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal evidence: %v", err)
+	}
+	return &evidence, nil
+}
+
+// EvaluateCaseAttributes returns why the attributes deny access to the investigation,
+// or an empty string if access is allowed. A restricted or secret case without a
+// jurisdiction or owning unit admits no one the rule applies to.
+func EvaluateCaseAttributes(attrs CallerAttributes, investigation *Investigation) string {
+	level := ClassificationLevels[investigation.Classification]
+
+	clearance, ok := ClearanceLevel(attrs.Clearance)
+	if !ok {
+		return fmt.Sprintf("clearance %q is not a valid clearance level", attrs.Clearance)
+	}
+	if clearance < level {
+		return fmt.Sprintf("clearance %q is below case classification %q", attrs.Clearance, investigation.Classification)
+	}
+
+	if level >= ClassificationLevels["restricted"] && (investigation.Jurisdiction == "" ||
+		!strings.EqualFold(attrs.Jurisdiction, investigation.Jurisdiction)) {
+		return fmt.Sprintf("jurisdiction %q cannot access %s case of jurisdiction %q",
+			attrs.Jurisdiction, investigation.Classification, investigation.Jurisdiction)
+	}
+
+	if level >= ClassificationLevels["secret"] && (investigation.OwningUnit == "" ||
+		!strings.EqualFold(attrs.Unit, investigation.OwningUnit)) {
+		return fmt.Sprintf("unit %q is not the owning unit of this secret case", attrs.Unit)
+	}
+
+	return ""
+}
+
+// ClearanceLevel converts a clearance attribute (level name or number 0-3) to a level.
+// A missing clearance is level 0; any other value that names no level is not valid.
+func ClearanceLevel(clearance string) (int, bool) {
+	if clearance == "" {
+		return 0, true
+	}
+	if level, ok := ClassificationLevels[strings.ToLower(clearance)]; ok {
+		return level, true
+	}
+	if level, err := strconv.Atoi(clearance); err == nil &&
+		level >= 0 && level <= ClassificationLevels["secret"] {
+		return level, true
+	}
+	return 0, false
+}
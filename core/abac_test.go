@@ -0,0 +1,67 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClearanceLevel(t *testing.T) {
+	for _, tc := range []struct {
+		clearance string
+		level     int
+		valid     bool
+	}{
+		{"", 0, true},
+		{"unclassified", 0, true},
+		{"Restricted", 1, true},
+		{"confidential", 2, true},
+		{"SECRET", 3, true},
+		{"0", 0, true},
+		{"3", 3, true},
+		{"4", 0, false},
+		{"99", 0, false},
+		{"-1", 0, false},
+		{"top-secret", 0, false},
+	} {
+		level, valid := ClearanceLevel(tc.clearance)
+		if level != tc.level || valid != tc.valid {
+			t.Errorf("ClearanceLevel(%q) = %d, %t; want %d, %t", tc.clearance, level, valid, tc.level, tc.valid)
+		}
+	}
+}
+
+func TestEvaluateCaseAttributes(t *testing.T) {
+	confidential := &Investigation{ID: "INV-001", Classification: "confidential", Jurisdiction: "north"}
+	secret := &Investigation{ID: "INV-002", Classification: "secret", Jurisdiction: "north", OwningUnit: "cyber"}
+
+	for _, tc := range []struct {
+		name          string
+		attrs         CallerAttributes
+		investigation *Investigation
+		deny          string // Part of the expected denial reason, empty if allowed
+	}{
+		{"cleared in jurisdiction", CallerAttributes{Jurisdiction: "North", Clearance: "confidential"}, confidential, ""},
+		{"numeric clearance", CallerAttributes{Jurisdiction: "north", Clearance: "2"}, confidential, ""},
+		{"clearance too low", CallerAttributes{Jurisdiction: "north", Clearance: "restricted"}, confidential, "below case classification"},
+		{"clearance out of range", CallerAttributes{Jurisdiction: "north", Clearance: "99"}, confidential, "not a valid clearance level"},
+		{"out of range on an open case", CallerAttributes{Clearance: "99"}, &Investigation{Classification: "unclassified"}, "not a valid clearance level"},
+		{"other jurisdiction", CallerAttributes{Jurisdiction: "south", Clearance: "secret"}, confidential, "jurisdiction"},
+		{"case without jurisdiction", CallerAttributes{Clearance: "secret"},
+			&Investigation{Classification: "restricted"}, "jurisdiction"},
+		{"unclassified without jurisdiction", CallerAttributes{}, &Investigation{Classification: "unclassified"}, ""},
+		{"owning unit", CallerAttributes{Jurisdiction: "north", Clearance: "secret", Unit: "cyber"}, secret, ""},
+		{"other unit", CallerAttributes{Jurisdiction: "north", Clearance: "secret", Unit: "fraud"}, secret, "owning unit"},
+		{"secret case without unit", CallerAttributes{Jurisdiction: "north", Clearance: "secret"},
+			&Investigation{Classification: "secret", Jurisdiction: "north"}, "owning unit"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reason := EvaluateCaseAttributes(tc.attrs, tc.investigation)
+			if tc.deny == "" && reason != "" {
+				t.Errorf("denied: %s", reason)
+			}
+			if tc.deny != "" && !strings.Contains(reason, tc.deny) {
+				t.Errorf("reason %q, want it to mention %q", reason, tc.deny)
+			}
+		})
+	}
+}
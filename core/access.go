@@ -0,0 +1,335 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	casbin "github.com/aub/dfir-casbin"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ==============================================================================
+// ACCESS CONTROL ENGINE
+// ==============================================================================
+//
+// Requests are checked against casbin/model.conf and the live on-ledger policy.
+// The caller's role comes from its certificate "role" attribute, falling back
+// to the on-ledger MSP-to-role mapping, and is added to the policy as a
+// request-scoped g assignment of the caller's subject (<msp>/CN=...). Subjects
+// carry the MSP so a common name issued by one organization's CA cannot pick up
+// the assignments or recusals of a member of another.
+
+// AccessControl evaluates permissions for one chain
+type AccessControl struct {
+	// Resource is the p.res value that scopes policy rules to the chain, "hot" or "cold"
+	Resource string
+}
+
+// Permission scopes returned by PermissionScope
+const (
+	ScopeAll  = "*"
+	ScopeSelf = "self"
+)
+
+// Role sources reported by ResolveRoleSource
+const (
+	RoleSourceCertificate = "certificate attribute"
+	RoleSourceMSP         = "MSP fallback"
+)
+
+// CheckPermission validates if the caller has permission for the action
+func (ac AccessControl) CheckPermission(ctx contractapi.TransactionContextInterface,
+	object string, action string, resource string) error {
+
+	// Check permission against the Casbin model and the live on-ledger policy
+	allowed, role, err := ac.Authorize(ctx, object, action, resource)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		LogAudit(ctx, action, object, resource, "denied", fmt.Sprintf("Insufficient permissions for role: %s", role))
+		return fmt.Errorf("access denied: %s does not have permission to %s on %s", role, action, object)
+	}
+
+	return nil
+}
+
+// Authorize evaluates the policy for the caller without recording a denial
+func (ac AccessControl) Authorize(ctx contractapi.TransactionContextInterface,
+	object string, action string, resource string) (bool, string, error) {
+
+	subject, err := Subject(ctx)
+	if err != nil {
+		return false, "", err
+	}
+
+	role, err := ResolveRole(ctx)
+	if err != nil {
+		return false, "", err
+	}
+
+	allowed, err := ac.EvaluatePermission(ctx, subject, role, object, action, resource)
+	return allowed, role, err
+}
+
+// PermissionScope checks the action on records of object. It returns ScopeAll when the
+// caller holds the permission chain-wide and ScopeSelf when it is granted only for
+// records the caller owns; callers must then apply CheckOwner or FilterOwned.
+func (ac AccessControl) PermissionScope(ctx contractapi.TransactionContextInterface,
+	object string, action string) (string, error) {
+
+	allowed, role, err := ac.Authorize(ctx, object, action, ScopeAll)
+	if err != nil {
+		return "", err
+	}
+	if allowed {
+		return ScopeAll, nil
+	}
+
+	allowed, _, err = ac.Authorize(ctx, object, action, ScopeSelf)
+	if err != nil {
+		return "", err
+	}
+	if allowed {
+		return ScopeSelf, nil
+	}
+
+	LogAudit(ctx, action, object, ScopeAll, "denied", fmt.Sprintf("Insufficient permissions for role: %s", role))
+	return "", fmt.Errorf("access denied: %s does not have permission to %s on %s", role, action, object)
+}
+
+// CheckOwner rejects access to another identity's record when the caller is limited to ScopeSelf
+func CheckOwner(ctx contractapi.TransactionContextInterface,
+	scope string, object string, action string, resourceID string, owner string) error {
+
+	if scope != ScopeSelf || IsCaller(ctx, owner) {
+		return nil
+	}
+
+	LogAudit(ctx, action, object, resourceID, "denied", "Permission is limited to the caller's own records")
+	return fmt.Errorf("access denied: permission to %s on %s is limited to your own records", action, object)
+}
+
+// IsCaller reports whether owner is the caller's client identity
+func IsCaller(ctx contractapi.TransactionContextInterface, owner string) bool {
+	clientID, err := ctx.GetClientIdentity().GetID()
+	return err == nil && owner != "" && owner == clientID
+}
+
+// FilterOwned keeps only the records owned by the caller when scope is ScopeSelf
+func FilterOwned[T any](ctx contractapi.TransactionContextInterface, scope string,
+	records []*T, owner func(*T) string) []*T {
+
+	if scope != ScopeSelf {
+		return records
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil
+	}
+
+	var owned []*T
+	for _, record := range records {
+		if owner(record) == clientID {
+			owned = append(owned, record)
+		}
+	}
+	return owned
+}
+
+// ResolveRole returns the caller's role certificate attribute, falling back to the MSP default role
+func ResolveRole(ctx contractapi.TransactionContextInterface) (string, error) {
+	role, _, err := ResolveRoleSource(ctx)
+	return role, err
+}
+
+// ResolveRoleSource resolves the caller's role like ResolveRole and reports where it came from
+func ResolveRoleSource(ctx contractapi.TransactionContextInterface) (string, string, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+
+	role, found, err := ctx.GetClientIdentity().GetAttributeValue("role")
+	if err != nil || !found {
+		role, err = RoleFromMSP(ctx, mspID)
+		return role, RoleSourceMSP, err
+	}
+	return role, RoleSourceCertificate, nil
+}
+
+// EvaluatePermission enforces casbin/model.conf and the live access policy for the caller
+func (ac AccessControl) EvaluatePermission(ctx contractapi.TransactionContextInterface,
+	subject string, role string, object string, action string, resource string) (bool, error) {
+
+	enforcer, err := LoadEnforcer(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// The certificate/MSP role is a request-scoped g assignment alongside the stored g lines
+	enforcer.AddRoleForUser(subject, role)
+
+	// "*" asks for the chain-wide resource so hot/cold scoped rules apply
+	if resource == ScopeAll {
+		resource = ac.Resource
+	}
+
+	return enforcer.Enforce(subject, object, action, resource)
+}
+
+// Subject returns the policy subject for the caller, e.g.
+// LawEnforcementMSP/CN=user1.lawenforcement.hot.coc.com
+func Subject(ctx contractapi.TransactionContextInterface) (string, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	cert, err := ctx.GetClientIdentity().GetX509Certificate()
+	if err != nil {
+		return "", fmt.Errorf("failed to get client certificate: %v", err)
+	}
+	if cert == nil {
+		return "", fmt.Errorf("client certificate not available")
+	}
+	return QualifySubject(mspID, "CN="+cert.Subject.CommonName), nil
+}
+
+// QualifySubject returns the MSP-qualified form of a CN=... subject
+func QualifySubject(mspID string, subject string) string {
+	return mspID + "/" + subject
+}
+
+// IsLegacySubject reports whether subject uses the unqualified CN=... form
+// written before subjects carried their MSP
+func IsLegacySubject(subject string) bool {
+	return strings.HasPrefix(subject, "CN=")
+}
+
+// CheckSystemAdmin allows only SystemAdmin callers (by certificate/MSP role or g assignment)
+func CheckSystemAdmin(ctx contractapi.TransactionContextInterface) error {
+	isAdmin, err := HasRole(ctx, "SystemAdmin")
+	if err != nil {
+		return err
+	}
+	if !isAdmin {
+		return fmt.Errorf("access denied: only SystemAdmin can change the access policy")
+	}
+	return nil
+}
+
+// HasRole reports whether the caller holds role directly, through its certificate/MSP
+// role, or through role inheritance in the live policy
+func HasRole(ctx contractapi.TransactionContextInterface, role string) (bool, error) {
+	subject, err := Subject(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	callerRole, err := ResolveRole(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	enforcer, err := LoadEnforcer(ctx)
+	if err != nil {
+		return false, err
+	}
+	enforcer.AddRoleForUser(subject, callerRole)
+
+	return enforcer.HasRoleForUser(subject, role), nil
+}
+
+// ==============================================================================
+// POLICY STORE
+// ==============================================================================
+
+// LoadAccessPolicy reads the live policy, falling back to the embedded policy.csv
+// as version 0 until the ledger has been seeded
+func LoadAccessPolicy(ctx contractapi.TransactionContextInterface) (*AccessPolicy, error) {
+	policyJSON, err := GetConfigState(ctx, AccessPolicyKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read access policy: %v", err)
+	}
+
+	if policyJSON == nil {
+		defaults, err := casbin.DefaultPolicy()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load default access policy: %v", err)
+		}
+		return &AccessPolicy{
+			Version: 0,
+			Rules:   defaults.Rules,
+			Roles:   defaults.Roles,
+			Change:  "embedded policy.csv",
+		}, nil
+	}
+
+	var policy AccessPolicy
+	if err := json.Unmarshal(policyJSON, &policy); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal access policy: %v", err)
+	}
+	return &policy, nil
+}
+
+// NewEnforcer builds an enforcer for the shipped model and the given policy
+func NewEnforcer(policy *casbin.Policy) (*casbin.Enforcer, error) {
+	model, err := casbin.DefaultModel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load access model: %v", err)
+	}
+	return casbin.NewEnforcerFromPolicy(model, policy)
+}
+
+// LoadEnforcer builds an enforcer for the live on-ledger policy
+func LoadEnforcer(ctx contractapi.TransactionContextInterface) (*casbin.Enforcer, error) {
+	policy, err := LoadAccessPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewEnforcer(&casbin.Policy{Rules: policy.Rules, Roles: policy.Roles})
+}
+
+// RoleFromMSP maps MSP ID to default role using the live mapping
+func RoleFromMSP(ctx contractapi.TransactionContextInterface, mspID string) (string, error) {
+	mapping, err := LoadMSPRoles(ctx)
+	if err != nil {
+		return "", err
+	}
+	if role, ok := mapping.Roles[mspID]; ok {
+		return role, nil
+	}
+	return casbin.DefaultRole, nil
+}
+
+// LoadMSPRoles reads the live mapping, falling back to the embedded msp_roles.csv
+// as version 0 until the ledger has been seeded
+func LoadMSPRoles(ctx contractapi.TransactionContextInterface) (*MSPRoleMapping, error) {
+	mappingJSON, err := GetConfigState(ctx, MSPRolesKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MSP role mapping: %v", err)
+	}
+
+	if mappingJSON == nil {
+		defaults, err := casbin.DefaultMSPRoles()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load default MSP role mapping: %v", err)
+		}
+		return &MSPRoleMapping{
+			Version: 0,
+			Roles:   defaults,
+			Change:  "embedded msp_roles.csv",
+		}, nil
+	}
+
+	var mapping MSPRoleMapping
+	if err := json.Unmarshal(mappingJSON, &mapping); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal MSP role mapping: %v", err)
+	}
+	if mapping.Roles == nil {
+		mapping.Roles = map[string]string{}
+	}
+	return &mapping, nil
+}
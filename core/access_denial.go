@@ -0,0 +1,203 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ==============================================================================
+// DENIED ACCESS RECORDS
+// ==============================================================================
+//
+// A transaction that fails a permission check is never committed, so the audit
+// entry and any event it wrote are discarded with it. Clients (or the gateway
+// acting for them) therefore submit RecordAccessDenial as a separate transaction
+// after an "access denied" error. The identity fields are taken from the
+// submitting certificate, and the chaincode re-evaluates the policy so auditors
+// can tell confirmed RBAC denials from other reported failures.
+
+// AccessDenial is a committed record of a denied access attempt
+type AccessDenial struct {
+	ID            string `json:"id"`
+	DocType       string `json:"doc_type"` // always "access_denial"
+	UserID        string `json:"user_id"`
+	Subject       string `json:"subject"` // <msp>/CN=...
+	ClientMSP     string `json:"client_msp"`
+	Role          string `json:"role"`
+	Function      string `json:"function"`
+	Object        string `json:"object"`
+	Action        string `json:"action"`
+	Resource      string `json:"resource"`
+	Reason        string `json:"reason"`       // Error returned to the client
+	DeniedTxID    string `json:"denied_tx_id"` // ID of the transaction that was denied, if known
+	Confirmed     bool   `json:"confirmed"`    // The live policy denies the action to the caller
+	Timestamp     int64  `json:"timestamp"`
+	TransactionID string `json:"transaction_id"`
+}
+
+// RecordAccessDenial commits a record of the caller's own denied attempt and emits an
+// AccessDenied event. Any identity may call it, so no permission check is made.
+func (cc ChainContract) RecordAccessDenial(ctx contractapi.TransactionContextInterface,
+	function string, object string, action string, resource string,
+	deniedTxID string, reason string) (*AccessDenial, error) {
+
+	if object == "" || action == "" {
+		return nil, fmt.Errorf("a denial record requires the object and action that were denied")
+	}
+	if resource == "" {
+		resource = "*"
+	}
+
+	clientID, _ := ctx.GetClientIdentity().GetID()
+	mspID, _ := ctx.GetClientIdentity().GetMSPID()
+	subject, err := Subject(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Confirm against the live policy; a case:<id> or evidence:<id> resource also applies recusals
+	allowed, role, err := cc.Access.Authorize(ctx, object, action, ScopeAll)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed && resource == ScopeSelf {
+		if allowed, _, err = cc.Access.Authorize(ctx, object, action, ScopeSelf); err != nil {
+			return nil, err
+		}
+	}
+	caseID, isCase := strings.CutPrefix(resource, caseResourcePrefix)
+	evidenceID, isEvidence := strings.CutPrefix(resource, evidenceResourcePrefix)
+	if allowed && (isCase || isEvidence) {
+		if !isCase {
+			caseID = ""
+		}
+		if !isEvidence {
+			evidenceID = ""
+		}
+		recused, err := recusalChecker(ctx, object, action)
+		if err != nil {
+			return nil, err
+		}
+		record, err := recused(caseID, evidenceID)
+		if err != nil {
+			return nil, err
+		}
+		allowed = record == ""
+	}
+
+	txID := ctx.GetStub().GetTxID()
+	now, err := TxNow(ctx)
+	if err != nil {
+		return nil, err
+	}
+	denial := AccessDenial{
+		ID:            TxScopedID(ctx, "denial"),
+		DocType:       "access_denial",
+		UserID:        clientID,
+		Subject:       subject,
+		ClientMSP:     mspID,
+		Role:          role,
+		Function:      function,
+		Object:        object,
+		Action:        action,
+		Resource:      resource,
+		Reason:        reason,
+		DeniedTxID:    deniedTxID,
+		Confirmed:     !allowed,
+		Timestamp:     now,
+		TransactionID: txID,
+	}
+
+	denialJSON, err := json.Marshal(denial)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal access denial: %v", err)
+	}
+
+	if err := ctx.GetStub().PutState(StateKey(RecordAccessDenial, denial.ID), denialJSON); err != nil {
+		return nil, fmt.Errorf("failed to store access denial: %v", err)
+	}
+
+	// Emit event
+	ctx.GetStub().SetEvent("AccessDenied", denialJSON)
+
+	return &denial, nil
+}
+
+// QueryAccessDenialsByUser retrieves denied attempts by a user (client ID or <msp>/CN=... subject)
+// between from and to (Unix seconds, 0 for unbounded)
+func (cc ChainContract) QueryAccessDenialsByUser(ctx contractapi.TransactionContextInterface,
+	userID string, from int64, to int64) ([]*AccessDenial, error) {
+
+	return cc.queryAccessDenials(ctx, map[string]interface{}{
+		"$or": []map[string]interface{}{{"user_id": userID}, {"subject": userID}},
+	}, from, to)
+}
+
+// QueryAccessDenialsByMSP retrieves denied attempts by members of an MSP
+// between from and to (Unix seconds, 0 for unbounded)
+func (cc ChainContract) QueryAccessDenialsByMSP(ctx contractapi.TransactionContextInterface,
+	mspID string, from int64, to int64) ([]*AccessDenial, error) {
+
+	return cc.queryAccessDenials(ctx, map[string]interface{}{"client_msp": mspID}, from, to)
+}
+
+// QueryAccessDenials retrieves every denied attempt between from and to (Unix seconds, 0 for unbounded)
+func (cc ChainContract) QueryAccessDenials(ctx contractapi.TransactionContextInterface,
+	from int64, to int64) ([]*AccessDenial, error) {
+
+	return cc.queryAccessDenials(ctx, map[string]interface{}{}, from, to)
+}
+
+// queryAccessDenials runs a CouchDB query over denial records restricted to a time window
+func (cc ChainContract) queryAccessDenials(ctx contractapi.TransactionContextInterface,
+	selector map[string]interface{}, from int64, to int64) ([]*AccessDenial, error) {
+
+	// Check permission
+	if err := cc.Access.CheckPermission(ctx, "audits.accessdenial", "view", "*"); err != nil {
+		return nil, err
+	}
+
+	selector["doc_type"] = "access_denial"
+	window := map[string]interface{}{}
+	if from > 0 {
+		window["$gte"] = from
+	}
+	if to > 0 {
+		window["$lte"] = to
+	}
+	if len(window) > 0 {
+		selector["timestamp"] = window
+	}
+
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector": selector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetQueryResult(string(queryJSON))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query access denials: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var results []*AccessDenial
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var denial AccessDenial
+		if err := json.Unmarshal(queryResponse.Value, &denial); err != nil {
+			return nil, err
+		}
+		results = append(results, &denial)
+	}
+
+	return results, nil
+}
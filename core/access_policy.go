@@ -0,0 +1,429 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	casbin "github.com/aub/dfir-casbin"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// EffectivePermissions is the resolved permission set of an identity or role
+type EffectivePermissions struct {
+	Identity    string     `json:"identity"`
+	Roles       []string   `json:"roles"`       // Direct and inherited roles, nearest first
+	Permissions [][]string `json:"permissions"` // sub, obj, act, res, eft
+}
+
+// AccessPolicyChange is emitted as the AccessPolicyChanged event
+type AccessPolicyChange struct {
+	Version int      `json:"version"`
+	Change  string   `json:"change"`
+	Line    []string `json:"line"`
+	By      string   `json:"by"`
+	Reason  string   `json:"reason,omitempty"`  // Why the change was made, when the transaction records one
+	Recusal *Recusal `json:"recusal,omitempty"` // Recusal added or lifted by the change
+}
+
+// ==============================================================================
+// POLICY STORE TRANSACTIONS (SystemAdmin only)
+// ==============================================================================
+
+// AddPolicy adds a p rule granting role the action on object for resource
+func (cc ChainContract) AddPolicy(ctx contractapi.TransactionContextInterface,
+	role string, object string, action string, resource string) error {
+
+	rule := []string{role, object, action, resource}
+	return changeAccessPolicy(ctx, "AddPolicy", rule, func(policy *casbin.Policy) error {
+		if !policy.AddRule(rule) {
+			return fmt.Errorf("policy %v already exists", rule)
+		}
+		return nil
+	})
+}
+
+// RemovePolicy removes a p rule
+func (cc ChainContract) RemovePolicy(ctx contractapi.TransactionContextInterface,
+	role string, object string, action string, resource string) error {
+
+	rule := []string{role, object, action, resource}
+	return changeAccessPolicy(ctx, "RemovePolicy", rule, func(policy *casbin.Policy) error {
+		if !policy.RemoveRule(rule) {
+			return fmt.Errorf("policy %v does not exist", rule)
+		}
+		// Never allow the store to lock out its own administrators
+		if !policy.HasRule([]string{"SystemAdmin", "*", "*", "*"}) {
+			return fmt.Errorf("the SystemAdmin full-access rule cannot be removed")
+		}
+		return nil
+	})
+}
+
+// AssignRole adds a g assignment of role to user (e.g. LawEnforcementMSP/CN=user1.lawenforcement.hot.coc.com)
+// or makes role user inherit every permission of role (e.g. BlockchainSupervisor, BlockchainInvestigator)
+func (cc ChainContract) AssignRole(ctx contractapi.TransactionContextInterface,
+	user string, role string) error {
+
+	return changeAccessPolicy(ctx, "AssignRole", []string{user, role}, func(policy *casbin.Policy) error {
+		if !policy.AddRole(user, role) {
+			return fmt.Errorf("%s already has role %s", user, role)
+		}
+		return nil
+	})
+}
+
+// RevokeRole removes a g assignment of role from user
+func (cc ChainContract) RevokeRole(ctx contractapi.TransactionContextInterface,
+	user string, role string) error {
+
+	return changeAccessPolicy(ctx, "RevokeRole", []string{user, role}, func(policy *casbin.Policy) error {
+		if !policy.RemoveRole(user, role) {
+			return fmt.Errorf("%s does not have role %s", user, role)
+		}
+		return nil
+	})
+}
+
+// QualifySubject migrates an identity named by the unqualified subject written before
+// subjects carried their MSP (CN=...) to <mspID>/CN=... in g assignments, recusal
+// deny rules and recusal records. A chain with records of its own that name subjects
+// (the hot chain's case teams) overrides it to migrate those as well.
+func (cc ChainContract) QualifySubject(ctx contractapi.TransactionContextInterface,
+	subject string, mspID string) error {
+
+	if !IsLegacySubject(subject) || mspID == "" {
+		return fmt.Errorf("QualifySubject requires an unqualified CN=... subject and an MSP ID")
+	}
+	qualified := QualifySubject(mspID, subject)
+
+	err := changeAccessPolicy(ctx, "QualifySubject", []string{subject, qualified}, func(policy *casbin.Policy) error {
+		if policy.RenameSubject(subject, qualified) == 0 {
+			return fmt.Errorf("no policy line names %s", subject)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return qualifyRecusals(ctx, subject, qualified)
+}
+
+// ListPolicies returns the live access policy
+func (cc ChainContract) ListPolicies(ctx contractapi.TransactionContextInterface) (*AccessPolicy, error) {
+	// Check permission
+	if err := cc.Access.CheckPermission(ctx, "rbac.policy", "view", "*"); err != nil {
+		return nil, err
+	}
+
+	return LoadAccessPolicy(ctx)
+}
+
+// GetEffectivePermissions resolves the roles and rules that apply to identity (a subject
+// such as <msp>/CN=... or a role name). An empty identity resolves the caller, including the
+// role from their certificate or MSP; other identities require rbac.policy view.
+func (cc ChainContract) GetEffectivePermissions(ctx contractapi.TransactionContextInterface,
+	identity string) (*EffectivePermissions, error) {
+
+	subject, err := Subject(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if identity == "" {
+		identity = subject
+	}
+
+	enforcer, err := LoadEnforcer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if identity == subject {
+		role, err := ResolveRole(ctx)
+		if err != nil {
+			return nil, err
+		}
+		enforcer.AddRoleForUser(subject, role)
+	} else if err := cc.Access.CheckPermission(ctx, "rbac.policy", "view", "*"); err != nil {
+		return nil, err
+	}
+
+	// contractapi rejects null for the array fields, so an identity without roles
+	// or rules resolves to empty lists
+	return &EffectivePermissions{
+		Identity:    identity,
+		Roles:       append([]string{}, enforcer.GetImplicitRolesForUser(identity)...),
+		Permissions: append([][]string{}, enforcer.GetImplicitPermissionsForUser(identity)...),
+	}, nil
+}
+
+// GetPolicyHistory returns every committed version of the access policy
+func (cc ChainContract) GetPolicyHistory(ctx contractapi.TransactionContextInterface) ([]map[string]interface{}, error) {
+	// Check permission
+	if err := cc.Access.CheckPermission(ctx, "rbac.policy", "view", "*"); err != nil {
+		return nil, err
+	}
+
+	// Versions written before MigrateKeys stay in the legacy key's history
+	var history []map[string]interface{}
+	for _, key := range append(LegacyKeys(RecordConfig, "access_policy"), AccessPolicyKey) {
+		versions, err := policyKeyHistory(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, versions...)
+	}
+
+	return history, nil
+}
+
+// policyKeyHistory returns the versions of the access policy written under key
+func policyKeyHistory(ctx contractapi.TransactionContextInterface, key string) ([]map[string]interface{}, error) {
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policy history: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var history []map[string]interface{}
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		record := make(map[string]interface{})
+		record["tx_id"] = response.TxId
+		record["timestamp"] = response.Timestamp.Seconds
+		record["is_delete"] = response.IsDelete
+
+		if !response.IsDelete {
+			var policy AccessPolicy
+			if err := json.Unmarshal(response.Value, &policy); err == nil {
+				record["value"] = policy
+			}
+		}
+
+		history = append(history, record)
+	}
+
+	return history, nil
+}
+
+// ==============================================================================
+// ACCESS EXPLANATION (evaluate-only)
+// ==============================================================================
+
+// AccessExplanation describes how a permission check is decided
+type AccessExplanation struct {
+	Identity   string              `json:"identity"`
+	Role       string              `json:"role,omitempty" metadata:",optional"` // Role from the certificate or MSP (caller only)
+	RoleSource string              `json:"role_source"`                         // certificate attribute, MSP fallback, policy assignments
+	Roles      []string            `json:"roles"`                               // Direct and inherited roles, nearest first
+	Object     string              `json:"object"`
+	Action     string              `json:"action"`
+	Resource   string              `json:"resource"` // Resource as evaluated, the chain's own for "*"
+	Rules      []casbin.RuleResult `json:"rules"`    // Rules of the identity's roles, matched or not
+	Decision   string              `json:"decision"` // allow, deny
+	Reason     string              `json:"reason"`
+}
+
+// ExplainAccess evaluates object/action/resource exactly as CheckPermission would, without
+// recording anything. An empty identity explains the caller; other identities (a subject such
+// as <msp>/CN=... or a role name) require rbac.policy view. A case:<id> or evidence:<id> resource
+// also applies recusal deny rules on that record.
+func (cc ChainContract) ExplainAccess(ctx contractapi.TransactionContextInterface,
+	object string, action string, resource string, identity string) (*AccessExplanation, error) {
+
+	subject, err := Subject(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if identity == "" {
+		identity = subject
+	}
+
+	enforcer, err := LoadEnforcer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	explanation := &AccessExplanation{
+		Identity:   identity,
+		RoleSource: "policy assignments",
+		Object:     object,
+		Action:     action,
+	}
+
+	if identity == subject {
+		role, source, err := ResolveRoleSource(ctx)
+		if err != nil {
+			return nil, err
+		}
+		enforcer.AddRoleForUser(subject, role)
+		explanation.Role = role
+		explanation.RoleSource = source
+	} else if err := cc.Access.CheckPermission(ctx, "rbac.policy", "view", "*"); err != nil {
+		return nil, err
+	}
+	explanation.Roles = append([]string{}, enforcer.GetImplicitRolesForUser(identity)...)
+
+	// Records are granted by the chain-wide rules unless a deny rule on the record overrides them
+	record := ""
+	if strings.HasPrefix(resource, caseResourcePrefix) || strings.HasPrefix(resource, evidenceResourcePrefix) {
+		record = resource
+		resource = "*"
+	}
+	if resource == "*" {
+		resource = cc.Access.Resource
+	}
+	explanation.Resource = resource
+
+	allowed, rules, err := enforcer.Explain(identity, object, action, resource)
+	if err != nil {
+		return nil, err
+	}
+	explanation.Rules = append([]casbin.RuleResult{}, rules...)
+
+	// The deciding rule is the first matching rule with the effect of the decision
+	effect := "deny"
+	if allowed {
+		effect = "allow"
+	}
+	var deciding []string
+	for _, result := range rules {
+		if result.Matched && result.Rule[len(result.Rule)-1] == effect {
+			deciding = result.Rule
+			break
+		}
+	}
+
+	if allowed && record != "" {
+		_, recordRules, err := enforcer.Explain(identity, object, action, record)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range recordRules {
+			if result.Matched && result.Rule[len(result.Rule)-1] == "deny" {
+				explanation.Rules = append(explanation.Rules, result)
+				if allowed {
+					allowed = false
+					deciding = result.Rule
+				}
+			}
+		}
+		explanation.Resource = record
+	}
+
+	switch {
+	case allowed:
+		explanation.Decision = "allow"
+		explanation.Reason = fmt.Sprintf("granted by %v", deciding)
+	case deciding != nil:
+		explanation.Decision = "deny"
+		explanation.Reason = fmt.Sprintf("denied by %v, which overrides every grant", deciding)
+	default:
+		explanation.Decision = "deny"
+		explanation.Reason = fmt.Sprintf("no rule grants %s on %s for %s to roles %v",
+			action, object, resource, explanation.Roles)
+	}
+
+	return explanation, nil
+}
+
+// ==============================================================================
+// POLICY STORE HELPERS
+// ==============================================================================
+
+// saveAccessPolicy stores the next version of the access policy
+func saveAccessPolicy(ctx contractapi.TransactionContextInterface,
+	policy *AccessPolicy, change string) error {
+
+	clientID, _ := ctx.GetClientIdentity().GetID()
+	now, err := TxNow(ctx)
+	if err != nil {
+		return err
+	}
+
+	policy.Version++
+	policy.UpdatedAt = now
+	policy.UpdatedBy = clientID
+	policy.Change = change
+
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("failed to marshal access policy: %v", err)
+	}
+	return ctx.GetStub().PutState(AccessPolicyKey, policyJSON)
+}
+
+// changeAccessPolicy applies a SystemAdmin change to the live policy
+func changeAccessPolicy(ctx contractapi.TransactionContextInterface,
+	change string, line []string, apply func(policy *casbin.Policy) error) error {
+
+	if err := CheckSystemAdmin(ctx); err != nil {
+		return err
+	}
+	return applyAccessPolicyChange(ctx, &AccessPolicyChange{Change: change, Line: line}, apply)
+}
+
+// applyAccessPolicyChange applies an already authorized change to the live policy, then
+// emits the AccessPolicyChanged event and writes the audit entry of the transaction.
+// Transactions that change the policy must not emit or audit anything else, since
+// each transaction keeps one event and one audit entry.
+func applyAccessPolicyChange(ctx contractapi.TransactionContextInterface,
+	event *AccessPolicyChange, apply func(policy *casbin.Policy) error) error {
+
+	current, err := LoadAccessPolicy(ctx)
+	if err != nil {
+		return err
+	}
+
+	policy := &casbin.Policy{Rules: current.Rules, Roles: current.Roles}
+	if err := apply(policy); err != nil {
+		return err
+	}
+
+	// Reject rules the model cannot evaluate before they reach the ledger
+	if _, err := NewEnforcer(policy); err != nil {
+		return fmt.Errorf("invalid access policy: %v", err)
+	}
+
+	current.Rules = policy.Rules
+	current.Roles = policy.Roles
+	if err := saveAccessPolicy(ctx, current, event.Change); err != nil {
+		return fmt.Errorf("failed to store access policy: %v", err)
+	}
+
+	// Emit event
+	event.Version = current.Version
+	event.By, _ = ctx.GetClientIdentity().GetID()
+	eventJSON, _ := json.Marshal(event)
+	ctx.GetStub().SetEvent("AccessPolicyChanged", eventJSON)
+
+	// Audit log
+	object, resourceID := "rbac.policy", "access_policy"
+	if event.Recusal != nil {
+		object, resourceID = "rbac.recusal", event.Recusal.ID
+	}
+	reason := fmt.Sprintf("%v applied, policy version %d", event.Line, current.Version)
+	if event.Reason != "" {
+		reason = fmt.Sprintf("%s (%s)", event.Reason, reason)
+	}
+	LogAudit(ctx, event.Change, object, resourceID, "success", reason)
+
+	return nil
+}
+
+// SeedAccessPolicy stores the embedded policy.csv as version 1 if the ledger has no policy yet
+func SeedAccessPolicy(ctx contractapi.TransactionContextInterface) error {
+	policy, err := LoadAccessPolicy(ctx)
+	if err != nil {
+		return err
+	}
+	if policy.Version > 0 {
+		return nil
+	}
+	return saveAccessPolicy(ctx, policy, "seeded from policy.csv")
+}
@@ -0,0 +1,599 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	sgxquote "github.com/aub/dfir-sgxquote"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// defaultAttestationQuorum is the number of distinct verifier MSPs required
+// when the ledger does not configure a threshold
+const defaultAttestationQuorum = 2
+
+// AttestationValidity is how long a single verifier's attestation counts towards the quorum
+const AttestationValidity = 24 * time.Hour
+
+// AttestationRecord is a single registration by a verifier, kept so auditors can
+// see who vouched for which attestation document
+type AttestationRecord struct {
+	ID             string `json:"id"`
+	DocType        string `json:"doc_type"` // always "attestation"
+	VerifierMSP    string `json:"verifier_msp"`
+	Verifier       string `json:"verifier"`        // Client ID
+	Subject        string `json:"subject"`         // <msp>/CN=...
+	AttestationDoc string `json:"attestation_doc"` // Base64 SGX quote
+	DocHash        string `json:"doc_hash"`        // SHA-256 of AttestationDoc
+	QuoteVersion   uint16 `json:"quote_version"`
+	MREnclave      string `json:"mr_enclave"`
+	MRSigner       string `json:"mr_signer"`
+	ISVSVN         uint16 `json:"isv_svn"`
+	RegisteredAt   int64  `json:"registered_at"`
+	ExpiresAt      int64  `json:"expires_at"`
+	TransactionID  string `json:"transaction_id"`
+}
+
+// SGXRootCA is the root certificate that the PCK certificate chain of every quote must chain to
+type SGXRootCA struct {
+	PEM         string `json:"pem"`
+	Subject     string `json:"subject"`
+	Fingerprint string `json:"fingerprint"` // SHA-256 of the DER certificate
+	UpdatedAt   int64  `json:"updated_at"`
+	UpdatedBy   string `json:"updated_by"`
+}
+
+// ==============================================================================
+// ATTESTATION QUORUM TRANSACTIONS
+// ==============================================================================
+
+// RegisterAttestation verifies an SGX quote (base64 in attestationDoc) and records the
+// caller's MSP as a verifier of the PRV configuration.
+// verifierMSP may be left empty; if given it must be the caller's own MSP.
+func (cc ChainContract) RegisterAttestation(ctx contractapi.TransactionContextInterface,
+	attestationDoc string, verifierMSP string) (*AttestationRecord, error) {
+
+	// Only verifier services can register attestations
+	if err := cc.checkAttestationVerifier(ctx); err != nil {
+		return nil, err
+	}
+
+	// Check PRV signature
+	if err := CheckPRVSignature(ctx); err != nil {
+		return nil, err
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	if verifierMSP != "" && verifierMSP != mspID {
+		return nil, fmt.Errorf("access denied: %s cannot register an attestation on behalf of %s", mspID, verifierMSP)
+	}
+	if attestationDoc == "" {
+		return nil, fmt.Errorf("attestation document is required")
+	}
+
+	config, err := LoadPRVConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if revocation := findRevocation(config, mspID); revocation != nil {
+		return nil, fmt.Errorf("access denied: verifier %s was revoked at %d: %s",
+			mspID, revocation.RevokedAt, revocation.Reason)
+	}
+
+	// Verify the SGX quote against the ledger's root CA and the PRV enclave measurements
+	quote, err := cc.verifyAttestationQuote(ctx, config, attestationDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := recordAttestation(ctx, mspID, attestationDoc, quote)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := VerifierEntry{
+		MSP:        mspID,
+		Verifier:   record.Verifier,
+		RecordID:   record.ID,
+		VerifiedAt: record.RegisteredAt,
+		ExpiresAt:  record.ExpiresAt,
+	}
+
+	// Replace any earlier attestation by the same MSP
+	verifiers := []VerifierEntry{entry}
+	for _, v := range config.VerifiedBy {
+		if v.MSP != mspID {
+			verifiers = append(verifiers, v)
+		}
+	}
+	config.VerifiedBy = verifiers
+
+	config.AttestationDoc = attestationDoc
+	if _, err := SavePRVConfig(ctx, config, fmt.Sprintf("attestation by %s (%s)", mspID, record.ID)); err != nil {
+		return nil, err
+	}
+	health := quorumHealth(config, record.RegisteredAt)
+
+	LogAudit(ctx, "RegisterAttestation", "attestation.config", record.ID, "success",
+		fmt.Sprintf("Attestation verified by %s, quorum %d/%d", mspID, len(health.ActiveMSPs), health.Threshold))
+
+	fmt.Printf("✓ Attestation registered by %s on the %s chain\n", mspID, cc.Access.Resource)
+	return record, nil
+}
+
+// GetPRVConfig retrieves the current PRV configuration and verifier quorum health
+func (cc ChainContract) GetPRVConfig(ctx contractapi.TransactionContextInterface) (*PRVConfig, error) {
+	config, err := LoadPRVConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := TxNow(ctx)
+	if err != nil {
+		return nil, err
+	}
+	config.Quorum = quorumHealth(config, now)
+
+	return config, nil
+}
+
+// SetAttestationQuorum sets the number of distinct, unexpired verifier MSPs
+// required before any transaction is accepted
+func (cc ChainContract) SetAttestationQuorum(ctx contractapi.TransactionContextInterface,
+	threshold int) error {
+
+	if err := CheckSystemAdmin(ctx); err != nil {
+		return err
+	}
+
+	// Check PRV signature
+	if err := CheckPRVSignature(ctx); err != nil {
+		return err
+	}
+
+	if threshold < 1 {
+		return fmt.Errorf("attestation quorum must be at least 1, got %d", threshold)
+	}
+
+	config, err := LoadPRVConfig(ctx)
+	if err != nil {
+		return err
+	}
+	previous := effectiveQuorum(config)
+	config.QuorumThreshold = threshold
+
+	configJSON, err := SavePRVConfig(ctx, config,
+		fmt.Sprintf("attestation quorum %d -> %d", previous, threshold))
+	if err != nil {
+		return err
+	}
+
+	// Emit event
+	ctx.GetStub().SetEvent("AttestationQuorumChanged", configJSON)
+
+	// Audit log
+	LogAudit(ctx, "SetAttestationQuorum", "attestation.config", "prv_config", "success",
+		fmt.Sprintf("Attestation quorum changed from %d to %d", previous, threshold))
+
+	return nil
+}
+
+// SetSGXRootCA stores the root certificate (e.g. the Intel SGX Root CA) trusted for quote verification
+func (cc ChainContract) SetSGXRootCA(ctx contractapi.TransactionContextInterface,
+	rootPEM string) error {
+
+	if err := CheckSystemAdmin(ctx); err != nil {
+		return err
+	}
+
+	// Check PRV signature
+	if err := CheckPRVSignature(ctx); err != nil {
+		return err
+	}
+
+	root, err := sgxquote.ParseRootCertificate([]byte(rootPEM))
+	if err != nil {
+		return err
+	}
+	if !root.IsCA {
+		return fmt.Errorf("certificate %s is not a CA certificate", root.Subject)
+	}
+
+	clientID, _ := ctx.GetClientIdentity().GetID()
+	now, err := TxNow(ctx)
+	if err != nil {
+		return err
+	}
+	fingerprint := sha256.Sum256(root.Raw)
+	rootCA := SGXRootCA{
+		PEM:         rootPEM,
+		Subject:     root.Subject.String(),
+		Fingerprint: hex.EncodeToString(fingerprint[:]),
+		UpdatedAt:   now,
+		UpdatedBy:   clientID,
+	}
+
+	rootJSON, err := json.Marshal(rootCA)
+	if err != nil {
+		return fmt.Errorf("failed to marshal SGX root CA: %v", err)
+	}
+	if err := ctx.GetStub().PutState(SGXRootCAKey, rootJSON); err != nil {
+		return fmt.Errorf("failed to store SGX root CA: %v", err)
+	}
+
+	// Emit event
+	ctx.GetStub().SetEvent("SGXRootCAChanged", rootJSON)
+
+	// Audit log
+	LogAudit(ctx, "SetSGXRootCA", "attestation.config", "sgx_root_ca", "success",
+		fmt.Sprintf("Trusted SGX root set to %s (%s)", rootCA.Subject, rootCA.Fingerprint))
+
+	return nil
+}
+
+// GetSGXRootCA returns the root certificate trusted for quote verification
+func (cc ChainContract) GetSGXRootCA(ctx contractapi.TransactionContextInterface) (*SGXRootCA, error) {
+	rootJSON, err := ctx.GetStub().GetState(SGXRootCAKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SGX root CA: %v", err)
+	}
+	if rootJSON == nil {
+		return nil, fmt.Errorf("SGX root CA not configured")
+	}
+
+	var rootCA SGXRootCA
+	if err := json.Unmarshal(rootJSON, &rootCA); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal SGX root CA: %v", err)
+	}
+	return &rootCA, nil
+}
+
+// QueryAttestations retrieves attestation registrations, optionally restricted to one verifier MSP
+func (cc ChainContract) QueryAttestations(ctx contractapi.TransactionContextInterface,
+	mspID string) ([]*AttestationRecord, error) {
+
+	// Check permission
+	if err := cc.Access.CheckPermission(ctx, "attestation.config", "view", "*"); err != nil {
+		return nil, err
+	}
+
+	selector := map[string]interface{}{"doc_type": "attestation"}
+	if mspID != "" {
+		selector["verifier_msp"] = mspID
+	}
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector": selector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetQueryResult(string(queryJSON))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attestations: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var results []*AttestationRecord
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var record AttestationRecord
+		if err := json.Unmarshal(queryResponse.Value, &record); err != nil {
+			return nil, err
+		}
+		results = append(results, &record)
+	}
+
+	return results, nil
+}
+
+// ==============================================================================
+// ATTESTATION HELPERS
+// ==============================================================================
+
+// InitPRVConfig stores the PRV configuration of a freshly deployed chain and seeds the
+// access policy and MSP role mapping. It runs once: InitLedger needs no permission, so
+// a chain that has a PRV config changes its key only through the quorum-approved
+// RotatePRVKey.
+func InitPRVConfig(ctx contractapi.TransactionContextInterface,
+	publicKeyHex string, mrenclaveHex string, mrsignerHex string) error {
+
+	existing, err := GetConfigState(ctx, PRVConfigKey)
+	if err != nil {
+		return fmt.Errorf("failed to read PRV config: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("PRV config already initialized; use RotatePRVKey to change the PRV key")
+	}
+
+	config := PRVConfig{
+		PublicKey:        publicKeyHex,
+		MREnclave:        mrenclaveHex,
+		MRSigner:         mrsignerHex,
+		AttestationDoc:   "",
+		VerifiedBy:       []VerifierEntry{},
+		QuorumThreshold:  defaultAttestationQuorum,
+		TCBLevel:         "1",
+		RevokedVerifiers: []VerifierRevocation{},
+	}
+	if _, err := SavePRVConfig(ctx, &config, "initialized"); err != nil {
+		return err
+	}
+
+	// Seed the on-ledger access policy from policy.csv
+	if err := SeedAccessPolicy(ctx); err != nil {
+		return fmt.Errorf("failed to seed access policy: %v", err)
+	}
+
+	// Seed the on-ledger MSP-to-role mapping from msp_roles.csv
+	if err := SeedMSPRoles(ctx); err != nil {
+		return fmt.Errorf("failed to seed MSP role mapping: %v", err)
+	}
+	return nil
+}
+
+// CheckAttestation verifies orderer/CA attestation is still valid
+func CheckAttestation(ctx contractapi.TransactionContextInterface) error {
+	config, err := LoadPRVConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	now, err := TxNow(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Require a quorum of distinct MSPs with unexpired attestations
+	health := quorumHealth(config, now)
+	if health.Met {
+		return nil
+	}
+
+	// Degraded mode: writes continue only under an active break-glass session
+	session, err := activeBreakGlass(ctx, now)
+	if err != nil {
+		return err
+	}
+	if session != nil {
+		return tagBreakGlassTransaction(ctx, session)
+	}
+
+	return fmt.Errorf("%s mode, writes blocked until attestation is renewed: insufficient verifiers: %d unexpired of %d required (active: %v, expired: %v)",
+		modeDegraded, len(health.ActiveMSPs), health.Threshold, health.ActiveMSPs, health.ExpiredMSPs)
+}
+
+// checkAttestationVerifier requires the caller to hold the AttestationVerifier role
+// and the policy to allow it to update the attestation config
+func (cc ChainContract) checkAttestationVerifier(ctx contractapi.TransactionContextInterface) error {
+	isVerifier, err := HasRole(ctx, "AttestationVerifier")
+	if err != nil {
+		return err
+	}
+	if !isVerifier {
+		role, _ := ResolveRole(ctx)
+		LogAudit(ctx, "RegisterAttestation", "attestation.config", "prv_config", "denied",
+			fmt.Sprintf("Role %s is not an attestation verifier", role))
+		return fmt.Errorf("access denied: only AttestationVerifier identities can register attestations")
+	}
+
+	return cc.Access.CheckPermission(ctx, "attestation.config", "update", "*")
+}
+
+// verifyAttestationQuote checks that attestationDoc is a base64 SGX quote signed under the
+// ledger's SGX root, for the PRV enclave in config, at or above its TCB level
+func (cc ChainContract) verifyAttestationQuote(ctx contractapi.TransactionContextInterface,
+	config *PRVConfig, attestationDoc string) (*sgxquote.Quote, error) {
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(attestationDoc))
+	if err != nil {
+		return nil, fmt.Errorf("attestation document must be a base64 SGX quote: %v", err)
+	}
+	quote, err := sgxquote.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SGX quote: %v", err)
+	}
+
+	rootCA, err := cc.GetSGXRootCA(ctx)
+	if err != nil {
+		return nil, err
+	}
+	root, err := sgxquote.ParseRootCertificate([]byte(rootCA.PEM))
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := TxNow(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := quote.Verify(root, time.Unix(now, 0)); err != nil {
+		return nil, fmt.Errorf("SGX quote verification failed: %v", err)
+	}
+
+	// The quote must come from the PRV enclave recorded in the config
+	if !strings.EqualFold(quote.Body.MREnclaveHex(), config.MREnclave) {
+		return nil, fmt.Errorf("quote MRENCLAVE %s does not match PRV config %s",
+			quote.Body.MREnclaveHex(), config.MREnclave)
+	}
+	if !strings.EqualFold(quote.Body.MRSignerHex(), config.MRSigner) {
+		return nil, fmt.Errorf("quote MRSIGNER %s does not match PRV config %s",
+			quote.Body.MRSignerHex(), config.MRSigner)
+	}
+
+	minSVN := 0
+	if config.TCBLevel != "" {
+		if minSVN, err = strconv.Atoi(config.TCBLevel); err != nil {
+			return nil, fmt.Errorf("invalid TCB level %q in PRV config: %v", config.TCBLevel, err)
+		}
+	}
+	if int(quote.Body.ISVSVN) < minSVN {
+		return nil, fmt.Errorf("quote ISV SVN %d is below TCB level %d", quote.Body.ISVSVN, minSVN)
+	}
+
+	return quote, nil
+}
+
+// recordAttestation stores the caller's registration as its own record
+func recordAttestation(ctx contractapi.TransactionContextInterface,
+	mspID string, attestationDoc string, quote *sgxquote.Quote) (*AttestationRecord, error) {
+
+	clientID, _ := ctx.GetClientIdentity().GetID()
+	subject, err := Subject(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	txID := ctx.GetStub().GetTxID()
+	now, err := TxNow(ctx)
+	if err != nil {
+		return nil, err
+	}
+	docHash := sha256.Sum256([]byte(attestationDoc))
+
+	record := AttestationRecord{
+		ID:             TxScopedID(ctx, "attestation"),
+		DocType:        "attestation",
+		VerifierMSP:    mspID,
+		Verifier:       clientID,
+		Subject:        subject,
+		AttestationDoc: attestationDoc,
+		DocHash:        hex.EncodeToString(docHash[:]),
+		QuoteVersion:   quote.Header.Version,
+		MREnclave:      quote.Body.MREnclaveHex(),
+		MRSigner:       quote.Body.MRSignerHex(),
+		ISVSVN:         quote.Body.ISVSVN,
+		RegisteredAt:   now,
+		ExpiresAt:      now + int64(AttestationValidity.Seconds()),
+		TransactionID:  txID,
+	}
+
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal attestation record: %v", err)
+	}
+	if err := ctx.GetStub().PutState(StateKey(RecordAttestation, record.ID), recordJSON); err != nil {
+		return nil, fmt.Errorf("failed to store attestation record: %v", err)
+	}
+
+	// Emit event
+	ctx.GetStub().SetEvent("AttestationRegistered", recordJSON)
+
+	return &record, nil
+}
+
+// LoadPRVConfig reads the PRV configuration from the ledger
+func LoadPRVConfig(ctx contractapi.TransactionContextInterface) (*PRVConfig, error) {
+	configJSON, err := GetConfigState(ctx, PRVConfigKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PRV config: %v", err)
+	}
+	if configJSON == nil {
+		return nil, fmt.Errorf("PRV config not initialized")
+	}
+
+	var config PRVConfig
+	if err := json.Unmarshal(configJSON, &config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal PRV config: %v", err)
+	}
+	if config.VerifiedBy == nil {
+		config.VerifiedBy = []VerifierEntry{}
+	}
+	if config.RevokedVerifiers == nil {
+		config.RevokedVerifiers = []VerifierRevocation{}
+	}
+	return &config, nil
+}
+
+// SavePRVConfig stores config as the next version, both as the live configuration and as
+// an immutable snapshot, without its computed quorum report
+func SavePRVConfig(ctx contractapi.TransactionContextInterface,
+	config *PRVConfig, change string) ([]byte, error) {
+
+	now, err := TxNow(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	config.Quorum = nil
+	config.Version++
+	config.EffectiveFrom = now
+	config.EffectiveTxID = ctx.GetStub().GetTxID()
+	config.Change = change
+	config.UpdatedAt = now
+	config.ExpiresAt = quorumHealth(config, now).ValidUntil
+
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal PRV config: %v", err)
+	}
+	if err := ctx.GetStub().PutState(PRVConfigKey, configJSON); err != nil {
+		return nil, fmt.Errorf("failed to store PRV config: %v", err)
+	}
+	if err := ctx.GetStub().PutState(PRVConfigVersionKey(config.Version), configJSON); err != nil {
+		return nil, fmt.Errorf("failed to store PRV config version %d: %v", config.Version, err)
+	}
+	return configJSON, nil
+}
+
+// effectiveQuorum returns the configured threshold, or the default for configs that predate it
+func effectiveQuorum(config *PRVConfig) int {
+	if config.QuorumThreshold < 1 {
+		return defaultAttestationQuorum
+	}
+	return config.QuorumThreshold
+}
+
+// quorumHealth counts the distinct MSPs whose attestation is unexpired at now
+func quorumHealth(config *PRVConfig, now int64) *QuorumHealth {
+	// Keep the latest expiry of each MSP so repeated entries count once
+	latest := map[string]int64{}
+	for _, v := range config.VerifiedBy {
+		if v.MSP == "" {
+			continue
+		}
+		if expires, ok := latest[v.MSP]; !ok || v.ExpiresAt > expires {
+			latest[v.MSP] = v.ExpiresAt
+		}
+	}
+
+	health := &QuorumHealth{
+		Threshold:   effectiveQuorum(config),
+		ActiveMSPs:  []string{},
+		ExpiredMSPs: []string{},
+		CheckedAt:   now,
+	}
+	var expiries []int64
+	for msp, expires := range latest {
+		if expires > now {
+			health.ActiveMSPs = append(health.ActiveMSPs, msp)
+			expiries = append(expiries, expires)
+		} else {
+			health.ExpiredMSPs = append(health.ExpiredMSPs, msp)
+		}
+	}
+	sort.Strings(health.ActiveMSPs)
+	sort.Strings(health.ExpiredMSPs)
+
+	// The quorum lapses when the threshold-th latest attestation expires
+	if len(expiries) >= health.Threshold {
+		sort.Slice(expiries, func(i, j int) bool { return expiries[i] > expiries[j] })
+		health.Met = true
+		health.ValidUntil = expiries[health.Threshold-1]
+	}
+	return health
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ==============================================================================
+// AUDIT LOG
+// ==============================================================================
+
+// AuditSelector is the CouchDB selector clause matching audit entries, including the
+// untyped entries written before 1.6.0, and no other record with a user_id and action
+// such as an access denial
+const AuditSelector = `"$or":[{"doc_type":"audit_log"},{"doc_type":{"$exists":false},"action":{"$exists":true}}]`
+
+// LogAudit creates an audit log entry for the current transaction
+func LogAudit(ctx contractapi.TransactionContextInterface,
+	action string, resource string, resourceID string, result string, reason string) error {
+
+	clientID, _ := ctx.GetClientIdentity().GetID()
+	mspID, _ := ctx.GetClientIdentity().GetMSPID()
+	txID := ctx.GetStub().GetTxID()
+	now, err := TxNow(ctx)
+	if err != nil {
+		return err
+	}
+
+	auditLog := AuditLog{
+		ID:            TxScopedID(ctx, "audit"),
+		DocType:       "audit_log",
+		UserID:        clientID,
+		Action:        action,
+		Resource:      resource,
+		ResourceID:    resourceID,
+		Result:        result,
+		Reason:        reason,
+		Timestamp:     now,
+		ClientMSP:     mspID,
+		TransactionID: txID,
+	}
+
+	auditJSON, err := json.Marshal(auditLog)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(StateKey(RecordAudit, auditLog.ID), auditJSON)
+}
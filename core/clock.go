@@ -0,0 +1,48 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ==============================================================================
+// DETERMINISTIC CLOCK AND IDENTIFIERS
+// ==============================================================================
+//
+// Every endorsing peer executes a proposal independently and the results only
+// validate if their write sets match, so nothing written to the ledger may come
+// from the peer's local clock or randomness. Timestamps are taken from the
+// proposal's transaction timestamp and generated identifiers from the
+// transaction ID, which the submitting client fixes for all endorsers. Chaincode
+// must not call time.Now.
+
+// TxNow returns the transaction timestamp in Unix seconds
+func TxNow(ctx contractapi.TransactionContextInterface) (int64, error) {
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	if txTimestamp == nil {
+		return 0, fmt.Errorf("failed to get transaction timestamp: proposal has no timestamp")
+	}
+	return txTimestamp.Seconds, nil
+}
+
+// TxTime returns the transaction timestamp as a time.Time
+func TxTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	now, err := TxNow(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(now, 0).UTC(), nil
+}
+
+// TxScopedID builds an identifier unique to the current transaction:
+// prefix, any qualifying parts and the transaction ID joined by "_"
+func TxScopedID(ctx contractapi.TransactionContextInterface, prefix string, parts ...string) string {
+	fields := append([]string{prefix}, parts...)
+	return strings.Join(append(fields, ctx.GetStub().GetTxID()), "_")
+}
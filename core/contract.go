@@ -0,0 +1,22 @@
+package core
+
+// ==============================================================================
+// TRANSACTIONS SHARED BY BOTH CHAINS
+// ==============================================================================
+//
+// The access policy, recusals, denial records, attestation quorum, PRV key,
+// operating mode, MSP role mapping, key migration and transfer configuration
+// work the same way on both chains. Each chaincode embeds a ChainContract, so
+// contractapi exposes its exported methods as transactions next to the chain's
+// own. Only transactions may be exported methods of ChainContract; helpers the
+// chaincodes call (CheckAttestation, CheckPRVSignature, CheckRecusal, ...) are
+// package functions.
+
+// ChainContract implements the shared transactions for one chain
+type ChainContract struct {
+	// Access scopes permission checks to the chain's policy rules
+	Access AccessControl
+
+	// TransferSource is the chain whose exports this chain imports, ChainHot or ChainCold
+	TransferSource string
+}
@@ -0,0 +1,19 @@
+// Package core is the data model and logic shared by the hot and cold DFIR
+// chaincodes: the record types both chains store, the world state key schema,
+// the deterministic clock, the audit log, the access-control engine (RBAC
+// against casbin/model.conf and the live on-ledger policy, plus the ABAC case
+// rules), the case package format that moves investigations between the
+// chains and the transactions both chains expose (ChainContract: access policy,
+// recusals, attestation, PRV key, operating mode and transfer configuration).
+// Keeping one copy means a case exported by one chain is read by the other with
+// exactly the fields it was written with.
+//
+// Functions that touch the ledger take the contract's transaction context, so
+// the chaincodes call them from their transactions after their own attestation
+// and signature checks (CheckAttestation, CheckPRVSignature). The chaincodes vendor this module, so re-run
+// `go mod vendor` in each chaincode after changing it, and raise Version with
+// any change to the record types or the package format.
+package core
+
+// Version is the release of the shared core both chaincodes are built with
+const Version = "1.7.0"
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ==============================================================================
+// KEY MIGRATION
+// ==============================================================================
+
+// KeyMove records one legacy key rewritten into the composite schema
+type KeyMove struct {
+	From       string   `json:"from"`
+	RecordType string   `json:"record_type"`
+	Attributes []string `json:"attributes"`
+}
+
+// KeyMigrationReport lists what MigrateKeys moved and what it left in place
+type KeyMigrationReport struct {
+	Moved         []KeyMove `json:"moved"`
+	Conflicts     []KeyMove `json:"conflicts"`    // Target key already holds a different record
+	Unrecognized  []string  `json:"unrecognized"` // Legacy keys no rule matched
+	Remaining     bool      `json:"remaining"`    // maxKeys was reached; call again to continue
+	TransactionID string    `json:"transaction_id"`
+}
+
+// MigrateKeys rewrites records stored under legacy flat keys into the composite key
+// schema and deletes the legacy keys. At most maxKeys records are moved per call
+// (0 = no limit) so large ledgers can be migrated over several transactions.
+// Conflicting and unrecognized keys are reported and left untouched.
+//
+// The PRV configuration is one of the records being moved, so no attestation
+// check is made; the caller must be SystemAdmin.
+func (cc ChainContract) MigrateKeys(ctx contractapi.TransactionContextInterface,
+	maxKeys int) (*KeyMigrationReport, error) {
+
+	// Check permission
+	if err := CheckSystemAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if maxKeys < 0 {
+		return nil, fmt.Errorf("maxKeys must not be negative")
+	}
+
+	report := &KeyMigrationReport{
+		Moved:         []KeyMove{},
+		Conflicts:     []KeyMove{},
+		Unrecognized:  []string{},
+		TransactionID: ctx.GetStub().GetTxID(),
+	}
+
+	// An open range returns only simple keys, which are exactly the legacy records
+	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read legacy keys: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		legacyKey := queryResponse.Key
+		if strings.HasPrefix(legacyKey, "\x00") {
+			continue
+		}
+
+		recordType, attributes, ok := ClassifyLegacyKey(legacyKey, queryResponse.Value)
+		if !ok {
+			report.Unrecognized = append(report.Unrecognized, legacyKey)
+			continue
+		}
+		move := KeyMove{From: legacyKey, RecordType: recordType, Attributes: attributes}
+
+		newKey, err := ctx.GetStub().CreateCompositeKey(recordType, attributes)
+		if err != nil {
+			report.Unrecognized = append(report.Unrecognized, legacyKey)
+			continue
+		}
+		existing, err := ctx.GetStub().GetState(newKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", legacyKey, err)
+		}
+		if existing != nil && string(existing) != string(queryResponse.Value) {
+			report.Conflicts = append(report.Conflicts, move)
+			continue
+		}
+
+		if maxKeys > 0 && len(report.Moved) == maxKeys {
+			report.Remaining = true
+			break
+		}
+		if err := ctx.GetStub().PutState(newKey, queryResponse.Value); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %v", legacyKey, err)
+		}
+		if err := ctx.GetStub().DelState(legacyKey); err != nil {
+			return nil, fmt.Errorf("failed to delete %s: %v", legacyKey, err)
+		}
+		report.Moved = append(report.Moved, move)
+	}
+
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal migration report: %v", err)
+	}
+
+	// Emit event
+	ctx.GetStub().SetEvent("KeysMigrated", reportJSON)
+
+	// Audit log
+	LogAudit(ctx, "MigrateKeys", "system", RecordConfig, "success",
+		fmt.Sprintf("Moved %d keys, %d conflicts, %d unrecognized, remaining: %t",
+			len(report.Moved), len(report.Conflicts), len(report.Unrecognized), report.Remaining))
+
+	return report, nil
+}
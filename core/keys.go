@@ -0,0 +1,241 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ==============================================================================
+// WORLD STATE KEY SCHEMA
+// ==============================================================================
+//
+// Every record is stored under a composite key whose object type names the
+// record type, followed by the attributes that identify the record:
+//   \x00investigation\x00INV-001\x00
+//   \x00export\x00INV-001\x00<txid>\x00
+//   \x00config\x00prv_config\x00
+// Both chains use the same schema, so a record has the same key wherever it is
+// stored and all records of a type can be listed with
+// GetStateByPartialCompositeKey. Records whose ID is generated from the
+// transaction (audit_<txid>, transfer_<evidence>_<txid>, ...) keep that ID as
+// their single attribute. Ledgers written before the schema existed are moved
+// over once with MigrateKeys.
+
+// Record types, the object type of every composite key
+const (
+	RecordConfig           = "config"
+	RecordInvestigation    = "investigation"
+	RecordEvidence         = "evidence"
+	RecordCustodyTransfer  = "custody_transfer"
+	RecordCaseTeam         = "case_team"
+	RecordRecusals         = "recusals"
+	RecordGUIDMapping      = "guid"
+	RecordAudit            = "audit"
+	RecordAccessDenial     = "access_denial"
+	RecordAttestation      = "attestation"
+	RecordPRVConfigVersion = "prv_config_version"
+	RecordPRVRotation      = "prv_rotation"
+	RecordBreakGlass       = "break_glass"
+	RecordBreakGlassTx     = "break_glass_tx"
+	RecordExport           = "export"
+	RecordImport           = "import"
+	RecordTransferComplete = "transfer_complete"
+	RecordCaseTransfer     = "case_transfer"
+	RecordArchiveMetadata  = "archive_metadata"
+	RecordImportSession    = "import_session"
+	RecordImportChunk      = "import_chunk"
+	RecordTransferAbort    = "transfer_abort"
+)
+
+// Transfer directions, the first attribute of transfer_complete and case_transfer keys
+const (
+	TransferArchive      = "archive"
+	TransferReactivation = "reactivation"
+)
+
+// StateKey returns the composite key of a record, the same key the stub's
+// CreateCompositeKey builds. Attributes that cannot be key parts (see
+// CheckKeyAttribute) yield the empty key, which the peer refuses to read or write.
+func StateKey(recordType string, attributes ...string) string {
+	key, err := shim.CreateCompositeKey(recordType, attributes)
+	if err != nil {
+		return ""
+	}
+	return key
+}
+
+// CheckKeyAttribute rejects identifiers that cannot be part of a composite key
+func CheckKeyAttribute(name string, value string) error {
+	if value == "" {
+		return fmt.Errorf("%s must not be empty", name)
+	}
+	if !utf8.ValidString(value) {
+		return fmt.Errorf("%s %q is not valid UTF-8", name, value)
+	}
+	if strings.ContainsRune(value, 0) || strings.ContainsRune(value, utf8.MaxRune) {
+		return fmt.Errorf("%s %q contains a reserved character", name, value)
+	}
+	return nil
+}
+
+// InvestigationKey returns the key of an investigation
+func InvestigationKey(id string) string {
+	return StateKey(RecordInvestigation, id)
+}
+
+// EvidenceKey returns the key of an evidence item
+func EvidenceKey(id string) string {
+	return StateKey(RecordEvidence, id)
+}
+
+// Configuration records, each a single record of type config
+var (
+	PRVConfigKey    = StateKey(RecordConfig, "prv_config")
+	SGXRootCAKey    = StateKey(RecordConfig, "sgx_root_ca")
+	AccessPolicyKey = StateKey(RecordConfig, "access_policy")
+	MSPRolesKey     = StateKey(RecordConfig, "msp_roles")
+	BreakGlassKey   = StateKey(RecordConfig, "break_glass_current")
+)
+
+// ==============================================================================
+// LEGACY KEYS
+// ==============================================================================
+
+// Legacy singleton keys and their configuration names
+var legacyConfigKeys = map[string]string{
+	"PRV_CONFIG":          "prv_config",
+	"SGX_ROOT_CA":         "sgx_root_ca",
+	"ACCESS_POLICY":       "access_policy",
+	"MSP_ROLE_MAP":        "msp_roles",
+	"BREAK_GLASS_CURRENT": "break_glass_current",
+}
+
+// Legacy key prefixes of records identified by a single attribute, longest first
+// where one prefix extends another
+var legacyPrefixes = []struct {
+	prefix     string
+	recordType string
+	keepPrefix bool // The legacy key is the record's ID and stays its attribute
+}{
+	{"PRV_CONFIG_V", RecordPRVConfigVersion, false},
+	{"PRV_ROTATION_", RecordPRVRotation, false},
+	{"CASE_TEAM_", RecordCaseTeam, false},
+	{"RECUSALS_", RecordRecusals, false},
+	{"GUID_", RecordGUIDMapping, false},
+	{"ARCHIVE_META_", RecordArchiveMetadata, false},
+	{"archive_metadata_", RecordArchiveMetadata, false},
+	{"archive_complete_", RecordTransferComplete, false},
+	{"reactivation_complete_", RecordTransferComplete, false},
+	{"investigation_", RecordInvestigation, false},
+	{"evidence_", RecordEvidence, false},
+	{"audit_", RecordAudit, true},
+	{"denial_", RecordAccessDenial, true},
+	{"attestation_", RecordAttestation, true},
+	{"breakglass_tx_", RecordBreakGlassTx, true},
+	{"breakglass_", RecordBreakGlass, true},
+	{"transfer_", RecordCustodyTransfer, true},
+}
+
+// ClassifyLegacyKey maps a pre-schema key to its record type and attributes.
+// Investigations and evidence were stored under their bare ID
+// and are recognized by their fields.
+func ClassifyLegacyKey(key string, value []byte) (string, []string, bool) {
+	if name, ok := legacyConfigKeys[key]; ok {
+		return RecordConfig, []string{name}, true
+	}
+
+	// export_<case>_<txid> and import_<case>_<txid>; case IDs may contain "_"
+	for _, recordType := range []string{RecordExport, RecordImport} {
+		if rest, ok := strings.CutPrefix(key, recordType+"_"); ok {
+			if i := strings.LastIndex(rest, "_"); i > 0 && i < len(rest)-1 {
+				return recordType, []string{rest[:i], rest[i+1:]}, true
+			}
+			return "", nil, false
+		}
+	}
+
+	for _, legacy := range legacyPrefixes {
+		rest, ok := strings.CutPrefix(key, legacy.prefix)
+		if !ok || rest == "" {
+			continue
+		}
+		switch {
+		case legacy.recordType == RecordTransferComplete && legacy.prefix == "archive_complete_":
+			return RecordTransferComplete, []string{TransferArchive, rest}, true
+		case legacy.recordType == RecordTransferComplete:
+			return RecordTransferComplete, []string{TransferReactivation, rest}, true
+		case legacy.keepPrefix:
+			return legacy.recordType, []string{key}, true
+		default:
+			return legacy.recordType, []string{rest}, true
+		}
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(value, &fields); err != nil {
+		return "", nil, false
+	}
+	var id string
+	if err := json.Unmarshal(fields["id"], &id); err != nil || id != key {
+		return "", nil, false
+	}
+	switch {
+	case hasFields(fields, "case_number", "investigating_org"):
+		return RecordInvestigation, []string{key}, true
+	case hasFields(fields, "case_id", "hash", "custodian"):
+		return RecordEvidence, []string{key}, true
+	}
+	return "", nil, false
+}
+
+// hasFields reports whether every name is a field of a decoded JSON object
+func hasFields(fields map[string]json.RawMessage, names ...string) bool {
+	for _, name := range names {
+		if _, ok := fields[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// GetConfigState reads a configuration record, falling back to its legacy key so
+// access control and attestation keep using the live records until MigrateKeys has run
+func GetConfigState(ctx contractapi.TransactionContextInterface, key string) ([]byte, error) {
+	value, err := ctx.GetStub().GetState(key)
+	if err != nil || value != nil {
+		return value, err
+	}
+
+	_, attributes, err := ctx.GetStub().SplitCompositeKey(key)
+	if err != nil || len(attributes) != 1 {
+		return nil, err
+	}
+	legacy := LegacyKeys(RecordConfig, attributes[0])
+	if len(legacy) == 0 {
+		return nil, nil
+	}
+	return ctx.GetStub().GetState(legacy[0])
+}
+
+// LegacyKeys returns the pre-schema keys a record may still be stored under, for
+// reads and history queries that span the migration
+func LegacyKeys(recordType string, id string) []string {
+	switch recordType {
+	case RecordConfig:
+		for legacyKey, name := range legacyConfigKeys {
+			if name == id {
+				return []string{legacyKey}
+			}
+		}
+	case RecordInvestigation:
+		return []string{id, "investigation_" + id}
+	case RecordEvidence:
+		return []string{id, "evidence_" + id}
+	}
+	return nil
+}
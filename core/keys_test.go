@@ -0,0 +1,55 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClassifyLegacyKey(t *testing.T) {
+	for key, want := range map[string][]string{
+		"PRV_CONFIG":                    {RecordConfig, "prv_config"},
+		"PRV_CONFIG_V0000000001":        {RecordPRVConfigVersion, "0000000001"},
+		"MSP_ROLE_MAP":                  {RecordConfig, "msp_roles"},
+		"investigation_INV_002":         {RecordInvestigation, "INV_002"},
+		"audit_tx1":                     {RecordAudit, "audit_tx1"},
+		"breakglass_tx_sess_tx2":        {RecordBreakGlassTx, "breakglass_tx_sess_tx2"},
+		"export_INV_002_tx2":            {RecordExport, "INV_002", "tx2"},
+		"import_INV-001_tx3":            {RecordImport, "INV-001", "tx3"},
+		"archive_complete_INV_002":      {RecordTransferComplete, TransferArchive, "INV_002"},
+		"reactivation_complete_INV-001": {RecordTransferComplete, TransferReactivation, "INV-001"},
+		"ARCHIVE_META_EVD-001":          {RecordArchiveMetadata, "EVD-001"},
+		"GUID_abc":                      {RecordGUIDMapping, "abc"},
+	} {
+		recordType, attributes, ok := ClassifyLegacyKey(key, nil)
+		if got := append([]string{recordType}, attributes...); !ok || !reflect.DeepEqual(got, want) {
+			t.Errorf("ClassifyLegacyKey(%q) = %v, %t, want %v", key, got, ok, want)
+		}
+	}
+}
+
+func TestClassifyLegacyKeyByFields(t *testing.T) {
+	for _, test := range []struct {
+		key, value string
+		want       []string
+	}{
+		{"INV-001", `{"id":"INV-001","case_number":"CASE-1","investigating_org":"LawEnforcement"}`,
+			[]string{RecordInvestigation, "INV-001"}},
+		{"EVD-001", `{"id":"EVD-001","case_id":"INV-001","hash":"abc","custodian":"x"}`,
+			[]string{RecordEvidence, "EVD-001"}},
+	} {
+		recordType, attributes, ok := ClassifyLegacyKey(test.key, []byte(test.value))
+		if got := append([]string{recordType}, attributes...); !ok || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ClassifyLegacyKey(%q) = %v, %t, want %v", test.key, got, ok, test.want)
+		}
+	}
+
+	for key, value := range map[string]string{
+		"notes":   `{"text":"not a record"}`,
+		"INV-003": `{"id":"INV-004","case_number":"CASE-4","investigating_org":"LawEnforcement"}`,
+		"export_": `{}`,
+	} {
+		if _, _, ok := ClassifyLegacyKey(key, []byte(value)); ok {
+			t.Errorf("ClassifyLegacyKey(%q) recognized a key no rule covers", key)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	casbin "github.com/aub/dfir-casbin"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ==============================================================================
+// MSP ROLE MAPPING TRANSACTIONS (SystemAdmin only)
+// ==============================================================================
+
+// SetMSPRole maps members of mspID to role, replacing any existing mapping
+func (cc ChainContract) SetMSPRole(ctx contractapi.TransactionContextInterface,
+	mspID string, role string) error {
+
+	if err := CheckSystemAdmin(ctx); err != nil {
+		return err
+	}
+
+	if mspID == "" || role == "" {
+		return fmt.Errorf("an MSP role mapping requires an MSP ID and a role")
+	}
+
+	// Only roles the access policy knows can be assigned
+	known, err := isPolicyRole(ctx, role)
+	if err != nil {
+		return err
+	}
+	if !known {
+		return fmt.Errorf("role %s is not defined in the access policy", role)
+	}
+
+	mapping, err := LoadMSPRoles(ctx)
+	if err != nil {
+		return err
+	}
+	previous := mapping.Roles[mspID]
+	mapping.Roles[mspID] = role
+
+	change := fmt.Sprintf("%s -> %s", mspID, role)
+	if previous != "" {
+		change = fmt.Sprintf("%s -> %s (was %s)", mspID, role, previous)
+	}
+	return saveMSPRoles(ctx, mapping, "SetMSPRole", change)
+}
+
+// RemoveMSPRole removes the mapping of mspID so its members fall back to the default role
+func (cc ChainContract) RemoveMSPRole(ctx contractapi.TransactionContextInterface,
+	mspID string) error {
+
+	if err := CheckSystemAdmin(ctx); err != nil {
+		return err
+	}
+
+	mapping, err := LoadMSPRoles(ctx)
+	if err != nil {
+		return err
+	}
+	previous, ok := mapping.Roles[mspID]
+	if !ok {
+		return fmt.Errorf("MSP %s has no role mapping", mspID)
+	}
+	delete(mapping.Roles, mspID)
+
+	return saveMSPRoles(ctx, mapping, "RemoveMSPRole",
+		fmt.Sprintf("%s removed (was %s, now %s)", mspID, previous, casbin.DefaultRole))
+}
+
+// GetMSPRoleMapping returns the live MSP-to-role mapping
+func (cc ChainContract) GetMSPRoleMapping(ctx contractapi.TransactionContextInterface) (*MSPRoleMapping, error) {
+	return LoadMSPRoles(ctx)
+}
+
+// ==============================================================================
+// MSP ROLE MAPPING HELPERS
+// ==============================================================================
+
+// saveMSPRoles stores the next version of the mapping, then emits an
+// MSPRoleMappingChanged event and writes an audit entry
+func saveMSPRoles(ctx contractapi.TransactionContextInterface,
+	mapping *MSPRoleMapping, action string, change string) error {
+
+	clientID, _ := ctx.GetClientIdentity().GetID()
+	now, err := TxNow(ctx)
+	if err != nil {
+		return err
+	}
+
+	mapping.Version++
+	mapping.UpdatedAt = now
+	mapping.UpdatedBy = clientID
+	mapping.Change = change
+
+	mappingJSON, err := json.Marshal(mapping)
+	if err != nil {
+		return fmt.Errorf("failed to marshal MSP role mapping: %v", err)
+	}
+	if err := ctx.GetStub().PutState(MSPRolesKey, mappingJSON); err != nil {
+		return fmt.Errorf("failed to store MSP role mapping: %v", err)
+	}
+
+	// Emit event
+	ctx.GetStub().SetEvent("MSPRoleMappingChanged", mappingJSON)
+
+	// Audit log
+	LogAudit(ctx, action, "rbac.msprole", "msp_roles", "success",
+		fmt.Sprintf("%s, mapping version %d", change, mapping.Version))
+
+	return nil
+}
+
+// SeedMSPRoles stores the embedded msp_roles.csv as version 1 if the ledger has no mapping yet
+func SeedMSPRoles(ctx contractapi.TransactionContextInterface) error {
+	mapping, err := LoadMSPRoles(ctx)
+	if err != nil {
+		return err
+	}
+	if mapping.Version > 0 {
+		return nil
+	}
+
+	msps := make([]string, 0, len(mapping.Roles))
+	for msp := range mapping.Roles {
+		msps = append(msps, msp)
+	}
+	sort.Strings(msps)
+	return saveMSPRoles(ctx, mapping, "SeedMSPRoles", fmt.Sprintf("seeded from msp_roles.csv: %v", msps))
+}
+
+// isPolicyRole reports whether role is the subject of a rule or part of a g line in the live policy
+func isPolicyRole(ctx contractapi.TransactionContextInterface, role string) (bool, error) {
+	if role == casbin.DefaultRole {
+		return true, nil
+	}
+
+	policy, err := LoadAccessPolicy(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, rule := range policy.Rules {
+		if len(rule) > 0 && rule[0] == role {
+			return true, nil
+		}
+	}
+	for _, link := range policy.Roles {
+		if len(link) == 2 && (link[0] == role || link[1] == role) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,544 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ==============================================================================
+// OPERATING MODES
+// ==============================================================================
+//
+// normal:      the attestation quorum is met and every transaction runs.
+// degraded:    the quorum has lapsed. Reads (which never check attestation) and
+//              attestation renewal still run; every other write is rejected.
+// break_glass: degraded, but a quorum of organization administrators has opened
+//              a time-limited session. Writes run again and each one is tagged
+//              with a breakglass_tx record. Once the session ends or expires an
+//              auditor must record a post-incident review before another session
+//              can be requested.
+
+// Operating modes reported by GetOperatingMode
+const (
+	modeNormal     = "normal"
+	modeDegraded   = "degraded"
+	modeBreakGlass = "break_glass"
+)
+
+const (
+	// breakGlassQuorum is the number of distinct MSP administrators that must approve a session
+	breakGlassQuorum = 2
+
+	// breakGlassApprovalWindow is how long a request collects approvals
+	breakGlassApprovalWindow = time.Hour
+
+	// maxBreakGlassDuration caps how long an approved session lasts
+	maxBreakGlassDuration = 4 * time.Hour
+)
+
+// BreakGlassApproval is one organization administrator's approval of a session
+type BreakGlassApproval struct {
+	MSP        string `json:"msp"`
+	Admin      string `json:"admin"`
+	ApprovedAt int64  `json:"approved_at"`
+}
+
+// BreakGlassReview is the post-incident review of a session
+type BreakGlassReview struct {
+	Reviewer    string `json:"reviewer"`
+	ReviewerMSP string `json:"reviewer_msp"`
+	Findings    string `json:"findings"`
+	ReviewedAt  int64  `json:"reviewed_at"`
+}
+
+// BreakGlassSession is a request to keep writing while the attestation quorum has lapsed
+type BreakGlassSession struct {
+	ID          string               `json:"id"`
+	DocType     string               `json:"doc_type"` // always "break_glass"
+	Reason      string               `json:"reason"`
+	RequestedBy string               `json:"requested_by"`
+	RequestedAt int64                `json:"requested_at"`
+	Duration    int64                `json:"duration"` // Seconds the session lasts once active
+	Approvals   []BreakGlassApproval `json:"approvals"`
+	Status      string               `json:"status"` // pending, active, ended, lapsed, reviewed
+	ActivatedAt int64                `json:"activated_at"`
+	ExpiresAt   int64                `json:"expires_at"`
+	EndedAt     int64                `json:"ended_at"`
+	EndedBy     string               `json:"ended_by"`
+	Review      *BreakGlassReview    `json:"review,omitempty" metadata:",optional"`
+}
+
+// BreakGlassTransaction tags a write that ran under a break-glass session
+type BreakGlassTransaction struct {
+	ID            string `json:"id"`
+	DocType       string `json:"doc_type"` // always "breakglass_tx"
+	SessionID     string `json:"session_id"`
+	TransactionID string `json:"transaction_id"`
+	Function      string `json:"function"`
+	UserID        string `json:"user_id"`
+	ClientMSP     string `json:"client_msp"`
+	Timestamp     int64  `json:"timestamp"`
+}
+
+// OperatingMode reports the current mode and what it is derived from
+type OperatingMode struct {
+	Mode       string             `json:"mode"`
+	Quorum     *QuorumHealth      `json:"quorum"`
+	BreakGlass *BreakGlassSession `json:"break_glass,omitempty" metadata:",optional"`
+	CheckedAt  int64              `json:"checked_at"`
+}
+
+// ==============================================================================
+// BREAK-GLASS TRANSACTIONS (organization administrators)
+// ==============================================================================
+
+// RequestBreakGlass opens a break-glass request lasting durationMinutes once approved.
+// The caller's approval counts towards the quorum.
+func (cc ChainContract) RequestBreakGlass(ctx contractapi.TransactionContextInterface,
+	reason string, durationMinutes int) (*BreakGlassSession, error) {
+
+	approval, err := orgAdminApproval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if reason == "" {
+		return nil, fmt.Errorf("a break-glass request requires a reason")
+	}
+	duration := time.Duration(durationMinutes) * time.Minute
+	if duration <= 0 || duration > maxBreakGlassDuration {
+		return nil, fmt.Errorf("break-glass duration must be between 1 and %d minutes", int(maxBreakGlassDuration.Minutes()))
+	}
+
+	// Only one session at a time, and the previous one must have been reviewed
+	current, err := currentBreakGlass(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil {
+		switch status := breakGlassStatus(current, approval.ApprovedAt); status {
+		case "pending", "active":
+			return nil, fmt.Errorf("break-glass session %s is already %s", current.ID, status)
+		case "lapsed":
+			current.Status = "lapsed"
+			if err := saveBreakGlass(ctx, current); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("break-glass session %s awaits its post-incident review", current.ID)
+		}
+	}
+
+	session := &BreakGlassSession{
+		ID:          TxScopedID(ctx, "breakglass"),
+		DocType:     "break_glass",
+		Reason:      reason,
+		RequestedBy: approval.Admin,
+		RequestedAt: approval.ApprovedAt,
+		Duration:    int64(duration.Seconds()),
+		Approvals:   []BreakGlassApproval{*approval},
+		Status:      "pending",
+	}
+	activateBreakGlass(session, approval.ApprovedAt)
+
+	if err := saveBreakGlass(ctx, session); err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().PutState(BreakGlassKey, []byte(session.ID)); err != nil {
+		return nil, fmt.Errorf("failed to store current break-glass session: %v", err)
+	}
+
+	// Emit event
+	sessionJSON, _ := json.Marshal(session)
+	ctx.GetStub().SetEvent("BreakGlassRequested", sessionJSON)
+
+	// Audit log
+	LogAudit(ctx, "RequestBreakGlass", "attestation.breakglass", session.ID, "success",
+		fmt.Sprintf("Break-glass requested by %s for %d minutes: %s", approval.MSP, durationMinutes, reason))
+
+	return session, nil
+}
+
+// ApproveBreakGlass adds the caller's organization to a pending request and
+// activates it once breakGlassQuorum distinct MSPs have approved
+func (cc ChainContract) ApproveBreakGlass(ctx contractapi.TransactionContextInterface,
+	sessionID string) (*BreakGlassSession, error) {
+
+	approval, err := orgAdminApproval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := loadBreakGlass(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if status := breakGlassStatus(session, approval.ApprovedAt); status != "pending" {
+		return nil, fmt.Errorf("break-glass session %s is %s, not pending", sessionID, status)
+	}
+	for _, existing := range session.Approvals {
+		if existing.MSP == approval.MSP {
+			return nil, fmt.Errorf("break-glass session %s already approved by %s", sessionID, approval.MSP)
+		}
+	}
+
+	session.Approvals = append(session.Approvals, *approval)
+	activateBreakGlass(session, approval.ApprovedAt)
+
+	if err := saveBreakGlass(ctx, session); err != nil {
+		return nil, err
+	}
+
+	// Emit event
+	sessionJSON, _ := json.Marshal(session)
+	ctx.GetStub().SetEvent("BreakGlassApproved", sessionJSON)
+
+	// Audit log
+	LogAudit(ctx, "ApproveBreakGlass", "attestation.breakglass", sessionID, "success",
+		fmt.Sprintf("Approved by %s (%d/%d), status %s", approval.MSP, len(session.Approvals), breakGlassQuorum, session.Status))
+
+	return session, nil
+}
+
+// EndBreakGlass closes an active or pending session before it expires
+func (cc ChainContract) EndBreakGlass(ctx contractapi.TransactionContextInterface,
+	sessionID string) error {
+
+	approval, err := orgAdminApproval(ctx)
+	if err != nil {
+		return err
+	}
+
+	session, err := loadBreakGlass(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	status := breakGlassStatus(session, approval.ApprovedAt)
+	if status != "pending" && status != "active" {
+		return fmt.Errorf("break-glass session %s is already %s", sessionID, status)
+	}
+
+	session.Status = "ended"
+	session.EndedAt = approval.ApprovedAt
+	session.EndedBy = approval.Admin
+	if err := saveBreakGlass(ctx, session); err != nil {
+		return err
+	}
+
+	// Emit event
+	sessionJSON, _ := json.Marshal(session)
+	ctx.GetStub().SetEvent("BreakGlassEnded", sessionJSON)
+
+	// Audit log
+	LogAudit(ctx, "EndBreakGlass", "attestation.breakglass", sessionID, "success",
+		fmt.Sprintf("Break-glass session ended by %s", approval.MSP))
+
+	return nil
+}
+
+// RecordBreakGlassReview records the post-incident review of a finished session,
+// allowing the next break-glass request
+func (cc ChainContract) RecordBreakGlassReview(ctx contractapi.TransactionContextInterface,
+	sessionID string, findings string) error {
+
+	// Check permission
+	if err := cc.Access.CheckPermission(ctx, "audits.breakglass", "review", "*"); err != nil {
+		return err
+	}
+
+	if findings == "" {
+		return fmt.Errorf("a post-incident review requires findings")
+	}
+
+	session, err := loadBreakGlass(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	now, err := TxNow(ctx)
+	if err != nil {
+		return err
+	}
+	status := breakGlassStatus(session, now)
+	if status != "ended" && status != "expired" {
+		return fmt.Errorf("break-glass session %s is %s and cannot be reviewed", sessionID, status)
+	}
+
+	clientID, _ := ctx.GetClientIdentity().GetID()
+	mspID, _ := ctx.GetClientIdentity().GetMSPID()
+	session.Status = "reviewed"
+	session.Review = &BreakGlassReview{
+		Reviewer:    clientID,
+		ReviewerMSP: mspID,
+		Findings:    findings,
+		ReviewedAt:  now,
+	}
+	if err := saveBreakGlass(ctx, session); err != nil {
+		return err
+	}
+
+	// Release the slot once the current session has been reviewed
+	currentID, err := ctx.GetStub().GetState(BreakGlassKey)
+	if err != nil {
+		return fmt.Errorf("failed to read current break-glass session: %v", err)
+	}
+	if string(currentID) == sessionID {
+		if err := ctx.GetStub().DelState(BreakGlassKey); err != nil {
+			return fmt.Errorf("failed to clear current break-glass session: %v", err)
+		}
+	}
+
+	// Emit event
+	sessionJSON, _ := json.Marshal(session)
+	ctx.GetStub().SetEvent("BreakGlassReviewed", sessionJSON)
+
+	// Audit log
+	LogAudit(ctx, "RecordBreakGlassReview", "audits.breakglass", sessionID, "success",
+		fmt.Sprintf("Post-incident review recorded by %s", mspID))
+
+	return nil
+}
+
+// ==============================================================================
+// OPERATING MODE QUERIES
+// ==============================================================================
+
+// GetOperatingMode reports whether the chain is in normal, degraded or break-glass mode
+func (cc ChainContract) GetOperatingMode(ctx contractapi.TransactionContextInterface) (*OperatingMode, error) {
+	config, err := LoadPRVConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now, err := TxNow(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mode := &OperatingMode{
+		Mode:      modeNormal,
+		Quorum:    quorumHealth(config, now),
+		CheckedAt: now,
+	}
+	if mode.BreakGlass, err = currentBreakGlass(ctx); err != nil {
+		return nil, err
+	}
+	if !mode.Quorum.Met {
+		mode.Mode = modeDegraded
+		if mode.BreakGlass != nil && breakGlassStatus(mode.BreakGlass, now) == "active" {
+			mode.Mode = modeBreakGlass
+		}
+	}
+	return mode, nil
+}
+
+// GetBreakGlassSession retrieves a break-glass session
+func (cc ChainContract) GetBreakGlassSession(ctx contractapi.TransactionContextInterface,
+	sessionID string) (*BreakGlassSession, error) {
+
+	return loadBreakGlass(ctx, sessionID)
+}
+
+// QueryBreakGlassTransactions lists the writes tagged with a break-glass session
+func (cc ChainContract) QueryBreakGlassTransactions(ctx contractapi.TransactionContextInterface,
+	sessionID string) ([]*BreakGlassTransaction, error) {
+
+	// Check permission
+	if err := cc.Access.CheckPermission(ctx, "audits.breakglass", "view", "*"); err != nil {
+		return nil, err
+	}
+
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{
+			"doc_type":   "breakglass_tx",
+			"session_id": sessionID,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetQueryResult(string(queryJSON))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query break-glass transactions: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var results []*BreakGlassTransaction
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var tagged BreakGlassTransaction
+		if err := json.Unmarshal(queryResponse.Value, &tagged); err != nil {
+			return nil, err
+		}
+		results = append(results, &tagged)
+	}
+
+	return results, nil
+}
+
+// ==============================================================================
+// OPERATING MODE HELPERS
+// ==============================================================================
+
+// activeBreakGlass returns the current session if it is active at now, or nil
+func activeBreakGlass(ctx contractapi.TransactionContextInterface,
+	now int64) (*BreakGlassSession, error) {
+
+	session, err := currentBreakGlass(ctx)
+	if err != nil || session == nil {
+		return nil, err
+	}
+	if breakGlassStatus(session, now) != "active" {
+		return nil, nil
+	}
+	return session, nil
+}
+
+// tagBreakGlassTransaction records that the current transaction ran under session
+func tagBreakGlassTransaction(ctx contractapi.TransactionContextInterface,
+	session *BreakGlassSession) error {
+
+	clientID, _ := ctx.GetClientIdentity().GetID()
+	mspID, _ := ctx.GetClientIdentity().GetMSPID()
+	txID := ctx.GetStub().GetTxID()
+	now, err := TxNow(ctx)
+	if err != nil {
+		return err
+	}
+
+	function := ""
+	if args := ctx.GetStub().GetStringArgs(); len(args) > 0 {
+		function = args[0]
+	}
+
+	tagged := BreakGlassTransaction{
+		ID:            TxScopedID(ctx, "breakglass_tx"),
+		DocType:       "breakglass_tx",
+		SessionID:     session.ID,
+		TransactionID: txID,
+		Function:      function,
+		UserID:        clientID,
+		ClientMSP:     mspID,
+		Timestamp:     now,
+	}
+
+	taggedJSON, err := json.Marshal(tagged)
+	if err != nil {
+		return fmt.Errorf("failed to marshal break-glass tag: %v", err)
+	}
+	if err := ctx.GetStub().PutState(StateKey(RecordBreakGlassTx, tagged.ID), taggedJSON); err != nil {
+		return fmt.Errorf("failed to store break-glass tag: %v", err)
+	}
+	return nil
+}
+
+// orgAdminApproval checks that the caller is an administrator of its organization
+// (Fabric NodeOU "admin") and returns its approval stamped with the transaction time
+func orgAdminApproval(ctx contractapi.TransactionContextInterface) (*BreakGlassApproval, error) {
+	cert, err := ctx.GetClientIdentity().GetX509Certificate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client certificate: %v", err)
+	}
+	if cert == nil {
+		return nil, fmt.Errorf("client certificate not available")
+	}
+
+	isAdmin := false
+	for _, ou := range cert.Subject.OrganizationalUnit {
+		if strings.EqualFold(ou, "admin") {
+			isAdmin = true
+		}
+	}
+	if !isAdmin {
+		return nil, fmt.Errorf("access denied: only organization administrators can manage break-glass sessions")
+	}
+
+	clientID, _ := ctx.GetClientIdentity().GetID()
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	now, err := TxNow(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BreakGlassApproval{
+		MSP:        mspID,
+		Admin:      clientID,
+		ApprovedAt: now,
+	}, nil
+}
+
+// activateBreakGlass starts a pending session once it has enough approvals
+func activateBreakGlass(session *BreakGlassSession, now int64) {
+	if session.Status == "pending" && len(session.Approvals) >= breakGlassQuorum {
+		session.Status = "active"
+		session.ActivatedAt = now
+		session.ExpiresAt = now + session.Duration
+	}
+}
+
+// breakGlassStatus is the stored status adjusted for time: an active session past
+// its expiry is "expired" and a pending one past the approval window is "lapsed"
+func breakGlassStatus(session *BreakGlassSession, now int64) string {
+	switch {
+	case session.Status == "active" && now >= session.ExpiresAt:
+		return "expired"
+	case session.Status == "pending" && now >= session.RequestedAt+int64(breakGlassApprovalWindow.Seconds()):
+		return "lapsed"
+	}
+	return session.Status
+}
+
+// currentBreakGlass returns the session that has not yet been reviewed, or nil
+func currentBreakGlass(ctx contractapi.TransactionContextInterface) (*BreakGlassSession, error) {
+	sessionID, err := ctx.GetStub().GetState(BreakGlassKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read current break-glass session: %v", err)
+	}
+	if sessionID == nil {
+		return nil, nil
+	}
+	return loadBreakGlass(ctx, string(sessionID))
+}
+
+// loadBreakGlass reads a break-glass session
+func loadBreakGlass(ctx contractapi.TransactionContextInterface,
+	sessionID string) (*BreakGlassSession, error) {
+
+	sessionJSON, err := ctx.GetStub().GetState(StateKey(RecordBreakGlass, sessionID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read break-glass session: %v", err)
+	}
+	if sessionJSON == nil {
+		return nil, fmt.Errorf("break-glass session %s does not exist", sessionID)
+	}
+
+	var session BreakGlassSession
+	if err := json.Unmarshal(sessionJSON, &session); err != nil || session.DocType != "break_glass" {
+		return nil, fmt.Errorf("%s is not a break-glass session", sessionID)
+	}
+	return &session, nil
+}
+
+// saveBreakGlass stores a break-glass session under its ID
+func saveBreakGlass(ctx contractapi.TransactionContextInterface,
+	session *BreakGlassSession) error {
+
+	sessionJSON, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to marshal break-glass session: %v", err)
+	}
+	if err := ctx.GetStub().PutState(StateKey(RecordBreakGlass, session.ID), sessionJSON); err != nil {
+		return fmt.Errorf("failed to store break-glass session: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package core
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// ==============================================================================
+// PACKAGE FORMAT
+// ==============================================================================
+//
+// Every CaseExportPackage carries the format_version it was written with and is
+// checked against the JSON Schema of that version (schemas/package_v<N>.json)
+// before the importing chain reads it. Packages of an older version are then
+// converted step by step with packageUpgrades and checked against the current
+// schema again, so a chain running this release imports packages written by
+// any earlier release. Packages exported before format_version existed are
+// version 1.
+//
+// Changing CaseExportPackage, Investigation or Evidence means adding a schema
+// for a new version, raising PackageFormatVersion and adding the upgrade from
+// the previous version.
+
+// PackageFormatVersion is the format_version written by ExportCase
+const PackageFormatVersion = 5
+
+//go:embed schemas/package_v*.json
+var packageSchemaFiles embed.FS
+
+var (
+	packageSchemasOnce sync.Once
+	packageSchemas     map[int]*gojsonschema.Schema
+	packageSchemasErr  error
+)
+
+// packageUpgrades converts a decoded package of the key version to the next version
+var packageUpgrades = map[int]func(pkg map[string]interface{}){
+	1: upgradePackageV1,
+	2: upgradePackageV2,
+	3: upgradePackageV3,
+	4: upgradePackageV4,
+}
+
+// upgradePackageV1 adds the record fields that version 1 packages may lack. Hot
+// chain releases before the core module wrote no archive or source fields, and
+// releases before the ABAC rules wrote no classification.
+func upgradePackageV1(pkg map[string]interface{}) {
+	if investigation, ok := pkg["investigation"].(map[string]interface{}); ok {
+		setMissing(investigation, map[string]interface{}{
+			"case_name": "", "investigating_org": "", "lead_investigator": "",
+			"opened_date": 0, "closed_date": 0, "archived_date": 0, "description": "",
+			"evidence_count": 0, "created_by": "", "archived_by": "", "created_at": 0,
+			"updated_at": 0, "archived_at": 0, "classification": "", "jurisdiction": "",
+			"owning_unit": "",
+		})
+	}
+
+	evidence, _ := pkg["evidence"].([]interface{})
+	for _, item := range evidence {
+		if record, ok := item.(map[string]interface{}); ok {
+			setMissing(record, map[string]interface{}{
+				"type": "", "description": "", "ipfs_hash": "", "location": "", "custodian": "",
+				"collected_by": "", "timestamp": 0, "status": "", "metadata": "", "file_size": 0,
+				"chain_type": "", "transaction_id": "", "custody_chain_ref": "", "created_by": "",
+				"archived_by": "", "created_at": 0, "updated_at": 0, "archived_at": 0,
+				"source_chain": "", "source_tx_id": "",
+			})
+		}
+	}
+	if evidence == nil {
+		pkg["evidence"] = []interface{}{}
+	}
+
+	setMissing(pkg, map[string]interface{}{"court_order": "", "exported_at": 0, "exported_by": ""})
+	pkg["format_version"] = 2
+}
+
+// upgradePackageV2 only raises the version; version 3 added the optional proof
+func upgradePackageV2(pkg map[string]interface{}) {
+	pkg["format_version"] = 3
+}
+
+// upgradePackageV3 adds expires_at. Packages exported before transfers had a
+// deadline carry none, so the importing chain applies its own transfer timeout.
+func upgradePackageV3(pkg map[string]interface{}) {
+	setMissing(pkg, map[string]interface{}{"expires_at": 0})
+	pkg["format_version"] = 4
+}
+
+// upgradePackageV4 only raises the version; version 5 added the optional manifest
+func upgradePackageV4(pkg map[string]interface{}) {
+	pkg["format_version"] = 5
+}
+
+// setMissing adds each default whose field is absent from record
+func setMissing(record map[string]interface{}, defaults map[string]interface{}) {
+	for field, value := range defaults {
+		if _, ok := record[field]; !ok {
+			record[field] = value
+		}
+	}
+}
+
+// loadPackageSchemas compiles the embedded schema of every format version
+func loadPackageSchemas() (map[int]*gojsonschema.Schema, error) {
+	packageSchemasOnce.Do(func() {
+		packageSchemas = map[int]*gojsonschema.Schema{}
+		for version := 1; version <= PackageFormatVersion; version++ {
+			schemaJSON, err := packageSchemaFiles.ReadFile(fmt.Sprintf("schemas/package_v%d.json", version))
+			if err != nil {
+				packageSchemasErr = fmt.Errorf("missing schema for package format version %d: %v", version, err)
+				return
+			}
+			schema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(schemaJSON))
+			if err != nil {
+				packageSchemasErr = fmt.Errorf("invalid schema for package format version %d: %v", version, err)
+				return
+			}
+			packageSchemas[version] = schema
+		}
+	})
+	return packageSchemas, packageSchemasErr
+}
+
+// DecodePackage validates a package against the schema of its format version,
+// upgrades it to PackageFormatVersion and decodes it. The error of a package that
+// does not validate lists every record that failed and why.
+func DecodePackage(packageJSON []byte) (*CaseExportPackage, error) {
+	var pkg map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(packageJSON))
+	decoder.UseNumber()
+	if err := decoder.Decode(&pkg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal export package: %v", err)
+	}
+	if pkg == nil {
+		return nil, fmt.Errorf("export package is empty")
+	}
+
+	version, err := packageFormatVersion(pkg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validatePackage(pkg, version); err != nil {
+		return nil, err
+	}
+	if version < PackageFormatVersion {
+		for ; version < PackageFormatVersion; version++ {
+			packageUpgrades[version](pkg)
+		}
+		if err := validatePackage(pkg, version); err != nil {
+			return nil, fmt.Errorf("upgraded %v", err)
+		}
+	}
+
+	upgradedJSON, err := json.Marshal(pkg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal export package: %v", err)
+	}
+	var exportPackage CaseExportPackage
+	if err := json.Unmarshal(upgradedJSON, &exportPackage); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal export package: %v", err)
+	}
+	return &exportPackage, nil
+}
+
+// ValidatePackage checks an encoded package against the schema of the current format version
+func ValidatePackage(packageJSON []byte) error {
+	var pkg map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(packageJSON))
+	decoder.UseNumber()
+	if err := decoder.Decode(&pkg); err != nil {
+		return fmt.Errorf("failed to unmarshal export package: %v", err)
+	}
+	return validatePackage(pkg, PackageFormatVersion)
+}
+
+// packageFormatVersion reads format_version, which version 1 packages do not have
+func packageFormatVersion(pkg map[string]interface{}) (int, error) {
+	raw, ok := pkg["format_version"]
+	if !ok {
+		return 1, nil
+	}
+	number, ok := raw.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("invalid package format_version: %v", raw)
+	}
+	version, err := strconv.Atoi(number.String())
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid package format_version: %s", number)
+	}
+	if version > PackageFormatVersion {
+		return 0, fmt.Errorf("unsupported package format version %d, this chain reads versions up to %d",
+			version, PackageFormatVersion)
+	}
+	return version, nil
+}
+
+// validatePackage checks a decoded package against the schema of version
+func validatePackage(pkg map[string]interface{}, version int) error {
+	schemas, err := loadPackageSchemas()
+	if err != nil {
+		return err
+	}
+
+	result, err := schemas[version].Validate(gojsonschema.NewGoLoader(pkg))
+	if err != nil {
+		return fmt.Errorf("failed to validate export package: %v", err)
+	}
+	if result.Valid() {
+		return nil
+	}
+
+	// Group the schema errors by the record they were found in
+	problems := map[string][]string{}
+	var records []string
+	for _, resultErr := range result.Errors() {
+		record, field := packageRecord(pkg, resultErr.Field())
+		description := resultErr.Description()
+		if field != "" {
+			description = field + ": " + description
+		}
+		if _, seen := problems[record]; !seen {
+			records = append(records, record)
+		}
+		problems[record] = append(problems[record], description)
+	}
+	sort.Strings(records)
+
+	invalid := make([]string, 0, len(records))
+	for _, record := range records {
+		invalid = append(invalid, fmt.Sprintf("%s (%s)", record, strings.Join(problems[record], ", ")))
+	}
+	return fmt.Errorf("export package does not match format version %d, %d records did not validate: %s",
+		version, len(records), strings.Join(invalid, "; "))
+}
+
+// packageRecord names the record a schema error path points into and returns
+// the rest of the path. Paths look like "evidence.3.hash" or "investigation".
+func packageRecord(pkg map[string]interface{}, path string) (string, string) {
+	parts := strings.Split(path, ".")
+	switch parts[0] {
+	case "investigation":
+		record := "investigation"
+		if investigation, ok := pkg["investigation"].(map[string]interface{}); ok {
+			if id, ok := investigation["id"].(string); ok && id != "" {
+				record += " " + id
+			}
+		}
+		return record, strings.Join(parts[1:], ".")
+	case "evidence":
+		if len(parts) < 2 {
+			return "evidence", ""
+		}
+		record := "evidence[" + parts[1] + "]"
+		evidence, _ := pkg["evidence"].([]interface{})
+		if index, err := strconv.Atoi(parts[1]); err == nil && index < len(evidence) {
+			if item, ok := evidence[index].(map[string]interface{}); ok {
+				if id, ok := item["id"].(string); ok && id != "" {
+					record += " " + id
+				}
+			}
+		}
+		return record, strings.Join(parts[2:], ".")
+	case "(root)":
+		return "package", ""
+	default:
+		return "package", path
+	}
+}
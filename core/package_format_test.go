@@ -0,0 +1,116 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// packageV1 is an archive package written by the hot chain before format_version
+// existed: no archive, source or classification fields and no evidence list
+const packageV1 = `{
+	"investigation": {"id": "INV-001", "case_number": "CASE-1", "case_name": "Case", "status": "transferring_to_archive",
+		"opened_date": 100, "closed_date": 200, "created_by": "alice", "created_at": 100, "updated_at": 200},
+	"evidence": [{"id": "EVD-001", "case_id": "INV-001", "hash": "abc", "custodian": "bob", "file_size": 42,
+		"chain_type": "hot", "transaction_id": "tx-evidence"}],
+	"court_order": "ORDER-1",
+	"exported_at": 300,
+	"exported_by": "court",
+	"source_chain": "hot",
+	"transfer_tx_id": "tx-export"
+}`
+
+func TestDecodePackageUpgradesVersion1(t *testing.T) {
+	exportPackage, err := DecodePackage([]byte(packageV1))
+	if err != nil {
+		t.Fatalf("version 1 package rejected: %v", err)
+	}
+	if exportPackage.FormatVersion != PackageFormatVersion {
+		t.Errorf("format version = %d, want %d", exportPackage.FormatVersion, PackageFormatVersion)
+	}
+	if got := exportPackage.Investigation; got.CaseNumber != "CASE-1" || got.ClosedDate != 200 || got.Classification != "" {
+		t.Errorf("upgraded investigation = %+v", got)
+	}
+	if len(exportPackage.Evidence) != 1 || exportPackage.Evidence[0].FileSize != 42 || exportPackage.Evidence[0].Hash != "abc" {
+		t.Errorf("upgraded evidence = %+v", exportPackage.Evidence)
+	}
+
+	// A version 1 package of a case without evidence has a null list
+	var pkg map[string]interface{}
+	json.Unmarshal([]byte(packageV1), &pkg)
+	pkg["evidence"] = nil
+	packageJSON, _ := json.Marshal(pkg)
+	exportPackage, err = DecodePackage(packageJSON)
+	if err != nil {
+		t.Fatalf("version 1 package without evidence rejected: %v", err)
+	}
+	if exportPackage.Evidence == nil || len(exportPackage.Evidence) != 0 {
+		t.Errorf("upgraded evidence = %#v, want an empty list", exportPackage.Evidence)
+	}
+}
+
+func TestDecodePackageListsInvalidRecords(t *testing.T) {
+	packageJSON, err := json.Marshal(ArchiveFlow.NewPackage(Investigation{ID: "INV-001", CaseNumber: "CASE-1"},
+		[]Evidence{{ID: "EVD-001", CaseID: "INV-001"}, {ID: "EVD-002", CaseID: "INV-001"}, {ID: "EVD-003", CaseID: "INV-001"}},
+		"ORDER-1", "court", 300, "tx-export"))
+	if err != nil {
+		t.Fatalf("failed to marshal package: %v", err)
+	}
+
+	var pkg map[string]interface{}
+	json.Unmarshal(packageJSON, &pkg)
+	delete(pkg["investigation"].(map[string]interface{}), "case_number")
+	evidence := pkg["evidence"].([]interface{})
+	evidence[0].(map[string]interface{})["file_size"] = "large"
+	delete(evidence[2].(map[string]interface{}), "hash")
+	packageJSON, _ = json.Marshal(pkg)
+
+	_, err = DecodePackage(packageJSON)
+	if err == nil {
+		t.Fatalf("package with invalid records accepted")
+	}
+	for _, want := range []string{"3 records did not validate", "investigation INV-001 (case_number is required)",
+		"evidence[0] EVD-001 (file_size: ", "evidence[2] EVD-003 (hash is required)"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "EVD-002") {
+		t.Errorf("error %q lists the valid record EVD-002", err)
+	}
+}
+
+func TestDecodePackageRejectsUnknownVersionsAndFields(t *testing.T) {
+	for name, packageJSON := range map[string]string{
+		"future version": strings.Replace(packageV1, `"court_order"`,
+			fmt.Sprintf(`"format_version": %d, "court_order"`, PackageFormatVersion+1), 1),
+		"invalid version": strings.Replace(packageV1, `"court_order"`, `"format_version": "2", "court_order"`, 1),
+		"unknown field": func() string {
+			exportPackage, _ := DecodePackage([]byte(packageV1))
+			packageJSON, _ := json.Marshal(exportPackage)
+			return strings.Replace(string(packageJSON), `"court_order"`, `"signature": "trust me", "court_order"`, 1)
+		}(),
+		"not an object": `["investigation"]`,
+	} {
+		if _, err := DecodePackage([]byte(packageJSON)); err == nil {
+			t.Errorf("%s: package accepted", name)
+		}
+	}
+}
+
+func TestCheckPackageListsForeignEvidence(t *testing.T) {
+	exportPackage := ArchiveFlow.NewPackage(Investigation{ID: "INV-001"},
+		[]Evidence{{ID: "EVD-001", CaseID: "INV-001"}, {ID: "EVD-002", CaseID: "INV-002"}, {ID: "", CaseID: "INV-001"}},
+		"ORDER-1", "court", 300, "tx-export")
+
+	err := ArchiveFlow.CheckPackage(exportPackage)
+	if err == nil {
+		t.Fatalf("package with foreign evidence accepted")
+	}
+	for _, want := range []string{"2 invalid records", "evidence[1] EVD-002 (belongs to case INV-002", "evidence[2] (evidence ID must not be empty)"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
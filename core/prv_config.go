@@ -0,0 +1,382 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ==============================================================================
+// PRV CONFIGURATION HISTORY
+// ==============================================================================
+//
+// SavePRVConfig writes every change as a new version as a prv_config_version record
+// in addition to the live configuration. A version is in force from its
+// EffectiveFrom timestamp until the next version's, so the attestation under
+// which any committed transaction ran can be recovered from its timestamp.
+// Fabric does not expose block numbers to chaincode; to check a block, pass the
+// timestamp from its header to GetPRVConfigAt.
+
+// prvRotationWindow is how long a proposed key rotation collects approvals
+const prvRotationWindow = 24 * time.Hour
+
+// RotationApproval is one verifier MSP's approval of a key rotation
+type RotationApproval struct {
+	MSP        string `json:"msp"`
+	Approver   string `json:"approver"`
+	ApprovedAt int64  `json:"approved_at"`
+}
+
+// PRVKeyRotation is a proposed change of the PRV key and enclave measurements,
+// applied once a quorum of verifier MSPs has approved it
+type PRVKeyRotation struct {
+	ID             string             `json:"id"`
+	FromPublicKey  string             `json:"from_public_key"`
+	PublicKey      string             `json:"public_key"`
+	MREnclave      string             `json:"mr_enclave"`
+	MRSigner       string             `json:"mr_signer"`
+	Reason         string             `json:"reason"`
+	ProposedBy     string             `json:"proposed_by"`
+	ProposedAt     int64              `json:"proposed_at"`
+	ExpiresAt      int64              `json:"expires_at"`
+	Approvals      []RotationApproval `json:"approvals"`
+	Status         string             `json:"status"` // pending, applied
+	AppliedVersion int                `json:"applied_version"`
+}
+
+// ==============================================================================
+// KEY ROTATION AND REVOCATION TRANSACTIONS
+// ==============================================================================
+
+// RotatePRVKey proposes or approves replacing the PRV key and enclave measurements.
+// Each verifier MSP calls it with the same arguments; the rotation is applied when
+// the approvals reach the attestation quorum. A PRV signature is not required so
+// a lost or compromised key can still be replaced.
+func (cc ChainContract) RotatePRVKey(ctx contractapi.TransactionContextInterface,
+	publicKeyHex string, mrEnclaveHex string, mrSignerHex string, reason string) (*PRVKeyRotation, error) {
+
+	// Only verifier services can approve a rotation
+	if err := cc.checkAttestationVerifier(ctx); err != nil {
+		return nil, err
+	}
+
+	key, err := parsePRVPublicKey(publicKeyHex)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, fmt.Errorf("cannot rotate to an empty or placeholder PRV key")
+	}
+	for _, measurement := range [][2]string{{"MRENCLAVE", mrEnclaveHex}, {"MRSIGNER", mrSignerHex}} {
+		if raw, err := hex.DecodeString(measurement[1]); err != nil || len(raw) != 32 {
+			return nil, fmt.Errorf("%s must be 32 bytes of hex", measurement[0])
+		}
+	}
+	publicKeyHex = strings.ToLower(publicKeyHex)
+	mrEnclaveHex = strings.ToLower(mrEnclaveHex)
+	mrSignerHex = strings.ToLower(mrSignerHex)
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	clientID, _ := ctx.GetClientIdentity().GetID()
+	now, err := TxNow(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := LoadPRVConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if findRevocation(config, mspID) != nil {
+		return nil, fmt.Errorf("access denied: verifier %s has been revoked", mspID)
+	}
+	if strings.EqualFold(config.PublicKey, publicKeyHex) &&
+		strings.EqualFold(config.MREnclave, mrEnclaveHex) && strings.EqualFold(config.MRSigner, mrSignerHex) {
+		return nil, fmt.Errorf("the proposed PRV key and measurements are already in force")
+	}
+
+	id := rotationID(config.PublicKey, publicKeyHex, mrEnclaveHex, mrSignerHex)
+	rotation, err := loadPRVKeyRotation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rotation == nil || rotation.Status != "pending" || rotation.ExpiresAt <= now {
+		rotation = &PRVKeyRotation{
+			ID:            id,
+			FromPublicKey: config.PublicKey,
+			PublicKey:     publicKeyHex,
+			MREnclave:     mrEnclaveHex,
+			MRSigner:      mrSignerHex,
+			Reason:        reason,
+			ProposedBy:    clientID,
+			ProposedAt:    now,
+			ExpiresAt:     now + int64(prvRotationWindow.Seconds()),
+			Approvals:     []RotationApproval{},
+			Status:        "pending",
+		}
+	}
+
+	for _, approval := range rotation.Approvals {
+		if approval.MSP == mspID {
+			return nil, fmt.Errorf("rotation %s already approved by %s", id, mspID)
+		}
+	}
+	rotation.Approvals = append(rotation.Approvals, RotationApproval{
+		MSP:        mspID,
+		Approver:   clientID,
+		ApprovedAt: now,
+	})
+
+	// Count approvals from MSPs that have not been revoked since approving
+	approvals := 0
+	for _, approval := range rotation.Approvals {
+		if findRevocation(config, approval.MSP) == nil {
+			approvals++
+		}
+	}
+
+	threshold := effectiveQuorum(config)
+	result := fmt.Sprintf("Rotation %s approved by %s (%d/%d)", id, mspID, approvals, threshold)
+	if approvals >= threshold {
+		// The new key must be attested afresh before transactions resume
+		config.PublicKey = publicKeyHex
+		config.MREnclave = mrEnclaveHex
+		config.MRSigner = mrSignerHex
+		config.AttestationDoc = ""
+		config.VerifiedBy = []VerifierEntry{}
+		if _, err := SavePRVConfig(ctx, config, fmt.Sprintf("PRV key rotated by %s: %s", id, rotation.Reason)); err != nil {
+			return nil, err
+		}
+		rotation.Status = "applied"
+		rotation.AppliedVersion = config.Version
+		result = fmt.Sprintf("Rotation %s applied as PRV config version %d", id, config.Version)
+	}
+
+	rotationJSON, err := json.Marshal(rotation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal key rotation: %v", err)
+	}
+	if err := ctx.GetStub().PutState(prvRotationKey(id), rotationJSON); err != nil {
+		return nil, fmt.Errorf("failed to store key rotation: %v", err)
+	}
+
+	// Emit event
+	ctx.GetStub().SetEvent("PRVKeyRotation", rotationJSON)
+
+	// Audit log
+	LogAudit(ctx, "RotatePRVKey", "attestation.config", id, "success", result)
+
+	return rotation, nil
+}
+
+// GetPRVKeyRotation retrieves a proposed or applied key rotation
+func (cc ChainContract) GetPRVKeyRotation(ctx contractapi.TransactionContextInterface,
+	rotationID string) (*PRVKeyRotation, error) {
+
+	rotation, err := loadPRVKeyRotation(ctx, rotationID)
+	if err != nil {
+		return nil, err
+	}
+	if rotation == nil {
+		return nil, fmt.Errorf("key rotation %s does not exist", rotationID)
+	}
+	return rotation, nil
+}
+
+// RevokeVerifier removes an MSP's attestation and bars it from registering
+// attestations or approving rotations
+func (cc ChainContract) RevokeVerifier(ctx contractapi.TransactionContextInterface,
+	mspID string, reason string) error {
+
+	if err := CheckSystemAdmin(ctx); err != nil {
+		return err
+	}
+
+	if mspID == "" || reason == "" {
+		return fmt.Errorf("revoking a verifier requires an MSP ID and a reason")
+	}
+
+	config, err := LoadPRVConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if findRevocation(config, mspID) != nil {
+		return fmt.Errorf("verifier %s is already revoked", mspID)
+	}
+
+	clientID, _ := ctx.GetClientIdentity().GetID()
+	now, err := TxNow(ctx)
+	if err != nil {
+		return err
+	}
+	config.RevokedVerifiers = append(config.RevokedVerifiers, VerifierRevocation{
+		MSP:       mspID,
+		Reason:    reason,
+		RevokedBy: clientID,
+		RevokedAt: now,
+	})
+
+	verifiers := []VerifierEntry{}
+	for _, v := range config.VerifiedBy {
+		if v.MSP != mspID {
+			verifiers = append(verifiers, v)
+		}
+	}
+	config.VerifiedBy = verifiers
+
+	configJSON, err := SavePRVConfig(ctx, config, fmt.Sprintf("verifier %s revoked: %s", mspID, reason))
+	if err != nil {
+		return err
+	}
+
+	// Emit event
+	ctx.GetStub().SetEvent("VerifierRevoked", configJSON)
+
+	// Audit log
+	LogAudit(ctx, "RevokeVerifier", "attestation.config", "prv_config", "success",
+		fmt.Sprintf("Verifier %s revoked in PRV config version %d: %s", mspID, config.Version, reason))
+
+	return nil
+}
+
+// ==============================================================================
+// PRV CONFIGURATION HISTORY QUERIES
+// ==============================================================================
+
+// GetPRVConfigHistory returns every stored PRV configuration version, oldest first
+func (cc ChainContract) GetPRVConfigHistory(ctx contractapi.TransactionContextInterface) ([]*PRVConfig, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(RecordPRVConfigVersion, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PRV config history: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var history []*PRVConfig
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var config PRVConfig
+		if err := json.Unmarshal(queryResponse.Value, &config); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal PRV config version: %v", err)
+		}
+		history = append(history, &config)
+	}
+
+	return history, nil
+}
+
+// GetPRVConfigAt returns the PRV configuration version in force at timestamp
+// (Unix seconds) and its quorum health at that time
+func (cc ChainContract) GetPRVConfigAt(ctx contractapi.TransactionContextInterface,
+	timestamp int64) (*PRVConfig, error) {
+
+	return cc.prvConfigInForce(ctx, timestamp, "")
+}
+
+// GetPRVConfigForTransaction returns the PRV configuration version a committed
+// transaction ran under and whether the attestation quorum was met at that time
+func (cc ChainContract) GetPRVConfigForTransaction(ctx contractapi.TransactionContextInterface,
+	txID string) (*PRVConfig, error) {
+
+	// Every committed transaction writes an audit entry stamped with its time
+	auditJSON, err := ctx.GetStub().GetState(StateKey(RecordAudit, "audit_"+txID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %v", err)
+	}
+	if auditJSON == nil {
+		return nil, fmt.Errorf("no audit record for transaction %s; use GetPRVConfigAt with its block timestamp", txID)
+	}
+
+	var auditLog AuditLog
+	if err := json.Unmarshal(auditJSON, &auditLog); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal audit log: %v", err)
+	}
+
+	return cc.prvConfigInForce(ctx, auditLog.Timestamp, txID)
+}
+
+// ==============================================================================
+// PRV CONFIGURATION HISTORY HELPERS
+// ==============================================================================
+
+// PRVConfigVersionKey returns the snapshot key of a version, zero padded so keys sort by version
+func PRVConfigVersionKey(version int) string {
+	return StateKey(RecordPRVConfigVersion, fmt.Sprintf("%010d", version))
+}
+
+// prvRotationKey returns the world state key of a key rotation
+func prvRotationKey(id string) string {
+	return StateKey(RecordPRVRotation, id)
+}
+
+// rotationID identifies a rotation by the key it replaces and the key and measurements it installs
+func rotationID(fromKey string, publicKey string, mrEnclave string, mrSigner string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		strings.ToLower(fromKey), publicKey, mrEnclave, mrSigner}, "|")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// prvConfigInForce finds the latest version effective at timestamp, ignoring versions
+// written by excludeTxID itself, and reports its quorum health at that time
+func (cc ChainContract) prvConfigInForce(ctx contractapi.TransactionContextInterface,
+	timestamp int64, excludeTxID string) (*PRVConfig, error) {
+
+	history, err := cc.GetPRVConfigHistory(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var inForce *PRVConfig
+	for _, config := range history {
+		if config.EffectiveFrom > timestamp || (excludeTxID != "" && config.EffectiveTxID == excludeTxID) {
+			break
+		}
+		inForce = config
+	}
+	if inForce == nil {
+		return nil, fmt.Errorf("no PRV configuration version was in force at %d", timestamp)
+	}
+
+	inForce.Quorum = quorumHealth(inForce, timestamp)
+	return inForce, nil
+}
+
+// loadPRVKeyRotation reads a key rotation, returning nil if it does not exist
+func loadPRVKeyRotation(ctx contractapi.TransactionContextInterface,
+	id string) (*PRVKeyRotation, error) {
+
+	rotationJSON, err := ctx.GetStub().GetState(prvRotationKey(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key rotation: %v", err)
+	}
+	if rotationJSON == nil {
+		return nil, nil
+	}
+
+	var rotation PRVKeyRotation
+	if err := json.Unmarshal(rotationJSON, &rotation); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal key rotation: %v", err)
+	}
+	return &rotation, nil
+}
+
+// findRevocation returns the revocation of mspID in config, or nil
+func findRevocation(config *PRVConfig, mspID string) *VerifierRevocation {
+	for i := range config.RevokedVerifiers {
+		if config.RevokedVerifiers[i].MSP == mspID {
+			return &config.RevokedVerifiers[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package core
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ==============================================================================
+// PRV SIGNED REQUESTS
+// ==============================================================================
+//
+// Sensitive operations must also be signed by the enclave-protected PRV key
+// whose public half is PRVConfig.PublicKey, so a stolen client certificate
+// alone cannot drive them. The client asks the PRV enclave to sign the request
+// (see prvSignedRequest) and passes the detached signature in the transient
+// field "prv_signature", which keeps it off the ledger and leaves the function
+// signatures unchanged. The request names its transaction ID, which Fabric
+// never commits twice, so a captured signature cannot be replayed. While
+// PublicKey is empty or the all-zero placeholder written by deploy-chaincode.sh
+// these operations are refused until RotatePRVKey installs a real key.
+
+// prvSignatureTransientKey is the transient field carrying the detached PRV signature
+const prvSignatureTransientKey = "prv_signature"
+
+// prvSignedRequest is the message the PRV key signs: the compact JSON encoding of
+// {"channel_id":...,"tx_id":...,"function":...,"args":[...]} with the function name
+// stripped of any "Contract:" prefix and the arguments exactly as submitted
+type prvSignedRequest struct {
+	ChannelID string   `json:"channel_id"`
+	TxID      string   `json:"tx_id"`
+	Function  string   `json:"function"`
+	Args      []string `json:"args"`
+}
+
+// CheckPRVSignature verifies the transient PRV signature over the current request
+// against the stored PRV public key
+func CheckPRVSignature(ctx contractapi.TransactionContextInterface) error {
+	config, err := LoadPRVConfig(ctx)
+	if err != nil {
+		return err
+	}
+	key, err := parsePRVPublicKey(config.PublicKey)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return fmt.Errorf("access denied: no PRV key is configured; install one with RotatePRVKey")
+	}
+
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("failed to read transient data: %v", err)
+	}
+	signature := transient[prvSignatureTransientKey]
+	if len(signature) == 0 {
+		return fmt.Errorf("access denied: operation requires a PRV signature in transient field %q",
+			prvSignatureTransientKey)
+	}
+
+	payload, err := prvRequestPayload(ctx)
+	if err != nil {
+		return err
+	}
+	if !verifyPRVSignature(key, payload, signature) {
+		return fmt.Errorf("access denied: PRV signature does not verify against the stored PRV public key")
+	}
+	return nil
+}
+
+// prvRequestPayload encodes the current invocation as a prvSignedRequest
+func prvRequestPayload(ctx contractapi.TransactionContextInterface) ([]byte, error) {
+	args := ctx.GetStub().GetStringArgs()
+	if len(args) == 0 {
+		return nil, fmt.Errorf("invocation has no function name")
+	}
+
+	function := args[0]
+	if i := strings.LastIndex(function, ":"); i >= 0 {
+		function = function[i+1:]
+	}
+
+	payload, err := json.Marshal(prvSignedRequest{
+		ChannelID: ctx.GetStub().GetChannelID(),
+		TxID:      ctx.GetStub().GetTxID(),
+		Function:  function,
+		Args:      append([]string{}, args[1:]...),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode signed request: %v", err)
+	}
+	return payload, nil
+}
+
+// parsePRVPublicKey decodes the hex PRV public key: a DER SubjectPublicKeyInfo
+// (ECDSA P-256 or Ed25519), a raw 32-byte Ed25519 key or a 65-byte uncompressed
+// P-256 point. It returns nil for an empty or all-zero placeholder key.
+func parsePRVPublicKey(keyHex string) (crypto.PublicKey, error) {
+	raw, err := hex.DecodeString(strings.TrimSpace(keyHex))
+	if err != nil {
+		return nil, fmt.Errorf("PRV public key is not valid hex: %v", err)
+	}
+	if len(raw) == 0 || bytes.Count(raw, []byte{0}) == len(raw) {
+		return nil, nil
+	}
+
+	switch {
+	case len(raw) == ed25519.PublicKeySize:
+		return ed25519.PublicKey(raw), nil
+	case len(raw) == 65 && raw[0] == 4:
+		if _, err := ecdh.P256().NewPublicKey(raw); err != nil {
+			return nil, fmt.Errorf("PRV public key is not a P-256 point: %v", err)
+		}
+		return &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(raw[1:33]),
+			Y:     new(big.Int).SetBytes(raw[33:]),
+		}, nil
+	}
+
+	key, err := x509.ParsePKIXPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse PRV public key: %v", err)
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("PRV public key must be ECDSA or Ed25519, got %T", key)
+	}
+}
+
+// verifyPRVSignature checks an Ed25519 signature over payload, or an ECDSA signature
+// (ASN.1 DER or raw r||s) over its SHA-256 digest
+func verifyPRVSignature(key crypto.PublicKey, payload []byte, signature []byte) bool {
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, payload, signature)
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(payload)
+		if len(signature) == 64 {
+			r := new(big.Int).SetBytes(signature[:32])
+			s := new(big.Int).SetBytes(signature[32:])
+			return ecdsa.Verify(k, digest[:], r, s)
+		}
+		return ecdsa.VerifyASN1(k, digest[:], signature)
+	}
+	return false
+}
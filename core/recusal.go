@@ -0,0 +1,429 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	casbin "github.com/aub/dfir-casbin"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ==============================================================================
+// CONFLICT-OF-INTEREST RECUSALS
+// ==============================================================================
+//
+// A recusal is stored in the access policy as a deny rule on a record resource:
+//   p, ForensicLabMSP/CN=examiner1.forensiclab.hot.coc.com, *, *, case:INV-001, deny
+// model.conf uses the deny-override effect, so the rule takes precedence over
+// every role grant (SystemAdmin included) for that case or evidence item.
+
+// Record resources named by recusal deny rules
+const (
+	caseResourcePrefix     = "case:"
+	evidenceResourcePrefix = "evidence:"
+)
+
+// Recusal records who is barred from a case or evidence item, why and on whose approval
+type Recusal struct {
+	ID         string `json:"id"`
+	CaseID     string `json:"case_id"`
+	EvidenceID string `json:"evidence_id,omitempty" metadata:",optional"` // Empty when the whole case is covered
+	User       string `json:"user"`                                       // Subject, e.g. ForensicLabMSP/CN=examiner1.forensiclab.hot.coc.com
+	Reason     string `json:"reason"`
+	ApprovedBy string `json:"approved_by"`
+	CreatedAt  int64  `json:"created_at"`
+	Status     string `json:"status"` // active, lifted
+	LiftedBy   string `json:"lifted_by,omitempty" metadata:",optional"`
+	LiftedAt   int64  `json:"lifted_at,omitempty" metadata:",optional"`
+	LiftReason string `json:"lift_reason,omitempty" metadata:",optional"`
+}
+
+// AddRecusal bars user from a case, or from one evidence item when evidenceID is set.
+// The caller is recorded as the approver.
+func (cc ChainContract) AddRecusal(ctx contractapi.TransactionContextInterface,
+	user string, caseID string, evidenceID string, reason string) (*Recusal, error) {
+
+	// Check attestation
+	if err := CheckAttestation(ctx); err != nil {
+		return nil, fmt.Errorf("attestation check failed: %v", err)
+	}
+
+	// Check permission
+	if err := cc.Access.CheckPermission(ctx, "rbac.recusal", "create", "*"); err != nil {
+		return nil, err
+	}
+
+	if user == "" || strings.TrimSpace(reason) == "" {
+		return nil, fmt.Errorf("recusal requires a user and a reason")
+	}
+
+	investigation, err := LoadInvestigation(ctx, caseID)
+	if err != nil {
+		return nil, err
+	}
+	if investigation == nil {
+		return nil, fmt.Errorf("investigation %s does not exist", caseID)
+	}
+	caseID = investigation.ID
+	if evidenceID != "" {
+		evidence, err := LoadEvidence(ctx, evidenceID)
+		if err != nil {
+			return nil, err
+		}
+		if evidence == nil || evidence.CaseID != caseID {
+			return nil, fmt.Errorf("evidence %s does not belong to case %s", evidenceID, caseID)
+		}
+	}
+
+	approver, err := Subject(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if approver == user {
+		return nil, fmt.Errorf("a recusal must be approved by someone other than the recused user")
+	}
+
+	now, err := TxNow(ctx)
+	if err != nil {
+		return nil, err
+	}
+	recusal := Recusal{
+		ID:         TxScopedID(ctx, "recusal"),
+		CaseID:     caseID,
+		EvidenceID: evidenceID,
+		User:       user,
+		Reason:     reason,
+		ApprovedBy: approver,
+		CreatedAt:  now,
+		Status:     "active",
+	}
+
+	recusals, err := loadRecusals(ctx, caseID)
+	if err != nil {
+		return nil, err
+	}
+	recusals = append(recusals, recusal)
+	if err := saveRecusals(ctx, caseID, recusals); err != nil {
+		return nil, err
+	}
+
+	// The policy change emits the event and writes the audit entry, both carrying the recusal
+	rule := recusalRule(&recusal)
+	change := &AccessPolicyChange{
+		Change:  "AddRecusal",
+		Line:    rule,
+		Reason:  fmt.Sprintf("%s recused from %s: %s", user, rule[3], reason),
+		Recusal: &recusal,
+	}
+	err = applyAccessPolicyChange(ctx, change, func(policy *casbin.Policy) error {
+		if !policy.AddRule(rule) {
+			return fmt.Errorf("%s is already recused from %s", user, rule[3])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &recusal, nil
+}
+
+// LiftRecusal ends an active recusal and removes its deny rule
+func (cc ChainContract) LiftRecusal(ctx contractapi.TransactionContextInterface,
+	caseID string, recusalID string, reason string) error {
+
+	// Check attestation
+	if err := CheckAttestation(ctx); err != nil {
+		return fmt.Errorf("attestation check failed: %v", err)
+	}
+
+	// Check permission
+	if err := cc.Access.CheckPermission(ctx, "rbac.recusal", "lift", "*"); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(reason) == "" {
+		return fmt.Errorf("lifting a recusal requires a reason")
+	}
+
+	recusals, err := loadRecusals(ctx, caseID)
+	if err != nil {
+		return err
+	}
+
+	var recusal *Recusal
+	for i := range recusals {
+		if recusals[i].ID == recusalID {
+			recusal = &recusals[i]
+		}
+	}
+	if recusal == nil {
+		return fmt.Errorf("recusal %s not found for case %s", recusalID, caseID)
+	}
+	if recusal.Status != "active" {
+		return fmt.Errorf("recusal %s is already %s", recusalID, recusal.Status)
+	}
+
+	liftedBy, _ := Subject(ctx)
+	now, err := TxNow(ctx)
+	if err != nil {
+		return err
+	}
+	recusal.Status = "lifted"
+	recusal.LiftedBy = liftedBy
+	recusal.LiftedAt = now
+	recusal.LiftReason = reason
+
+	if err := saveRecusals(ctx, caseID, recusals); err != nil {
+		return err
+	}
+
+	// The policy change emits the event and writes the audit entry, both carrying the recusal
+	rule := recusalRule(recusal)
+	change := &AccessPolicyChange{
+		Change:  "LiftRecusal",
+		Line:    rule,
+		Reason:  fmt.Sprintf("Recusal of %s lifted: %s", recusal.User, reason),
+		Recusal: recusal,
+	}
+	return applyAccessPolicyChange(ctx, change, func(policy *casbin.Policy) error {
+		policy.RemoveRule(rule)
+		return nil
+	})
+}
+
+// QueryActiveRecusals returns the active recusals on a case and its evidence
+func (cc ChainContract) QueryActiveRecusals(ctx contractapi.TransactionContextInterface,
+	caseID string) ([]Recusal, error) {
+
+	// Check permission
+	if err := cc.Access.CheckPermission(ctx, "rbac.recusal", "view", "*"); err != nil {
+		return nil, err
+	}
+
+	recusals, err := loadRecusals(ctx, caseID)
+	if err != nil {
+		return nil, err
+	}
+
+	active := []Recusal{}
+	for _, recusal := range recusals {
+		if recusal.Status == "active" {
+			active = append(active, recusal)
+		}
+	}
+	return active, nil
+}
+
+// ==============================================================================
+// RECUSAL HELPERS
+// ==============================================================================
+
+// recusalsKey returns the world state key of a case's recusals
+func recusalsKey(caseID string) string {
+	return StateKey(RecordRecusals, caseID)
+}
+
+// loadRecusals reads every recusal recorded against a case
+func loadRecusals(ctx contractapi.TransactionContextInterface,
+	caseID string) ([]Recusal, error) {
+
+	recusalsJSON, err := ctx.GetStub().GetState(recusalsKey(caseID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recusals: %v", err)
+	}
+	if recusalsJSON == nil {
+		return nil, nil
+	}
+
+	var recusals []Recusal
+	if err := json.Unmarshal(recusalsJSON, &recusals); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal recusals: %v", err)
+	}
+	return recusals, nil
+}
+
+// saveRecusals stores the recusals of a case
+func saveRecusals(ctx contractapi.TransactionContextInterface,
+	caseID string, recusals []Recusal) error {
+
+	recusalsJSON, err := json.Marshal(recusals)
+	if err != nil {
+		return fmt.Errorf("failed to marshal recusals: %v", err)
+	}
+	if err := ctx.GetStub().PutState(recusalsKey(caseID), recusalsJSON); err != nil {
+		return fmt.Errorf("failed to store recusals: %v", err)
+	}
+	return nil
+}
+
+// qualifyRecusals renames an unqualified recused user in every case's recusal records
+func qualifyRecusals(ctx contractapi.TransactionContextInterface,
+	subject string, qualified string) error {
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(RecordRecusals, []string{})
+	if err != nil {
+		return fmt.Errorf("failed to list recusals: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return err
+		}
+
+		var recusals []Recusal
+		if err := json.Unmarshal(response.Value, &recusals); err != nil {
+			return fmt.Errorf("failed to unmarshal recusals: %v", err)
+		}
+		renamed := false
+		for i := range recusals {
+			if recusals[i].User == subject {
+				recusals[i].User = qualified
+				renamed = true
+			}
+		}
+		if renamed {
+			if err := saveRecusals(ctx, recusals[0].CaseID, recusals); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// recusalRule returns the deny rule that enforces a recusal
+func recusalRule(recusal *Recusal) []string {
+	resource := caseResourcePrefix + recusal.CaseID
+	if recusal.EvidenceID != "" {
+		resource = evidenceResourcePrefix + recusal.EvidenceID
+	}
+	return []string{recusal.User, "*", "*", resource, "deny"}
+}
+
+// recordResources returns the record resources covering a case and, optionally, one of its evidence items
+func recordResources(caseID string, evidenceID string) []string {
+	var resources []string
+	if caseID != "" {
+		resources = append(resources, caseResourcePrefix+caseID)
+	}
+	if evidenceID != "" {
+		resources = append(resources, evidenceResourcePrefix+evidenceID)
+	}
+	return resources
+}
+
+// recusalChecker returns a function reporting the first record resource a deny rule
+// bars the caller from, so list queries evaluate the policy only once
+func recusalChecker(ctx contractapi.TransactionContextInterface,
+	object string, action string) (func(caseID string, evidenceID string) (string, error), error) {
+
+	subject, err := Subject(ctx)
+	if err != nil {
+		return nil, err
+	}
+	role, err := ResolveRole(ctx)
+	if err != nil {
+		return nil, err
+	}
+	enforcer, err := LoadEnforcer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	enforcer.AddRoleForUser(subject, role)
+
+	return func(caseID string, evidenceID string) (string, error) {
+		for _, resource := range recordResources(caseID, evidenceID) {
+			denied, err := enforcer.Denied(subject, object, action, resource)
+			if err != nil {
+				return "", err
+			}
+			if denied {
+				return resource, nil
+			}
+		}
+		return "", nil
+	}, nil
+}
+
+// CheckRecusal rejects the action when a deny rule bars the caller from the case or evidence item.
+// Deny rules override role grants, so this applies after CheckPermission has allowed the action.
+func CheckRecusal(ctx contractapi.TransactionContextInterface,
+	object string, action string, caseID string, evidenceID string) error {
+
+	recused, err := recusalChecker(ctx, object, action)
+	if err != nil {
+		return err
+	}
+	resource, err := recused(caseID, evidenceID)
+	if err != nil {
+		return err
+	}
+	if resource == "" {
+		return nil
+	}
+
+	LogAudit(ctx, action, object, resource, "denied", "Caller is recused from this record")
+	return fmt.Errorf("access denied: you are recused from %s", resource)
+}
+
+// CheckEvidenceRecusal applies CheckRecusal to an evidence item and its case
+func CheckEvidenceRecusal(ctx contractapi.TransactionContextInterface,
+	object string, action string, evidenceID string) error {
+
+	evidence, err := LoadEvidence(ctx, evidenceID)
+	if err != nil {
+		return err
+	}
+	caseID := ""
+	if evidence != nil {
+		caseID = evidence.CaseID
+	}
+	return CheckRecusal(ctx, object, action, caseID, evidenceID)
+}
+
+// FilterRecusedInvestigations drops the investigations the caller is recused from
+func FilterRecusedInvestigations(ctx contractapi.TransactionContextInterface,
+	action string, investigations []*Investigation) ([]*Investigation, error) {
+
+	recused, err := recusalChecker(ctx, "blockchain.investigation", action)
+	if err != nil {
+		return nil, err
+	}
+
+	var allowed []*Investigation
+	for _, investigation := range investigations {
+		resource, err := recused(investigation.ID, "")
+		if err != nil {
+			return nil, err
+		}
+		if resource == "" {
+			allowed = append(allowed, investigation)
+		}
+	}
+	return allowed, nil
+}
+
+// FilterRecusedEvidence drops the evidence the caller is recused from, directly or through its case
+func FilterRecusedEvidence(ctx contractapi.TransactionContextInterface,
+	action string, evidenceList []*Evidence) ([]*Evidence, error) {
+
+	recused, err := recusalChecker(ctx, "blockchain.evidence", action)
+	if err != nil {
+		return nil, err
+	}
+
+	var allowed []*Evidence
+	for _, evidence := range evidenceList {
+		resource, err := recused(evidence.CaseID, evidence.ID)
+		if err != nil {
+			return nil, err
+		}
+		if resource == "" {
+			allowed = append(allowed, evidence)
+		}
+	}
+	return allowed, nil
+}
@@ -0,0 +1,586 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ==============================================================================
+// CROSS-CHAIN CASE TRANSFER
+// ==============================================================================
+//
+// A case moves between the chains in three transactions: the source chain
+// exports it and marks it in flight (ExportCase), the target chain stores the
+// package (ImportCase) and the source chain records that the import committed
+// and that the target chain received the package it exported (CompleteTransfer,
+// see transfer_record.go). A transfer whose import does not happen by its
+// deadline can be aborted (AbortTransfer, see transfer_abort.go). Archival moves a closed case from hot to cold,
+// reactivation moves an archived case from cold back to hot. A case with more
+// evidence than fits in one transaction is imported in chunks (see
+// transfer_chunks.go). Both chains use
+// the record types of this package, so every field of the case and its
+// evidence survives the round trip.
+
+// CaseExportPackage holds complete case data for cross-chain transfer
+type CaseExportPackage struct {
+	FormatVersion int           `json:"format_version"` // See PackageFormatVersion
+	Investigation Investigation `json:"investigation"`
+	Evidence      []Evidence    `json:"evidence"`
+	CourtOrder    string        `json:"court_order"`
+	ExportedAt    int64         `json:"exported_at"`
+	ExportedBy    string        `json:"exported_by"`
+	ExpiresAt     int64         `json:"expires_at"` // Import deadline, 0 if exported without one (see TransferDeadline)
+	SourceChain   string        `json:"source_chain"`
+	TransferTxID  string        `json:"transfer_tx_id"`
+
+	// Manifest replaces Evidence in the package of a chunked export
+	Manifest *TransferManifest `json:"manifest,omitempty" metadata:",optional"`
+
+	// Proof is added by the relayer once the export has committed
+	Proof *TransferProof `json:"proof,omitempty" metadata:",optional"`
+}
+
+// TransferFlow describes one direction of a case transfer
+type TransferFlow struct {
+	Direction       string // TransferArchive or TransferReactivation
+	SourceChain     string
+	TargetChain     string
+	ExportStatus    string // Status a case must have to be exported
+	PendingStatus   string // Status on the source chain until the transfer completes
+	CompletedStatus string // Status on the source chain once the import is confirmed
+	ImportedStatus  string // Status of the imported case on the target chain
+	EvidenceStatus  string // Status of the imported evidence on the target chain
+	AllowExisting   bool   // The target chain may still hold the case from an earlier transfer
+}
+
+// ArchiveFlow moves a closed case from the hot chain to the cold chain
+var ArchiveFlow = TransferFlow{
+	Direction:       TransferArchive,
+	SourceChain:     ChainHot,
+	TargetChain:     ChainCold,
+	ExportStatus:    "closed",
+	PendingStatus:   "transferring_to_archive",
+	CompletedStatus: "archived_on_cold",
+	ImportedStatus:  "archived",
+	EvidenceStatus:  "archived",
+}
+
+// ReactivationFlow moves an archived case from the cold chain back to the hot chain,
+// replacing the hot chain's copy left by the archive transfer
+var ReactivationFlow = TransferFlow{
+	Direction:       TransferReactivation,
+	SourceChain:     ChainCold,
+	TargetChain:     ChainHot,
+	ExportStatus:    "archived",
+	PendingStatus:   "transferring_to_hot",
+	CompletedStatus: "transferred_to_hot",
+	ImportedStatus:  "open",
+	EvidenceStatus:  "reviewed",
+	AllowExisting:   true,
+}
+
+// NewPackage builds the package the flow's source chain exports for a case
+func (f TransferFlow) NewPackage(investigation Investigation, evidence []Evidence,
+	courtOrder string, exportedBy string, exportedAt int64, txID string) *CaseExportPackage {
+
+	if evidence == nil {
+		evidence = []Evidence{}
+	}
+	return &CaseExportPackage{
+		FormatVersion: PackageFormatVersion,
+		Investigation: investigation,
+		Evidence:      evidence,
+		CourtOrder:    courtOrder,
+		ExportedAt:    exportedAt,
+		ExportedBy:    exportedBy,
+		SourceChain:   f.SourceChain,
+		TransferTxID:  txID,
+	}
+}
+
+// CheckPackage verifies that a package was exported by the flow's source chain and
+// that its records can be stored. The error lists every record that cannot.
+func (f TransferFlow) CheckPackage(exportPackage *CaseExportPackage) error {
+	if exportPackage.SourceChain != f.SourceChain {
+		return fmt.Errorf("invalid source chain: %s, expected '%s'", exportPackage.SourceChain, f.SourceChain)
+	}
+
+	var invalid []string
+	caseID := exportPackage.Investigation.ID
+	if err := CheckKeyAttribute("investigation ID", caseID); err != nil {
+		invalid = append(invalid, fmt.Sprintf("investigation (%v)", err))
+	}
+	for i, evidence := range exportPackage.Evidence {
+		if err := CheckKeyAttribute("evidence ID", evidence.ID); err != nil {
+			invalid = append(invalid, fmt.Sprintf("evidence[%d] (%v)", i, err))
+		} else if evidence.CaseID != caseID {
+			invalid = append(invalid, fmt.Sprintf("evidence[%d] %s (belongs to case %s, not %s)",
+				i, evidence.ID, evidence.CaseID, caseID))
+		}
+	}
+	if exportPackage.Manifest != nil {
+		if len(exportPackage.Evidence) != 0 {
+			invalid = append(invalid, "evidence (a chunked package carries its evidence in chunks)")
+		}
+		invalid = append(invalid, exportPackage.Manifest.check()...)
+	}
+	if invalid != nil {
+		return fmt.Errorf("export package has %d invalid records: %s", len(invalid), strings.Join(invalid, "; "))
+	}
+	return nil
+}
+
+// ImportRecords returns the records the target chain stores for a package: the case
+// and its evidence with the target chain's status, and for an archive the
+// metadata that ties each evidence item to its hot chain export
+func (f TransferFlow) ImportRecords(exportPackage *CaseExportPackage, importedBy string,
+	now int64) (Investigation, []Evidence, []ArchiveMetadata) {
+
+	investigation := exportPackage.Investigation
+	investigation.Status = f.ImportedStatus
+	investigation.UpdatedAt = now
+	switch f.Direction {
+	case TransferArchive:
+		investigation.ArchivedDate = now
+		investigation.ArchivedAt = now
+		investigation.ArchivedBy = importedBy
+	case TransferReactivation:
+		investigation.ClosedDate = 0 // Clear closed date for reactivated case
+	}
+
+	evidenceList := make([]Evidence, 0, len(exportPackage.Evidence))
+	var metadataList []ArchiveMetadata
+	for _, evidence := range exportPackage.Evidence {
+		evidence.ChainType = f.TargetChain
+		evidence.Status = f.EvidenceStatus
+		evidence.UpdatedAt = now
+		evidence.SourceChain = exportPackage.SourceChain
+		evidence.SourceTxID = exportPackage.TransferTxID
+
+		if f.Direction == TransferArchive {
+			evidence.ArchivedAt = now
+			evidence.ArchivedBy = importedBy
+			metadataList = append(metadataList, ArchiveMetadata{
+				EvidenceID:         evidence.ID,
+				OriginalChain:      exportPackage.SourceChain,
+				OriginalTxID:       exportPackage.TransferTxID,
+				ArchivalVerifiedBy: importedBy,
+				ArchivalTimestamp:  now,
+				IntegrityHash:      evidence.Hash,
+			})
+		}
+		evidenceList = append(evidenceList, evidence)
+	}
+
+	return investigation, evidenceList, metadataList
+}
+
+// ExportCase builds the package for a case on the source chain, stores it as an
+// export record, commits to its hash in a case_transfer record and marks the
+// case in flight. It returns the package JSON and the transfer record. The
+// package of a case with more than ChunkSize evidence items carries a manifest
+// of chunks instead of the evidence.
+func (f TransferFlow) ExportCase(ctx contractapi.TransactionContextInterface,
+	investigationID string, courtOrder string) ([]byte, *CaseTransfer, error) {
+
+	investigation, err := LoadInvestigation(ctx, investigationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if investigation == nil {
+		return nil, nil, fmt.Errorf("investigation %s does not exist", investigationID)
+	}
+
+	if investigation.Status != f.ExportStatus {
+		return nil, nil, fmt.Errorf("can only export %s investigations for %s, current status: %s",
+			f.ExportStatus, f.Direction, investigation.Status)
+	}
+
+	// Read the evidence records themselves: a rich query on case_id would also
+	// match other records that carry one, such as case teams and recusals
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(RecordEvidence, []string{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read evidence: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	// A case is exported whole or not at all
+	var evidenceList []Evidence
+	var malformed []string
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to iterate evidence: %v", err)
+		}
+
+		// A record too malformed to name its case may belong to this one
+		var evidence Evidence
+		if err := json.Unmarshal(queryResponse.Value, &evidence); err != nil {
+			var owner struct {
+				CaseID string `json:"case_id"`
+			}
+			if json.Unmarshal(queryResponse.Value, &owner) != nil || owner.CaseID == investigationID {
+				malformed = append(malformed, fmt.Sprintf("%s (%v)", queryResponse.Key, err))
+			}
+			continue
+		}
+		if evidence.CaseID == investigationID {
+			evidenceList = append(evidenceList, evidence)
+		}
+	}
+	if malformed != nil {
+		return nil, nil, fmt.Errorf("cannot export %s, %d evidence records are malformed: %s",
+			investigationID, len(malformed), strings.Join(malformed, "; "))
+	}
+
+	clientID, _ := ctx.GetClientIdentity().GetID()
+	now, err := TxNow(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	txID := ctx.GetStub().GetTxID()
+
+	timeout, err := LoadTransferTimeout(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	exportPackage := f.NewPackage(*investigation, evidenceList, courtOrder, clientID, now, txID)
+	exportPackage.ExpiresAt = now + timeout.Seconds
+	packageJSON, err := json.Marshal(exportPackage)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal export package: %v", err)
+	}
+	if err := ValidatePackage(packageJSON); err != nil {
+		return nil, nil, fmt.Errorf("cannot export %s: %v", investigationID, err)
+	}
+	if err := f.CheckPackage(exportPackage); err != nil {
+		return nil, nil, fmt.Errorf("cannot export %s: %v", investigationID, err)
+	}
+
+	// A large case goes out in chunks, the package only lists them
+	if len(evidenceList) > ChunkSize {
+		manifest, err := newManifest(evidenceList)
+		if err != nil {
+			return nil, nil, err
+		}
+		exportPackage.Evidence = []Evidence{}
+		exportPackage.Manifest = manifest
+		if packageJSON, err = json.Marshal(exportPackage); err != nil {
+			return nil, nil, fmt.Errorf("failed to marshal export package: %v", err)
+		}
+	}
+
+	packageHash, err := PackageHash(exportPackage)
+	if err != nil {
+		return nil, nil, err
+	}
+	transfer := &CaseTransfer{
+		InvestigationID: investigationID,
+		Direction:       f.Direction,
+		ExportTxID:      txID,
+		PackageHash:     packageHash,
+		FormatVersion:   PackageFormatVersion,
+		PriorStatus:     investigation.Status,
+		ExportedAt:      now,
+		ExportedBy:      clientID,
+		State:           TransferStateExported,
+		ExpiresAt:       exportPackage.ExpiresAt,
+	}
+
+	// Update investigation status to indicate transfer in progress
+	investigation.Status = f.PendingStatus
+	investigation.UpdatedAt = now
+	invBytes, err := json.Marshal(investigation)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal investigation: %v", err)
+	}
+	if err := ctx.GetStub().PutState(InvestigationKey(investigationID), invBytes); err != nil {
+		return nil, nil, fmt.Errorf("failed to update investigation status: %v", err)
+	}
+
+	// Store export record
+	if err := ctx.GetStub().PutState(StateKey(RecordExport, investigationID, txID), packageJSON); err != nil {
+		return nil, nil, fmt.Errorf("failed to store export record: %v", err)
+	}
+	if err := saveCaseTransfer(ctx, transfer); err != nil {
+		return nil, nil, err
+	}
+
+	return packageJSON, transfer, nil
+}
+
+// ImportCase stores the case and evidence of a package exported by the flow's source
+// chain, together with an import record holding the hash of the package as
+// received. It returns the import record. Chunked packages are imported with
+// BeginImport instead (see transfer_chunks.go).
+func (f TransferFlow) ImportCase(ctx contractapi.TransactionContextInterface,
+	packageJSON string) (*CaseImport, error) {
+
+	exportPackage, packageHash, err := f.verifyImport(ctx, packageJSON)
+	if err != nil {
+		return nil, err
+	}
+	if exportPackage.Manifest != nil {
+		return nil, fmt.Errorf("export %s of investigation %s is chunked, import it chunk by chunk",
+			exportPackage.TransferTxID, exportPackage.Investigation.ID)
+	}
+
+	now, err := TxNow(ctx)
+	if err != nil {
+		return nil, err
+	}
+	clientID, _ := ctx.GetClientIdentity().GetID()
+
+	investigation, evidenceList, metadataList := f.ImportRecords(exportPackage, clientID, now)
+	if err := storeInvestigation(ctx, &investigation); err != nil {
+		return nil, err
+	}
+	if err := storeEvidence(ctx, evidenceList, metadataList); err != nil {
+		return nil, err
+	}
+
+	return storeImport(ctx, exportPackage, packageHash, len(exportPackage.Evidence), clientID, now)
+}
+
+// verifyImport decodes a package for the flow's target chain and checks that the
+// transfer was not aborted and its deadline has not passed, that the source
+// chain provably committed it and that the case may be stored. It returns the
+// package and its hash.
+func (f TransferFlow) verifyImport(ctx contractapi.TransactionContextInterface,
+	packageJSON string) (*CaseExportPackage, string, error) {
+
+	exportPackage, err := DecodePackage([]byte(packageJSON))
+	if err != nil {
+		return nil, "", err
+	}
+	if err := f.CheckPackage(exportPackage); err != nil {
+		return nil, "", err
+	}
+
+	// Past its deadline the source chain may have aborted the transfer
+	deadline, err := TransferDeadline(ctx, exportPackage.ExportedAt, exportPackage.ExpiresAt)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := f.checkTransferOpen(ctx, exportPackage.Investigation.ID, exportPackage.TransferTxID, deadline); err != nil {
+		return nil, "", err
+	}
+
+	// Only packages the source chain provably committed are imported
+	trust, err := LoadTransferTrust(ctx, f.SourceChain)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := VerifyTransferProof(trust, exportPackage); err != nil {
+		return nil, "", err
+	}
+
+	if err := f.checkExisting(ctx, exportPackage.Investigation.ID); err != nil {
+		return nil, "", err
+	}
+
+	packageHash, err := PackageHash(exportPackage)
+	if err != nil {
+		return nil, "", err
+	}
+	return exportPackage, packageHash, nil
+}
+
+// checkTransferOpen fails once the abort of the transfer of exportTxID has been
+// recorded or its deadline has passed. Only the recorded abort does not depend
+// on the proposal timestamp.
+func (f TransferFlow) checkTransferOpen(ctx contractapi.TransactionContextInterface,
+	investigationID string, exportTxID string, deadline int64) error {
+
+	aborted, err := LoadTransferAbort(ctx, investigationID, exportTxID)
+	if err != nil {
+		return err
+	}
+	if aborted != nil {
+		return fmt.Errorf("transfer %s of investigation %s was aborted on the %s chain in transaction %s: %s",
+			exportTxID, investigationID, f.SourceChain, aborted.AbortTxID, aborted.AbortReason)
+	}
+
+	now, err := TxNow(ctx)
+	if err != nil {
+		return err
+	}
+	if now > deadline {
+		return fmt.Errorf("transfer %s of investigation %s expired at %s and may have been aborted on the %s chain",
+			exportTxID, investigationID, formatDeadline(deadline), f.SourceChain)
+	}
+	return nil
+}
+
+// checkExisting fails if the target chain already holds the case and the flow does not replace it
+func (f TransferFlow) checkExisting(ctx contractapi.TransactionContextInterface, investigationID string) error {
+	existing, err := ctx.GetStub().GetState(InvestigationKey(investigationID))
+	if err != nil {
+		return fmt.Errorf("failed to check investigation existence: %v", err)
+	}
+	if existing != nil && !f.AllowExisting {
+		return fmt.Errorf("investigation %s already exists on %s chain", investigationID, f.TargetChain)
+	}
+	return nil
+}
+
+// storeInvestigation writes an imported case
+func storeInvestigation(ctx contractapi.TransactionContextInterface, investigation *Investigation) error {
+	invBytes, err := json.Marshal(investigation)
+	if err != nil {
+		return fmt.Errorf("failed to marshal investigation: %v", err)
+	}
+	if err := ctx.GetStub().PutState(InvestigationKey(investigation.ID), invBytes); err != nil {
+		return fmt.Errorf("failed to store investigation: %v", err)
+	}
+	return nil
+}
+
+// storeEvidence writes imported evidence and its archive metadata
+func storeEvidence(ctx contractapi.TransactionContextInterface, evidenceList []Evidence,
+	metadataList []ArchiveMetadata) error {
+
+	for _, evidence := range evidenceList {
+		evidenceBytes, err := json.Marshal(evidence)
+		if err != nil {
+			return fmt.Errorf("failed to marshal evidence %s: %v", evidence.ID, err)
+		}
+		if err := ctx.GetStub().PutState(EvidenceKey(evidence.ID), evidenceBytes); err != nil {
+			return fmt.Errorf("failed to store evidence %s: %v", evidence.ID, err)
+		}
+	}
+
+	for _, metadata := range metadataList {
+		metadataBytes, err := json.Marshal(metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal archive metadata: %v", err)
+		}
+		if err := ctx.GetStub().PutState(StateKey(RecordArchiveMetadata, metadata.EvidenceID), metadataBytes); err != nil {
+			return fmt.Errorf("failed to store archive metadata: %v", err)
+		}
+	}
+	return nil
+}
+
+// storeImport writes the import record of a package
+func storeImport(ctx contractapi.TransactionContextInterface, exportPackage *CaseExportPackage,
+	packageHash string, evidenceCount int, importedBy string, now int64) (*CaseImport, error) {
+
+	txID := ctx.GetStub().GetTxID()
+	importRecord := &CaseImport{
+		InvestigationID: exportPackage.Investigation.ID,
+		SourceChain:     exportPackage.SourceChain,
+		SourceTxID:      exportPackage.TransferTxID,
+		CourtOrder:      exportPackage.CourtOrder,
+		ImportedAt:      now,
+		ImportedBy:      importedBy,
+		ImportTxID:      txID,
+		EvidenceCount:   evidenceCount,
+		PackageHash:     packageHash,
+	}
+	importBytes, err := json.Marshal(importRecord)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal import record: %v", err)
+	}
+	if err := ctx.GetStub().PutState(StateKey(RecordImport, importRecord.InvestigationID, txID), importBytes); err != nil {
+		return nil, fmt.Errorf("failed to store import record: %v", err)
+	}
+	return importRecord, nil
+}
+
+// CompleteTransfer moves an in-flight case on the source chain to its completed
+// status and records the target chain's import transaction. proofJSON is a
+// TransferProof holding the target chain block that committed the import; the
+// import transaction and the package hash it recorded are read from that
+// block, and the transfer completes only if the hash is the one the export
+// committed to. On a mismatch it marks the transfer rejected and leaves the
+// case in flight; the returned record says which happened.
+func (f TransferFlow) CompleteTransfer(ctx contractapi.TransactionContextInterface,
+	investigationID string, proofJSON string) (*CaseTransfer, error) {
+
+	investigation, err := LoadInvestigation(ctx, investigationID)
+	if err != nil {
+		return nil, err
+	}
+	if investigation == nil {
+		return nil, fmt.Errorf("investigation %s does not exist", investigationID)
+	}
+
+	transfer, err := f.LoadCaseTransfer(ctx, investigationID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer == nil {
+		return nil, fmt.Errorf("no export of investigation %s for %s", investigationID, f.Direction)
+	}
+	if transfer.State != TransferStateExported {
+		return nil, fmt.Errorf("transfer of investigation %s is already %s", investigationID, transfer.State)
+	}
+
+	// Verify current status
+	if investigation.Status != f.PendingStatus {
+		return nil, fmt.Errorf("invalid status for completion: %s", investigation.Status)
+	}
+	if err := f.upgradeHash(ctx, transfer); err != nil {
+		return nil, err
+	}
+
+	// Only an import the target chain provably committed completes the transfer
+	var proof TransferProof
+	if err := json.Unmarshal([]byte(proofJSON), &proof); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal import proof: %v", err)
+	}
+	trust, err := LoadTransferTrust(ctx, f.TargetChain)
+	if err != nil {
+		return nil, err
+	}
+	importRecord, err := VerifyImportProof(trust, &proof, investigationID, transfer.ExportTxID)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := TxNow(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// A package that changed in flight is flagged, not completed
+	if !transfer.Settle(importRecord, now) {
+		if err := saveCaseTransfer(ctx, transfer); err != nil {
+			return nil, err
+		}
+		return transfer, nil
+	}
+
+	investigation.Status = f.CompletedStatus
+	investigation.UpdatedAt = now
+	invBytes, err := json.Marshal(investigation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal investigation: %v", err)
+	}
+	if err := ctx.GetStub().PutState(InvestigationKey(investigationID), invBytes); err != nil {
+		return nil, fmt.Errorf("failed to update investigation: %v", err)
+	}
+
+	// Store completion record
+	completionRecord := map[string]interface{}{
+		"investigation_id":             investigationID,
+		f.TargetChain + "_chain_tx_id": importRecord.ImportTxID,
+		"package_hash":                 importRecord.PackageHash,
+		"completed_at":                 now,
+	}
+	completionBytes, _ := json.Marshal(completionRecord)
+	completionKey := StateKey(RecordTransferComplete, f.Direction, investigationID)
+	if err := ctx.GetStub().PutState(completionKey, completionBytes); err != nil {
+		return nil, fmt.Errorf("failed to store completion record: %v", err)
+	}
+	if err := saveCaseTransfer(ctx, transfer); err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
@@ -0,0 +1,236 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ==============================================================================
+// TRANSFER DEADLINES AND ABORTS
+// ==============================================================================
+//
+// An exported case stays in flight until its transfer completes, so a transfer
+// whose import never happens would hold the case forever. Every export
+// therefore carries a deadline, expires_at, set from the source chain's
+// transfer timeout. The target chain refuses to import a package after its
+// deadline, and only after the deadline may the source chain abort the
+// transfer with AbortTransfer, which puts the case back to the status it had
+// before the export. As the deadline is part of the proven package, both
+// chains hold the transfer to the same one. The deadline is compared with the
+// proposal timestamp, which the client chooses, so the target chain also
+// takes the abort itself: RecordAbort stores the abort proven by the source
+// chain block that committed it, and from then on every import step of that
+// export is refused whatever its timestamp. The relayer records each abort it
+// sees. A transfer rejected for a package hash mismatch may be aborted at
+// once: its import already happened and the case cannot complete.
+//
+// Packages exported without a deadline are held to the exported_at of the
+// package plus the chain's own transfer timeout, so both chains should be
+// configured with the same timeout.
+
+// TransferTimeoutKey is the world state key of the transfer timeout
+var TransferTimeoutKey = StateKey(RecordConfig, "transfer_timeout")
+
+// Bounds of the transfer timeout, in seconds
+const (
+	DefaultTransferTimeout int64 = 72 * 60 * 60 // Until a SystemAdmin sets one
+	MinTransferTimeout     int64 = 10 * 60      // Leaves the relayer time to import
+)
+
+// TransferTimeout is how long an exported case may wait for its import
+type TransferTimeout struct {
+	Seconds   int64  `json:"seconds"`
+	UpdatedAt int64  `json:"updated_at"`
+	UpdatedBy string `json:"updated_by"`
+}
+
+// LoadTransferTimeout reads the transfer timeout, DefaultTransferTimeout if none was set
+func LoadTransferTimeout(ctx contractapi.TransactionContextInterface) (*TransferTimeout, error) {
+	timeoutJSON, err := ctx.GetStub().GetState(TransferTimeoutKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transfer timeout: %v", err)
+	}
+	if timeoutJSON == nil {
+		return &TransferTimeout{Seconds: DefaultTransferTimeout}, nil
+	}
+
+	var timeout TransferTimeout
+	if err := json.Unmarshal(timeoutJSON, &timeout); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal transfer timeout: %v", err)
+	}
+	return &timeout, nil
+}
+
+// SaveTransferTimeout stores a new transfer timeout. It applies to exports from
+// now on; transfers in flight keep the deadline they were exported with.
+func SaveTransferTimeout(ctx contractapi.TransactionContextInterface, seconds int64) ([]byte, error) {
+	if seconds < MinTransferTimeout {
+		return nil, fmt.Errorf("transfer timeout must be at least %d seconds", MinTransferTimeout)
+	}
+
+	now, err := TxNow(ctx)
+	if err != nil {
+		return nil, err
+	}
+	clientID, _ := ctx.GetClientIdentity().GetID()
+	timeoutJSON, err := json.Marshal(TransferTimeout{Seconds: seconds, UpdatedAt: now, UpdatedBy: clientID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal transfer timeout: %v", err)
+	}
+	if err := ctx.GetStub().PutState(TransferTimeoutKey, timeoutJSON); err != nil {
+		return nil, fmt.Errorf("failed to store transfer timeout: %v", err)
+	}
+	return timeoutJSON, nil
+}
+
+// TransferDeadline returns the deadline of a transfer exported at exportedAt:
+// expiresAt if the export set one, otherwise exportedAt plus the chain's transfer timeout
+func TransferDeadline(ctx contractapi.TransactionContextInterface, exportedAt int64, expiresAt int64) (int64, error) {
+	if expiresAt != 0 {
+		return expiresAt, nil
+	}
+	timeout, err := LoadTransferTimeout(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return exportedAt + timeout.Seconds, nil
+}
+
+// formatDeadline renders a deadline for error messages
+func formatDeadline(deadline int64) string {
+	return time.Unix(deadline, 0).UTC().Format(time.RFC3339)
+}
+
+// AbortTransfer cancels a case transfer on the source chain once its deadline
+// has passed, or at once if it was rejected, and returns the case to the
+// status it had before the export. It returns the aborted transfer record.
+func (f TransferFlow) AbortTransfer(ctx contractapi.TransactionContextInterface,
+	investigationID string, reason string) (*CaseTransfer, error) {
+
+	if reason == "" {
+		return nil, fmt.Errorf("a reason is required to abort a transfer")
+	}
+
+	transfer, err := f.LoadCaseTransfer(ctx, investigationID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer == nil {
+		return nil, fmt.Errorf("no export of investigation %s for %s", investigationID, f.Direction)
+	}
+	if transfer.State != TransferStateExported && transfer.State != TransferStateRejected {
+		return nil, fmt.Errorf("transfer of investigation %s is already %s", investigationID, transfer.State)
+	}
+
+	investigation, err := LoadInvestigation(ctx, investigationID)
+	if err != nil {
+		return nil, err
+	}
+	if investigation == nil {
+		return nil, fmt.Errorf("investigation %s does not exist", investigationID)
+	}
+	if investigation.Status != f.PendingStatus {
+		return nil, fmt.Errorf("invalid status for abort: %s", investigation.Status)
+	}
+
+	now, err := TxNow(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Until the deadline the target chain may still import the package
+	if transfer.State == TransferStateExported {
+		deadline, err := TransferDeadline(ctx, transfer.ExportedAt, transfer.ExpiresAt)
+		if err != nil {
+			return nil, err
+		}
+		if now <= deadline {
+			return nil, fmt.Errorf("transfer of investigation %s can be aborted after its deadline %s",
+				investigationID, formatDeadline(deadline))
+		}
+	}
+
+	priorStatus := transfer.PriorStatus
+	if priorStatus == "" {
+		priorStatus = f.ExportStatus
+	}
+	investigation.Status = priorStatus
+	investigation.UpdatedAt = now
+	invBytes, err := json.Marshal(investigation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal investigation: %v", err)
+	}
+	if err := ctx.GetStub().PutState(InvestigationKey(investigationID), invBytes); err != nil {
+		return nil, fmt.Errorf("failed to restore investigation status: %v", err)
+	}
+
+	clientID, _ := ctx.GetClientIdentity().GetID()
+	transfer.State = TransferStateAborted
+	transfer.AbortedAt = now
+	transfer.AbortedBy = clientID
+	transfer.AbortReason = reason
+	transfer.AbortTxID = ctx.GetStub().GetTxID()
+	if err := saveCaseTransfer(ctx, transfer); err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// TransferAbortKey is the world state key of the abort of the transfer exported
+// in exportTxID, as recorded on the target chain
+func TransferAbortKey(investigationID string, exportTxID string) string {
+	return StateKey(RecordTransferAbort, investigationID, exportTxID)
+}
+
+// RecordAbort stores on the flow's target chain the abort of a transfer.
+// proofJSON is a TransferProof holding the source chain block that committed
+// the abort. It returns the aborted transfer record.
+func (f TransferFlow) RecordAbort(ctx contractapi.TransactionContextInterface,
+	investigationID string, proofJSON string) (*CaseTransfer, error) {
+
+	var proof TransferProof
+	if err := json.Unmarshal([]byte(proofJSON), &proof); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal abort proof: %v", err)
+	}
+	trust, err := LoadTransferTrust(ctx, f.SourceChain)
+	if err != nil {
+		return nil, err
+	}
+	transfer, err := VerifyAbortProof(trust, &proof, f.Direction, investigationID)
+	if err != nil {
+		return nil, err
+	}
+
+	transferJSON, err := json.Marshal(transfer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal aborted transfer: %v", err)
+	}
+	if err := ctx.GetStub().PutState(TransferAbortKey(investigationID, transfer.ExportTxID), transferJSON); err != nil {
+		return nil, fmt.Errorf("failed to store transfer abort: %v", err)
+	}
+	return transfer, nil
+}
+
+// LoadTransferAbort reads the recorded abort of the transfer exported in
+// exportTxID, or nil if none was recorded
+func LoadTransferAbort(ctx contractapi.TransactionContextInterface,
+	investigationID string, exportTxID string) (*CaseTransfer, error) {
+
+	transferJSON, err := ctx.GetStub().GetState(TransferAbortKey(investigationID, exportTxID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transfer abort: %v", err)
+	}
+	if transferJSON == nil {
+		return nil, nil
+	}
+
+	var transfer CaseTransfer
+	if err := json.Unmarshal(transferJSON, &transfer); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal transfer abort: %v", err)
+	}
+	return &transfer, nil
+}
@@ -0,0 +1,551 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ==============================================================================
+// CHUNKED CASE TRANSFER
+// ==============================================================================
+//
+// A case with thousands of evidence items does not fit in one Fabric
+// transaction, so ExportCase sends a case with more than ChunkSize items in
+// chunks. Its package carries no evidence but a manifest that lists the
+// evidence IDs of every chunk and the hash of the chunk's JSON; the package
+// hash and the transfer proof therefore cover every chunk. The source chain
+// serves each chunk from its world state (ExportChunk) and the target chain
+// imports the case in several transactions:
+//
+//   BeginImport     verifies the proven package and opens an import session
+//   ImportChunk     stores the evidence of one chunk whose hash matches the manifest
+//   FinalizeImport  checks that every chunk is in, stores the case and the import record
+//
+// Each step may be repeated: a session or chunk that is already stored is
+// returned as is, so an import interrupted at any point resumes with the
+// chunks LoadImportProgress reports missing. The import record, and so the
+// hash reported to CompleteTransfer, is only written by FinalizeImport. Every
+// step is refused after the transfer's deadline or once its abort is recorded
+// (see RecordAbort); evidence of an abandoned session stays on the target
+// chain until a later transfer of the case replaces it.
+
+// ChunkSize is the number of evidence items per chunk, and the most a package carries itself
+const ChunkSize = 200
+
+// TransferManifest lists the chunks of a chunked package
+type TransferManifest struct {
+	EvidenceCount int             `json:"evidence_count"`
+	Chunks        []ManifestChunk `json:"chunks"`
+}
+
+// ManifestChunk is one chunk of a chunked package
+type ManifestChunk struct {
+	Index       int      `json:"index"`
+	EvidenceIDs []string `json:"evidence_ids"`
+	Hash        string   `json:"hash"` // Hex SHA-256 of the chunk JSON served by ExportChunk
+}
+
+// ImportSession is the target chain's record of a chunked import in progress
+type ImportSession struct {
+	InvestigationID string            `json:"investigation_id"`
+	SourceTxID      string            `json:"source_tx_id"`
+	PackageHash     string            `json:"package_hash"`
+	Package         CaseExportPackage `json:"package"` // Without its proof
+	Deadline        int64             `json:"deadline"`
+	StartedAt       int64             `json:"started_at"`
+	StartedBy       string            `json:"started_by"`
+	StartTxID       string            `json:"start_tx_id"`
+
+	FinalizedAt int64  `json:"finalized_at,omitempty" metadata:",optional"`
+	ImportTxID  string `json:"import_tx_id,omitempty" metadata:",optional"` // Transaction of FinalizeImport
+}
+
+// ImportedChunk is the target chain's record of an imported chunk
+type ImportedChunk struct {
+	InvestigationID string `json:"investigation_id"`
+	SourceTxID      string `json:"source_tx_id"`
+	Index           int    `json:"index"`
+	Hash            string `json:"hash"`
+	EvidenceCount   int    `json:"evidence_count"`
+	ImportedAt      int64  `json:"imported_at"`
+	ImportedBy      string `json:"imported_by"`
+	ImportTxID      string `json:"import_tx_id"`
+}
+
+// ImportProgress tells which chunks of a chunked import are stored
+type ImportProgress struct {
+	Session  *ImportSession `json:"session"`
+	Imported []int          `json:"imported_chunks"`
+	Missing  []int          `json:"missing_chunks"`
+}
+
+// ImportSessionKey is the world state key of the chunked import of an export
+func ImportSessionKey(investigationID string, exportTxID string) string {
+	return StateKey(RecordImportSession, investigationID, exportTxID)
+}
+
+// ImportChunkKey is the world state key of an imported chunk. The index is
+// zero padded so that the chunks of an import list in order.
+func ImportChunkKey(investigationID string, exportTxID string, index int) string {
+	return StateKey(RecordImportChunk, investigationID, exportTxID, fmt.Sprintf("%06d", index))
+}
+
+// encodeChunk returns the JSON of a chunk and its hash
+func encodeChunk(evidence []Evidence) ([]byte, string, error) {
+	chunkJSON, err := json.Marshal(evidence)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal chunk: %v", err)
+	}
+	hash := sha256.Sum256(chunkJSON)
+	return chunkJSON, hex.EncodeToString(hash[:]), nil
+}
+
+// newManifest splits the evidence of a case into chunks of ChunkSize items, ordered by evidence ID
+func newManifest(evidenceList []Evidence) (*TransferManifest, error) {
+	sorted := append([]Evidence(nil), evidenceList...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	manifest := &TransferManifest{EvidenceCount: len(sorted)}
+	for start := 0; start < len(sorted); start += ChunkSize {
+		end := start + ChunkSize
+		if end > len(sorted) {
+			end = len(sorted)
+		}
+		_, hash, err := encodeChunk(sorted[start:end])
+		if err != nil {
+			return nil, err
+		}
+		chunk := ManifestChunk{Index: len(manifest.Chunks), Hash: hash}
+		for _, evidence := range sorted[start:end] {
+			chunk.EvidenceIDs = append(chunk.EvidenceIDs, evidence.ID)
+		}
+		manifest.Chunks = append(manifest.Chunks, chunk)
+	}
+	return manifest, nil
+}
+
+// check lists what makes a manifest unusable, for CheckPackage
+func (m *TransferManifest) check() []string {
+	var invalid []string
+	count := 0
+	seen := map[string]bool{}
+	for i, chunk := range m.Chunks {
+		if chunk.Index != i {
+			invalid = append(invalid, fmt.Sprintf("manifest chunk %d (has index %d)", i, chunk.Index))
+		}
+		if len(chunk.EvidenceIDs) == 0 {
+			invalid = append(invalid, fmt.Sprintf("manifest chunk %d (lists no evidence)", i))
+		}
+		for _, id := range chunk.EvidenceIDs {
+			if err := CheckKeyAttribute("evidence ID", id); err != nil {
+				invalid = append(invalid, fmt.Sprintf("manifest chunk %d (%v)", i, err))
+			} else if seen[id] {
+				invalid = append(invalid, fmt.Sprintf("manifest chunk %d (evidence %s listed twice)", i, id))
+			}
+			seen[id] = true
+		}
+		count += len(chunk.EvidenceIDs)
+	}
+	if len(m.Chunks) == 0 {
+		invalid = append(invalid, "manifest (lists no chunks)")
+	}
+	if count != m.EvidenceCount {
+		invalid = append(invalid, fmt.Sprintf("manifest (lists %d evidence items, declares %d)", count, m.EvidenceCount))
+	}
+	return invalid
+}
+
+// ExportChunk returns the JSON of one chunk of a chunked export on the source
+// chain, read from the world state and checked against the export's manifest
+func (f TransferFlow) ExportChunk(ctx contractapi.TransactionContextInterface,
+	investigationID string, exportTxID string, index int) (string, error) {
+
+	packageJSON, err := ctx.GetStub().GetState(StateKey(RecordExport, investigationID, exportTxID))
+	if err != nil {
+		return "", fmt.Errorf("failed to read export record: %v", err)
+	}
+	if packageJSON == nil {
+		return "", fmt.Errorf("export record %s of investigation %s not found", exportTxID, investigationID)
+	}
+	exportPackage, err := DecodePackage(packageJSON)
+	if err != nil {
+		return "", fmt.Errorf("export record %s: %v", exportTxID, err)
+	}
+	if exportPackage.SourceChain != f.SourceChain {
+		return "", fmt.Errorf("export %s of investigation %s is not a %s export", exportTxID, investigationID, f.Direction)
+	}
+	if exportPackage.Manifest == nil {
+		return "", fmt.Errorf("export %s of investigation %s is not chunked", exportTxID, investigationID)
+	}
+	if index < 0 || index >= len(exportPackage.Manifest.Chunks) {
+		return "", fmt.Errorf("export %s of investigation %s has no chunk %d", exportTxID, investigationID, index)
+	}
+
+	chunk := exportPackage.Manifest.Chunks[index]
+	evidenceList := make([]Evidence, 0, len(chunk.EvidenceIDs))
+	for _, evidenceID := range chunk.EvidenceIDs {
+		evidence, err := LoadEvidence(ctx, evidenceID)
+		if err != nil {
+			return "", err
+		}
+		if evidence == nil {
+			return "", fmt.Errorf("evidence %s of chunk %d no longer exists", evidenceID, index)
+		}
+		evidenceList = append(evidenceList, *evidence)
+	}
+
+	chunkJSON, hash, err := encodeChunk(evidenceList)
+	if err != nil {
+		return "", err
+	}
+	if hash != chunk.Hash {
+		return "", fmt.Errorf("evidence of chunk %d changed since export %s: hash %s, manifest lists %s",
+			index, exportTxID, hash, chunk.Hash)
+	}
+	return string(chunkJSON), nil
+}
+
+// LoadImportSession reads the chunked import of an export, or nil if none was begun
+func LoadImportSession(ctx contractapi.TransactionContextInterface,
+	investigationID string, exportTxID string) (*ImportSession, error) {
+
+	sessionJSON, err := ctx.GetStub().GetState(ImportSessionKey(investigationID, exportTxID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read import session: %v", err)
+	}
+	if sessionJSON == nil {
+		return nil, nil
+	}
+
+	var session ImportSession
+	if err := json.Unmarshal(sessionJSON, &session); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal import session: %v", err)
+	}
+	return &session, nil
+}
+
+// saveImportSession stores a chunked import session
+func saveImportSession(ctx contractapi.TransactionContextInterface, session *ImportSession) error {
+	sessionJSON, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to marshal import session: %v", err)
+	}
+	if err := ctx.GetStub().PutState(ImportSessionKey(session.InvestigationID, session.SourceTxID), sessionJSON); err != nil {
+		return fmt.Errorf("failed to store import session: %v", err)
+	}
+	return nil
+}
+
+// openSession reads the unfinished session of a chunked import that is neither
+// aborted nor past its deadline
+func (f TransferFlow) openSession(ctx contractapi.TransactionContextInterface,
+	investigationID string, exportTxID string) (*ImportSession, error) {
+
+	session, err := LoadImportSession(ctx, investigationID, exportTxID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, fmt.Errorf("no import of export %s of investigation %s was begun", exportTxID, investigationID)
+	}
+	if session.ImportTxID != "" {
+		return nil, fmt.Errorf("export %s of investigation %s was already imported in transaction %s",
+			exportTxID, investigationID, session.ImportTxID)
+	}
+	if err := f.checkTransferOpen(ctx, investigationID, exportTxID, session.Deadline); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// importedChunks lists the chunks of a chunked import stored so far, in index order
+func importedChunks(ctx contractapi.TransactionContextInterface,
+	investigationID string, exportTxID string) ([]ImportedChunk, error) {
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(RecordImportChunk,
+		[]string{investigationID, exportTxID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query imported chunks: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var chunks []ImportedChunk
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate imported chunks: %v", err)
+		}
+		var chunk ImportedChunk
+		if err := json.Unmarshal(queryResponse.Value, &chunk); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal imported chunk %s: %v", queryResponse.Key, err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// BeginImport opens the chunked import of a package on the flow's target
+// chain once the package passes the checks of ImportCase. Beginning an import
+// that is already open returns its session unchanged.
+func (f TransferFlow) BeginImport(ctx contractapi.TransactionContextInterface,
+	packageJSON string) (*ImportSession, error) {
+
+	exportPackage, packageHash, err := f.verifyImport(ctx, packageJSON)
+	if err != nil {
+		return nil, err
+	}
+	investigationID := exportPackage.Investigation.ID
+	exportTxID := exportPackage.TransferTxID
+	if exportPackage.Manifest == nil {
+		return nil, fmt.Errorf("export %s of investigation %s is not chunked, import it whole",
+			exportTxID, investigationID)
+	}
+
+	existing, err := LoadImportSession(ctx, investigationID, exportTxID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		if existing.ImportTxID != "" {
+			return nil, fmt.Errorf("export %s of investigation %s was already imported in transaction %s",
+				exportTxID, investigationID, existing.ImportTxID)
+		}
+		if existing.PackageHash != packageHash {
+			return nil, fmt.Errorf("import of export %s of investigation %s was begun with package hash %s, not %s",
+				exportTxID, investigationID, existing.PackageHash, packageHash)
+		}
+		return existing, nil
+	}
+
+	deadline, err := TransferDeadline(ctx, exportPackage.ExportedAt, exportPackage.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	now, err := TxNow(ctx)
+	if err != nil {
+		return nil, err
+	}
+	clientID, _ := ctx.GetClientIdentity().GetID()
+
+	exportPackage.Proof = nil // Verified, and the session only needs what it proves
+	session := &ImportSession{
+		InvestigationID: investigationID,
+		SourceTxID:      exportTxID,
+		PackageHash:     packageHash,
+		Package:         *exportPackage,
+		Deadline:        deadline,
+		StartedAt:       now,
+		StartedBy:       clientID,
+		StartTxID:       ctx.GetStub().GetTxID(),
+	}
+	if err := saveImportSession(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// ImportChunk stores the evidence of one chunk of an open chunked import,
+// provided the chunk JSON hashes to the manifest entry of its index.
+// Importing a chunk that is already stored returns its record unchanged.
+func (f TransferFlow) ImportChunk(ctx contractapi.TransactionContextInterface,
+	investigationID string, exportTxID string, index int, chunkJSON string) (*ImportedChunk, error) {
+
+	session, err := f.openSession(ctx, investigationID, exportTxID)
+	if err != nil {
+		return nil, err
+	}
+	manifest := session.Package.Manifest
+	if index < 0 || index >= len(manifest.Chunks) {
+		return nil, fmt.Errorf("export %s of investigation %s has no chunk %d", exportTxID, investigationID, index)
+	}
+
+	existingJSON, err := ctx.GetStub().GetState(ImportChunkKey(investigationID, exportTxID, index))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read imported chunk: %v", err)
+	}
+	if existingJSON != nil {
+		var existing ImportedChunk
+		if err := json.Unmarshal(existingJSON, &existing); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal imported chunk: %v", err)
+		}
+		return &existing, nil
+	}
+
+	// The manifest is proven, so a chunk matching its hash is what the source chain exported
+	chunk := manifest.Chunks[index]
+	hash := sha256.Sum256([]byte(chunkJSON))
+	if hex.EncodeToString(hash[:]) != chunk.Hash {
+		return nil, fmt.Errorf("chunk %d of export %s has hash %s, the manifest lists %s",
+			index, exportTxID, hex.EncodeToString(hash[:]), chunk.Hash)
+	}
+	var evidence []Evidence
+	if err := json.Unmarshal([]byte(chunkJSON), &evidence); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal chunk %d: %v", index, err)
+	}
+	if len(evidence) != len(chunk.EvidenceIDs) {
+		return nil, fmt.Errorf("chunk %d holds %d evidence items, the manifest lists %d",
+			index, len(evidence), len(chunk.EvidenceIDs))
+	}
+	for i, item := range evidence {
+		if item.ID != chunk.EvidenceIDs[i] {
+			return nil, fmt.Errorf("chunk %d holds evidence %s where the manifest lists %s",
+				index, item.ID, chunk.EvidenceIDs[i])
+		}
+	}
+
+	chunkPackage := session.Package
+	chunkPackage.Evidence = evidence
+	chunkPackage.Manifest = nil
+	if err := f.CheckPackage(&chunkPackage); err != nil {
+		return nil, fmt.Errorf("chunk %d: %v", index, err)
+	}
+
+	now, err := TxNow(ctx)
+	if err != nil {
+		return nil, err
+	}
+	clientID, _ := ctx.GetClientIdentity().GetID()
+
+	_, evidenceList, metadataList := f.ImportRecords(&chunkPackage, clientID, now)
+	if err := storeEvidence(ctx, evidenceList, metadataList); err != nil {
+		return nil, err
+	}
+
+	record := &ImportedChunk{
+		InvestigationID: investigationID,
+		SourceTxID:      exportTxID,
+		Index:           index,
+		Hash:            chunk.Hash,
+		EvidenceCount:   len(evidence),
+		ImportedAt:      now,
+		ImportedBy:      clientID,
+		ImportTxID:      ctx.GetStub().GetTxID(),
+	}
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal imported chunk: %v", err)
+	}
+	if err := ctx.GetStub().PutState(ImportChunkKey(investigationID, exportTxID, index), recordJSON); err != nil {
+		return nil, fmt.Errorf("failed to store imported chunk: %v", err)
+	}
+	return record, nil
+}
+
+// FinalizeImport completes a chunked import once every chunk is stored: it
+// writes the case and the import record, with the hash of the package the
+// session was begun with, and closes the session. It returns the import record.
+func (f TransferFlow) FinalizeImport(ctx contractapi.TransactionContextInterface,
+	investigationID string, exportTxID string) (*CaseImport, error) {
+
+	session, err := f.openSession(ctx, investigationID, exportTxID)
+	if err != nil {
+		return nil, err
+	}
+	manifest := session.Package.Manifest
+
+	chunks, err := importedChunks(ctx, investigationID, exportTxID)
+	if err != nil {
+		return nil, err
+	}
+	imported := map[int]bool{}
+	evidenceCount := 0
+	for _, chunk := range chunks {
+		imported[chunk.Index] = true
+		evidenceCount += chunk.EvidenceCount
+	}
+	if missing := missingChunks(manifest, imported); len(missing) != 0 {
+		return nil, fmt.Errorf("import of export %s of investigation %s is missing %d of %d chunks: %s",
+			exportTxID, investigationID, len(missing), len(manifest.Chunks), formatIndexes(missing))
+	}
+	if evidenceCount != manifest.EvidenceCount {
+		return nil, fmt.Errorf("chunks of export %s hold %d evidence items, the manifest declares %d",
+			exportTxID, evidenceCount, manifest.EvidenceCount)
+	}
+
+	// The case may have been stored since the import was begun
+	if err := f.checkExisting(ctx, investigationID); err != nil {
+		return nil, err
+	}
+
+	now, err := TxNow(ctx)
+	if err != nil {
+		return nil, err
+	}
+	clientID, _ := ctx.GetClientIdentity().GetID()
+
+	investigation, _, _ := f.ImportRecords(&session.Package, clientID, now)
+	if err := storeInvestigation(ctx, &investigation); err != nil {
+		return nil, err
+	}
+	importRecord, err := storeImport(ctx, &session.Package, session.PackageHash, evidenceCount, clientID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	session.FinalizedAt = now
+	session.ImportTxID = importRecord.ImportTxID
+	if err := saveImportSession(ctx, session); err != nil {
+		return nil, err
+	}
+	return importRecord, nil
+}
+
+// LoadImportProgress reports which chunks of the chunked import of an export
+// are stored and which are missing. It returns nil if no import was begun.
+func LoadImportProgress(ctx contractapi.TransactionContextInterface,
+	investigationID string, exportTxID string) (*ImportProgress, error) {
+
+	session, err := LoadImportSession(ctx, investigationID, exportTxID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, nil
+	}
+	chunks, err := importedChunks(ctx, investigationID, exportTxID)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := &ImportProgress{Session: session, Imported: []int{}}
+	imported := map[int]bool{}
+	for _, chunk := range chunks {
+		imported[chunk.Index] = true
+		progress.Imported = append(progress.Imported, chunk.Index)
+	}
+	progress.Missing = missingChunks(session.Package.Manifest, imported)
+	return progress, nil
+}
+
+// missingChunks lists the indexes of the manifest's chunks that are not imported
+func missingChunks(manifest *TransferManifest, imported map[int]bool) []int {
+	missing := []int{}
+	for _, chunk := range manifest.Chunks {
+		if !imported[chunk.Index] {
+			missing = append(missing, chunk.Index)
+		}
+	}
+	return missing
+}
+
+// formatIndexes renders chunk indexes for error messages, eliding long lists
+func formatIndexes(indexes []int) string {
+	const shown = 10
+	text := ""
+	for i, index := range indexes {
+		if i == shown {
+			return text + fmt.Sprintf(", ... (%d more)", len(indexes)-shown)
+		}
+		if i > 0 {
+			text += ", "
+		}
+		text += strconv.Itoa(index)
+	}
+	return text
+}
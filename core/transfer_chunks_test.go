@@ -0,0 +1,73 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestManifestChunksEvidenceByID(t *testing.T) {
+	var evidence []Evidence
+	for i := 2*ChunkSize + 50; i > 0; i-- {
+		evidence = append(evidence, Evidence{ID: fmt.Sprintf("EVD-%04d", i), CaseID: "INV-001"})
+	}
+	manifest, err := newManifest(evidence)
+	if err != nil {
+		t.Fatalf("failed to build manifest: %v", err)
+	}
+
+	if manifest.EvidenceCount != len(evidence) || len(manifest.Chunks) != 3 {
+		t.Fatalf("manifest of %d items in %d chunks, want %d in 3", manifest.EvidenceCount, len(manifest.Chunks), len(evidence))
+	}
+	last := manifest.Chunks[2]
+	if len(last.EvidenceIDs) != 50 || last.EvidenceIDs[0] != "EVD-0401" || last.EvidenceIDs[49] != "EVD-0450" {
+		t.Errorf("last chunk lists %d items from %s", len(last.EvidenceIDs), last.EvidenceIDs[0])
+	}
+	chunk := make([]Evidence, 0, 50)
+	for _, id := range last.EvidenceIDs {
+		chunk = append(chunk, Evidence{ID: id, CaseID: "INV-001"})
+	}
+	if _, hash, _ := encodeChunk(chunk); hash != last.Hash {
+		t.Errorf("chunk hash %s, manifest lists %s", hash, last.Hash)
+	}
+
+	// A chunked package passes the schema and the checks of the target chain
+	exportPackage := ArchiveFlow.NewPackage(Investigation{ID: "INV-001"}, nil, "ORDER-1", "court", 300, "tx-export")
+	exportPackage.Manifest = manifest
+	packageJSON, err := json.Marshal(exportPackage)
+	if err != nil {
+		t.Fatalf("failed to marshal package: %v", err)
+	}
+	decoded, err := DecodePackage(packageJSON)
+	if err != nil {
+		t.Fatalf("chunked package does not validate: %v", err)
+	}
+	if err := ArchiveFlow.CheckPackage(decoded); err != nil {
+		t.Errorf("chunked package rejected: %v", err)
+	}
+}
+
+func TestCheckPackageListsInvalidManifest(t *testing.T) {
+	exportPackage := ArchiveFlow.NewPackage(Investigation{ID: "INV-001"},
+		[]Evidence{{ID: "EVD-001", CaseID: "INV-001"}}, "ORDER-1", "court", 300, "tx-export")
+	exportPackage.Manifest = &TransferManifest{EvidenceCount: 4, Chunks: []ManifestChunk{
+		{Index: 0, EvidenceIDs: []string{"EVD-001", "EVD-002"}},
+		{Index: 2, EvidenceIDs: []string{"EVD-002"}},
+	}}
+
+	err := ArchiveFlow.CheckPackage(exportPackage)
+	if err == nil {
+		t.Fatalf("package with an invalid manifest accepted")
+	}
+	for _, want := range []string{
+		"evidence (a chunked package carries its evidence in chunks)",
+		"manifest chunk 1 (has index 2)",
+		"manifest chunk 1 (evidence EVD-002 listed twice)",
+		"manifest (lists 3 evidence items, declares 4)",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ==============================================================================
+// TRANSFER CONFIGURATION (SystemAdmin only)
+// ==============================================================================
+//
+// Every export carries a deadline: the export time plus the timeout set with
+// SetTransferTimeout. The target chain refuses the import after the deadline
+// and AbortCaseTransfer may then cancel the transfer. Set the same timeout on
+// both chains; each applies its own to packages exported without a deadline
+// (see transfer_abort.go). Cases are imported from the other chain only with a
+// proof that it committed their export, checked against the trust anchors
+// registered with SetTransferTrust (see transfer_trust.go).
+
+// SetTransferTimeout sets how many seconds an exported case may wait for its
+// import. It applies to exports from now on.
+func (cc ChainContract) SetTransferTimeout(ctx contractapi.TransactionContextInterface,
+	seconds int64) error {
+
+	if err := CheckSystemAdmin(ctx); err != nil {
+		return err
+	}
+
+	// Check PRV signature
+	if err := CheckPRVSignature(ctx); err != nil {
+		return err
+	}
+
+	timeoutJSON, err := SaveTransferTimeout(ctx, seconds)
+	if err != nil {
+		return err
+	}
+
+	// Emit event
+	ctx.GetStub().SetEvent("TransferTimeoutChanged", timeoutJSON)
+
+	// Audit log
+	LogAudit(ctx, "SetTransferTimeout", "transfer.config", "transfer_timeout", "success",
+		fmt.Sprintf("Transfer timeout set to %d seconds", seconds))
+
+	return nil
+}
+
+// GetTransferTimeout returns the transfer timeout, the default until one is set
+func (cc ChainContract) GetTransferTimeout(ctx contractapi.TransactionContextInterface) (*TransferTimeout, error) {
+	return LoadTransferTimeout(ctx)
+}
+
+// SetTransferTrust registers the other chain's channel, chaincode name, peer and
+// orderer MSP root certificates and the number of peer MSPs that must endorse an
+// export. trustJSON is a TransferTrust; each call stores a new version.
+func (cc ChainContract) SetTransferTrust(ctx contractapi.TransactionContextInterface,
+	trustJSON string, change string) error {
+
+	if err := CheckSystemAdmin(ctx); err != nil {
+		return err
+	}
+
+	// Check PRV signature
+	if err := CheckPRVSignature(ctx); err != nil {
+		return err
+	}
+
+	var trust TransferTrust
+	if err := json.Unmarshal([]byte(trustJSON), &trust); err != nil {
+		return fmt.Errorf("failed to unmarshal transfer trust: %v", err)
+	}
+	if trust.SourceChain != cc.TransferSource {
+		return fmt.Errorf("invalid source chain: %s, expected '%s'", trust.SourceChain, cc.TransferSource)
+	}
+	trust.Change = change
+
+	storedJSON, err := SaveTransferTrust(ctx, &trust)
+	if err != nil {
+		return err
+	}
+
+	// Emit event
+	ctx.GetStub().SetEvent("TransferTrustChanged", storedJSON)
+
+	// Audit log
+	LogAudit(ctx, "SetTransferTrust", "transfer.config", "transfer_trust", "success",
+		fmt.Sprintf("Version %d: %d trusted MSPs of %s/%s, %d endorsements required: %s", trust.Version,
+			len(trust.MSPs), trust.ChannelID, trust.ChaincodeName, trust.MinEndorsements, change))
+
+	return nil
+}
+
+// GetTransferTrust returns the registered trust anchors of the other chain
+func (cc ChainContract) GetTransferTrust(ctx contractapi.TransactionContextInterface) (*TransferTrust, error) {
+	return LoadTransferTrust(ctx, cc.TransferSource)
+}
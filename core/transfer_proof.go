@@ -0,0 +1,451 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ==============================================================================
+// TRANSFER PROOFS
+// ==============================================================================
+//
+// The importing chain accepts a package only together with the source chain
+// block that committed its export transaction. The block carries everything
+// needed to check the package offline:
+//   - the block header, whose data hash covers every transaction in the block
+//     and which an orderer of the source chain signed
+//   - the export transaction, marked valid by the source chain's committers
+//   - the endorsements of the export by the source chain's peers, which sign
+//     the transaction's write set, including the export record holding the
+//     package itself
+// Certificates are checked against the roots registered with SetTransferTrust
+// at the time the export transaction was proposed, so the check is the same
+// on every endorser. The source chain completes the transfer the same way,
+// with the target chain block that committed the import record (see
+// VerifyImportProof).
+
+// TransferProof is a chain's evidence that it committed its side of a transfer
+type TransferProof struct {
+	Block []byte `json:"block"` // Block holding the export or import transaction, as returned by qscc GetBlockByTxID
+}
+
+// blockHeader is the ASN.1 encoding of a block header that orderers sign
+type blockHeader struct {
+	Number       *big.Int
+	PreviousHash []byte
+	DataHash     []byte
+}
+
+// VerifyTransferProof checks that the proof of exportPackage is a block of the
+// trusted source chain that committed exactly this package. The package is
+// compared field by field with the export record written by the endorsed transaction.
+func VerifyTransferProof(trust *TransferTrust, exportPackage *CaseExportPackage) error {
+	if exportPackage.Proof == nil || len(exportPackage.Proof.Block) == 0 {
+		return fmt.Errorf("export package %s carries no transfer proof", exportPackage.TransferTxID)
+	}
+	block, err := proofBlock(exportPackage.Proof)
+	if err != nil {
+		return err
+	}
+
+	// Find the export transaction
+	var tx *blockTransaction
+	for i, envelopeBytes := range block.Data.Data {
+		payload, header, err := unmarshalEnvelope(envelopeBytes)
+		if err != nil {
+			return fmt.Errorf("invalid transfer proof: transaction %d: %v", i, err)
+		}
+		if header.TxId == exportPackage.TransferTxID {
+			tx = &blockTransaction{index: i, header: header, payload: payload}
+			break
+		}
+	}
+	if tx == nil {
+		return fmt.Errorf("invalid transfer proof: block %d does not contain transaction %s",
+			block.Header.Number, exportPackage.TransferTxID)
+	}
+
+	// The endorsed write set must hold this package as the export record
+	exportKey := StateKey(RecordExport, exportPackage.Investigation.ID, exportPackage.TransferTxID)
+	exportedJSON, err := verifyCommittedWrite(trust, block, tx, exportKey)
+	if err != nil {
+		return err
+	}
+	exported, err := DecodePackage(exportedJSON)
+	if err != nil {
+		return fmt.Errorf("invalid transfer proof: endorsed export record: %v", err)
+	}
+	received := *exportPackage
+	received.Proof = nil
+	if !reflect.DeepEqual(*exported, received) {
+		return fmt.Errorf("invalid transfer proof: package differs from the export record committed by transaction %s",
+			tx.header.TxId)
+	}
+	return nil
+}
+
+// VerifyImportProof checks that proof is a block of the trusted target chain
+// holding a transaction that committed the import of export exportTxID of a
+// case, and returns the import record that transaction wrote. The source chain
+// completes a transfer with the hash and transaction ID of this record only.
+func VerifyImportProof(trust *TransferTrust, proof *TransferProof, investigationID string,
+	exportTxID string) (*CaseImport, error) {
+
+	// The import record is keyed by the transaction that wrote it
+	var importRecord CaseImport
+	err := verifyProvenWrite(trust, proof, "the import of investigation "+investigationID,
+		func(txID string) string { return StateKey(RecordImport, investigationID, txID) },
+		func(txID string, value []byte) error {
+			if err := json.Unmarshal(value, &importRecord); err != nil {
+				return fmt.Errorf("endorsed import record: %v", err)
+			}
+			if importRecord.ImportTxID != txID {
+				return fmt.Errorf("import record of transaction %s names transaction %s", txID, importRecord.ImportTxID)
+			}
+			if importRecord.InvestigationID != investigationID || importRecord.SourceTxID != exportTxID {
+				return fmt.Errorf("transaction %s imported export %s of investigation %s, not export %s",
+					txID, importRecord.SourceTxID, importRecord.InvestigationID, exportTxID)
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return &importRecord, nil
+}
+
+// VerifyAbortProof checks that proof is a block of the trusted source chain
+// holding the transaction that aborted the latest transfer of a case in
+// direction, and returns the aborted transfer record it wrote. The target
+// chain refuses to import a transfer whose abort it has been shown.
+func VerifyAbortProof(trust *TransferTrust, proof *TransferProof, direction string,
+	investigationID string) (*CaseTransfer, error) {
+
+	var transfer CaseTransfer
+	err := verifyProvenWrite(trust, proof, fmt.Sprintf("the abort of the %s transfer of investigation %s", direction, investigationID),
+		func(string) string { return CaseTransferKey(direction, investigationID) },
+		func(txID string, value []byte) error {
+			if err := json.Unmarshal(value, &transfer); err != nil {
+				return fmt.Errorf("endorsed case transfer: %v", err)
+			}
+			if transfer.State != TransferStateAborted {
+				return fmt.Errorf("transaction %s left the transfer %s", txID, transfer.State)
+			}
+			if transfer.AbortTxID != txID {
+				return fmt.Errorf("case transfer of transaction %s names abort transaction %s", txID, transfer.AbortTxID)
+			}
+			if transfer.InvestigationID != investigationID || transfer.Direction != direction {
+				return fmt.Errorf("transaction %s aborted the %s transfer of investigation %s",
+					txID, transfer.Direction, transfer.InvestigationID)
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// verifyProvenWrite looks in the block of proof for a transaction of the trusted
+// chain that provably wrote key(txID) a value check accepts. what describes
+// the write for error messages.
+func verifyProvenWrite(trust *TransferTrust, proof *TransferProof, what string,
+	key func(txID string) string, check func(txID string, value []byte) error) error {
+
+	if proof == nil || len(proof.Block) == 0 {
+		return fmt.Errorf("no proof of %s", what)
+	}
+	block, err := proofBlock(proof)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	for i, envelopeBytes := range block.Data.Data {
+		payload, header, err := unmarshalEnvelope(envelopeBytes)
+		if err != nil {
+			return fmt.Errorf("invalid transfer proof: transaction %d: %v", i, err)
+		}
+		tx := &blockTransaction{index: i, header: header, payload: payload}
+		value, err := verifyCommittedWrite(trust, block, tx, key(header.TxId))
+		if err == nil {
+			err = check(header.TxId, value)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("transaction %s: %v", header.TxId, err))
+			continue
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid transfer proof: block %d does not commit %s (%s)",
+		block.Header.Number, what, strings.Join(problems, "; "))
+}
+
+// blockTransaction is one transaction of a proof block
+type blockTransaction struct {
+	index   int
+	header  *common.ChannelHeader
+	payload *common.Payload
+}
+
+// proofBlock decodes the block of a proof and checks that its data matches its header
+func proofBlock(proof *TransferProof) (*common.Block, error) {
+	block := &common.Block{}
+	if err := proto.Unmarshal(proof.Block, block); err != nil {
+		return nil, fmt.Errorf("invalid transfer proof: failed to unmarshal block: %v", err)
+	}
+	if block.Header == nil || block.Data == nil || block.Metadata == nil {
+		return nil, fmt.Errorf("invalid transfer proof: incomplete block")
+	}
+	dataHash := sha256.Sum256(bytes.Join(block.Data.Data, nil))
+	if !bytes.Equal(dataHash[:], block.Header.DataHash) {
+		return nil, fmt.Errorf("invalid transfer proof: block data does not match the header of block %d", block.Header.Number)
+	}
+	return block, nil
+}
+
+// verifyCommittedWrite checks that tx is a valid transaction of the trusted chain,
+// committed in a block an orderer signed and endorsed by enough trusted peers,
+// and returns the value its endorsed write set holds for key
+func verifyCommittedWrite(trust *TransferTrust, block *common.Block, tx *blockTransaction, key string) ([]byte, error) {
+	channelHeader := tx.header
+	if channelHeader.ChannelId != trust.ChannelID {
+		return nil, fmt.Errorf("invalid transfer proof: transaction is on channel %s, expected %s",
+			channelHeader.ChannelId, trust.ChannelID)
+	}
+	if channelHeader.Type != int32(common.HeaderType_ENDORSER_TRANSACTION) {
+		return nil, fmt.Errorf("invalid transfer proof: transaction %s is not an endorser transaction", channelHeader.TxId)
+	}
+	if channelHeader.Timestamp == nil {
+		return nil, fmt.Errorf("invalid transfer proof: transaction %s has no timestamp", channelHeader.TxId)
+	}
+	proposedAt := time.Unix(channelHeader.Timestamp.Seconds, int64(channelHeader.Timestamp.Nanos)).UTC()
+
+	// The committing peers must have marked the transaction valid
+	metadata := block.Metadata.Metadata
+	if len(metadata) <= int(common.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+		return nil, fmt.Errorf("invalid transfer proof: block %d has no transaction validation flags", block.Header.Number)
+	}
+	flags := metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	if tx.index >= len(flags) || flags[tx.index] != byte(peer.TxValidationCode_VALID) {
+		return nil, fmt.Errorf("invalid transfer proof: transaction %s was not committed as valid", channelHeader.TxId)
+	}
+
+	if err := verifyBlockSignature(trust, block, proposedAt); err != nil {
+		return nil, err
+	}
+
+	responsePayload, err := verifyEndorsements(trust, tx.payload, proposedAt)
+	if err != nil {
+		return nil, err
+	}
+	return endorsedWrite(trust, responsePayload, key)
+}
+
+// unmarshalEnvelope decodes the payload and channel header of a transaction envelope
+func unmarshalEnvelope(envelopeBytes []byte) (*common.Payload, *common.ChannelHeader, error) {
+	envelope := &common.Envelope{}
+	if err := proto.Unmarshal(envelopeBytes, envelope); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal envelope: %v", err)
+	}
+	payload := &common.Payload{}
+	if err := proto.Unmarshal(envelope.Payload, payload); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal payload: %v", err)
+	}
+	if payload.Header == nil {
+		return nil, nil, fmt.Errorf("payload has no header")
+	}
+	channelHeader := &common.ChannelHeader{}
+	if err := proto.Unmarshal(payload.Header.ChannelHeader, channelHeader); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal channel header: %v", err)
+	}
+	return payload, channelHeader, nil
+}
+
+// verifyBlockSignature requires a valid signature of the block header by a trusted orderer
+func verifyBlockSignature(trust *TransferTrust, block *common.Block, at time.Time) error {
+	metadata := &common.Metadata{}
+	if err := proto.Unmarshal(block.Metadata.Metadata[common.BlockMetadataIndex_SIGNATURES], metadata); err != nil {
+		return fmt.Errorf("invalid transfer proof: failed to unmarshal block signatures: %v", err)
+	}
+
+	headerBytes, err := asn1.Marshal(blockHeader{
+		Number:       new(big.Int).SetUint64(block.Header.Number),
+		PreviousHash: block.Header.PreviousHash,
+		DataHash:     block.Header.DataHash,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode block header: %v", err)
+	}
+
+	var problems []string
+	for _, signature := range metadata.Signatures {
+		signatureHeader := &common.SignatureHeader{}
+		if err := proto.Unmarshal(signature.SignatureHeader, signatureHeader); err != nil {
+			problems = append(problems, fmt.Sprintf("failed to unmarshal signature header: %v", err))
+			continue
+		}
+		signed := append(append(append([]byte{}, metadata.Value...), signature.SignatureHeader...), headerBytes...)
+		if _, err := verifySignature(trust, MSPRoleOrderer, signatureHeader.Creator, signed, signature.Signature, at); err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid transfer proof: block %d is not signed by a trusted orderer (%s)",
+		block.Header.Number, strings.Join(problems, "; "))
+}
+
+// verifyEndorsements checks the endorsements of a transaction and returns
+// the proposal response payload they sign
+func verifyEndorsements(trust *TransferTrust, payload *common.Payload, at time.Time) ([]byte, error) {
+	transaction := &peer.Transaction{}
+	if err := proto.Unmarshal(payload.Data, transaction); err != nil {
+		return nil, fmt.Errorf("invalid transfer proof: failed to unmarshal transaction: %v", err)
+	}
+	if len(transaction.Actions) != 1 {
+		return nil, fmt.Errorf("invalid transfer proof: transaction has %d actions, expected 1", len(transaction.Actions))
+	}
+	actionPayload := &peer.ChaincodeActionPayload{}
+	if err := proto.Unmarshal(transaction.Actions[0].Payload, actionPayload); err != nil {
+		return nil, fmt.Errorf("invalid transfer proof: failed to unmarshal chaincode action: %v", err)
+	}
+	if actionPayload.Action == nil {
+		return nil, fmt.Errorf("invalid transfer proof: transaction has no endorsed action")
+	}
+
+	responsePayload := actionPayload.Action.ProposalResponsePayload
+	endorsers := map[string]bool{}
+	var problems []string
+	for _, endorsement := range actionPayload.Action.Endorsements {
+		signed := append(append([]byte{}, responsePayload...), endorsement.Endorser...)
+		mspID, err := verifySignature(trust, MSPRolePeer, endorsement.Endorser, signed, endorsement.Signature, at)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		endorsers[mspID] = true
+	}
+
+	if len(endorsers) < trust.MinEndorsements {
+		var endorsed []string
+		for mspID := range endorsers {
+			endorsed = append(endorsed, mspID)
+		}
+		sort.Strings(endorsed)
+		return nil, fmt.Errorf("invalid transfer proof: transaction endorsed by %d trusted peer MSPs %v, %d required (%s)",
+			len(endorsers), endorsed, trust.MinEndorsements, strings.Join(problems, "; "))
+	}
+	return responsePayload, nil
+}
+
+// endorsedWrite returns the value the endorsed transaction wrote to key in the source chaincode
+func endorsedWrite(trust *TransferTrust, responsePayloadBytes []byte, key string) ([]byte, error) {
+	responsePayload := &peer.ProposalResponsePayload{}
+	if err := proto.Unmarshal(responsePayloadBytes, responsePayload); err != nil {
+		return nil, fmt.Errorf("invalid transfer proof: failed to unmarshal proposal response: %v", err)
+	}
+	action := &peer.ChaincodeAction{}
+	if err := proto.Unmarshal(responsePayload.Extension, action); err != nil {
+		return nil, fmt.Errorf("invalid transfer proof: failed to unmarshal chaincode action: %v", err)
+	}
+	if action.ChaincodeId == nil || action.ChaincodeId.Name != trust.ChaincodeName {
+		return nil, fmt.Errorf("invalid transfer proof: transaction did not invoke chaincode %s", trust.ChaincodeName)
+	}
+	if action.Response == nil || action.Response.Status != 200 {
+		return nil, fmt.Errorf("invalid transfer proof: transaction did not succeed")
+	}
+
+	results := &rwset.TxReadWriteSet{}
+	if err := proto.Unmarshal(action.Results, results); err != nil {
+		return nil, fmt.Errorf("invalid transfer proof: failed to unmarshal write set: %v", err)
+	}
+	for _, namespace := range results.NsRwset {
+		if namespace.Namespace != trust.ChaincodeName {
+			continue
+		}
+		kvSet := &kvrwset.KVRWSet{}
+		if err := proto.Unmarshal(namespace.Rwset, kvSet); err != nil {
+			return nil, fmt.Errorf("invalid transfer proof: failed to unmarshal write set: %v", err)
+		}
+		for _, write := range kvSet.Writes {
+			if write.Key == key && !write.IsDelete {
+				return write.Value, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("invalid transfer proof: transaction did not write %s", key)
+}
+
+// verifySignature checks that serializedIdentity is a certificate issued by a trusted
+// MSP of role and that it signed data. It returns the signer's MSP ID.
+func verifySignature(trust *TransferTrust, role string, serializedIdentity []byte,
+	data []byte, signature []byte, at time.Time) (string, error) {
+
+	identity := &msp.SerializedIdentity{}
+	if err := proto.Unmarshal(serializedIdentity, identity); err != nil {
+		return "", fmt.Errorf("failed to unmarshal signer identity: %v", err)
+	}
+
+	var trusted *TrustedMSP
+	for i := range trust.MSPs {
+		if trust.MSPs[i].MSPID == identity.Mspid && trust.MSPs[i].Role == role {
+			trusted = &trust.MSPs[i]
+			break
+		}
+	}
+	if trusted == nil {
+		return "", fmt.Errorf("%s is not a trusted %s MSP", identity.Mspid, role)
+	}
+
+	certs, err := parseCertificates([]string{string(identity.IdBytes)}, false)
+	if err != nil {
+		return "", fmt.Errorf("%s signer: %v", identity.Mspid, err)
+	}
+	cert := certs[0]
+
+	options := x509.VerifyOptions{
+		Roots:         x509.NewCertPool(),
+		Intermediates: x509.NewCertPool(),
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	roots, _ := parseCertificates(trusted.RootCerts, true)
+	for _, root := range roots {
+		options.Roots.AddCert(root)
+	}
+	intermediates, _ := parseCertificates(trusted.IntermediateCerts, true)
+	for _, intermediate := range intermediates {
+		options.Intermediates.AddCert(intermediate)
+	}
+	if _, err := cert.Verify(options); err != nil {
+		return "", fmt.Errorf("%s signer %s: %v", identity.Mspid, cert.Subject.CommonName, err)
+	}
+
+	publicKey, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return "", fmt.Errorf("%s signer %s does not use an ECDSA key", identity.Mspid, cert.Subject.CommonName)
+	}
+	digest := sha256.Sum256(data)
+	if !ecdsa.VerifyASN1(publicKey, digest[:], signature) {
+		return "", fmt.Errorf("%s signer %s: invalid signature", identity.Mspid, cert.Subject.CommonName)
+	}
+	return identity.Mspid, nil
+}
@@ -0,0 +1,452 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// proofTime is when the export transactions in these tests were proposed
+var proofTime = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// testSigner is an identity of the simulated source chain network
+type testSigner struct {
+	mspID    string
+	identity []byte // Serialized identity
+	key      *ecdsa.PrivateKey
+}
+
+// testMSP is a CA of the simulated source chain network
+type testMSP struct {
+	id      string
+	rootPEM string
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+}
+
+func newTestMSP(t *testing.T, id string) *testMSP {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca." + id},
+		NotBefore:             proofTime.Add(-24 * time.Hour),
+		NotAfter:              proofTime.Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create CA certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testMSP{
+		id:      id,
+		rootPEM: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		cert:    cert,
+		key:     key,
+	}
+}
+
+func (m *testMSP) signer(t *testing.T, cn string) *testSigner {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    proofTime.Add(-time.Hour),
+		NotAfter:     proofTime.Add(30 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, m.cert, &key.PublicKey, m.key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	identity, _ := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   m.id,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	})
+	return &testSigner{mspID: m.id, identity: identity, key: key}
+}
+
+func (s *testSigner) sign(t *testing.T, data []byte) []byte {
+	t.Helper()
+	digest := sha256.Sum256(data)
+	signature, err := ecdsa.SignASN1(rand.Reader, s.key, digest[:])
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	return signature
+}
+
+// sourceNetwork is a simulated hot chain with two peer organizations and an orderer
+type sourceNetwork struct {
+	trust     *TransferTrust
+	endorsers []*testSigner
+	orderer   *testSigner
+}
+
+func newSourceNetwork(t *testing.T) *sourceNetwork {
+	lawEnforcement := newTestMSP(t, "LawEnforcementMSP")
+	forensicLab := newTestMSP(t, "ForensicLabMSP")
+	orderer := newTestMSP(t, "OrdererMSP")
+	return &sourceNetwork{
+		trust: &TransferTrust{
+			SourceChain:   ChainHot,
+			ChannelID:     "hotchannel",
+			ChaincodeName: "dfir",
+			MSPs: []TrustedMSP{
+				{MSPID: lawEnforcement.id, Role: MSPRolePeer, RootCerts: []string{lawEnforcement.rootPEM}},
+				{MSPID: forensicLab.id, Role: MSPRolePeer, RootCerts: []string{forensicLab.rootPEM}},
+				{MSPID: orderer.id, Role: MSPRoleOrderer, RootCerts: []string{orderer.rootPEM}},
+			},
+			MinEndorsements: 2,
+		},
+		endorsers: []*testSigner{
+			lawEnforcement.signer(t, "peer0.lawenforcement.hot.coc.com"),
+			forensicLab.signer(t, "peer0.forensiclab.hot.coc.com"),
+		},
+		orderer: orderer.signer(t, "orderer.hot.coc.com"),
+	}
+}
+
+// proofOptions tampers with the block built by commitExport
+type proofOptions struct {
+	channelID   string
+	chaincode   string
+	txFlag      peer.TxValidationCode
+	endorsers   []*testSigner
+	orderer     *testSigner
+	exportValue []byte // Written as the export record instead of the package
+}
+
+// commitExport builds the block the source chain commits for the export of pkg,
+// preceded by an unrelated transaction
+func (n *sourceNetwork) commitExport(t *testing.T, pkg *CaseExportPackage, options proofOptions) []byte {
+	t.Helper()
+	exportValue := options.exportValue
+	if exportValue == nil {
+		var err error
+		if exportValue, err = json.Marshal(pkg); err != nil {
+			t.Fatalf("failed to marshal package: %v", err)
+		}
+	}
+	return n.commit(t, pkg.TransferTxID, []*kvrwset.KVWrite{
+		{Key: InvestigationKey(pkg.Investigation.ID), Value: []byte(`{"status":"transferring_to_archive"}`)},
+		{Key: StateKey(RecordExport, pkg.Investigation.ID, pkg.TransferTxID), Value: exportValue},
+	}, options)
+}
+
+// commitImport builds the block the network commits for transaction txID writing
+// importRecord, preceded by an unrelated transaction
+func (n *sourceNetwork) commitImport(t *testing.T, txID string, importRecord *CaseImport, options proofOptions) []byte {
+	t.Helper()
+	return n.commit(t, txID, []*kvrwset.KVWrite{
+		{Key: InvestigationKey(importRecord.InvestigationID), Value: []byte(`{"status":"archived"}`)},
+		{Key: StateKey(RecordImport, importRecord.InvestigationID, txID), Value: mustMarshal(t, importRecord)},
+	}, options)
+}
+
+// commit builds the block holding transaction txID with the given writes,
+// preceded by an unrelated transaction
+func (n *sourceNetwork) commit(t *testing.T, txID string, writes []*kvrwset.KVWrite, options proofOptions) []byte {
+	t.Helper()
+	if options.channelID == "" {
+		options.channelID = n.trust.ChannelID
+	}
+	if options.chaincode == "" {
+		options.chaincode = n.trust.ChaincodeName
+	}
+	if options.endorsers == nil {
+		options.endorsers = n.endorsers
+	}
+	if options.orderer == nil {
+		options.orderer = n.orderer
+	}
+
+	kvSet, _ := proto.Marshal(&kvrwset.KVRWSet{Writes: writes})
+	results, _ := proto.Marshal(&rwset.TxReadWriteSet{
+		DataModel: rwset.TxReadWriteSet_KV,
+		NsRwset:   []*rwset.NsReadWriteSet{{Namespace: options.chaincode, Rwset: kvSet}},
+	})
+	extension, _ := proto.Marshal(&peer.ChaincodeAction{
+		Results:     results,
+		Response:    &peer.Response{Status: 200},
+		ChaincodeId: &peer.ChaincodeID{Name: options.chaincode},
+	})
+	responsePayload, _ := proto.Marshal(&peer.ProposalResponsePayload{ProposalHash: []byte("proposal"), Extension: extension})
+
+	var endorsements []*peer.Endorsement
+	for _, endorser := range options.endorsers {
+		signed := append(append([]byte{}, responsePayload...), endorser.identity...)
+		endorsements = append(endorsements, &peer.Endorsement{Endorser: endorser.identity, Signature: endorser.sign(t, signed)})
+	}
+	actionPayload, _ := proto.Marshal(&peer.ChaincodeActionPayload{
+		Action: &peer.ChaincodeEndorsedAction{ProposalResponsePayload: responsePayload, Endorsements: endorsements},
+	})
+	transaction, _ := proto.Marshal(&peer.Transaction{Actions: []*peer.TransactionAction{{Payload: actionPayload}}})
+
+	envelope := func(txID string, data []byte) []byte {
+		channelHeader, _ := proto.Marshal(&common.ChannelHeader{
+			Type:      int32(common.HeaderType_ENDORSER_TRANSACTION),
+			ChannelId: options.channelID,
+			TxId:      txID,
+			Timestamp: &timestamp.Timestamp{Seconds: proofTime.Unix()},
+		})
+		payload, _ := proto.Marshal(&common.Payload{Header: &common.Header{ChannelHeader: channelHeader}, Data: data})
+		envelopeBytes, _ := proto.Marshal(&common.Envelope{Payload: payload, Signature: []byte("client")})
+		return envelopeBytes
+	}
+	data := [][]byte{envelope("tx-other", []byte{}), envelope(txID, transaction)}
+	dataHash := sha256.Sum256(bytes.Join(data, nil))
+	header := &common.BlockHeader{Number: 7, PreviousHash: []byte("previous"), DataHash: dataHash[:]}
+
+	headerBytes, _ := asn1.Marshal(blockHeader{Number: big.NewInt(7), PreviousHash: header.PreviousHash, DataHash: header.DataHash})
+	signatureHeader, _ := proto.Marshal(&common.SignatureHeader{Creator: options.orderer.identity, Nonce: []byte("nonce")})
+	value := []byte("orderer metadata")
+	signed := append(append(append([]byte{}, value...), signatureHeader...), headerBytes...)
+	signatures, _ := proto.Marshal(&common.Metadata{
+		Value:      value,
+		Signatures: []*common.MetadataSignature{{SignatureHeader: signatureHeader, Signature: options.orderer.sign(t, signed)}},
+	})
+
+	blockBytes, err := proto.Marshal(&common.Block{
+		Header:   header,
+		Data:     &common.BlockData{Data: data},
+		Metadata: &common.BlockMetadata{Metadata: [][]byte{signatures, {}, {byte(peer.TxValidationCode_VALID), byte(options.txFlag)}}},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal block: %v", err)
+	}
+	return blockBytes
+}
+
+// provenPackage returns the package as the importing chain receives it
+func provenPackage(t *testing.T, pkg *CaseExportPackage, block []byte) *CaseExportPackage {
+	t.Helper()
+	proven := *pkg
+	proven.Proof = &TransferProof{Block: block}
+	packageJSON, err := json.Marshal(&proven)
+	if err != nil {
+		t.Fatalf("failed to marshal package: %v", err)
+	}
+	decoded, err := DecodePackage(packageJSON)
+	if err != nil {
+		t.Fatalf("proven package does not validate: %v", err)
+	}
+	return decoded
+}
+
+func testExportPackage() *CaseExportPackage {
+	return ArchiveFlow.NewPackage(
+		Investigation{ID: "INV-001", CaseNumber: "CASE-1", Status: "closed"},
+		[]Evidence{{ID: "EVD-001", CaseID: "INV-001", Hash: "abc"}},
+		"ORDER-1", "court", proofTime.Unix(), "tx-export")
+}
+
+func TestVerifyTransferProof(t *testing.T) {
+	network := newSourceNetwork(t)
+	if err := CheckTransferTrust(network.trust); err != nil {
+		t.Fatalf("trust anchors rejected: %v", err)
+	}
+
+	pkg := testExportPackage()
+	proven := provenPackage(t, pkg, network.commitExport(t, pkg, proofOptions{}))
+	if err := VerifyTransferProof(network.trust, proven); err != nil {
+		t.Fatalf("valid proof rejected: %v", err)
+	}
+}
+
+func TestVerifyTransferProofRejectsForgeries(t *testing.T) {
+	network := newSourceNetwork(t)
+	outsider := newTestMSP(t, "LawEnforcementMSP").signer(t, "peer0.fake.com")
+	pkg := testExportPackage()
+
+	tampered := *pkg
+	tampered.Evidence = []Evidence{{ID: "EVD-001", CaseID: "INV-001", Hash: "forged"}}
+	tamperedJSON, _ := json.Marshal(&tampered)
+
+	for _, test := range []struct {
+		name    string
+		pkg     *CaseExportPackage
+		options proofOptions
+		want    string
+	}{
+		{"wrong channel", pkg, proofOptions{channelID: "coldchannel"}, "on channel coldchannel"},
+		{"wrong chaincode", pkg, proofOptions{chaincode: "other"}, "did not invoke chaincode dfir"},
+		{"invalid transaction", pkg, proofOptions{txFlag: peer.TxValidationCode_MVCC_READ_CONFLICT}, "not committed as valid"},
+		{"one endorsement", pkg, proofOptions{endorsers: network.endorsers[:1]}, "1 trusted peer MSPs"},
+		{"untrusted endorser", pkg, proofOptions{endorsers: []*testSigner{network.endorsers[1], outsider}}, "certificate signed by unknown authority"},
+		{"peer signs block", pkg, proofOptions{orderer: network.endorsers[0]}, "not a trusted orderer MSP"},
+		{"different package exported", pkg, proofOptions{exportValue: tamperedJSON}, "package differs"},
+		{"package changed in flight", &tampered, proofOptions{exportValue: mustMarshal(t, pkg)}, "package differs"},
+	} {
+		block := network.commitExport(t, pkg, test.options)
+		err := VerifyTransferProof(network.trust, provenPackage(t, test.pkg, block))
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: err = %v, want %q", test.name, err, test.want)
+		}
+	}
+
+	if err := VerifyTransferProof(network.trust, pkg); err == nil || !strings.Contains(err.Error(), "no transfer proof") {
+		t.Errorf("package without proof: err = %v", err)
+	}
+
+	// A block whose data was changed after the orderer signed it
+	block := &common.Block{}
+	proto.Unmarshal(network.commitExport(t, pkg, proofOptions{}), block)
+	block.Data.Data = block.Data.Data[1:]
+	blockBytes, _ := proto.Marshal(block)
+	if err := VerifyTransferProof(network.trust, provenPackage(t, pkg, blockBytes)); err == nil ||
+		!strings.Contains(err.Error(), "does not match the header") {
+		t.Errorf("truncated block: err = %v", err)
+	}
+}
+
+func TestVerifyImportProof(t *testing.T) {
+	network := newSourceNetwork(t)
+	importRecord := &CaseImport{InvestigationID: "INV-001", SourceChain: ChainHot, SourceTxID: "tx-export",
+		ImportTxID: "tx-import", EvidenceCount: 1, PackageHash: "abc"}
+
+	proof := &TransferProof{Block: network.commitImport(t, "tx-import", importRecord, proofOptions{})}
+	proven, err := VerifyImportProof(network.trust, proof, "INV-001", "tx-export")
+	if err != nil {
+		t.Fatalf("valid import proof rejected: %v", err)
+	}
+	if *proven != *importRecord {
+		t.Errorf("proven import record = %+v, want %+v", proven, importRecord)
+	}
+
+	otherExport := *importRecord
+	otherExport.SourceTxID = "tx-earlier-export"
+	otherTx := *importRecord
+	otherTx.ImportTxID = "tx-other"
+	for _, test := range []struct {
+		name    string
+		record  *CaseImport
+		options proofOptions
+		want    string
+	}{
+		{"invalid transaction", importRecord, proofOptions{txFlag: peer.TxValidationCode_MVCC_READ_CONFLICT}, "not committed as valid"},
+		{"one endorsement", importRecord, proofOptions{endorsers: network.endorsers[:1]}, "1 trusted peer MSPs"},
+		{"peer signs block", importRecord, proofOptions{orderer: network.endorsers[0]}, "not a trusted orderer MSP"},
+		{"import of another export", &otherExport, proofOptions{}, "not export tx-export"},
+		{"record names another transaction", &otherTx, proofOptions{}, "names transaction tx-other"},
+	} {
+		proof := &TransferProof{Block: network.commitImport(t, "tx-import", test.record, test.options)}
+		_, err := VerifyImportProof(network.trust, proof, "INV-001", "tx-export")
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: err = %v, want %q", test.name, err, test.want)
+		}
+	}
+
+	// The block of the export is no proof of the import
+	pkg := testExportPackage()
+	proof = &TransferProof{Block: network.commitExport(t, pkg, proofOptions{})}
+	if _, err := VerifyImportProof(network.trust, proof, "INV-001", "tx-export"); err == nil ||
+		!strings.Contains(err.Error(), "does not commit the import of investigation INV-001") {
+		t.Errorf("export block as import proof: err = %v", err)
+	}
+	if _, err := VerifyImportProof(network.trust, nil, "INV-001", "tx-export"); err == nil {
+		t.Errorf("missing import proof accepted")
+	}
+}
+
+func TestVerifyAbortProof(t *testing.T) {
+	network := newSourceNetwork(t)
+	aborted := &CaseTransfer{InvestigationID: "INV-001", Direction: TransferArchive, ExportTxID: "tx-export",
+		State: TransferStateAborted, AbortReason: "cold chain unreachable", AbortTxID: "tx-abort"}
+	commitAbort := func(transfer *CaseTransfer, options proofOptions) *TransferProof {
+		return &TransferProof{Block: network.commit(t, "tx-abort", []*kvrwset.KVWrite{
+			{Key: CaseTransferKey(transfer.Direction, transfer.InvestigationID), Value: mustMarshal(t, transfer)},
+		}, options)}
+	}
+
+	proven, err := VerifyAbortProof(network.trust, commitAbort(aborted, proofOptions{}), TransferArchive, "INV-001")
+	if err != nil {
+		t.Fatalf("valid abort proof rejected: %v", err)
+	}
+	if *proven != *aborted {
+		t.Errorf("proven abort = %+v, want %+v", proven, aborted)
+	}
+
+	exported := *aborted
+	exported.State = TransferStateExported
+	otherTx := *aborted
+	otherTx.AbortTxID = "tx-earlier-abort"
+	for _, test := range []struct {
+		name     string
+		transfer *CaseTransfer
+		options  proofOptions
+		want     string
+	}{
+		{"one endorsement", aborted, proofOptions{endorsers: network.endorsers[:1]}, "1 trusted peer MSPs"},
+		{"invalid transaction", aborted, proofOptions{txFlag: peer.TxValidationCode_MVCC_READ_CONFLICT}, "not committed as valid"},
+		{"transfer not aborted", &exported, proofOptions{}, "left the transfer exported"},
+		{"earlier abort", &otherTx, proofOptions{}, "names abort transaction tx-earlier-abort"},
+	} {
+		_, err := VerifyAbortProof(network.trust, commitAbort(test.transfer, test.options), TransferArchive, "INV-001")
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: err = %v, want %q", test.name, err, test.want)
+		}
+	}
+
+	// The abort of the other direction does not stop this one
+	if _, err := VerifyAbortProof(network.trust, commitAbort(aborted, proofOptions{}), TransferReactivation, "INV-001"); err == nil {
+		t.Errorf("abort of the archive transfer accepted for the reactivation")
+	}
+}
+
+func TestCheckTransferTrust(t *testing.T) {
+	network := newSourceNetwork(t)
+	for name, change := range map[string]func(*TransferTrust){
+		"no orderer":         func(trust *TransferTrust) { trust.MSPs = trust.MSPs[:2] },
+		"too many endorsers": func(trust *TransferTrust) { trust.MinEndorsements = 3 },
+		"invalid role":       func(trust *TransferTrust) { trust.MSPs[0].Role = "client" },
+		"invalid root":       func(trust *TransferTrust) { trust.MSPs[0].RootCerts = []string{"not a certificate"} },
+		"duplicate MSP":      func(trust *TransferTrust) { trust.MSPs[1].MSPID = trust.MSPs[0].MSPID },
+		"no channel":         func(trust *TransferTrust) { trust.ChannelID = "" },
+	} {
+		trust := *network.trust
+		trust.MSPs = append([]TrustedMSP{}, network.trust.MSPs...)
+		change(&trust)
+		if err := CheckTransferTrust(&trust); err == nil {
+			t.Errorf("%s: trust anchors accepted", name)
+		}
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	return data
+}
@@ -0,0 +1,213 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ==============================================================================
+// TRANSFER HASH COMMITMENT
+// ==============================================================================
+//
+// ExportCase commits to the hash of the package it exported in a case_transfer
+// record, ImportCase records the hash of the package it received, and
+// CompleteTransfer finishes the transfer only when the import record, proven
+// by the target chain block that committed it, holds the hash the source chain
+// committed to. A mismatch means the data changed in flight: the transfer is
+// marked rejected and the case stays in flight, so CompleteTransfer still
+// commits the flag instead of failing the transaction.
+
+// Transfer states of a case_transfer record
+const (
+	TransferStateExported  = "exported"  // Waiting for the target chain to import
+	TransferStateCompleted = "completed" // Target chain imported the committed package
+	TransferStateRejected  = "rejected"  // Target chain imported a different package
+	TransferStateAborted   = "aborted"   // Cancelled on the source chain, see AbortTransfer
+)
+
+// CaseTransfer is the source chain's record of one case transfer
+type CaseTransfer struct {
+	InvestigationID string `json:"investigation_id"`
+	Direction       string `json:"direction"`      // TransferArchive or TransferReactivation
+	ExportTxID      string `json:"export_tx_id"`   // Key of the export record
+	PackageHash     string `json:"package_hash"`   // Hash the source chain committed to
+	FormatVersion   int    `json:"format_version"` // Format the hash was computed at
+	PriorStatus     string `json:"prior_status"`   // Status of the case before the export
+	ExportedAt      int64  `json:"exported_at"`
+	ExportedBy      string `json:"exported_by"`
+	State           string `json:"state"`
+
+	ExpiresAt      int64  `json:"expires_at,omitempty" metadata:",optional"`    // Deadline of the import, see TransferDeadline
+	ImportedHash   string `json:"imported_hash,omitempty" metadata:",optional"` // Hash the target chain reported
+	TargetTxID     string `json:"target_tx_id,omitempty" metadata:",optional"`
+	CompletedAt    int64  `json:"completed_at,omitempty" metadata:",optional"`
+	RejectedReason string `json:"rejected_reason,omitempty" metadata:",optional"`
+	AbortedAt      int64  `json:"aborted_at,omitempty" metadata:",optional"`
+	AbortedBy      string `json:"aborted_by,omitempty" metadata:",optional"`
+	AbortReason    string `json:"abort_reason,omitempty" metadata:",optional"`
+	AbortTxID      string `json:"abort_tx_id,omitempty" metadata:",optional"`
+}
+
+// CaseImport is the target chain's record of an imported package
+type CaseImport struct {
+	InvestigationID string `json:"investigation_id"`
+	SourceChain     string `json:"source_chain"`
+	SourceTxID      string `json:"source_tx_id"`
+	CourtOrder      string `json:"court_order"`
+	ImportedAt      int64  `json:"imported_at"`
+	ImportedBy      string `json:"imported_by"`
+	ImportTxID      string `json:"import_tx_id"`
+	EvidenceCount   int    `json:"evidence_count"`
+	PackageHash     string `json:"package_hash"` // Hash of the package as received
+}
+
+// CaseTransferKey is the world state key of the latest transfer of a case in a direction
+func CaseTransferKey(direction string, investigationID string) string {
+	return StateKey(RecordCaseTransfer, direction, investigationID)
+}
+
+// PackageHash is the canonical hash of a package: the hex SHA-256 of its JSON
+// encoding at PackageFormatVersion without the proof. Both chains compute it
+// on the decoded package, so field order and older format versions do not
+// change it.
+func PackageHash(exportPackage *CaseExportPackage) (string, error) {
+	canonical := *exportPackage
+	canonical.Proof = nil
+	if canonical.FormatVersion != PackageFormatVersion {
+		return "", fmt.Errorf("cannot hash package of format version %d, decode it first", canonical.FormatVersion)
+	}
+	packageJSON, err := json.Marshal(canonical)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal export package: %v", err)
+	}
+	hash := sha256.Sum256(packageJSON)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// Settle completes the transfer when the target chain's import record holds the
+// committed hash and rejects it otherwise. It reports whether the hashes matched.
+func (t *CaseTransfer) Settle(importRecord *CaseImport, now int64) bool {
+	t.ImportedHash = importRecord.PackageHash
+	t.TargetTxID = importRecord.ImportTxID
+	t.CompletedAt = now
+	if t.ImportedHash != t.PackageHash {
+		t.State = TransferStateRejected
+		t.RejectedReason = fmt.Sprintf("package hash mismatch: exported %s, imported %s", t.PackageHash, t.ImportedHash)
+		return false
+	}
+	t.State = TransferStateCompleted
+	return true
+}
+
+// LoadCaseTransfer reads the latest transfer of a case in the flow's direction.
+// Cases exported before case_transfer records existed are described from
+// their latest export record. It returns nil if the case was never exported.
+func (f TransferFlow) LoadCaseTransfer(ctx contractapi.TransactionContextInterface,
+	investigationID string) (*CaseTransfer, error) {
+
+	transferJSON, err := ctx.GetStub().GetState(CaseTransferKey(f.Direction, investigationID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read case transfer: %v", err)
+	}
+	if transferJSON != nil {
+		var transfer CaseTransfer
+		if err := json.Unmarshal(transferJSON, &transfer); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal case transfer: %v", err)
+		}
+		return &transfer, nil
+	}
+
+	return f.legacyCaseTransfer(ctx, investigationID)
+}
+
+// legacyCaseTransfer hashes the latest export record the flow's source chain wrote for a case
+func (f TransferFlow) legacyCaseTransfer(ctx contractapi.TransactionContextInterface,
+	investigationID string) (*CaseTransfer, error) {
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(RecordExport, []string{investigationID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query export records: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var exports []*CaseExportPackage
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate export records: %v", err)
+		}
+		exportPackage, err := DecodePackage(queryResponse.Value)
+		if err != nil {
+			return nil, fmt.Errorf("export record %s: %v", queryResponse.Key, err)
+		}
+		if exportPackage.SourceChain == f.SourceChain {
+			exports = append(exports, exportPackage)
+		}
+	}
+	if len(exports) == 0 {
+		return nil, nil
+	}
+	sort.SliceStable(exports, func(i, j int) bool { return exports[i].ExportedAt < exports[j].ExportedAt })
+	latest := exports[len(exports)-1]
+
+	hash, err := PackageHash(latest)
+	if err != nil {
+		return nil, err
+	}
+	return &CaseTransfer{
+		InvestigationID: investigationID,
+		Direction:       f.Direction,
+		ExportTxID:      latest.TransferTxID,
+		PackageHash:     hash,
+		FormatVersion:   PackageFormatVersion,
+		PriorStatus:     f.ExportStatus,
+		ExportedAt:      latest.ExportedAt,
+		ExportedBy:      latest.ExportedBy,
+		State:           TransferStateExported,
+		ExpiresAt:       latest.ExpiresAt,
+	}, nil
+}
+
+// upgradeHash recomputes the committed hash of a transfer exported at an
+// earlier package format version from its export record, so that it can be
+// compared with the hash the target chain computes at the current version
+func (f TransferFlow) upgradeHash(ctx contractapi.TransactionContextInterface, transfer *CaseTransfer) error {
+	if transfer.FormatVersion == PackageFormatVersion {
+		return nil
+	}
+	packageJSON, err := ctx.GetStub().GetState(StateKey(RecordExport, transfer.InvestigationID, transfer.ExportTxID))
+	if err != nil {
+		return fmt.Errorf("failed to read export record: %v", err)
+	}
+	if packageJSON == nil {
+		return fmt.Errorf("export record %s of investigation %s not found", transfer.ExportTxID, transfer.InvestigationID)
+	}
+	exportPackage, err := DecodePackage(packageJSON)
+	if err != nil {
+		return fmt.Errorf("export record %s: %v", transfer.ExportTxID, err)
+	}
+	hash, err := PackageHash(exportPackage)
+	if err != nil {
+		return err
+	}
+	transfer.PackageHash = hash
+	transfer.FormatVersion = PackageFormatVersion
+	return nil
+}
+
+// saveCaseTransfer stores the latest transfer of a case
+func saveCaseTransfer(ctx contractapi.TransactionContextInterface, transfer *CaseTransfer) error {
+	transferJSON, err := json.Marshal(transfer)
+	if err != nil {
+		return fmt.Errorf("failed to marshal case transfer: %v", err)
+	}
+	if err := ctx.GetStub().PutState(CaseTransferKey(transfer.Direction, transfer.InvestigationID), transferJSON); err != nil {
+		return fmt.Errorf("failed to store case transfer: %v", err)
+	}
+	return nil
+}
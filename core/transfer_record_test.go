@@ -0,0 +1,72 @@
+package core
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestPackageHashIsCanonical(t *testing.T) {
+	pkg := testExportPackage()
+	want, err := PackageHash(pkg)
+	if err != nil {
+		t.Fatalf("failed to hash package: %v", err)
+	}
+
+	// The importing chain hashes what it decoded, with the proof attached
+	received := *pkg
+	received.Proof = &TransferProof{Block: []byte("block")}
+	decoded, err := DecodePackage(mustMarshal(t, received))
+	if err != nil {
+		t.Fatalf("failed to decode package: %v", err)
+	}
+	if got, _ := PackageHash(decoded); got != want {
+		t.Errorf("hash changed through the proof and JSON round trip: got %s, want %s", got, want)
+	}
+
+	// Field order of the received JSON does not matter
+	var fields map[string]interface{}
+	if err := json.Unmarshal(mustMarshal(t, pkg), &fields); err != nil {
+		t.Fatalf("failed to unmarshal package: %v", err)
+	}
+	decoded, err = DecodePackage(mustMarshal(t, fields))
+	if err != nil {
+		t.Fatalf("failed to decode reordered package: %v", err)
+	}
+	if got, _ := PackageHash(decoded); got != want {
+		t.Errorf("hash depends on field order: got %s, want %s", got, want)
+	}
+
+	tampered := *pkg
+	tampered.Evidence = []Evidence{{ID: "EVD-001", CaseID: "INV-001", Hash: "def"}}
+	if got, _ := PackageHash(&tampered); got == want {
+		t.Errorf("hash did not change with the evidence hash")
+	}
+
+	old := *pkg
+	old.FormatVersion = 2
+	if _, err := PackageHash(&old); err == nil {
+		t.Errorf("hashed a package that was not decoded to the current format")
+	}
+}
+
+func TestCaseTransferSettle(t *testing.T) {
+	transfer := CaseTransfer{PackageHash: "aaaa", State: TransferStateExported}
+	if !transfer.Settle(&CaseImport{PackageHash: "aaaa", ImportTxID: "tx-import"}, 7000) {
+		t.Fatalf("matching hash rejected")
+	}
+	if transfer.State != TransferStateCompleted || transfer.TargetTxID != "tx-import" || transfer.CompletedAt != 7000 {
+		t.Errorf("unexpected completed transfer: %+v", transfer)
+	}
+
+	transfer = CaseTransfer{PackageHash: "aaaa", State: TransferStateExported}
+	if transfer.Settle(&CaseImport{PackageHash: "bbbb", ImportTxID: "tx-import"}, 7000) {
+		t.Fatalf("mismatched hash accepted")
+	}
+	if transfer.State != TransferStateRejected || transfer.ImportedHash != "bbbb" {
+		t.Errorf("unexpected rejected transfer: %+v", transfer)
+	}
+	if !strings.Contains(transfer.RejectedReason, "exported aaaa, imported bbbb") {
+		t.Errorf("rejection does not name both hashes: %s", transfer.RejectedReason)
+	}
+}
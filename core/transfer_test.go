@@ -0,0 +1,151 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// fillRecord sets every field of the struct pointed to by record to a value
+// derived from the field name, so a dropped or swapped field shows up as a diff
+func fillRecord(t *testing.T, record interface{}) {
+	t.Helper()
+	v := reflect.ValueOf(record).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		name := v.Type().Field(i).Name
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(name + "-value")
+		case reflect.Int, reflect.Int64:
+			field.SetInt(int64(1000 + i))
+		default:
+			t.Fatalf("fillRecord: unsupported kind %s of field %s", field.Kind(), name)
+		}
+	}
+}
+
+// diffFields lists the fields that differ between two records of the same type,
+// skipping the named fields
+func diffFields(got interface{}, want interface{}, skip ...string) []string {
+	skipped := map[string]bool{}
+	for _, name := range skip {
+		skipped[name] = true
+	}
+
+	g, w := reflect.ValueOf(got), reflect.ValueOf(want)
+	var diffs []string
+	for i := 0; i < g.NumField(); i++ {
+		name := g.Type().Field(i).Name
+		if skipped[name] {
+			continue
+		}
+		if !reflect.DeepEqual(g.Field(i).Interface(), w.Field(i).Interface()) {
+			diffs = append(diffs, fmt.Sprintf("%s: got %v, want %v", name, g.Field(i).Interface(), w.Field(i).Interface()))
+		}
+	}
+	return diffs
+}
+
+// transferPackage exports through the JSON encoding a chain stores and the other reads
+func transferPackage(t *testing.T, flow TransferFlow, investigation Investigation,
+	evidence []Evidence, txID string) *CaseExportPackage {
+
+	t.Helper()
+	packageJSON, err := json.Marshal(flow.NewPackage(investigation, evidence, "ORDER-1", "court", 5000, txID))
+	if err != nil {
+		t.Fatalf("failed to marshal %s package: %v", flow.Direction, err)
+	}
+	exportPackage, err := DecodePackage(packageJSON)
+	if err != nil {
+		t.Fatalf("%s package does not validate: %v", flow.Direction, err)
+	}
+	if err := flow.CheckPackage(exportPackage); err != nil {
+		t.Fatalf("%s package rejected: %v", flow.Direction, err)
+	}
+	return exportPackage
+}
+
+// Fields each transfer sets on the imported records
+var (
+	investigationLifecycle = []string{"Status", "UpdatedAt", "ClosedDate", "ArchivedDate", "ArchivedBy", "ArchivedAt"}
+	evidenceLifecycle      = []string{"Status", "UpdatedAt", "ChainType", "ArchivedBy", "ArchivedAt", "SourceChain", "SourceTxID"}
+)
+
+func TestTransferRoundTripPreservesFields(t *testing.T) {
+	var original Investigation
+	fillRecord(t, &original)
+	original.ID = "INV-001"
+	original.Status = ArchiveFlow.PendingStatus
+
+	var evidence Evidence
+	fillRecord(t, &evidence)
+	evidence.ID = "EVD-001"
+	evidence.CaseID = original.ID
+
+	// hot -> cold
+	archived, archivedEvidence, metadata := ArchiveFlow.ImportRecords(
+		transferPackage(t, ArchiveFlow, original, []Evidence{evidence}, "tx-archive"), "cold-court", 6000)
+
+	if diffs := diffFields(archived, original, investigationLifecycle...); diffs != nil {
+		t.Errorf("archive changed investigation fields: %v", diffs)
+	}
+	if len(archivedEvidence) != 1 {
+		t.Fatalf("archive imported %d evidence items, want 1", len(archivedEvidence))
+	}
+	if diffs := diffFields(archivedEvidence[0], evidence, evidenceLifecycle...); diffs != nil {
+		t.Errorf("archive changed evidence fields: %v", diffs)
+	}
+	if archived.Status != "archived" || archived.ArchivedAt != 6000 || archived.ArchivedBy != "cold-court" {
+		t.Errorf("archived case = %s at %d by %s, want archived at 6000 by cold-court",
+			archived.Status, archived.ArchivedAt, archived.ArchivedBy)
+	}
+	if e := archivedEvidence[0]; e.ChainType != ChainCold || e.SourceChain != ChainHot || e.SourceTxID != "tx-archive" {
+		t.Errorf("archived evidence chain %s from %s/%s, want cold from hot/tx-archive",
+			e.ChainType, e.SourceChain, e.SourceTxID)
+	}
+	if len(metadata) != 1 || metadata[0].IntegrityHash != evidence.Hash || metadata[0].OriginalTxID != "tx-archive" {
+		t.Errorf("archive metadata = %+v, want the evidence hash and tx-archive", metadata)
+	}
+
+	// cold -> hot
+	archived.Status = ReactivationFlow.PendingStatus
+	reactivated, reactivatedEvidence, metadata := ReactivationFlow.ImportRecords(
+		transferPackage(t, ReactivationFlow, archived, archivedEvidence, "tx-reactivate"), "hot-court", 7000)
+
+	if diffs := diffFields(reactivated, original, investigationLifecycle...); diffs != nil {
+		t.Errorf("round trip changed investigation fields: %v", diffs)
+	}
+	if diffs := diffFields(reactivatedEvidence[0], evidence, evidenceLifecycle...); diffs != nil {
+		t.Errorf("round trip changed evidence fields: %v", diffs)
+	}
+	if metadata != nil {
+		t.Errorf("reactivation produced archive metadata: %+v", metadata)
+	}
+
+	// The archive record survives reactivation
+	if reactivated.ArchivedAt != 6000 || reactivated.ArchivedBy != "cold-court" || reactivated.ArchivedDate != 6000 {
+		t.Errorf("reactivated case lost its archive fields: %+v", reactivated)
+	}
+	if reactivated.Status != "open" || reactivated.ClosedDate != 0 || reactivated.UpdatedAt != 7000 {
+		t.Errorf("reactivated case = %s, closed %d, updated %d; want open, 0, 7000",
+			reactivated.Status, reactivated.ClosedDate, reactivated.UpdatedAt)
+	}
+	if e := reactivatedEvidence[0]; e.Status != "reviewed" || e.ChainType != ChainHot ||
+		e.SourceChain != ChainCold || e.SourceTxID != "tx-reactivate" || e.ArchivedAt != 6000 {
+		t.Errorf("reactivated evidence = %+v", e)
+	}
+}
+
+func TestCheckPackageRejectsWrongSource(t *testing.T) {
+	exportPackage := ArchiveFlow.NewPackage(Investigation{ID: "INV-001"}, nil, "ORDER-1", "court", 5000, "tx1")
+	if err := ReactivationFlow.CheckPackage(exportPackage); err == nil {
+		t.Errorf("reactivation accepted a package exported by the hot chain")
+	}
+
+	exportPackage.Investigation.ID = "INV\x00001"
+	if err := ArchiveFlow.CheckPackage(exportPackage); err == nil {
+		t.Errorf("archive accepted a case ID that cannot be a key attribute")
+	}
+}
@@ -0,0 +1,176 @@
+package core
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ==============================================================================
+// TRANSFER TRUST ANCHORS
+// ==============================================================================
+//
+// A chain imports a case only with a proof that the other chain committed the
+// export (see VerifyTransferProof). The proof is checked against the other
+// chain's MSP root certificates, which a SystemAdmin registers on this ledger
+// with the chaincode's SetTransferTrust transaction.
+
+// TransferTrustKey is the world state key of the trust anchors of the other chain
+var TransferTrustKey = StateKey(RecordConfig, "transfer_trust")
+
+// MSP roles in the other chain's network
+const (
+	MSPRolePeer    = "peer"    // Endorses the export transaction
+	MSPRoleOrderer = "orderer" // Signs the block that holds it
+)
+
+// TrustedMSP is one MSP of the other chain's network
+type TrustedMSP struct {
+	MSPID             string   `json:"msp_id"`
+	Role              string   `json:"role"`                                    // MSPRolePeer or MSPRoleOrderer
+	RootCerts         []string `json:"root_certs"`                              // PEM
+	IntermediateCerts []string `json:"intermediate_certs" metadata:",optional"` // PEM
+}
+
+// TransferTrust is the on-ledger description of the chain whose exports this ledger imports
+type TransferTrust struct {
+	SourceChain     string       `json:"source_chain"`   // ChainHot or ChainCold
+	ChannelID       string       `json:"channel_id"`     // Channel of the source chain's chaincode
+	ChaincodeName   string       `json:"chaincode_name"` // Name the source chain's chaincode is deployed under
+	MSPs            []TrustedMSP `json:"msps"`
+	MinEndorsements int          `json:"min_endorsements"` // Distinct peer MSPs that must endorse an export
+
+	Version   int    `json:"version"`
+	UpdatedAt int64  `json:"updated_at"`
+	UpdatedBy string `json:"updated_by"`
+	Change    string `json:"change"`
+}
+
+// CheckTransferTrust verifies that trust anchors can be used to check proofs
+func CheckTransferTrust(trust *TransferTrust) error {
+	if trust.SourceChain != ChainHot && trust.SourceChain != ChainCold {
+		return fmt.Errorf("invalid source chain: %s", trust.SourceChain)
+	}
+	if trust.ChannelID == "" || trust.ChaincodeName == "" {
+		return fmt.Errorf("transfer trust requires the source channel and chaincode name")
+	}
+
+	seen := map[string]bool{}
+	peers, orderers := 0, 0
+	for _, msp := range trust.MSPs {
+		if msp.MSPID == "" {
+			return fmt.Errorf("trusted MSP without an MSP ID")
+		}
+		if seen[msp.MSPID] {
+			return fmt.Errorf("MSP %s is listed twice", msp.MSPID)
+		}
+		seen[msp.MSPID] = true
+
+		switch msp.Role {
+		case MSPRolePeer:
+			peers++
+		case MSPRoleOrderer:
+			orderers++
+		default:
+			return fmt.Errorf("MSP %s has invalid role %q, expected %s or %s", msp.MSPID, msp.Role, MSPRolePeer, MSPRoleOrderer)
+		}
+
+		if len(msp.RootCerts) == 0 {
+			return fmt.Errorf("MSP %s has no root certificates", msp.MSPID)
+		}
+		if _, err := parseCertificates(msp.RootCerts, true); err != nil {
+			return fmt.Errorf("MSP %s: %v", msp.MSPID, err)
+		}
+		if _, err := parseCertificates(msp.IntermediateCerts, true); err != nil {
+			return fmt.Errorf("MSP %s: %v", msp.MSPID, err)
+		}
+	}
+
+	if orderers == 0 {
+		return fmt.Errorf("transfer trust requires at least one orderer MSP")
+	}
+	if trust.MinEndorsements < 1 || trust.MinEndorsements > peers {
+		return fmt.Errorf("min_endorsements must be between 1 and the %d trusted peer MSPs", peers)
+	}
+	return nil
+}
+
+// LoadTransferTrust reads the trust anchors of sourceChain
+func LoadTransferTrust(ctx contractapi.TransactionContextInterface, sourceChain string) (*TransferTrust, error) {
+	trustJSON, err := ctx.GetStub().GetState(TransferTrustKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transfer trust: %v", err)
+	}
+	if trustJSON == nil {
+		return nil, fmt.Errorf("no trust anchors registered for the %s chain", sourceChain)
+	}
+
+	var trust TransferTrust
+	if err := json.Unmarshal(trustJSON, &trust); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal transfer trust: %v", err)
+	}
+	if trust.SourceChain != sourceChain {
+		return nil, fmt.Errorf("registered trust anchors are for the %s chain, not %s", trust.SourceChain, sourceChain)
+	}
+	return &trust, nil
+}
+
+// SaveTransferTrust checks trust and stores it as the next version of the trust anchors
+func SaveTransferTrust(ctx contractapi.TransactionContextInterface, trust *TransferTrust) ([]byte, error) {
+	if err := CheckTransferTrust(trust); err != nil {
+		return nil, err
+	}
+
+	previousJSON, err := ctx.GetStub().GetState(TransferTrustKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transfer trust: %v", err)
+	}
+	trust.Version = 1
+	if previousJSON != nil {
+		var previous TransferTrust
+		if err := json.Unmarshal(previousJSON, &previous); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal transfer trust: %v", err)
+		}
+		trust.Version = previous.Version + 1
+	}
+
+	now, err := TxNow(ctx)
+	if err != nil {
+		return nil, err
+	}
+	clientID, _ := ctx.GetClientIdentity().GetID()
+	trust.UpdatedAt = now
+	trust.UpdatedBy = clientID
+
+	trustJSON, err := json.Marshal(trust)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal transfer trust: %v", err)
+	}
+	if err := ctx.GetStub().PutState(TransferTrustKey, trustJSON); err != nil {
+		return nil, fmt.Errorf("failed to store transfer trust: %v", err)
+	}
+	return trustJSON, nil
+}
+
+// parseCertificates decodes PEM certificates, requiring CA certificates when ca is set
+func parseCertificates(pemCerts []string, ca bool) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for _, pemCert := range pemCerts {
+		block, _ := pem.Decode([]byte(pemCert))
+		if block == nil || block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("invalid PEM certificate")
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %v", err)
+		}
+		if ca && !cert.IsCA {
+			return nil, fmt.Errorf("certificate %s is not a CA certificate", cert.Subject)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
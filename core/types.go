@@ -0,0 +1,177 @@
+package core
+
+import "encoding/json"
+
+// ==============================================================================
+// DATA STRUCTURES (Shared by the hot and cold chains)
+// ==============================================================================
+
+// Chain names, as stored in Evidence.ChainType and the source_chain of a package
+const (
+	ChainHot  = "hot"
+	ChainCold = "cold"
+)
+
+// Investigation represents a case/investigation. The archive fields are set by the
+// cold chain and kept when a case is reactivated on the hot chain.
+type Investigation struct {
+	ID               string `json:"id"`
+	CaseNumber       string `json:"case_number"`
+	CaseName         string `json:"case_name"`
+	InvestigatingOrg string `json:"investigating_org"`
+	LeadInvestigator string `json:"lead_investigator"`
+	Status           string `json:"status"` // open, under_investigation, closed, archived, see also the transfer statuses
+	OpenedDate       int64  `json:"opened_date"`
+	ClosedDate       int64  `json:"closed_date"`
+	ArchivedDate     int64  `json:"archived_date"`
+	Description      string `json:"description"`
+	EvidenceCount    int    `json:"evidence_count"`
+	CreatedBy        string `json:"created_by"`
+	ArchivedBy       string `json:"archived_by"`
+	CreatedAt        int64  `json:"created_at"`
+	UpdatedAt        int64  `json:"updated_at"`
+	ArchivedAt       int64  `json:"archived_at"`
+	Classification   string `json:"classification"` // unclassified, restricted, confidential, secret
+	Jurisdiction     string `json:"jurisdiction"`   // Region/agency jurisdiction of the case
+	OwningUnit       string `json:"owning_unit"`    // Unit that owns the case
+}
+
+// Evidence represents a piece of digital evidence
+type Evidence struct {
+	ID              string `json:"id"`
+	CaseID          string `json:"case_id"`
+	Type            string `json:"type"`
+	Description     string `json:"description"`
+	Hash            string `json:"hash"`              // SHA-256
+	IPFSHash        string `json:"ipfs_hash"`         // IPFS CID
+	Location        string `json:"location"`          // Physical/digital location
+	Custodian       string `json:"custodian"`         // Current custodian, frozen once archived
+	CollectedBy     string `json:"collected_by"`      // Original collector
+	Timestamp       int64  `json:"timestamp"`         // Collection timestamp
+	Status          string `json:"status"`            // collected, analyzed, reviewed, archived, disposed
+	Metadata        string `json:"metadata"`          // JSON metadata
+	FileSize        int64  `json:"file_size"`         // File size in bytes
+	ChainType       string `json:"chain_type"`        // hot or cold
+	TransactionID   string `json:"transaction_id"`    // Blockchain tx ID
+	CustodyChainRef string `json:"custody_chain_ref"` // Reference to custody chain
+	CreatedBy       string `json:"created_by"`
+	ArchivedBy      string `json:"archived_by"`
+	CreatedAt       int64  `json:"created_at"`
+	UpdatedAt       int64  `json:"updated_at"`
+	ArchivedAt      int64  `json:"archived_at"`
+	SourceChain     string `json:"source_chain"` // Chain the evidence was last imported from
+	SourceTxID      string `json:"source_tx_id"` // Export transaction on that chain
+}
+
+// AuditLog records all operations for compliance
+type AuditLog struct {
+	ID            string `json:"id"`
+	DocType       string `json:"doc_type"` // always "audit_log"; entries written before 1.6.0 have none
+	UserID        string `json:"user_id"`
+	Action        string `json:"action"`
+	Resource      string `json:"resource"`
+	ResourceID    string `json:"resource_id"`
+	Result        string `json:"result"` // success, denied, error
+	Reason        string `json:"reason"`
+	Timestamp     int64  `json:"timestamp"`
+	ClientMSP     string `json:"client_msp"`
+	TransactionID string `json:"transaction_id"`
+}
+
+// ArchiveMetadata stores archival verification info
+type ArchiveMetadata struct {
+	EvidenceID         string `json:"evidence_id"`
+	OriginalChain      string `json:"original_chain"`
+	OriginalTxID       string `json:"original_tx_id"`
+	ArchivalVerifiedBy string `json:"archival_verified_by"`
+	ArchivalTimestamp  int64  `json:"archival_timestamp"`
+	IntegrityHash      string `json:"integrity_hash"`
+}
+
+// PRVConfig stores attestation verification keys and measurements
+type PRVConfig struct {
+	PublicKey       string          `json:"public_key"`
+	MREnclave       string          `json:"mr_enclave"`
+	MRSigner        string          `json:"mr_signer"`
+	UpdatedAt       int64           `json:"updated_at"`
+	AttestationDoc  string          `json:"attestation_doc"`
+	VerifiedBy      []VerifierEntry `json:"verified_by"`
+	QuorumThreshold int             `json:"quorum_threshold"` // Distinct unexpired verifier MSPs required
+	TCBLevel        string          `json:"tcb_level"`        // Minimum ISV SVN of the PRV enclave
+	ExpiresAt       int64           `json:"expires_at"`       // When the verifier quorum lapses
+
+	// Every change stores a new version; earlier versions stay readable for audits
+	Version          int                  `json:"version"`
+	EffectiveFrom    int64                `json:"effective_from"`
+	EffectiveTxID    string               `json:"effective_tx_id"`
+	Change           string               `json:"change"`
+	RevokedVerifiers []VerifierRevocation `json:"revoked_verifiers"`
+
+	// Quorum is computed by the PRV config queries and never stored
+	Quorum *QuorumHealth `json:"quorum,omitempty" metadata:",optional"`
+}
+
+// VerifierEntry is one MSP's attestation of the PRV configuration
+type VerifierEntry struct {
+	MSP        string `json:"msp"`
+	Verifier   string `json:"verifier"`  // Client ID that registered the attestation
+	RecordID   string `json:"record_id"` // AttestationRecord backing this entry
+	VerifiedAt int64  `json:"verified_at"`
+	ExpiresAt  int64  `json:"expires_at"`
+}
+
+// UnmarshalJSON also accepts the plain MSP strings stored by earlier versions.
+// Such entries carry no timestamp and therefore count as expired.
+func (v *VerifierEntry) UnmarshalJSON(data []byte) error {
+	var msp string
+	if err := json.Unmarshal(data, &msp); err == nil {
+		*v = VerifierEntry{MSP: msp}
+		return nil
+	}
+
+	type entry VerifierEntry
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return err
+	}
+	*v = VerifierEntry(e)
+	return nil
+}
+
+// VerifierRevocation bars an MSP from counting towards the attestation quorum
+type VerifierRevocation struct {
+	MSP       string `json:"msp"`
+	Reason    string `json:"reason"`
+	RevokedBy string `json:"revoked_by"`
+	RevokedAt int64  `json:"revoked_at"`
+}
+
+// QuorumHealth reports whether enough distinct MSPs currently attest the PRV configuration
+type QuorumHealth struct {
+	Threshold   int      `json:"threshold"`
+	ActiveMSPs  []string `json:"active_msps"`
+	ExpiredMSPs []string `json:"expired_msps"`
+	Met         bool     `json:"met"`
+	ValidUntil  int64    `json:"valid_until"` // When the quorum lapses unless renewed, 0 if not met
+	CheckedAt   int64    `json:"checked_at"`
+}
+
+// AccessPolicy is the on-ledger copy of the Casbin p rules and g role assignments
+type AccessPolicy struct {
+	Version   int        `json:"version"`
+	Rules     [][]string `json:"rules"` // p, sub, obj, act, res
+	Roles     [][]string `json:"roles"` // g, user, role
+	UpdatedAt int64      `json:"updated_at"`
+	UpdatedBy string     `json:"updated_by"`
+	Change    string     `json:"change"` // Description of the change that produced this version
+}
+
+// MSPRoleMapping is the on-ledger default role of each MSP, used when a
+// certificate carries no "role" attribute
+type MSPRoleMapping struct {
+	Version   int               `json:"version"`
+	Roles     map[string]string `json:"roles"` // MSP ID -> role
+	UpdatedAt int64             `json:"updated_at"`
+	UpdatedBy string            `json:"updated_by"`
+	Change    string            `json:"change"`
+}
@@ -205,7 +205,7 @@ check_result "Committed to Cold blockchain"
 
 # Step 10: Initialize chaincode with PRV configuration
 print_step "Initializing chaincode..."
-echo "Note: Using placeholder PRV config. Update later with real Intel SGX keys."
+echo "Note: Using placeholder PRV config. PRV-signed operations are refused until RotatePRVKey installs the real Intel SGX key."
 
 # Initialize Hot blockchain with InitLedger
 echo "Initializing Hot blockchain chaincode..."
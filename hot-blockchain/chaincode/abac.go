@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	core "github.com/aub/dfir-core"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ==============================================================================
+// ATTRIBUTE-BASED ACCESS CONTROL
+// ==============================================================================
+//
+// The ABAC rules themselves live in the core module and are shared with the cold
+// chain; the hot chain is where a case's classification is set.
+
+// SetInvestigationClassification sets the classification, jurisdiction and owning unit of a case
+func (cc *DFIRChaincode) SetInvestigationClassification(ctx contractapi.TransactionContextInterface,
+	id string, classification string, jurisdiction string, unit string) error {
+
+	// Check attestation
+	if err := core.CheckAttestation(ctx); err != nil {
+		return fmt.Errorf("attestation check failed: %v", err)
+	}
+
+	// Check permission
+	if err := accessControl.CheckPermission(ctx, "blockchain.investigation", "update", "*"); err != nil {
+		return err
+	}
+
+	// ReadInvestigation applies the ABAC rules for the current classification
+	investigation, err := cc.ReadInvestigation(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// Only case leads may reclassify
+	if err := cc.checkCaseMember(ctx, id, "manage"); err != nil {
+		return err
+	}
+	if err := core.CheckRecusal(ctx, "blockchain.investigation", "update", id, ""); err != nil {
+		return err
+	}
+
+	level, ok := core.ClassificationLevels[classification]
+	if !ok {
+		return fmt.Errorf("invalid classification: %s", classification)
+	}
+	if level >= core.ClassificationLevels["restricted"] && jurisdiction == "" {
+		return fmt.Errorf("a %s case requires a jurisdiction", classification)
+	}
+	if level >= core.ClassificationLevels["secret"] && unit == "" {
+		return fmt.Errorf("a %s case requires an owning unit", classification)
+	}
+
+	// Callers cannot classify a case above their own clearance
+	exempt, err := core.IsOversight(ctx)
+	if err != nil {
+		return err
+	}
+	clearance, valid := core.ClearanceLevel(core.GetCallerAttributes(ctx).Clearance)
+	if !exempt && (!valid || clearance < level) {
+		core.LogAudit(ctx, "update", "blockchain.investigation", id, "denied", "Classification exceeds caller clearance")
+		return fmt.Errorf("access denied: cannot classify %s above your clearance", id)
+	}
+
+	now, err := core.TxNow(ctx)
+	if err != nil {
+		return err
+	}
+
+	investigation.Classification = classification
+	investigation.Jurisdiction = jurisdiction
+	investigation.OwningUnit = unit
+	investigation.UpdatedAt = now
+
+	investigationJSON, err := json.Marshal(investigation)
+	if err != nil {
+		return fmt.Errorf("failed to marshal investigation: %v", err)
+	}
+
+	if err := ctx.GetStub().PutState(core.InvestigationKey(id), investigationJSON); err != nil {
+		return fmt.Errorf("failed to update investigation: %v", err)
+	}
+
+	// Emit event
+	ctx.GetStub().SetEvent("InvestigationUpdated", investigationJSON)
+
+	// Audit log
+	core.LogAudit(ctx, "SetInvestigationClassification", "blockchain.investigation", id, "success",
+		fmt.Sprintf("Classification %s, jurisdiction %s, unit %s", classification, jurisdiction, unit))
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRestrictedCaseLimitedToJurisdiction(t *testing.T) {
+	endorsers := newEndorsers(t)
+	lead := newCreator(t, "LawEnforcementMSP", "investigator1.lawenforcement.hot.coc.com",
+		map[string]string{"jurisdiction": "north", "clearance": "restricted"})
+	local := newCreator(t, "LawEnforcementMSP", "investigator2.lawenforcement.hot.coc.com",
+		map[string]string{"jurisdiction": "north", "clearance": "restricted"})
+	foreign := newCreator(t, "LawEnforcementMSP", "investigator3.lawenforcement.hot.coc.com",
+		map[string]string{"jurisdiction": "south", "clearance": "restricted"})
+	initLedger(t, endorsers, lead)
+
+	endorseAll(t, endorsers, "tx-case", lead, "CreateInvestigation",
+		"INV-001", "CASE-2025-001", "Ransomware", "LawEnforcement", "investigator1", "abac test")
+	endorseAll(t, endorsers, "tx-classify", lead, "SetInvestigationClassification",
+		"INV-001", "restricted", "north", "")
+
+	// Both hold the same role and are on the case team; only the jurisdiction differs
+	for _, member := range []string{"investigator2", "investigator3"} {
+		endorseAll(t, endorsers, "tx-add-"+member, lead, "AddCaseMember", "INV-001",
+			"LawEnforcementMSP/CN="+member+".lawenforcement.hot.coc.com", "observer")
+	}
+
+	if result := endorsers[0].endorse("tx-read-local", local, proposalTime, "ReadInvestigation", "INV-001"); result.Status != 200 {
+		t.Errorf("read by an investigator of the case jurisdiction failed: %s", result.Message)
+	}
+	result := endorsers[0].endorse("tx-read-foreign", foreign, proposalTime, "ReadInvestigation", "INV-001")
+	if !strings.Contains(result.Message, `jurisdiction "south" cannot access restricted case`) {
+		t.Errorf("read by an investigator of another jurisdiction: %d %s", result.Status, result.Message)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	core "github.com/aub/dfir-core"
+)
+
+func TestRecordAccessDenialConfirmsAgainstPolicy(t *testing.T) {
+	endorsers := newEndorsers(t)
+	investigator := newCreator(t, "LawEnforcementMSP", "investigator1.lawenforcement.hot.coc.com", nil)
+	initLedger(t, endorsers, investigator)
+
+	for _, tc := range []struct {
+		txID      string
+		object    string
+		action    string
+		confirmed bool
+	}{
+		{"tx-denial-archive", "blockchain.investigation", "archive", true},
+		{"tx-denial-view", "blockchain.evidence", "view", false},
+	} {
+		result := endorseAll(t, endorsers, tc.txID, investigator, "RecordAccessDenial",
+			"Function", tc.object, tc.action, "", "tx-denied", "access denied")
+		var denial core.AccessDenial
+		if err := json.Unmarshal([]byte(result.Payload), &denial); err != nil {
+			t.Fatalf("failed to decode denial: %v", err)
+		}
+		if denial.Confirmed != tc.confirmed {
+			t.Errorf("%s %s confirmed = %t, want %t", tc.action, tc.object, denial.Confirmed, tc.confirmed)
+		}
+		if denial.DocType != "access_denial" || denial.Subject != "LawEnforcementMSP/CN=investigator1.lawenforcement.hot.coc.com" {
+			t.Errorf("denial recorded as %s for %s", denial.DocType, denial.Subject)
+		}
+	}
+
+	// Audit entries are typed so audit queries can leave denial records out
+	result := endorseAll(t, endorsers, "tx-case", investigator, "CreateInvestigation",
+		"INV-001", "CASE-2025-001", "Ransomware", "LawEnforcement", "investigator1", "denial test")
+	var audit map[string]interface{}
+	if err := json.Unmarshal([]byte(result.Writes[core.StateKey(core.RecordAudit, "audit_tx-case")]), &audit); err != nil {
+		t.Fatalf("failed to read audit entry: %v", err)
+	}
+	if audit["doc_type"] != "audit_log" {
+		t.Errorf("audit entry doc_type = %v, want audit_log", audit["doc_type"])
+	}
+	query := fmt.Sprintf(`{"selector":{"user_id":"%s",%s}}`, "x509::user", core.AuditSelector)
+	if !json.Valid([]byte(query)) {
+		t.Errorf("audit query is not valid JSON: %s", query)
+	}
+}
+
+func TestRecordAccessDenialRequiresObjectAndAction(t *testing.T) {
+	endorsers := newEndorsers(t)
+	investigator := newCreator(t, "LawEnforcementMSP", "investigator1.lawenforcement.hot.coc.com", nil)
+	initLedger(t, endorsers, investigator)
+
+	result := endorsers[0].endorse("tx-denial", investigator, proposalTime, "RecordAccessDenial",
+		"Function", "", "view", "", "", "access denied")
+	if result.Status == 200 {
+		t.Errorf("denial without an object recorded")
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	core "github.com/aub/dfir-core"
+)
+
+// effectiveRoles returns the roles GetEffectivePermissions resolves for the caller
+func effectiveRoles(t *testing.T, e *endorser, txID string, creator []byte) []string {
+	t.Helper()
+	result := e.endorse(txID, creator, proposalTime, "GetEffectivePermissions", "")
+	if result.Status != 200 {
+		t.Fatalf("GetEffectivePermissions failed: %s", result.Message)
+	}
+	var permissions core.EffectivePermissions
+	if err := json.Unmarshal([]byte(result.Payload), &permissions); err != nil {
+		t.Fatalf("failed to decode permissions: %v", err)
+	}
+	return permissions.Roles
+}
+
+func hasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func TestQualifySubjectMigratesLegacyAssignments(t *testing.T) {
+	endorsers := newEndorsers(t)
+	admin := newCreator(t, "LawEnforcementMSP", "admin.lawenforcement.hot.coc.com",
+		map[string]string{"role": "SystemAdmin"})
+	initLedger(t, endorsers, admin)
+
+	// Two MSPs' CAs issued the same common name
+	examiner := newCreator(t, "ForensicLabMSP", "examiner1.coc.com", nil)
+	impostor := newCreator(t, "LawEnforcementMSP", "examiner1.coc.com", nil)
+
+	// An assignment written before subjects carried their MSP no longer matches anyone
+	endorseAll(t, endorsers, "tx-assign", admin, "AssignRole", "CN=examiner1.coc.com", "BlockchainAuditor")
+	if hasRole(effectiveRoles(t, endorsers[0], "tx-roles-1", examiner), "BlockchainAuditor") {
+		t.Fatalf("unqualified assignment applied to a qualified subject")
+	}
+
+	endorseAll(t, endorsers, "tx-qualify", admin, "QualifySubject", "CN=examiner1.coc.com", "ForensicLabMSP")
+	if !hasRole(effectiveRoles(t, endorsers[0], "tx-roles-2", examiner), "BlockchainAuditor") {
+		t.Errorf("migrated assignment does not apply to ForensicLabMSP/CN=examiner1.coc.com")
+	}
+	if hasRole(effectiveRoles(t, endorsers[0], "tx-roles-3", impostor), "BlockchainAuditor") {
+		t.Errorf("migrated assignment applies to the same common name in another MSP")
+	}
+
+	result := endorsers[0].endorse("tx-qualify-2", examiner, proposalTime, "QualifySubject", "CN=examiner1.coc.com", "ForensicLabMSP")
+	if result.Status == 200 {
+		t.Errorf("QualifySubject by a non-administrator succeeded")
+	}
+}
+
+func TestPolicyReadsRequirePolicyView(t *testing.T) {
+	endorsers := newEndorsers(t)
+	admin := newCreator(t, "LawEnforcementMSP", "admin.lawenforcement.hot.coc.com",
+		map[string]string{"role": "SystemAdmin"})
+	investigator := newCreator(t, "LawEnforcementMSP", "investigator1.lawenforcement.hot.coc.com", nil)
+	initLedger(t, endorsers, admin)
+
+	for _, function := range []string{"ListPolicies", "GetPolicyHistory"} {
+		// The mock stub has no key history, so only the permission check is compared
+		result := endorsers[0].endorse("tx-admin-"+function, admin, proposalTime, function)
+		if strings.Contains(result.Message, "access denied") {
+			t.Errorf("%s by SystemAdmin denied: %s", function, result.Message)
+		}
+		result = endorsers[0].endorse("tx-investigator-"+function, investigator, proposalTime, function)
+		if !strings.Contains(result.Message, "access denied") {
+			t.Errorf("%s by an investigator was not denied: %d %s", function, result.Status, result.Message)
+		}
+	}
+}
+
+func TestRolesInheritParentPermissions(t *testing.T) {
+	endorsers := newEndorsers(t)
+	admin := newCreator(t, "LawEnforcementMSP", "admin.lawenforcement.hot.coc.com",
+		map[string]string{"role": "SystemAdmin"})
+	supervisor := newCreator(t, "LawEnforcementMSP", "supervisor1.lawenforcement.hot.coc.com",
+		map[string]string{"role": "BlockchainSupervisor"})
+	auditor := newCreator(t, "AuditorMSP", "auditor1.auditor.hot.coc.com", nil)
+	initLedger(t, endorsers, admin)
+
+	// Supervisors create cases through the permissions they inherit from investigators
+	if !hasRole(effectiveRoles(t, endorsers[0], "tx-roles-supervisor", supervisor), "BlockchainInvestigator") {
+		t.Errorf("BlockchainSupervisor does not inherit BlockchainInvestigator")
+	}
+	endorseAll(t, endorsers, "tx-case", supervisor, "CreateInvestigation",
+		"INV-001", "CASE-2025-001", "Ransomware", "LawEnforcement", "supervisor1", "inheritance test")
+
+	result := endorsers[0].endorse("tx-case-auditor", auditor, proposalTime, "CreateInvestigation",
+		"INV-002", "CASE-2025-002", "Fraud", "LawEnforcement", "auditor1", "inheritance test")
+	if !strings.Contains(result.Message, "access denied") {
+		t.Errorf("case created by an auditor: %d %s", result.Status, result.Message)
+	}
+
+	// An assignment that makes the inheritance loop back is rejected
+	result = endorsers[0].endorse("tx-assign-cycle", admin, proposalTime, "AssignRole",
+		"BlockchainInvestigator", "BlockchainSupervisor")
+	if !strings.Contains(result.Message, "role inheritance cycle") {
+		t.Errorf("cyclic role assignment: %d %s", result.Status, result.Message)
+	}
+
+	// Other identities' permissions are resolved for policy viewers only
+	result = endorsers[0].endorse("tx-effective-admin", admin, proposalTime, "GetEffectivePermissions", "BlockchainCourt")
+	var permissions core.EffectivePermissions
+	if err := json.Unmarshal([]byte(result.Payload), &permissions); err != nil {
+		t.Fatalf("failed to decode permissions: %d %s: %v", result.Status, result.Message, err)
+	}
+	if !hasRole(permissions.Roles, "BlockchainAuditor") {
+		t.Errorf("BlockchainCourt resolves to roles %v, want BlockchainAuditor among them", permissions.Roles)
+	}
+	// A role that inherits nothing resolves to no further roles
+	result = endorsers[0].endorse("tx-effective-leaf", admin, proposalTime, "GetEffectivePermissions", "BlockchainAuditor")
+	if result.Status != 200 {
+		t.Errorf("permissions of a role without parents: %s", result.Message)
+	}
+	result = endorsers[0].endorse("tx-effective-supervisor", supervisor, proposalTime, "GetEffectivePermissions", "BlockchainCourt")
+	if !strings.Contains(result.Message, "access denied") {
+		t.Errorf("permissions of another identity resolved for a supervisor: %d %s", result.Status, result.Message)
+	}
+}
+
+// explainAccess returns the ExplainAccess decision for the caller's request
+func explainAccess(t *testing.T, e *endorser, txID string, creator []byte, args ...string) *core.AccessExplanation {
+	t.Helper()
+	result := e.endorse(txID, creator, proposalTime, "ExplainAccess", args...)
+	if result.Status != 200 {
+		t.Fatalf("ExplainAccess failed: %s", result.Message)
+	}
+	if len(result.Writes) != 0 {
+		t.Errorf("ExplainAccess wrote %v", keys(result.Writes))
+	}
+	var explanation core.AccessExplanation
+	if err := json.Unmarshal([]byte(result.Payload), &explanation); err != nil {
+		t.Fatalf("failed to decode explanation: %v", err)
+	}
+	return &explanation
+}
+
+func TestExplainAccessReportsDecision(t *testing.T) {
+	endorsers := newEndorsers(t)
+	admin := newCreator(t, "LawEnforcementMSP", "admin.lawenforcement.hot.coc.com",
+		map[string]string{"role": "SystemAdmin"})
+	investigator := newCreator(t, "LawEnforcementMSP", "investigator1.lawenforcement.hot.coc.com", nil)
+	initLedger(t, endorsers, admin)
+
+	explanation := explainAccess(t, endorsers[0], "tx-explain-allow", investigator,
+		"blockchain.investigation", "create", "*", "")
+	if explanation.Decision != "allow" || explanation.Role != "BlockchainInvestigator" ||
+		explanation.RoleSource != core.RoleSourceMSP {
+		t.Errorf("investigator create explained as %s for %s from %s: %s", explanation.Decision,
+			explanation.Role, explanation.RoleSource, explanation.Reason)
+	}
+
+	explanation = explainAccess(t, endorsers[0], "tx-explain-deny", admin,
+		"blockchain.investigation", "create", "*", "BlockchainAuditor")
+	if explanation.Decision != "deny" || !strings.Contains(explanation.Reason, "no rule grants create") {
+		t.Errorf("auditor create explained as %s: %s", explanation.Decision, explanation.Reason)
+	}
+
+	// Explaining another identity requires policy view
+	result := endorsers[0].endorse("tx-explain-other", investigator, proposalTime, "ExplainAccess",
+		"blockchain.investigation", "create", "*", "BlockchainAuditor")
+	if !strings.Contains(result.Message, "access denied") {
+		t.Errorf("investigator explained another identity: %d %s", result.Status, result.Message)
+	}
+}
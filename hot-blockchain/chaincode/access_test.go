@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelfScopeLimitsReadsToOwnRecords(t *testing.T) {
+	endorsers := newEndorsers(t)
+	owner := newCreator(t, "LawEnforcementMSP", "investigator1.lawenforcement.hot.coc.com", nil)
+	other := newCreator(t, "LawEnforcementMSP", "investigator2.lawenforcement.hot.coc.com", nil)
+	supervisor := newCreator(t, "LawEnforcementMSP", "supervisor1.lawenforcement.hot.coc.com",
+		map[string]string{"role": "BlockchainSupervisor"})
+	initLedger(t, endorsers, owner)
+
+	// Investigators may view only the audit entries they caused
+	endorseAll(t, endorsers, "tx-case", owner, "CreateInvestigation",
+		"INV-001", "CASE-2025-001", "Ransomware", "LawEnforcement", "investigator1", "ownership test")
+	if result := endorsers[0].endorse("tx-read-owner", owner, proposalTime, "ReadAuditLog", "audit_tx-case"); result.Status != 200 {
+		t.Errorf("owner read of own audit entry failed: %s", result.Message)
+	}
+	result := endorsers[0].endorse("tx-read-other", other, proposalTime, "ReadAuditLog", "audit_tx-case")
+	if !strings.Contains(result.Message, "limited to your own records") {
+		t.Errorf("read of another investigator's audit entry: %d %s", result.Status, result.Message)
+	}
+
+	// A chain-wide grant is not limited to the caller's records
+	if result := endorsers[0].endorse("tx-read-supervisor", supervisor, proposalTime, "ReadAuditLog", "audit_tx-case"); result.Status != 200 {
+		t.Errorf("supervisor read failed: %s", result.Message)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	core "github.com/aub/dfir-core"
+)
+
+func TestAttestationQuorumGatesWrites(t *testing.T) {
+	endorsers := newEndorsers(t)
+	investigator := newCreator(t, "LawEnforcementMSP", "investigator1.lawenforcement.hot.coc.com", nil)
+	initLedger(t, endorsers, investigator)
+
+	// Two distinct MSPs meet the default quorum
+	endorseAll(t, endorsers, "tx-case-1", investigator, "CreateInvestigation",
+		"INV-001", "CASE-2025-001", "Ransomware", "LawEnforcement", "investigator1", "quorum test")
+	result := endorsers[0].endorse("tx-config", investigator, proposalTime, "GetPRVConfig")
+	var config PRVConfig
+	if err := json.Unmarshal([]byte(result.Payload), &config); err != nil {
+		t.Fatalf("failed to decode PRV config: %d %s: %v", result.Status, result.Message, err)
+	}
+	if config.Quorum == nil || !config.Quorum.Met || len(config.Quorum.ActiveMSPs) != 2 {
+		t.Errorf("quorum health = %+v, want met by 2 MSPs", config.Quorum)
+	}
+
+	// Writes stop once the attestations expire
+	lapsed := proposalTime.Add(core.AttestationValidity).Add(time.Minute)
+	result = endorsers[0].endorse("tx-case-2", investigator, lapsed, "CreateInvestigation",
+		"INV-002", "CASE-2025-002", "Fraud", "LawEnforcement", "investigator1", "quorum test")
+	if !strings.Contains(result.Message, "insufficient verifiers: 0 unexpired of 2 required") {
+		t.Errorf("write after the quorum lapsed: %d %s", result.Status, result.Message)
+	}
+
+	// Repeated attestations by one MSP count once
+	updatePRVConfig(t, endorsers, func(config *PRVConfig) {
+		for i := range config.VerifiedBy {
+			config.VerifiedBy[i].MSP = "LawEnforcementMSP"
+		}
+	})
+	result = endorsers[0].endorse("tx-case-3", investigator, proposalTime, "CreateInvestigation",
+		"INV-003", "CASE-2025-003", "Fraud", "LawEnforcement", "investigator1", "quorum test")
+	if !strings.Contains(result.Message, "insufficient verifiers: 1 unexpired of 2 required") {
+		t.Errorf("write attested by a single MSP: %d %s", result.Status, result.Message)
+	}
+}
+
+// sgxFixtures holds SGX quotes of the PRV test enclave signed under a test root CA
+const sgxFixtures = "../../sgxquote/testdata/"
+
+func TestRegisterAttestationRequiresVerifierRole(t *testing.T) {
+	endorsers := newEndorsers(t)
+	admin := newCreator(t, "LawEnforcementMSP", "admin.lawenforcement.hot.coc.com",
+		map[string]string{"role": "SystemAdmin"})
+	investigator := newCreator(t, "LawEnforcementMSP", "investigator1.lawenforcement.hot.coc.com", nil)
+	verifier := newCreator(t, "CourtMSP", "verifier.court.hot.coc.com",
+		map[string]string{"role": "AttestationVerifier"})
+	initLedger(t, endorsers, admin)
+
+	rootPEM, err := os.ReadFile(sgxFixtures + "root_ca.pem")
+	if err != nil {
+		t.Fatalf("failed to read root CA: %v", err)
+	}
+	quote, err := os.ReadFile(sgxFixtures + "quote_v3.bin")
+	if err != nil {
+		t.Fatalf("failed to read quote: %v", err)
+	}
+	attestationDoc := base64.StdEncoding.EncodeToString(quote)
+
+	endorseAll(t, endorsers, "tx-root", admin, "SetSGXRootCA", string(rootPEM))
+	enclave := sha256.Sum256([]byte("dfir-prv-enclave"))
+	signer := sha256.Sum256([]byte("dfir-prv-signer"))
+	updatePRVConfig(t, endorsers, func(config *PRVConfig) {
+		config.MREnclave = hex.EncodeToString(enclave[:])
+		config.MRSigner = hex.EncodeToString(signer[:])
+	})
+
+	result := endorsers[0].endorse("tx-attest-investigator", investigator, proposalTime,
+		"RegisterAttestation", attestationDoc, "")
+	if !strings.Contains(result.Message, "only AttestationVerifier identities") {
+		t.Errorf("attestation by an investigator: %d %s", result.Status, result.Message)
+	}
+	result = endorsers[0].endorse("tx-attest-other-msp", verifier, proposalTime,
+		"RegisterAttestation", attestationDoc, "ForensicLabMSP")
+	if !strings.Contains(result.Message, "on behalf of ForensicLabMSP") {
+		t.Errorf("attestation on behalf of another MSP: %d %s", result.Status, result.Message)
+	}
+
+	// The verifier is recorded under its own MSP in a record of its own
+	result = endorseAll(t, endorsers, "tx-attest", verifier, "RegisterAttestation", attestationDoc, "")
+	var record core.AttestationRecord
+	if err := json.Unmarshal([]byte(result.Payload), &record); err != nil {
+		t.Fatalf("failed to decode attestation record: %v", err)
+	}
+	if record.VerifierMSP != "CourtMSP" || record.QuoteVersion != 3 {
+		t.Errorf("attestation recorded for %s with quote version %d", record.VerifierMSP, record.QuoteVersion)
+	}
+	if _, ok := result.Writes[core.StateKey(core.RecordAttestation, record.ID)]; !ok {
+		t.Errorf("attestation %s not stored as its own record, writes: %v", record.ID, keys(result.Writes))
+	}
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	core "github.com/aub/dfir-core"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ==============================================================================
+// CASE TEAM MEMBERSHIP
+// ==============================================================================
+//
+// Investigations and their evidence are visible only to the case team once RBAC
+// has allowed the action. Each member holds a case role that limits what they may
+// do on the case; oversight roles (SystemAdmin, Court, Auditor) are not scoped.
+// The creator of an investigation becomes its first lead.
+
+// Case roles and the case actions they allow
+var caseRoleActions = map[string][]string{
+	"lead":     {"*"},
+	"analyst":  {"view", "list", "history", "create", "update", "transfer"},
+	"observer": {"view", "list", "history"},
+}
+
+// CaseMember is a member of an investigation's team
+type CaseMember struct {
+	Member  string `json:"member"` // Subject, e.g. LawEnforcementMSP/CN=user1.lawenforcement.hot.coc.com
+	Role    string `json:"role"`   // lead, analyst, observer
+	AddedBy string `json:"added_by"`
+	AddedAt int64  `json:"added_at"`
+}
+
+// CaseTeam is the membership list of an investigation
+type CaseTeam struct {
+	DocType string       `json:"doc_type"` // "case_team", so rich queries can tell teams from evidence
+	CaseID  string       `json:"case_id"`
+	Members []CaseMember `json:"members"`
+}
+
+// AddCaseMember adds member to the case team with the given case role (case leads only)
+func (cc *DFIRChaincode) AddCaseMember(ctx contractapi.TransactionContextInterface,
+	caseID string, member string, role string) error {
+
+	// Check attestation
+	if err := core.CheckAttestation(ctx); err != nil {
+		return fmt.Errorf("attestation check failed: %v", err)
+	}
+
+	// Check permission
+	if err := accessControl.CheckPermission(ctx, "blockchain.investigation", "update", "*"); err != nil {
+		return err
+	}
+
+	if _, ok := caseRoleActions[role]; !ok {
+		return fmt.Errorf("invalid case role: %s", role)
+	}
+
+	team, err := cc.loadCaseTeam(ctx, caseID)
+	if err != nil {
+		return err
+	}
+	if team == nil {
+		return fmt.Errorf("investigation %s does not exist", caseID)
+	}
+	if err := cc.checkCaseMember(ctx, caseID, "manage"); err != nil {
+		return err
+	}
+	if err := core.CheckRecusal(ctx, "blockchain.investigation", "update", caseID, ""); err != nil {
+		return err
+	}
+
+	for _, existing := range team.Members {
+		if existing.Member == member {
+			return fmt.Errorf("%s is already a member of case %s as %s", member, caseID, existing.Role)
+		}
+	}
+
+	addedBy, _ := core.Subject(ctx)
+	now, err := core.TxNow(ctx)
+	if err != nil {
+		return err
+	}
+	newMember := CaseMember{
+		Member:  member,
+		Role:    role,
+		AddedBy: addedBy,
+		AddedAt: now,
+	}
+	team.Members = append(team.Members, newMember)
+
+	if err := cc.saveCaseTeam(ctx, team); err != nil {
+		return err
+	}
+
+	// Emit event
+	eventJSON, _ := json.Marshal(map[string]interface{}{"case_id": caseID, "member": newMember})
+	ctx.GetStub().SetEvent("CaseMemberAdded", eventJSON)
+
+	// Audit log
+	core.LogAudit(ctx, "AddCaseMember", "blockchain.investigation", caseID, "success",
+		fmt.Sprintf("%s added as %s", member, role))
+
+	return nil
+}
+
+// RemoveCaseMember removes member from the case team (case leads only)
+func (cc *DFIRChaincode) RemoveCaseMember(ctx contractapi.TransactionContextInterface,
+	caseID string, member string) error {
+
+	// Check attestation
+	if err := core.CheckAttestation(ctx); err != nil {
+		return fmt.Errorf("attestation check failed: %v", err)
+	}
+
+	// Check permission
+	if err := accessControl.CheckPermission(ctx, "blockchain.investigation", "update", "*"); err != nil {
+		return err
+	}
+
+	team, err := cc.loadCaseTeam(ctx, caseID)
+	if err != nil {
+		return err
+	}
+	if team == nil {
+		return fmt.Errorf("investigation %s does not exist", caseID)
+	}
+	if err := cc.checkCaseMember(ctx, caseID, "manage"); err != nil {
+		return err
+	}
+	if err := core.CheckRecusal(ctx, "blockchain.investigation", "update", caseID, ""); err != nil {
+		return err
+	}
+
+	index := -1
+	leads := 0
+	for i, existing := range team.Members {
+		if existing.Member == member {
+			index = i
+		}
+		if existing.Role == "lead" {
+			leads++
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("%s is not a member of case %s", member, caseID)
+	}
+
+	// A case must always keep a lead who can manage its team
+	if team.Members[index].Role == "lead" && leads == 1 {
+		return fmt.Errorf("cannot remove the last lead of case %s", caseID)
+	}
+
+	removed := team.Members[index]
+	team.Members = append(team.Members[:index], team.Members[index+1:]...)
+
+	if err := cc.saveCaseTeam(ctx, team); err != nil {
+		return err
+	}
+
+	// Emit event
+	eventJSON, _ := json.Marshal(map[string]interface{}{"case_id": caseID, "member": removed})
+	ctx.GetStub().SetEvent("CaseMemberRemoved", eventJSON)
+
+	// Audit log
+	core.LogAudit(ctx, "RemoveCaseMember", "blockchain.investigation", caseID, "success",
+		fmt.Sprintf("%s removed (was %s)", member, removed.Role))
+
+	return nil
+}
+
+// ListCaseMembers returns the members of the case team
+func (cc *DFIRChaincode) ListCaseMembers(ctx contractapi.TransactionContextInterface,
+	caseID string) ([]CaseMember, error) {
+
+	// Check permission
+	if err := accessControl.CheckPermission(ctx, "blockchain.investigation", "view", "*"); err != nil {
+		return nil, err
+	}
+
+	team, err := cc.loadCaseTeam(ctx, caseID)
+	if err != nil {
+		return nil, err
+	}
+	if team == nil {
+		return nil, fmt.Errorf("investigation %s does not exist", caseID)
+	}
+	if err := cc.checkCaseMember(ctx, caseID, "view"); err != nil {
+		return nil, err
+	}
+	if err := core.CheckRecusal(ctx, "blockchain.investigation", "view", caseID, ""); err != nil {
+		return nil, err
+	}
+
+	members := append([]CaseMember{}, team.Members...)
+	sort.Slice(members, func(i, j int) bool { return members[i].Member < members[j].Member })
+	return members, nil
+}
+
+// QualifySubject migrates an unqualified CN=... subject to <mspID>/CN=... in the access
+// policy and recusal records (see core.ChainContract.QualifySubject) and in case team members
+func (cc *DFIRChaincode) QualifySubject(ctx contractapi.TransactionContextInterface,
+	subject string, mspID string) error {
+
+	if err := cc.ChainContract.QualifySubject(ctx, subject, mspID); err != nil {
+		return err
+	}
+	return cc.qualifyCaseTeams(ctx, subject, core.QualifySubject(mspID, subject))
+}
+
+// ==============================================================================
+// CASE TEAM HELPERS
+// ==============================================================================
+
+// caseTeamKey returns the world state key of a case team
+func caseTeamKey(caseID string) string {
+	return core.StateKey(core.RecordCaseTeam, caseID)
+}
+
+// loadCaseTeam reads the team of an investigation, or nil if the investigation does
+// not exist. Cases created before teams existed have their creator as sole lead.
+func (cc *DFIRChaincode) loadCaseTeam(ctx contractapi.TransactionContextInterface,
+	caseID string) (*CaseTeam, error) {
+
+	investigation, err := core.LoadInvestigation(ctx, caseID)
+	if err != nil {
+		return nil, err
+	}
+	if investigation == nil {
+		return nil, nil
+	}
+
+	teamJSON, err := ctx.GetStub().GetState(caseTeamKey(investigation.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read case team: %v", err)
+	}
+	if teamJSON == nil {
+		return &CaseTeam{
+			CaseID: investigation.ID,
+			Members: []CaseMember{{
+				Member:  investigation.CreatedBy,
+				Role:    "lead",
+				AddedBy: investigation.CreatedBy,
+				AddedAt: investigation.CreatedAt,
+			}},
+		}, nil
+	}
+
+	var team CaseTeam
+	if err := json.Unmarshal(teamJSON, &team); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal case team: %v", err)
+	}
+	return &team, nil
+}
+
+// saveCaseTeam stores a case team
+func (cc *DFIRChaincode) saveCaseTeam(ctx contractapi.TransactionContextInterface, team *CaseTeam) error {
+	team.DocType = "case_team"
+	teamJSON, err := json.Marshal(team)
+	if err != nil {
+		return fmt.Errorf("failed to marshal case team: %v", err)
+	}
+	if err := ctx.GetStub().PutState(caseTeamKey(team.CaseID), teamJSON); err != nil {
+		return fmt.Errorf("failed to store case team: %v", err)
+	}
+	return nil
+}
+
+// qualifyCaseTeams renames an unqualified member subject on every case team
+func (cc *DFIRChaincode) qualifyCaseTeams(ctx contractapi.TransactionContextInterface,
+	subject string, qualified string) error {
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(core.RecordCaseTeam, []string{})
+	if err != nil {
+		return fmt.Errorf("failed to list case teams: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return err
+		}
+
+		var team CaseTeam
+		if err := json.Unmarshal(response.Value, &team); err != nil {
+			return fmt.Errorf("failed to unmarshal case team: %v", err)
+		}
+		renamed := false
+		for i := range team.Members {
+			if team.Members[i].Member == subject {
+				team.Members[i].Member = qualified
+				renamed = true
+			}
+		}
+		if renamed {
+			if err := cc.saveCaseTeam(ctx, &team); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// createCaseTeam stores a new team with the caller as lead
+func (cc *DFIRChaincode) createCaseTeam(ctx contractapi.TransactionContextInterface, caseID string) error {
+	subject, err := core.Subject(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := core.TxNow(ctx)
+	if err != nil {
+		return err
+	}
+
+	return cc.saveCaseTeam(ctx, &CaseTeam{
+		CaseID: caseID,
+		Members: []CaseMember{{
+			Member:  subject,
+			Role:    "lead",
+			AddedBy: subject,
+			AddedAt: now,
+		}},
+	})
+}
+
+// callerCaseRole returns the caller's role on the team, or "" if not a member.
+// Members are matched by subject (<msp>/CN=...) or by full client identity.
+func (cc *DFIRChaincode) callerCaseRole(ctx contractapi.TransactionContextInterface, team *CaseTeam) string {
+	if team == nil {
+		return ""
+	}
+	subject, _ := core.Subject(ctx)
+	for _, member := range team.Members {
+		if (subject != "" && member.Member == subject) || core.IsCaller(ctx, member.Member) {
+			return member.Role
+		}
+	}
+	return ""
+}
+
+// caseRoleAllows reports whether the case role permits the action
+func caseRoleAllows(role string, action string) bool {
+	for _, allowed := range caseRoleActions[role] {
+		if allowed == "*" || allowed == action {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCaseMember rejects callers who are not on the case team or whose case role
+// does not permit the action. Oversight roles and records without a case are not scoped.
+func (cc *DFIRChaincode) checkCaseMember(ctx contractapi.TransactionContextInterface,
+	caseID string, action string) error {
+
+	oversight, err := core.IsOversight(ctx)
+	if err != nil {
+		return err
+	}
+	if oversight || caseID == "" {
+		return nil
+	}
+
+	team, err := cc.loadCaseTeam(ctx, caseID)
+	if err != nil {
+		return err
+	}
+
+	role := cc.callerCaseRole(ctx, team)
+	if role == "" {
+		core.LogAudit(ctx, action, "blockchain.investigation", caseID, "denied", "Caller is not a member of the case team")
+		return fmt.Errorf("access denied: you are not a member of case %s", caseID)
+	}
+	if !caseRoleAllows(role, action) {
+		core.LogAudit(ctx, action, "blockchain.investigation", caseID, "denied",
+			fmt.Sprintf("Case role %s cannot %s", role, action))
+		return fmt.Errorf("access denied: case role %s cannot %s on case %s", role, action, caseID)
+	}
+	return nil
+}
+
+// checkEvidenceIDMember applies checkCaseMember to the case of an evidence item
+func (cc *DFIRChaincode) checkEvidenceIDMember(ctx contractapi.TransactionContextInterface,
+	evidenceID string, action string) error {
+
+	evidence, err := core.LoadEvidence(ctx, evidenceID)
+	if err != nil || evidence == nil {
+		return err
+	}
+	return cc.checkCaseMember(ctx, evidence.CaseID, action)
+}
+
+// filterInvestigationsByTeam keeps the investigations whose team the caller belongs to
+func (cc *DFIRChaincode) filterInvestigationsByTeam(ctx contractapi.TransactionContextInterface,
+	investigations []*Investigation) ([]*Investigation, error) {
+
+	oversight, err := core.IsOversight(ctx)
+	if err != nil || oversight {
+		return investigations, err
+	}
+
+	var allowed []*Investigation
+	for _, investigation := range investigations {
+		team, err := cc.loadCaseTeam(ctx, investigation.ID)
+		if err != nil {
+			return nil, err
+		}
+		if caseRoleAllows(cc.callerCaseRole(ctx, team), "list") {
+			allowed = append(allowed, investigation)
+		}
+	}
+	return allowed, nil
+}
+
+// filterEvidenceByTeam keeps the evidence whose case team the caller belongs to
+func (cc *DFIRChaincode) filterEvidenceByTeam(ctx contractapi.TransactionContextInterface,
+	evidenceList []*Evidence) ([]*Evidence, error) {
+
+	oversight, err := core.IsOversight(ctx)
+	if err != nil || oversight {
+		return evidenceList, err
+	}
+
+	decisions := map[string]bool{}
+	var allowed []*Evidence
+	for _, evidence := range evidenceList {
+		ok, seen := decisions[evidence.CaseID]
+		if !seen {
+			team, err := cc.loadCaseTeam(ctx, evidence.CaseID)
+			if err != nil {
+				return nil, err
+			}
+			ok = caseRoleAllows(cc.callerCaseRole(ctx, team), "list")
+			decisions[evidence.CaseID] = ok
+		}
+		if ok {
+			allowed = append(allowed, evidence)
+		}
+	}
+	return allowed, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCaseTeamScopesInvestigators(t *testing.T) {
+	endorsers := newEndorsers(t)
+	lead := newCreator(t, "LawEnforcementMSP", "investigator1.lawenforcement.hot.coc.com", nil)
+	analyst := newCreator(t, "LawEnforcementMSP", "investigator2.lawenforcement.hot.coc.com", nil)
+	initLedger(t, endorsers, lead)
+
+	endorseAll(t, endorsers, "tx-case", lead, "CreateInvestigation",
+		"INV-001", "CASE-2025-001", "Ransomware", "LawEnforcement", "investigator1", "case team test")
+
+	// The team record carries its doc_type and none of the fields evidence queries select on
+	var team map[string]interface{}
+	if err := json.Unmarshal(endorsers[0].stub.State[caseTeamKey("INV-001")], &team); err != nil {
+		t.Fatalf("failed to read case team: %v", err)
+	}
+	if team["doc_type"] != "case_team" || team["chain_type"] != nil {
+		t.Errorf("case team record = %v, want doc_type case_team and no chain_type", team)
+	}
+
+	result := endorsers[0].endorse("tx-read-1", analyst, proposalTime, "ReadInvestigation", "INV-001")
+	if !strings.Contains(result.Message, "not a member of case INV-001") {
+		t.Fatalf("non-member read: %d %s", result.Status, result.Message)
+	}
+
+	endorseAll(t, endorsers, "tx-add", lead, "AddCaseMember",
+		"INV-001", "LawEnforcementMSP/CN=investigator2.lawenforcement.hot.coc.com", "observer")
+	if result := endorsers[0].endorse("tx-read-2", analyst, proposalTime, "ReadInvestigation", "INV-001"); result.Status != 200 {
+		t.Fatalf("observer read failed: %s", result.Message)
+	}
+
+	// Observers may view but not update, and only leads manage the team
+	result = endorsers[0].endorse("tx-update", analyst, proposalTime, "UpdateInvestigationStatus", "INV-001", "closed")
+	if !strings.Contains(result.Message, "access denied") {
+		t.Errorf("observer update: %d %s", result.Status, result.Message)
+	}
+	result = endorsers[0].endorse("tx-add-2", analyst, proposalTime, "AddCaseMember",
+		"INV-001", "LawEnforcementMSP/CN=investigator3.lawenforcement.hot.coc.com", "analyst")
+	if !strings.Contains(result.Message, "access denied") {
+		t.Errorf("observer added a member: %d %s", result.Status, result.Message)
+	}
+}
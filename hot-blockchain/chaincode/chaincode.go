@@ -3,72 +3,43 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"strings"
-	"time"
 
+	core "github.com/aub/dfir-core"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
 // DFIRChaincode - Hot blockchain chaincode for active investigations
 type DFIRChaincode struct {
 	contractapi.Contract
+	core.ChainContract
 }
 
+// policyResource is the p.res value that scopes policy rules to this chain
+const policyResource = "hot"
+
+// accessControl evaluates permissions against the rules scoped to this chain
+var accessControl = core.AccessControl{Resource: policyResource}
+
+// chainContract configures the transactions shared with the cold chain, whose
+// exports this chain imports
+var chainContract = core.ChainContract{Access: accessControl, TransferSource: core.ChainCold}
+
 // ==============================================================================
 // DATA STRUCTURES
 // ==============================================================================
 
-// PRVConfig stores attestation verification keys and measurements
-type PRVConfig struct {
-	PublicKey      string   `json:"public_key"`
-	MREnclave      string   `json:"mr_enclave"`
-	MRSigner       string   `json:"mr_signer"`
-	UpdatedAt      int64    `json:"updated_at"`
-	AttestationDoc string   `json:"attestation_doc"`
-	VerifiedBy     []string `json:"verified_by"`
-	TCBLevel       string   `json:"tcb_level"`
-	ExpiresAt      int64    `json:"expires_at"`
-}
-
-// Investigation represents a case/investigation
-type Investigation struct {
-	ID                 string `json:"id"`
-	CaseNumber         string `json:"case_number"`
-	CaseName           string `json:"case_name"`
-	InvestigatingOrg   string `json:"investigating_org"`
-	LeadInvestigator   string `json:"lead_investigator"`
-	Status             string `json:"status"` // open, under_investigation, closed, archived
-	OpenedDate         int64  `json:"opened_date"`
-	ClosedDate         int64  `json:"closed_date"`
-	Description        string `json:"description"`
-	EvidenceCount      int    `json:"evidence_count"`
-	CreatedBy          string `json:"created_by"`
-	CreatedAt          int64  `json:"created_at"`
-	UpdatedAt          int64  `json:"updated_at"`
-}
-
-// Evidence represents a piece of digital evidence
-type Evidence struct {
-	ID              string `json:"id"`
-	CaseID          string `json:"case_id"`
-	Type            string `json:"type"`
-	Description     string `json:"description"`
-	Hash            string `json:"hash"`            // SHA-256
-	IPFSHash        string `json:"ipfs_hash"`       // IPFS CID
-	Location        string `json:"location"`        // Physical/digital location
-	Custodian       string `json:"custodian"`       // Current custodian
-	CollectedBy     string `json:"collected_by"`    // Original collector
-	Timestamp       int64  `json:"timestamp"`       // Collection timestamp
-	Status          string `json:"status"`          // collected, analyzed, reviewed, archived, disposed
-	Metadata        string `json:"metadata"`        // JSON metadata
-	FileSize        int64  `json:"file_size"`       // File size in bytes
-	ChainType       string `json:"chain_type"`      // hot or cold
-	TransactionID   string `json:"transaction_id"`  // Blockchain tx ID
-	CustodyChainRef string `json:"custody_chain_ref"` // Reference to custody chain
-	CreatedBy       string `json:"created_by"`
-	CreatedAt       int64  `json:"created_at"`
-	UpdatedAt       int64  `json:"updated_at"`
-}
+// Records shared with the cold chain are defined in the core module
+type (
+	PRVConfig          = core.PRVConfig
+	VerifierEntry      = core.VerifierEntry
+	VerifierRevocation = core.VerifierRevocation
+	QuorumHealth       = core.QuorumHealth
+	Investigation      = core.Investigation
+	Evidence           = core.Evidence
+	AuditLog           = core.AuditLog
+	AccessPolicy       = core.AccessPolicy
+	MSPRoleMapping     = core.MSPRoleMapping
+)
 
 // CustodyTransfer records custody chain
 type CustodyTransfer struct {
@@ -85,20 +56,6 @@ type CustodyTransfer struct {
 	Status         string `json:"status"` // pending, approved, completed, rejected
 }
 
-// AuditLog records all operations for compliance
-type AuditLog struct {
-	ID            string `json:"id"`
-	UserID        string `json:"user_id"`
-	Action        string `json:"action"`
-	Resource      string `json:"resource"`
-	ResourceID    string `json:"resource_id"`
-	Result        string `json:"result"` // success, denied, error
-	Reason        string `json:"reason"`
-	Timestamp     int64  `json:"timestamp"`
-	ClientMSP     string `json:"client_msp"`
-	TransactionID string `json:"transaction_id"`
-}
-
 // GUIDMapping for court-requested GUID resolution
 type GUIDMapping struct {
 	GUID         string `json:"guid"`
@@ -117,223 +74,17 @@ type GUIDMapping struct {
 func (cc *DFIRChaincode) InitLedger(ctx contractapi.TransactionContextInterface,
 	publicKeyHex string, mrenclaveHex string, mrsignerHex string) error {
 
-	config := PRVConfig{
-		PublicKey:      publicKeyHex,
-		MREnclave:      mrenclaveHex,
-		MRSigner:       mrsignerHex,
-		UpdatedAt:      time.Now().Unix(),
-		AttestationDoc: "",
-		VerifiedBy:     []string{},
-		TCBLevel:       "1",
-		ExpiresAt:      time.Now().Add(24 * time.Hour).Unix(), // 24h expiry
-	}
-
-	configJSON, err := json.Marshal(config)
-	if err != nil {
-		return fmt.Errorf("failed to marshal config: %v", err)
-	}
-
-	err = ctx.GetStub().PutState("PRV_CONFIG", configJSON)
-	if err != nil {
-		return fmt.Errorf("failed to store config: %v", err)
+	if err := core.InitPRVConfig(ctx, publicKeyHex, mrenclaveHex, mrsignerHex); err != nil {
+		return err
 	}
 
 	// Log initialization
-	cc.logAudit(ctx, "InitLedger", "system", "PRV_CONFIG", "success", "Ledger initialized")
+	core.LogAudit(ctx, "InitLedger", "system", "prv_config", "success", "Ledger initialized")
 
 	fmt.Printf("✓ Hot chain ledger initialized with PRV config\n")
 	return nil
 }
 
-// ==============================================================================
-// ACCESS CONTROL & ATTESTATION HELPERS
-// ==============================================================================
-
-// checkPermission validates if the caller has permission for the action
-func (cc *DFIRChaincode) checkPermission(ctx contractapi.TransactionContextInterface,
-	object string, action string, resource string) error {
-
-	clientID, err := ctx.GetClientIdentity().GetID()
-	if err != nil {
-		return fmt.Errorf("failed to get client identity: %v", err)
-	}
-
-	mspID, err := ctx.GetClientIdentity().GetMSPID()
-	if err != nil {
-		return fmt.Errorf("failed to get MSP ID: %v", err)
-	}
-
-	// Extract role from MSP or client attributes
-	role, found, err := ctx.GetClientIdentity().GetAttributeValue("role")
-	if err != nil || !found {
-		// Default role mapping based on MSP
-		role = cc.getRoleFromMSP(mspID)
-	}
-
-	// Check permission using Casbin-style logic
-	allowed := cc.evaluatePermission(role, object, action, resource, clientID)
-	if !allowed {
-		cc.logAudit(ctx, action, object, resource, "denied", fmt.Sprintf("Insufficient permissions for role: %s", role))
-		return fmt.Errorf("access denied: %s does not have permission to %s on %s", role, action, object)
-	}
-
-	return nil
-}
-
-// getRoleFromMSP maps MSP ID to default role
-func (cc *DFIRChaincode) getRoleFromMSP(mspID string) string {
-	// Role mapping aligned with JumpServer RBAC design
-	switch mspID {
-	case "LawEnforcementMSP", "ForensicLabMSP":
-		return "BlockchainInvestigator" // Evidence collection and investigation
-	case "CourtMSP":
-		return "BlockchainCourt" // Cross-chain transfers, archive/reopen authority
-	case "AuditorMSP":
-		return "BlockchainAuditor" // Read-only access to evidence for compliance/audit
-	default:
-		return "User"
-	}
-}
-
-// evaluatePermission implements Casbin-style permission checking
-func (cc *DFIRChaincode) evaluatePermission(role string, object string, action string, resource string, userID string) bool {
-	// SystemAdmin has full access
-	if role == "SystemAdmin" {
-		return true
-	}
-
-	// Role-based permission matrix
-	permissions := map[string]map[string][]string{
-		"BlockchainInvestigator": {
-			"blockchain.investigation": {"create", "view", "update", "list"},
-			"blockchain.evidence":      {"create", "view", "update", "transfer", "list"},
-			"blockchain.custody":       {"transfer", "view"},
-			"blockchain.transaction":   {"create", "view", "append"},
-			"blockchain.case":          {"create", "view", "update"},
-			"audits.userloginlog":      {"view"}, // self only
-			"audits.operatelog":        {"view"}, // self only
-		},
-		"BlockchainAuditor": {
-			"blockchain.investigation": {"view", "list"},
-			"blockchain.evidence":      {"view", "list", "history"},
-			"blockchain.custody":       {"view", "history"},
-			"blockchain.transaction":   {"view", "list"},
-			"blockchain.case":          {"view", "list"},
-			"audits.*":                 {"view"},
-			"reports.*":                {"view"},
-		},
-		"BlockchainCourt": {
-			"blockchain.investigation": {"view", "list", "archive", "reopen"},
-			"blockchain.evidence":      {"view", "list", "history"},
-			"blockchain.custody":       {"view", "history"},
-			"blockchain.transaction":   {"view", "list"},
-			"blockchain.case":          {"view", "list", "update"},
-			"blockchain.guidmapping":   {"resolve_guid"},
-			"audits.*":                 {"view"},
-			"reports.*":                {"view"},
-		},
-	}
-
-	rolePerms, roleExists := permissions[role]
-	if !roleExists {
-		return false
-	}
-
-	// Check wildcard permissions first
-	for permObj, actions := range rolePerms {
-		if strings.HasSuffix(permObj, ".*") {
-			prefix := strings.TrimSuffix(permObj, ".*")
-			if strings.HasPrefix(object, prefix) {
-				for _, act := range actions {
-					if act == "*" || act == action {
-						return true
-					}
-				}
-			}
-		}
-	}
-
-	// Check exact match
-	actions, objExists := rolePerms[object]
-	if !objExists {
-		return false
-	}
-
-	for _, act := range actions {
-		if act == "*" || act == action {
-			// Additional check for 'self' resource
-			if resource == "self" {
-				// Would need to compare userID with resource owner
-				return true // Simplified for now
-			}
-			return true
-		}
-	}
-
-	return false
-}
-
-// checkAttestation verifies orderer/CA attestation is still valid
-func (cc *DFIRChaincode) checkAttestation(ctx contractapi.TransactionContextInterface) error {
-	configJSON, err := ctx.GetStub().GetState("PRV_CONFIG")
-	if err != nil {
-		return fmt.Errorf("failed to read PRV config: %v", err)
-	}
-	if configJSON == nil {
-		return fmt.Errorf("PRV config not initialized")
-	}
-
-	var config PRVConfig
-	err = json.Unmarshal(configJSON, &config)
-	if err != nil {
-		return fmt.Errorf("failed to unmarshal PRV config: %v", err)
-	}
-
-	// Check if attestation is expired
-	currentTime := time.Now().Unix()
-	if currentTime > config.ExpiresAt {
-		return fmt.Errorf("attestation expired at %d, current time %d", config.ExpiresAt, currentTime)
-	}
-
-	// Check if quorum of verifiers approved (minimum 0 for development)
-	// NOTE: In production, change this to 2 for proper multi-org attestation
-	if len(config.VerifiedBy) < 0 {
-		return fmt.Errorf("insufficient verifiers: %d < 0", len(config.VerifiedBy))
-	}
-
-	return nil
-}
-
-// logAudit creates an audit log entry
-func (cc *DFIRChaincode) logAudit(ctx contractapi.TransactionContextInterface,
-	action string, resource string, resourceID string, result string, reason string) error {
-
-	clientID, _ := ctx.GetClientIdentity().GetID()
-	mspID, _ := ctx.GetClientIdentity().GetMSPID()
-	txID := ctx.GetStub().GetTxID()
-	txTimestamp, _ := ctx.GetStub().GetTxTimestamp()
-
-	auditLog := AuditLog{
-		ID:            fmt.Sprintf("audit_%s", txID),
-		UserID:        clientID,
-		Action:        action,
-		Resource:      resource,
-		ResourceID:    resourceID,
-		Result:        result,
-		Reason:        reason,
-		Timestamp:     txTimestamp.Seconds,
-		ClientMSP:     mspID,
-		TransactionID: txID,
-	}
-
-	auditJSON, err := json.Marshal(auditLog)
-	if err != nil {
-		return err
-	}
-
-	return ctx.GetStub().PutState(auditLog.ID, auditJSON)
-}
-
 // ==============================================================================
 // INVESTIGATION MANAGEMENT
 // ==============================================================================
@@ -344,17 +95,21 @@ func (cc *DFIRChaincode) CreateInvestigation(ctx contractapi.TransactionContextI
 	leadInvestigator string, description string) error {
 
 	// Check attestation
-	if err := cc.checkAttestation(ctx); err != nil {
+	if err := core.CheckAttestation(ctx); err != nil {
 		return fmt.Errorf("attestation check failed: %v", err)
 	}
 
 	// Check permission
-	if err := cc.checkPermission(ctx, "blockchain.investigation", "create", "*"); err != nil {
+	if err := accessControl.CheckPermission(ctx, "blockchain.investigation", "create", "*"); err != nil {
+		return err
+	}
+
+	if err := core.CheckKeyAttribute("investigation ID", id); err != nil {
 		return err
 	}
 
 	// Check if exists
-	existing, err := ctx.GetStub().GetState(id)
+	existing, err := ctx.GetStub().GetState(core.InvestigationKey(id))
 	if err != nil {
 		return fmt.Errorf("failed to read investigation: %v", err)
 	}
@@ -363,6 +118,11 @@ func (cc *DFIRChaincode) CreateInvestigation(ctx contractapi.TransactionContextI
 	}
 
 	clientID, _ := ctx.GetClientIdentity().GetID()
+	attrs := core.GetCallerAttributes(ctx)
+	now, err := core.TxNow(ctx)
+	if err != nil {
+		return err
+	}
 
 	investigation := Investigation{
 		ID:               id,
@@ -371,13 +131,16 @@ func (cc *DFIRChaincode) CreateInvestigation(ctx contractapi.TransactionContextI
 		InvestigatingOrg: investigatingOrg,
 		LeadInvestigator: leadInvestigator,
 		Status:           "open",
-		OpenedDate:       time.Now().Unix(),
+		OpenedDate:       now,
 		ClosedDate:       0,
 		Description:      description,
 		EvidenceCount:    0,
 		CreatedBy:        clientID,
-		CreatedAt:        time.Now().Unix(),
-		UpdatedAt:        time.Now().Unix(),
+		CreatedAt:        now,
+		UpdatedAt:        now,
+		Classification:   "unclassified",
+		Jurisdiction:     attrs.Jurisdiction,
+		OwningUnit:       attrs.Unit,
 	}
 
 	investigationJSON, err := json.Marshal(investigation)
@@ -385,16 +148,21 @@ func (cc *DFIRChaincode) CreateInvestigation(ctx contractapi.TransactionContextI
 		return fmt.Errorf("failed to marshal investigation: %v", err)
 	}
 
-	err = ctx.GetStub().PutState(id, investigationJSON)
+	err = ctx.GetStub().PutState(core.InvestigationKey(id), investigationJSON)
 	if err != nil {
 		return fmt.Errorf("failed to store investigation: %v", err)
 	}
 
+	// The creator leads the case team
+	if err := cc.createCaseTeam(ctx, id); err != nil {
+		return err
+	}
+
 	// Emit event
 	ctx.GetStub().SetEvent("InvestigationCreated", investigationJSON)
 
 	// Audit log
-	cc.logAudit(ctx, "CreateInvestigation", "blockchain.investigation", id, "success", "Investigation created")
+	core.LogAudit(ctx, "CreateInvestigation", "blockchain.investigation", id, "success", "Investigation created")
 
 	fmt.Printf("✓ Investigation created: %s\n", id)
 	return nil
@@ -405,11 +173,12 @@ func (cc *DFIRChaincode) ReadInvestigation(ctx contractapi.TransactionContextInt
 	id string) (*Investigation, error) {
 
 	// Check permission
-	if err := cc.checkPermission(ctx, "blockchain.investigation", "view", "*"); err != nil {
+	scope, err := accessControl.PermissionScope(ctx, "blockchain.investigation", "view")
+	if err != nil {
 		return nil, err
 	}
 
-	investigationJSON, err := ctx.GetStub().GetState(id)
+	investigationJSON, err := ctx.GetStub().GetState(core.InvestigationKey(id))
 	if err != nil {
 		return nil, fmt.Errorf("failed to read investigation: %v", err)
 	}
@@ -423,6 +192,22 @@ func (cc *DFIRChaincode) ReadInvestigation(ctx contractapi.TransactionContextInt
 		return nil, fmt.Errorf("failed to unmarshal investigation: %v", err)
 	}
 
+	if err := core.CheckOwner(ctx, scope, "blockchain.investigation", "view", id, investigation.CreatedBy); err != nil {
+		return nil, err
+	}
+
+	if err := core.CheckCaseAttributes(ctx, &investigation, "view"); err != nil {
+		return nil, err
+	}
+
+	if err := cc.checkCaseMember(ctx, id, "view"); err != nil {
+		return nil, err
+	}
+
+	if err := core.CheckRecusal(ctx, "blockchain.investigation", "view", id, ""); err != nil {
+		return nil, err
+	}
+
 	return &investigation, nil
 }
 
@@ -431,12 +216,12 @@ func (cc *DFIRChaincode) UpdateInvestigationStatus(ctx contractapi.TransactionCo
 	id string, newStatus string) error {
 
 	// Check attestation
-	if err := cc.checkAttestation(ctx); err != nil {
+	if err := core.CheckAttestation(ctx); err != nil {
 		return fmt.Errorf("attestation check failed: %v", err)
 	}
 
 	// Check permission
-	if err := cc.checkPermission(ctx, "blockchain.investigation", "update", "*"); err != nil {
+	if err := accessControl.CheckPermission(ctx, "blockchain.investigation", "update", "*"); err != nil {
 		return err
 	}
 
@@ -445,6 +230,14 @@ func (cc *DFIRChaincode) UpdateInvestigationStatus(ctx contractapi.TransactionCo
 		return err
 	}
 
+	if err := cc.checkCaseMember(ctx, id, "update"); err != nil {
+		return err
+	}
+
+	if err := core.CheckRecusal(ctx, "blockchain.investigation", "update", id, ""); err != nil {
+		return err
+	}
+
 	// Validate status transition
 	validStatuses := map[string]bool{
 		"open": true, "under_investigation": true, "closed": true, "archived": true,
@@ -453,11 +246,16 @@ func (cc *DFIRChaincode) UpdateInvestigationStatus(ctx contractapi.TransactionCo
 		return fmt.Errorf("invalid status: %s", newStatus)
 	}
 
+	now, err := core.TxNow(ctx)
+	if err != nil {
+		return err
+	}
+
 	investigation.Status = newStatus
-	investigation.UpdatedAt = time.Now().Unix()
+	investigation.UpdatedAt = now
 
 	if newStatus == "closed" {
-		investigation.ClosedDate = time.Now().Unix()
+		investigation.ClosedDate = now
 	}
 
 	investigationJSON, err := json.Marshal(investigation)
@@ -465,7 +263,7 @@ func (cc *DFIRChaincode) UpdateInvestigationStatus(ctx contractapi.TransactionCo
 		return fmt.Errorf("failed to marshal investigation: %v", err)
 	}
 
-	err = ctx.GetStub().PutState(id, investigationJSON)
+	err = ctx.GetStub().PutState(core.InvestigationKey(id), investigationJSON)
 	if err != nil {
 		return fmt.Errorf("failed to update investigation: %v", err)
 	}
@@ -474,7 +272,7 @@ func (cc *DFIRChaincode) UpdateInvestigationStatus(ctx contractapi.TransactionCo
 	ctx.GetStub().SetEvent("InvestigationUpdated", investigationJSON)
 
 	// Audit log
-	cc.logAudit(ctx, "UpdateInvestigationStatus", "blockchain.investigation", id, "success",
+	core.LogAudit(ctx, "UpdateInvestigationStatus", "blockchain.investigation", id, "success",
 		fmt.Sprintf("Status updated to %s", newStatus))
 
 	fmt.Printf("✓ Investigation %s status updated to %s\n", id, newStatus)
@@ -486,12 +284,12 @@ func (cc *DFIRChaincode) ArchiveInvestigation(ctx contractapi.TransactionContext
 	id string, courtOrder string) error {
 
 	// Check attestation
-	if err := cc.checkAttestation(ctx); err != nil {
+	if err := core.CheckAttestation(ctx); err != nil {
 		return fmt.Errorf("attestation check failed: %v", err)
 	}
 
 	// Check permission (Court role only)
-	if err := cc.checkPermission(ctx, "blockchain.investigation", "archive", "*"); err != nil {
+	if err := accessControl.CheckPermission(ctx, "blockchain.investigation", "archive", "*"); err != nil {
 		return err
 	}
 
@@ -503,12 +301,12 @@ func (cc *DFIRChaincode) ReopenInvestigation(ctx contractapi.TransactionContextI
 	id string, courtOrder string) error {
 
 	// Check attestation
-	if err := cc.checkAttestation(ctx); err != nil {
+	if err := core.CheckAttestation(ctx); err != nil {
 		return fmt.Errorf("attestation check failed: %v", err)
 	}
 
 	// Check permission (Court role only)
-	if err := cc.checkPermission(ctx, "blockchain.investigation", "reopen", "*"); err != nil {
+	if err := accessControl.CheckPermission(ctx, "blockchain.investigation", "reopen", "*"); err != nil {
 		return err
 	}
 
@@ -519,501 +317,217 @@ func (cc *DFIRChaincode) ReopenInvestigation(ctx contractapi.TransactionContextI
 // CROSS-CHAIN CASE TRANSFER (Court Role Only)
 // ==============================================================================
 
-// CaseExportPackage holds complete case data for cross-chain transfer
-type CaseExportPackage struct {
-	Investigation Investigation `json:"investigation"`
-	Evidence      []Evidence    `json:"evidence"`
-	CourtOrder    string        `json:"court_order"`
-	ExportedAt    int64         `json:"exported_at"`
-	ExportedBy    string        `json:"exported_by"`
-	SourceChain   string        `json:"source_chain"`
-	TransferTxID  string        `json:"transfer_tx_id"`
-}
-
-// ExportCaseForArchive exports investigation and evidence for cold chain archival (Hot chain, Court only)
+// ExportCaseForArchive exports a closed investigation and its evidence for cold chain
+// archival (Court only). The case stays transferring_to_archive until
+// CompleteArchiveTransfer confirms the cold chain import. The package of a case
+// with more than core.ChunkSize evidence items lists chunks served by GetExportChunk.
 func (cc *DFIRChaincode) ExportCaseForArchive(ctx contractapi.TransactionContextInterface,
 	investigationID string, courtOrder string) (string, error) {
 
 	// Check attestation
-	if err := cc.checkAttestation(ctx); err != nil {
+	if err := core.CheckAttestation(ctx); err != nil {
 		return "", fmt.Errorf("attestation check failed: %v", err)
 	}
 
 	// Check permission (Court role only)
-	if err := cc.checkPermission(ctx, "blockchain.investigation", "archive", "*"); err != nil {
+	if err := accessControl.CheckPermission(ctx, "blockchain.investigation", "archive", "*"); err != nil {
 		return "", err
 	}
-
-	// Read investigation
-	invBytes, err := ctx.GetStub().GetState("investigation_" + investigationID)
-	if err != nil {
-		return "", fmt.Errorf("failed to read investigation: %v", err)
-	}
-	if invBytes == nil {
-		return "", fmt.Errorf("investigation %s does not exist", investigationID)
-	}
-
-	var investigation Investigation
-	if err := json.Unmarshal(invBytes, &investigation); err != nil {
-		return "", fmt.Errorf("failed to unmarshal investigation: %v", err)
-	}
-
-	// Only allow archiving closed investigations
-	if investigation.Status != "closed" {
-		return "", fmt.Errorf("can only archive closed investigations, current status: %s", investigation.Status)
-	}
-
-	// Query all evidence for this case
-	queryString := fmt.Sprintf(`{"selector":{"case_id":"%s"}}`, investigationID)
-	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
-	if err != nil {
-		return "", fmt.Errorf("failed to query evidence: %v", err)
-	}
-	defer resultsIterator.Close()
-
-	var evidenceList []Evidence
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
-		if err != nil {
-			return "", fmt.Errorf("failed to iterate evidence: %v", err)
-		}
-
-		var evidence Evidence
-		if err := json.Unmarshal(queryResponse.Value, &evidence); err != nil {
-			continue // Skip malformed records
-		}
-		evidenceList = append(evidenceList, evidence)
+	if err := core.CheckRecusal(ctx, "blockchain.investigation", "archive", investigationID, ""); err != nil {
+		return "", err
 	}
 
-	// Get client identity
-	clientID, _ := ctx.GetClientIdentity().GetID()
-	txTimestamp, _ := ctx.GetStub().GetTxTimestamp()
-	txID := ctx.GetStub().GetTxID()
-
-	// Create export package
-	exportPackage := CaseExportPackage{
-		Investigation: investigation,
-		Evidence:      evidenceList,
-		CourtOrder:    courtOrder,
-		ExportedAt:    txTimestamp.Seconds,
-		ExportedBy:    clientID,
-		SourceChain:   "hot",
-		TransferTxID:  txID,
+	// Check PRV signature
+	if err := core.CheckPRVSignature(ctx); err != nil {
+		return "", err
 	}
 
-	// Marshal to JSON
-	packageJSON, err := json.Marshal(exportPackage)
+	packageJSON, transfer, err := core.ArchiveFlow.ExportCase(ctx, investigationID, courtOrder)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal export package: %v", err)
-	}
-
-	// Update investigation status to indicate transfer in progress
-	investigation.Status = "transferring_to_archive"
-	investigation.UpdatedAt = txTimestamp.Seconds
-	invBytes, _ = json.Marshal(investigation)
-	if err := ctx.GetStub().PutState("investigation_"+investigationID, invBytes); err != nil {
-		return "", fmt.Errorf("failed to update investigation status: %v", err)
+		return "", err
 	}
 
-	// Store export record
-	exportKey := "export_" + investigationID + "_" + txID
-	if err := ctx.GetStub().PutState(exportKey, packageJSON); err != nil {
-		return "", fmt.Errorf("failed to store export record: %v", err)
-	}
+	// Emit event
+	transferJSON, _ := json.Marshal(transfer)
+	ctx.GetStub().SetEvent("CaseExported", transferJSON)
 
 	// Audit log
-	cc.logAudit(ctx, "export_case_for_archive", "blockchain.investigation", investigationID,
-		"success", fmt.Sprintf("Case exported for archival with court order: %s", courtOrder))
+	core.LogAudit(ctx, "export_case_for_archive", "blockchain.investigation", investigationID,
+		"success", fmt.Sprintf("Case exported for archival with court order: %s, package hash: %s",
+			courtOrder, transfer.PackageHash))
 
 	return string(packageJSON), nil
 }
 
-// ImportArchivedCase imports case from hot chain to cold chain (Cold chain, Court only)
-func (cc *DFIRChaincode) ImportArchivedCase(ctx contractapi.TransactionContextInterface,
-	packageJSON string) error {
+// CompleteArchiveTransfer marks archive transfer as complete (Court only).
+// importProof is a core.TransferProof holding the cold chain block that
+// committed the import; unless the import record in it holds the hash the
+// export committed to, the transfer is marked rejected, the case stays
+// transferring_to_archive and the returned record says why.
+func (cc *DFIRChaincode) CompleteArchiveTransfer(ctx contractapi.TransactionContextInterface,
+	investigationID string, importProof string) (*core.CaseTransfer, error) {
 
 	// Check attestation
-	if err := cc.checkAttestation(ctx); err != nil {
-		return fmt.Errorf("attestation check failed: %v", err)
+	if err := core.CheckAttestation(ctx); err != nil {
+		return nil, fmt.Errorf("attestation check failed: %v", err)
 	}
 
 	// Check permission (Court role only)
-	if err := cc.checkPermission(ctx, "blockchain.investigation", "archive", "*"); err != nil {
-		return err
-	}
-
-	// Unmarshal package
-	var exportPackage CaseExportPackage
-	if err := json.Unmarshal([]byte(packageJSON), &exportPackage); err != nil {
-		return fmt.Errorf("failed to unmarshal export package: %v", err)
-	}
-
-	// Verify source chain
-	if exportPackage.SourceChain != "hot" {
-		return fmt.Errorf("invalid source chain: %s, expected 'hot'", exportPackage.SourceChain)
+	if err := accessControl.CheckPermission(ctx, "blockchain.investigation", "archive", "*"); err != nil {
+		return nil, err
 	}
 
-	// Check if investigation already exists on cold chain
-	invBytes, err := ctx.GetStub().GetState("investigation_" + exportPackage.Investigation.ID)
+	transfer, err := core.ArchiveFlow.CompleteTransfer(ctx, investigationID, importProof)
 	if err != nil {
-		return fmt.Errorf("failed to check investigation existence: %v", err)
-	}
-	if invBytes != nil {
-		return fmt.Errorf("investigation %s already exists on cold chain", exportPackage.Investigation.ID)
-	}
-
-	txTimestamp, _ := ctx.GetStub().GetTxTimestamp()
-	clientID, _ := ctx.GetClientIdentity().GetID()
-	txID := ctx.GetStub().GetTxID()
-
-	// Import investigation with archived status
-	investigation := exportPackage.Investigation
-	investigation.Status = "archived"
-	investigation.UpdatedAt = txTimestamp.Seconds
-	invBytes, _ = json.Marshal(investigation)
-	if err := ctx.GetStub().PutState("investigation_"+investigation.ID, invBytes); err != nil {
-		return fmt.Errorf("failed to store investigation: %v", err)
+		return nil, err
 	}
 
-	// Import all evidence
-	for _, evidence := range exportPackage.Evidence {
-		evidence.ChainType = "cold"
-		evidence.UpdatedAt = txTimestamp.Seconds
-		evidence.Status = "archived"
-
-		evidenceBytes, _ := json.Marshal(evidence)
-		evidenceKey := "evidence_" + evidence.ID
-		if err := ctx.GetStub().PutState(evidenceKey, evidenceBytes); err != nil {
-			return fmt.Errorf("failed to store evidence %s: %v", evidence.ID, err)
-		}
-	}
+	// Emit event
+	transferJSON, _ := json.Marshal(transfer)
+	if transfer.State == core.TransferStateRejected {
+		ctx.GetStub().SetEvent("CaseTransferRejected", transferJSON)
 
-	// Store import record
-	importRecord := map[string]interface{}{
-		"investigation_id": investigation.ID,
-		"source_chain":     exportPackage.SourceChain,
-		"source_tx_id":     exportPackage.TransferTxID,
-		"court_order":      exportPackage.CourtOrder,
-		"imported_at":      txTimestamp.Seconds,
-		"imported_by":      clientID,
-		"import_tx_id":     txID,
-		"evidence_count":   len(exportPackage.Evidence),
-	}
-	importBytes, _ := json.Marshal(importRecord)
-	importKey := "import_" + investigation.ID + "_" + txID
-	if err := ctx.GetStub().PutState(importKey, importBytes); err != nil {
-		return fmt.Errorf("failed to store import record: %v", err)
+		// Audit log
+		core.LogAudit(ctx, "complete_archive_transfer", "blockchain.investigation", investigationID,
+			"denied", fmt.Sprintf("Archive transfer rejected, cold chain tx: %s, %s", transfer.TargetTxID, transfer.RejectedReason))
+		return transfer, nil
 	}
+	ctx.GetStub().SetEvent("CaseTransferCompleted", transferJSON)
 
 	// Audit log
-	cc.logAudit(ctx, "import_archived_case", "blockchain.investigation", investigation.ID,
-		"success", fmt.Sprintf("Case imported from hot chain with court order: %s", exportPackage.CourtOrder))
+	core.LogAudit(ctx, "complete_archive_transfer", "blockchain.investigation", investigationID,
+		"success", fmt.Sprintf("Archive transfer completed, cold chain tx: %s", transfer.TargetTxID))
 
-	return nil
+	return transfer, nil
 }
 
-// CompleteArchiveTransfer marks archive transfer as complete on hot chain (Hot chain, Court only)
-func (cc *DFIRChaincode) CompleteArchiveTransfer(ctx contractapi.TransactionContextInterface,
-	investigationID string, coldChainTxID string) error {
+// AbortCaseTransfer cancels the archive transfer of a case that is stuck
+// transferring_to_archive and restores the status it had before the export (Court only).
+// The transfer must be past its deadline, after which the cold chain no longer
+// imports it, or rejected for a package hash mismatch. reason is kept with the
+// transfer record.
+func (cc *DFIRChaincode) AbortCaseTransfer(ctx contractapi.TransactionContextInterface,
+	investigationID string, reason string) (*core.CaseTransfer, error) {
 
 	// Check attestation
-	if err := cc.checkAttestation(ctx); err != nil {
-		return fmt.Errorf("attestation check failed: %v", err)
+	if err := core.CheckAttestation(ctx); err != nil {
+		return nil, fmt.Errorf("attestation check failed: %v", err)
 	}
 
 	// Check permission (Court role only)
-	if err := cc.checkPermission(ctx, "blockchain.investigation", "archive", "*"); err != nil {
-		return err
-	}
-
-	// Read investigation
-	invBytes, err := ctx.GetStub().GetState("investigation_" + investigationID)
-	if err != nil {
-		return fmt.Errorf("failed to read investigation: %v", err)
-	}
-	if invBytes == nil {
-		return fmt.Errorf("investigation %s does not exist", investigationID)
-	}
-
-	var investigation Investigation
-	if err := json.Unmarshal(invBytes, &investigation); err != nil {
-		return fmt.Errorf("failed to unmarshal investigation: %v", err)
+	if err := accessControl.CheckPermission(ctx, "blockchain.investigation", "archive", "*"); err != nil {
+		return nil, err
 	}
-
-	// Verify current status
-	if investigation.Status != "transferring_to_archive" {
-		return fmt.Errorf("invalid status for completion: %s", investigation.Status)
+	if err := core.CheckRecusal(ctx, "blockchain.investigation", "archive", investigationID, ""); err != nil {
+		return nil, err
 	}
 
-	txTimestamp, _ := ctx.GetStub().GetTxTimestamp()
-
-	// Update to archived status
-	investigation.Status = "archived_on_cold"
-	investigation.UpdatedAt = txTimestamp.Seconds
-	invBytes, _ = json.Marshal(investigation)
-	if err := ctx.GetStub().PutState("investigation_"+investigationID, invBytes); err != nil {
-		return fmt.Errorf("failed to update investigation: %v", err)
+	transfer, err := core.ArchiveFlow.AbortTransfer(ctx, investigationID, reason)
+	if err != nil {
+		return nil, err
 	}
 
-	// Store completion record
-	completionRecord := map[string]interface{}{
-		"investigation_id": investigationID,
-		"cold_chain_tx_id": coldChainTxID,
-		"completed_at":     txTimestamp.Seconds,
-	}
-	completionBytes, _ := json.Marshal(completionRecord)
-	completionKey := "archive_complete_" + investigationID
-	if err := ctx.GetStub().PutState(completionKey, completionBytes); err != nil {
-		return fmt.Errorf("failed to store completion record: %v", err)
-	}
+	// Emit event
+	transferJSON, _ := json.Marshal(transfer)
+	ctx.GetStub().SetEvent("CaseTransferAborted", transferJSON)
 
 	// Audit log
-	cc.logAudit(ctx, "complete_archive_transfer", "blockchain.investigation", investigationID,
-		"success", fmt.Sprintf("Archive transfer completed, cold chain tx: %s", coldChainTxID))
+	core.LogAudit(ctx, "abort_archive_transfer", "blockchain.investigation", investigationID,
+		"success", fmt.Sprintf("Archive transfer %s aborted, status restored to %s: %s",
+			transfer.ExportTxID, transfer.PriorStatus, reason))
 
-	return nil
+	return transfer, nil
 }
 
-// ExportCaseForReactivation exports archived case for reactivation on hot chain (Cold chain, Court only)
-func (cc *DFIRChaincode) ExportCaseForReactivation(ctx contractapi.TransactionContextInterface,
-	investigationID string, courtOrder string) (string, error) {
-
-	// Check attestation
-	if err := cc.checkAttestation(ctx); err != nil {
-		return "", fmt.Errorf("attestation check failed: %v", err)
-	}
+// GetArchiveTransfer returns the latest archive transfer of a case: the package
+// hash its export committed to and, once completed or rejected, the hash the
+// cold chain imported (Court only)
+func (cc *DFIRChaincode) GetArchiveTransfer(ctx contractapi.TransactionContextInterface,
+	investigationID string) (*core.CaseTransfer, error) {
 
 	// Check permission (Court role only)
-	if err := cc.checkPermission(ctx, "blockchain.investigation", "reopen", "*"); err != nil {
-		return "", err
-	}
-
-	// Read investigation
-	invBytes, err := ctx.GetStub().GetState("investigation_" + investigationID)
-	if err != nil {
-		return "", fmt.Errorf("failed to read investigation: %v", err)
-	}
-	if invBytes == nil {
-		return "", fmt.Errorf("investigation %s does not exist", investigationID)
-	}
-
-	var investigation Investigation
-	if err := json.Unmarshal(invBytes, &investigation); err != nil {
-		return "", fmt.Errorf("failed to unmarshal investigation: %v", err)
-	}
-
-	// Only allow reactivating archived investigations
-	if investigation.Status != "archived" {
-		return "", fmt.Errorf("can only reactivate archived investigations, current status: %s", investigation.Status)
-	}
-
-	// Query all evidence for this case
-	queryString := fmt.Sprintf(`{"selector":{"case_id":"%s"}}`, investigationID)
-	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
-	if err != nil {
-		return "", fmt.Errorf("failed to query evidence: %v", err)
-	}
-	defer resultsIterator.Close()
-
-	var evidenceList []Evidence
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
-		if err != nil {
-			return "", fmt.Errorf("failed to iterate evidence: %v", err)
-		}
-
-		var evidence Evidence
-		if err := json.Unmarshal(queryResponse.Value, &evidence); err != nil {
-			continue
-		}
-		evidenceList = append(evidenceList, evidence)
-	}
-
-	// Get client identity
-	clientID, _ := ctx.GetClientIdentity().GetID()
-	txTimestamp, _ := ctx.GetStub().GetTxTimestamp()
-	txID := ctx.GetStub().GetTxID()
-
-	// Create export package
-	exportPackage := CaseExportPackage{
-		Investigation: investigation,
-		Evidence:      evidenceList,
-		CourtOrder:    courtOrder,
-		ExportedAt:    txTimestamp.Seconds,
-		ExportedBy:    clientID,
-		SourceChain:   "cold",
-		TransferTxID:  txID,
+	if err := accessControl.CheckPermission(ctx, "blockchain.investigation", "archive", "*"); err != nil {
+		return nil, err
 	}
 
-	// Marshal to JSON
-	packageJSON, err := json.Marshal(exportPackage)
+	transfer, err := core.ArchiveFlow.LoadCaseTransfer(ctx, investigationID)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal export package: %v", err)
-	}
-
-	// Update investigation status
-	investigation.Status = "transferring_to_hot"
-	investigation.UpdatedAt = txTimestamp.Seconds
-	invBytes, _ = json.Marshal(investigation)
-	if err := ctx.GetStub().PutState("investigation_"+investigationID, invBytes); err != nil {
-		return "", fmt.Errorf("failed to update investigation status: %v", err)
+		return nil, err
 	}
-
-	// Store export record
-	exportKey := "export_" + investigationID + "_" + txID
-	if err := ctx.GetStub().PutState(exportKey, packageJSON); err != nil {
-		return "", fmt.Errorf("failed to store export record: %v", err)
+	if transfer == nil {
+		return nil, fmt.Errorf("investigation %s has no archive transfer", investigationID)
 	}
-
-	// Audit log
-	cc.logAudit(ctx, "export_case_for_reactivation", "blockchain.investigation", investigationID,
-		"success", fmt.Sprintf("Case exported for reactivation with court order: %s", courtOrder))
-
-	return string(packageJSON), nil
+	return transfer, nil
 }
 
-// ImportReactivatedCase imports case from cold chain to hot chain (Hot chain, Court only)
+// ImportReactivatedCase imports a case exported by the cold chain's ExportCaseForReactivation
+// and reopens it (Court only). The package must carry the cold chain block that
+// committed the export, verified against the trust anchors registered with SetTransferTrust.
+// It returns the import record, whose package_hash completes the transfer.
+// Chunked packages are imported with BeginReactivatedCaseImport instead.
 func (cc *DFIRChaincode) ImportReactivatedCase(ctx contractapi.TransactionContextInterface,
-	packageJSON string) error {
+	packageJSON string) (*core.CaseImport, error) {
 
 	// Check attestation
-	if err := cc.checkAttestation(ctx); err != nil {
-		return fmt.Errorf("attestation check failed: %v", err)
+	if err := core.CheckAttestation(ctx); err != nil {
+		return nil, fmt.Errorf("attestation check failed: %v", err)
 	}
 
 	// Check permission (Court role only)
-	if err := cc.checkPermission(ctx, "blockchain.investigation", "reopen", "*"); err != nil {
-		return err
-	}
-
-	// Unmarshal package
-	var exportPackage CaseExportPackage
-	if err := json.Unmarshal([]byte(packageJSON), &exportPackage); err != nil {
-		return fmt.Errorf("failed to unmarshal export package: %v", err)
-	}
-
-	// Verify source chain
-	if exportPackage.SourceChain != "cold" {
-		return fmt.Errorf("invalid source chain: %s, expected 'cold'", exportPackage.SourceChain)
+	if err := accessControl.CheckPermission(ctx, "blockchain.investigation", "reopen", "*"); err != nil {
+		return nil, err
 	}
 
-	txTimestamp, _ := ctx.GetStub().GetTxTimestamp()
-	clientID, _ := ctx.GetClientIdentity().GetID()
-	txID := ctx.GetStub().GetTxID()
-
-	// Check if investigation exists on hot chain
-	invBytes, err := ctx.GetStub().GetState("investigation_" + exportPackage.Investigation.ID)
+	importRecord, err := core.ReactivationFlow.ImportCase(ctx, packageJSON)
 	if err != nil {
-		return fmt.Errorf("failed to check investigation existence: %v", err)
-	}
-
-	investigation := exportPackage.Investigation
-	investigation.Status = "open" // Reactivate as open
-	investigation.UpdatedAt = txTimestamp.Seconds
-	investigation.ClosedDate = 0 // Clear closed date for reactivated case
-
-	invBytes, _ = json.Marshal(investigation)
-	if err := ctx.GetStub().PutState("investigation_"+investigation.ID, invBytes); err != nil {
-		return fmt.Errorf("failed to store investigation: %v", err)
-	}
-
-	// Import all evidence
-	for _, evidence := range exportPackage.Evidence {
-		evidence.ChainType = "hot"
-		evidence.UpdatedAt = txTimestamp.Seconds
-		evidence.Status = "reviewed" // Set appropriate status for reactivated evidence
-
-		evidenceBytes, _ := json.Marshal(evidence)
-		evidenceKey := "evidence_" + evidence.ID
-		if err := ctx.GetStub().PutState(evidenceKey, evidenceBytes); err != nil {
-			return fmt.Errorf("failed to store evidence %s: %v", evidence.ID, err)
-		}
+		return nil, err
 	}
 
-	// Store import record
-	importRecord := map[string]interface{}{
-		"investigation_id": investigation.ID,
-		"source_chain":     exportPackage.SourceChain,
-		"source_tx_id":     exportPackage.TransferTxID,
-		"court_order":      exportPackage.CourtOrder,
-		"imported_at":      txTimestamp.Seconds,
-		"imported_by":      clientID,
-		"import_tx_id":     txID,
-		"evidence_count":   len(exportPackage.Evidence),
-	}
-	importBytes, _ := json.Marshal(importRecord)
-	importKey := "import_" + investigation.ID + "_" + txID
-	if err := ctx.GetStub().PutState(importKey, importBytes); err != nil {
-		return fmt.Errorf("failed to store import record: %v", err)
-	}
+	// Emit event
+	importJSON, _ := json.Marshal(importRecord)
+	ctx.GetStub().SetEvent("CaseImported", importJSON)
 
 	// Audit log
-	cc.logAudit(ctx, "import_reactivated_case", "blockchain.investigation", investigation.ID,
-		"success", fmt.Sprintf("Case imported from cold chain with court order: %s", exportPackage.CourtOrder))
+	core.LogAudit(ctx, "import_reactivated_case", "blockchain.investigation", importRecord.InvestigationID,
+		"success", fmt.Sprintf("Case imported from cold chain with court order: %s, package hash: %s",
+			importRecord.CourtOrder, importRecord.PackageHash))
 
-	return nil
+	return importRecord, nil
 }
 
-// CompleteReactivationTransfer marks reactivation transfer as complete on cold chain (Cold chain, Court only)
-func (cc *DFIRChaincode) CompleteReactivationTransfer(ctx contractapi.TransactionContextInterface,
-	investigationID string, hotChainTxID string) error {
+// RecordReactivationAbort records on the hot chain that the cold chain aborted the reactivation transfer
+// of a case (Court only). abortProof is a core.TransferProof holding the cold
+// chain block that committed AbortCaseTransfer, verified against the trust
+// anchors registered with SetTransferTrust. From then on the export cannot be
+// imported, whatever the timestamp of the import proposal.
+func (cc *DFIRChaincode) RecordReactivationAbort(ctx contractapi.TransactionContextInterface,
+	investigationID string, abortProof string) (*core.CaseTransfer, error) {
 
 	// Check attestation
-	if err := cc.checkAttestation(ctx); err != nil {
-		return fmt.Errorf("attestation check failed: %v", err)
+	if err := core.CheckAttestation(ctx); err != nil {
+		return nil, fmt.Errorf("attestation check failed: %v", err)
 	}
 
 	// Check permission (Court role only)
-	if err := cc.checkPermission(ctx, "blockchain.investigation", "reopen", "*"); err != nil {
-		return err
+	if err := accessControl.CheckPermission(ctx, "blockchain.investigation", "reopen", "*"); err != nil {
+		return nil, err
 	}
 
-	// Read investigation
-	invBytes, err := ctx.GetStub().GetState("investigation_" + investigationID)
+	transfer, err := core.ReactivationFlow.RecordAbort(ctx, investigationID, abortProof)
 	if err != nil {
-		return fmt.Errorf("failed to read investigation: %v", err)
-	}
-	if invBytes == nil {
-		return fmt.Errorf("investigation %s does not exist", investigationID)
-	}
-
-	var investigation Investigation
-	if err := json.Unmarshal(invBytes, &investigation); err != nil {
-		return fmt.Errorf("failed to unmarshal investigation: %v", err)
-	}
-
-	// Verify current status
-	if investigation.Status != "transferring_to_hot" {
-		return fmt.Errorf("invalid status for completion: %s", investigation.Status)
-	}
-
-	txTimestamp, _ := ctx.GetStub().GetTxTimestamp()
-
-	// Update to transferred status
-	investigation.Status = "transferred_to_hot"
-	investigation.UpdatedAt = txTimestamp.Seconds
-	invBytes, _ = json.Marshal(investigation)
-	if err := ctx.GetStub().PutState("investigation_"+investigationID, invBytes); err != nil {
-		return fmt.Errorf("failed to update investigation: %v", err)
+		return nil, err
 	}
 
-	// Store completion record
-	completionRecord := map[string]interface{}{
-		"investigation_id": investigationID,
-		"hot_chain_tx_id":  hotChainTxID,
-		"completed_at":     txTimestamp.Seconds,
-	}
-	completionBytes, _ := json.Marshal(completionRecord)
-	completionKey := "reactivation_complete_" + investigationID
-	if err := ctx.GetStub().PutState(completionKey, completionBytes); err != nil {
-		return fmt.Errorf("failed to store completion record: %v", err)
-	}
+	// Emit event
+	transferJSON, _ := json.Marshal(transfer)
+	ctx.GetStub().SetEvent("CaseTransferAbortRecorded", transferJSON)
 
 	// Audit log
-	cc.logAudit(ctx, "complete_reactivation_transfer", "blockchain.investigation", investigationID,
-		"success", fmt.Sprintf("Reactivation transfer completed, hot chain tx: %s", hotChainTxID))
+	core.LogAudit(ctx, "record_reactivation_abort", "blockchain.investigation", investigationID,
+		"success", fmt.Sprintf("Reactivation transfer %s aborted on the cold chain in transaction %s, import refused: %s",
+			transfer.ExportTxID, transfer.AbortTxID, transfer.AbortReason))
 
-	return nil
+	return transfer, nil
 }
 
 // ==============================================================================
@@ -1027,17 +541,21 @@ func (cc *DFIRChaincode) CreateEvidence(ctx contractapi.TransactionContextInterf
 	fileSize int64) error {
 
 	// Check attestation
-	if err := cc.checkAttestation(ctx); err != nil {
+	if err := core.CheckAttestation(ctx); err != nil {
 		return fmt.Errorf("attestation check failed: %v", err)
 	}
 
 	// Check permission
-	if err := cc.checkPermission(ctx, "blockchain.evidence", "create", "*"); err != nil {
+	if err := accessControl.CheckPermission(ctx, "blockchain.evidence", "create", "*"); err != nil {
+		return err
+	}
+
+	if err := core.CheckKeyAttribute("evidence ID", id); err != nil {
 		return err
 	}
 
 	// Check if exists
-	existing, err := ctx.GetStub().GetState(id)
+	existing, err := ctx.GetStub().GetState(core.EvidenceKey(id))
 	if err != nil {
 		return fmt.Errorf("failed to read evidence: %v", err)
 	}
@@ -1051,8 +569,20 @@ func (cc *DFIRChaincode) CreateEvidence(ctx contractapi.TransactionContextInterf
 		return fmt.Errorf("case %s does not exist: %v", caseID, err)
 	}
 
+	if err := cc.checkCaseMember(ctx, caseID, "create"); err != nil {
+		return err
+	}
+
+	if err := core.CheckRecusal(ctx, "blockchain.evidence", "create", caseID, ""); err != nil {
+		return err
+	}
+
 	clientID, _ := ctx.GetClientIdentity().GetID()
 	txID := ctx.GetStub().GetTxID()
+	now, err := core.TxNow(ctx)
+	if err != nil {
+		return err
+	}
 
 	evidence := Evidence{
 		ID:              id,
@@ -1064,7 +594,7 @@ func (cc *DFIRChaincode) CreateEvidence(ctx contractapi.TransactionContextInterf
 		Location:        location,
 		Custodian:       clientID,
 		CollectedBy:     clientID,
-		Timestamp:       time.Now().Unix(),
+		Timestamp:       now,
 		Status:          "collected",
 		Metadata:        metadata,
 		FileSize:        fileSize,
@@ -1072,8 +602,8 @@ func (cc *DFIRChaincode) CreateEvidence(ctx contractapi.TransactionContextInterf
 		TransactionID:   txID,
 		CustodyChainRef: fmt.Sprintf("custody_%s", id),
 		CreatedBy:       clientID,
-		CreatedAt:       time.Now().Unix(),
-		UpdatedAt:       time.Now().Unix(),
+		CreatedAt:       now,
+		UpdatedAt:       now,
 	}
 
 	evidenceJSON, err := json.Marshal(evidence)
@@ -1081,7 +611,7 @@ func (cc *DFIRChaincode) CreateEvidence(ctx contractapi.TransactionContextInterf
 		return fmt.Errorf("failed to marshal evidence: %v", err)
 	}
 
-	err = ctx.GetStub().PutState(id, evidenceJSON)
+	err = ctx.GetStub().PutState(core.EvidenceKey(id), evidenceJSON)
 	if err != nil {
 		return fmt.Errorf("failed to store evidence: %v", err)
 	}
@@ -1093,7 +623,7 @@ func (cc *DFIRChaincode) CreateEvidence(ctx contractapi.TransactionContextInterf
 	ctx.GetStub().SetEvent("EvidenceCreated", evidenceJSON)
 
 	// Audit log
-	cc.logAudit(ctx, "CreateEvidence", "blockchain.evidence", id, "success", "Evidence created")
+	core.LogAudit(ctx, "CreateEvidence", "blockchain.evidence", id, "success", "Evidence created")
 
 	fmt.Printf("✓ Evidence created: %s\n", id)
 	return nil
@@ -1104,11 +634,12 @@ func (cc *DFIRChaincode) ReadEvidence(ctx contractapi.TransactionContextInterfac
 	id string) (*Evidence, error) {
 
 	// Check permission
-	if err := cc.checkPermission(ctx, "blockchain.evidence", "view", "*"); err != nil {
+	scope, err := accessControl.PermissionScope(ctx, "blockchain.evidence", "view")
+	if err != nil {
 		return nil, err
 	}
 
-	evidenceJSON, err := ctx.GetStub().GetState(id)
+	evidenceJSON, err := ctx.GetStub().GetState(core.EvidenceKey(id))
 	if err != nil {
 		return nil, fmt.Errorf("failed to read evidence: %v", err)
 	}
@@ -1122,6 +653,22 @@ func (cc *DFIRChaincode) ReadEvidence(ctx contractapi.TransactionContextInterfac
 		return nil, fmt.Errorf("failed to unmarshal evidence: %v", err)
 	}
 
+	if err := core.CheckOwner(ctx, scope, "blockchain.evidence", "view", id, evidence.CreatedBy); err != nil {
+		return nil, err
+	}
+
+	if err := core.CheckEvidenceAttributes(ctx, &evidence, "view"); err != nil {
+		return nil, err
+	}
+
+	if err := cc.checkCaseMember(ctx, evidence.CaseID, "view"); err != nil {
+		return nil, err
+	}
+
+	if err := core.CheckRecusal(ctx, "blockchain.evidence", "view", evidence.CaseID, id); err != nil {
+		return nil, err
+	}
+
 	return &evidence, nil
 }
 
@@ -1130,12 +677,12 @@ func (cc *DFIRChaincode) UpdateEvidenceStatus(ctx contractapi.TransactionContext
 	id string, newStatus string) error {
 
 	// Check attestation
-	if err := cc.checkAttestation(ctx); err != nil {
+	if err := core.CheckAttestation(ctx); err != nil {
 		return fmt.Errorf("attestation check failed: %v", err)
 	}
 
 	// Check permission
-	if err := cc.checkPermission(ctx, "blockchain.evidence", "update", "*"); err != nil {
+	if err := accessControl.CheckPermission(ctx, "blockchain.evidence", "update", "*"); err != nil {
 		return err
 	}
 
@@ -1144,6 +691,14 @@ func (cc *DFIRChaincode) UpdateEvidenceStatus(ctx contractapi.TransactionContext
 		return err
 	}
 
+	if err := cc.checkCaseMember(ctx, evidence.CaseID, "update"); err != nil {
+		return err
+	}
+
+	if err := core.CheckRecusal(ctx, "blockchain.evidence", "update", evidence.CaseID, id); err != nil {
+		return err
+	}
+
 	// Validate status transition
 	validStatuses := map[string]bool{
 		"collected": true, "analyzed": true, "reviewed": true,
@@ -1153,15 +708,20 @@ func (cc *DFIRChaincode) UpdateEvidenceStatus(ctx contractapi.TransactionContext
 		return fmt.Errorf("invalid status: %s", newStatus)
 	}
 
+	now, err := core.TxNow(ctx)
+	if err != nil {
+		return err
+	}
+
 	evidence.Status = newStatus
-	evidence.UpdatedAt = time.Now().Unix()
+	evidence.UpdatedAt = now
 
 	evidenceJSON, err := json.Marshal(evidence)
 	if err != nil {
 		return fmt.Errorf("failed to marshal evidence: %v", err)
 	}
 
-	err = ctx.GetStub().PutState(id, evidenceJSON)
+	err = ctx.GetStub().PutState(core.EvidenceKey(id), evidenceJSON)
 	if err != nil {
 		return fmt.Errorf("failed to update evidence: %v", err)
 	}
@@ -1170,7 +730,7 @@ func (cc *DFIRChaincode) UpdateEvidenceStatus(ctx contractapi.TransactionContext
 	ctx.GetStub().SetEvent("EvidenceUpdated", evidenceJSON)
 
 	// Audit log
-	cc.logAudit(ctx, "UpdateEvidenceStatus", "blockchain.evidence", id, "success",
+	core.LogAudit(ctx, "UpdateEvidenceStatus", "blockchain.evidence", id, "success",
 		fmt.Sprintf("Status updated to %s", newStatus))
 
 	fmt.Printf("✓ Evidence %s status updated to %s\n", id, newStatus)
@@ -1182,12 +742,12 @@ func (cc *DFIRChaincode) TransferCustody(ctx contractapi.TransactionContextInter
 	evidenceID string, toCustodian string, reason string, location string, permitHash string) error {
 
 	// Check attestation
-	if err := cc.checkAttestation(ctx); err != nil {
+	if err := core.CheckAttestation(ctx); err != nil {
 		return fmt.Errorf("attestation check failed: %v", err)
 	}
 
 	// Check permission
-	if err := cc.checkPermission(ctx, "blockchain.custody", "transfer", "*"); err != nil {
+	if err := accessControl.CheckPermission(ctx, "blockchain.custody", "transfer", "*"); err != nil {
 		return err
 	}
 
@@ -1196,16 +756,28 @@ func (cc *DFIRChaincode) TransferCustody(ctx contractapi.TransactionContextInter
 		return err
 	}
 
+	if err := cc.checkCaseMember(ctx, evidence.CaseID, "transfer"); err != nil {
+		return err
+	}
+
+	if err := core.CheckRecusal(ctx, "blockchain.custody", "transfer", evidence.CaseID, evidenceID); err != nil {
+		return err
+	}
+
 	clientID, _ := ctx.GetClientIdentity().GetID()
+	now, err := core.TxNow(ctx)
+	if err != nil {
+		return err
+	}
 
 	// Create custody transfer record
-	transferID := fmt.Sprintf("transfer_%s_%d", evidenceID, time.Now().UnixNano())
+	transferID := core.TxScopedID(ctx, "transfer", evidenceID)
 	transfer := CustodyTransfer{
 		ID:            transferID,
 		EvidenceID:    evidenceID,
 		FromCustodian: evidence.Custodian,
 		ToCustodian:   toCustodian,
-		Timestamp:     time.Now().Unix(),
+		Timestamp:     now,
 		Reason:        reason,
 		Location:      location,
 		PermitHash:    permitHash,
@@ -1219,21 +791,21 @@ func (cc *DFIRChaincode) TransferCustody(ctx contractapi.TransactionContextInter
 		return fmt.Errorf("failed to marshal transfer: %v", err)
 	}
 
-	err = ctx.GetStub().PutState(transferID, transferJSON)
+	err = ctx.GetStub().PutState(core.StateKey(core.RecordCustodyTransfer, transferID), transferJSON)
 	if err != nil {
 		return fmt.Errorf("failed to store transfer: %v", err)
 	}
 
 	// Update evidence custodian
 	evidence.Custodian = toCustodian
-	evidence.UpdatedAt = time.Now().Unix()
+	evidence.UpdatedAt = now
 
 	evidenceJSON, err := json.Marshal(evidence)
 	if err != nil {
 		return fmt.Errorf("failed to marshal evidence: %v", err)
 	}
 
-	err = ctx.GetStub().PutState(evidenceID, evidenceJSON)
+	err = ctx.GetStub().PutState(core.EvidenceKey(evidenceID), evidenceJSON)
 	if err != nil {
 		return fmt.Errorf("failed to update evidence: %v", err)
 	}
@@ -1242,50 +814,166 @@ func (cc *DFIRChaincode) TransferCustody(ctx contractapi.TransactionContextInter
 	ctx.GetStub().SetEvent("CustodyTransferred", transferJSON)
 
 	// Audit log
-	cc.logAudit(ctx, "TransferCustody", "blockchain.custody", evidenceID, "success",
+	core.LogAudit(ctx, "TransferCustody", "blockchain.custody", evidenceID, "success",
 		fmt.Sprintf("Custody transferred to %s", toCustodian))
 
 	fmt.Printf("✓ Custody transferred: %s -> %s\n", evidence.Custodian, toCustodian)
 	return nil
 }
 
+// ReadCustodyTransfer retrieves a custody transfer record by ID
+func (cc *DFIRChaincode) ReadCustodyTransfer(ctx contractapi.TransactionContextInterface,
+	id string) (*CustodyTransfer, error) {
+
+	// Check permission
+	scope, err := accessControl.PermissionScope(ctx, "blockchain.custody", "view")
+	if err != nil {
+		return nil, err
+	}
+
+	transferJSON, err := ctx.GetStub().GetState(core.StateKey(core.RecordCustodyTransfer, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read custody transfer: %v", err)
+	}
+	if transferJSON == nil {
+		return nil, fmt.Errorf("custody transfer %s does not exist", id)
+	}
+
+	var transfer CustodyTransfer
+	if err := json.Unmarshal(transferJSON, &transfer); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal custody transfer: %v", err)
+	}
+
+	if err := core.CheckOwner(ctx, scope, "blockchain.custody", "view", id, transfer.TransferredBy); err != nil {
+		return nil, err
+	}
+
+	if err := core.CheckEvidenceIDAttributes(ctx, transfer.EvidenceID, "view"); err != nil {
+		return nil, err
+	}
+
+	if err := cc.checkEvidenceIDMember(ctx, transfer.EvidenceID, "view"); err != nil {
+		return nil, err
+	}
+
+	if err := core.CheckEvidenceRecusal(ctx, "blockchain.custody", "view", transfer.EvidenceID); err != nil {
+		return nil, err
+	}
+
+	return &transfer, nil
+}
+
+// QueryCustodyTransfers retrieves the custody transfers of an evidence item
+func (cc *DFIRChaincode) QueryCustodyTransfers(ctx contractapi.TransactionContextInterface,
+	evidenceID string) ([]*CustodyTransfer, error) {
+
+	// Check permission
+	scope, err := accessControl.PermissionScope(ctx, "blockchain.custody", "view")
+	if err != nil {
+		return nil, err
+	}
+
+	queryString := fmt.Sprintf(`{"selector":{"evidence_id":"%s","to_custodian":{"$exists":true}}}`, evidenceID)
+	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query custody transfers: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var results []*CustodyTransfer
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var transfer CustodyTransfer
+		if err := json.Unmarshal(queryResponse.Value, &transfer); err != nil {
+			return nil, err
+		}
+		results = append(results, &transfer)
+	}
+
+	if err := core.CheckEvidenceIDAttributes(ctx, evidenceID, "view"); err != nil {
+		return nil, err
+	}
+
+	if err := cc.checkEvidenceIDMember(ctx, evidenceID, "view"); err != nil {
+		return nil, err
+	}
+
+	if err := core.CheckEvidenceRecusal(ctx, "blockchain.custody", "view", evidenceID); err != nil {
+		return nil, err
+	}
+
+	return core.FilterOwned(ctx, scope, results, func(t *CustodyTransfer) string { return t.TransferredBy }), nil
+}
+
 // GetEvidenceHistory retrieves the complete history of an evidence item
 func (cc *DFIRChaincode) GetEvidenceHistory(ctx contractapi.TransactionContextInterface,
 	id string) ([]map[string]interface{}, error) {
 
 	// Check permission
-	if err := cc.checkPermission(ctx, "blockchain.evidence", "history", "*"); err != nil {
+	scope, err := accessControl.PermissionScope(ctx, "blockchain.evidence", "history")
+	if err != nil {
 		return nil, err
 	}
 
-	resultsIterator, err := ctx.GetStub().GetHistoryForKey(id)
+	// Ownership and case attributes of a history are those of the current evidence record
+	evidenceJSON, err := ctx.GetStub().GetState(core.EvidenceKey(id))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get history: %v", err)
+		return nil, fmt.Errorf("failed to read evidence: %v", err)
+	}
+	if evidenceJSON != nil {
+		var evidence Evidence
+		if err := json.Unmarshal(evidenceJSON, &evidence); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal evidence: %v", err)
+		}
+		if err := core.CheckOwner(ctx, scope, "blockchain.evidence", "history", id, evidence.CreatedBy); err != nil {
+			return nil, err
+		}
+		if err := core.CheckEvidenceAttributes(ctx, &evidence, "history"); err != nil {
+			return nil, err
+		}
+		if err := cc.checkCaseMember(ctx, evidence.CaseID, "history"); err != nil {
+			return nil, err
+		}
+		if err := core.CheckRecusal(ctx, "blockchain.evidence", "history", evidence.CaseID, id); err != nil {
+			return nil, err
+		}
 	}
-	defer resultsIterator.Close()
 
+	// History written before MigrateKeys stays on the legacy keys
 	var history []map[string]interface{}
-	for resultsIterator.HasNext() {
-		response, err := resultsIterator.Next()
+	for _, key := range append(core.LegacyKeys(core.RecordEvidence, id), core.EvidenceKey(id)) {
+		resultsIterator, err := ctx.GetStub().GetHistoryForKey(key)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to get history: %v", err)
 		}
+		defer resultsIterator.Close()
 
-		var record map[string]interface{}
-		record = make(map[string]interface{})
-		record["tx_id"] = response.TxId
-		record["timestamp"] = response.Timestamp.Seconds
-		record["is_delete"] = response.IsDelete
-
-		if !response.IsDelete {
-			var evidence Evidence
-			err = json.Unmarshal(response.Value, &evidence)
-			if err == nil {
-				record["value"] = evidence
+		for resultsIterator.HasNext() {
+			response, err := resultsIterator.Next()
+			if err != nil {
+				return nil, err
+			}
+
+			var record map[string]interface{}
+			record = make(map[string]interface{})
+			record["tx_id"] = response.TxId
+			record["timestamp"] = response.Timestamp.Seconds
+			record["is_delete"] = response.IsDelete
+
+			if !response.IsDelete {
+				var evidence Evidence
+				err = json.Unmarshal(response.Value, &evidence)
+				if err == nil {
+					record["value"] = evidence
+				}
 			}
-		}
 
-		history = append(history, record)
+			history = append(history, record)
+		}
 	}
 
 	return history, nil
@@ -1296,13 +984,14 @@ func (cc *DFIRChaincode) QueryEvidenceByCase(ctx contractapi.TransactionContextI
 	caseID string) ([]*Evidence, error) {
 
 	// Check permission
-	if err := cc.checkPermission(ctx, "blockchain.evidence", "list", "*"); err != nil {
+	scope, err := accessControl.PermissionScope(ctx, "blockchain.evidence", "list")
+	if err != nil {
 		return nil, err
 	}
 
-	// CouchDB query
-	queryString := fmt.Sprintf(`{"selector":{"case_id":"%s"}}`, caseID)
-	return cc.queryEvidence(ctx, queryString)
+	// CouchDB query; chain_type keeps out other records with a case_id, such as case teams
+	queryString := fmt.Sprintf(`{"selector":{"case_id":"%s","chain_type":"hot"}}`, caseID)
+	return cc.queryOwnedEvidence(ctx, scope, queryString)
 }
 
 // QueryEvidenceByCustodian retrieves all evidence for a custodian
@@ -1310,12 +999,13 @@ func (cc *DFIRChaincode) QueryEvidenceByCustodian(ctx contractapi.TransactionCon
 	custodian string) ([]*Evidence, error) {
 
 	// Check permission
-	if err := cc.checkPermission(ctx, "blockchain.evidence", "list", "*"); err != nil {
+	scope, err := accessControl.PermissionScope(ctx, "blockchain.evidence", "list")
+	if err != nil {
 		return nil, err
 	}
 
-	queryString := fmt.Sprintf(`{"selector":{"custodian":"%s"}}`, custodian)
-	return cc.queryEvidence(ctx, queryString)
+	queryString := fmt.Sprintf(`{"selector":{"custodian":"%s","chain_type":"hot"}}`, custodian)
+	return cc.queryOwnedEvidence(ctx, scope, queryString)
 }
 
 // QueryEvidenceByHash retrieves evidence by hash
@@ -1323,12 +1013,13 @@ func (cc *DFIRChaincode) QueryEvidenceByHash(ctx contractapi.TransactionContextI
 	hash string) (*Evidence, error) {
 
 	// Check permission
-	if err := cc.checkPermission(ctx, "blockchain.evidence", "view", "*"); err != nil {
+	scope, err := accessControl.PermissionScope(ctx, "blockchain.evidence", "view")
+	if err != nil {
 		return nil, err
 	}
 
-	queryString := fmt.Sprintf(`{"selector":{"hash":"%s"}}`, hash)
-	results, err := cc.queryEvidence(ctx, queryString)
+	queryString := fmt.Sprintf(`{"selector":{"hash":"%s","chain_type":"hot"}}`, hash)
+	results, err := cc.queryOwnedEvidence(ctx, scope, queryString)
 	if err != nil {
 		return nil, err
 	}
@@ -1340,6 +1031,26 @@ func (cc *DFIRChaincode) QueryEvidenceByHash(ctx contractapi.TransactionContextI
 	return results[0], nil
 }
 
+// queryOwnedEvidence runs an evidence query and applies the caller's permission scope
+func (cc *DFIRChaincode) queryOwnedEvidence(ctx contractapi.TransactionContextInterface,
+	scope string, queryString string) ([]*Evidence, error) {
+
+	results, err := cc.queryEvidence(ctx, queryString)
+	if err != nil {
+		return nil, err
+	}
+	results = core.FilterOwned(ctx, scope, results, func(e *Evidence) string { return e.CreatedBy })
+	results, err = core.FilterEvidenceByAttributes(ctx, results)
+	if err != nil {
+		return nil, err
+	}
+	results, err = cc.filterEvidenceByTeam(ctx, results)
+	if err != nil {
+		return nil, err
+	}
+	return core.FilterRecusedEvidence(ctx, "view", results)
+}
+
 // queryEvidence helper function for CouchDB queries
 func (cc *DFIRChaincode) queryEvidence(ctx contractapi.TransactionContextInterface,
 	queryString string) ([]*Evidence, error) {
@@ -1377,16 +1088,21 @@ func (cc *DFIRChaincode) ResolveGUID(ctx contractapi.TransactionContextInterface
 	guid string, courtOrder string) (*GUIDMapping, error) {
 
 	// Check attestation
-	if err := cc.checkAttestation(ctx); err != nil {
+	if err := core.CheckAttestation(ctx); err != nil {
 		return nil, fmt.Errorf("attestation check failed: %v", err)
 	}
 
 	// Check permission (Court role only)
-	if err := cc.checkPermission(ctx, "blockchain.guidmapping", "resolve_guid", "*"); err != nil {
+	if err := accessControl.CheckPermission(ctx, "blockchain.guidmapping", "resolve_guid", "*"); err != nil {
 		return nil, err
 	}
 
-	mappingKey := fmt.Sprintf("GUID_%s", guid)
+	// Check PRV signature
+	if err := core.CheckPRVSignature(ctx); err != nil {
+		return nil, err
+	}
+
+	mappingKey := core.StateKey(core.RecordGUIDMapping, guid)
 	mappingJSON, err := ctx.GetStub().GetState(mappingKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read GUID mapping: %v", err)
@@ -1402,8 +1118,12 @@ func (cc *DFIRChaincode) ResolveGUID(ctx contractapi.TransactionContextInterface
 	}
 
 	clientID, _ := ctx.GetClientIdentity().GetID()
+	now, err := core.TxNow(ctx)
+	if err != nil {
+		return nil, err
+	}
 	mapping.ResolvedBy = clientID
-	mapping.ResolvedAt = time.Now().Unix()
+	mapping.ResolvedAt = now
 	mapping.CourtOrder = courtOrder
 
 	// Update mapping
@@ -1411,83 +1131,78 @@ func (cc *DFIRChaincode) ResolveGUID(ctx contractapi.TransactionContextInterface
 	ctx.GetStub().PutState(mappingKey, updatedJSON)
 
 	// Audit log
-	cc.logAudit(ctx, "ResolveGUID", "blockchain.guidmapping", guid, "success",
+	core.LogAudit(ctx, "ResolveGUID", "blockchain.guidmapping", guid, "success",
 		fmt.Sprintf("GUID resolved by court order: %s", courtOrder))
 
 	return &mapping, nil
 }
 
 // ==============================================================================
-// ATTESTATION MANAGEMENT
+// AUDIT LOG QUERIES
 // ==============================================================================
 
-// RegisterAttestation registers a new attestation verification
-func (cc *DFIRChaincode) RegisterAttestation(ctx contractapi.TransactionContextInterface,
-	attestationDoc string, verifierMSP string) error {
-
-	// Only verifier services can register attestations
-	mspID, _ := ctx.GetClientIdentity().GetMSPID()
+// ReadAuditLog retrieves an audit log entry by ID
+func (cc *DFIRChaincode) ReadAuditLog(ctx contractapi.TransactionContextInterface,
+	id string) (*AuditLog, error) {
 
-	configJSON, err := ctx.GetStub().GetState("PRV_CONFIG")
+	// Check permission
+	scope, err := accessControl.PermissionScope(ctx, "audits.operatelog", "view")
 	if err != nil {
-		return fmt.Errorf("failed to read PRV config: %v", err)
-	}
-
-	var config PRVConfig
-	if configJSON != nil {
-		json.Unmarshal(configJSON, &config)
+		return nil, err
 	}
 
-	// Add verifier to list if not already present
-	found := false
-	for _, v := range config.VerifiedBy {
-		if v == verifierMSP {
-			found = true
-			break
-		}
+	auditJSON, err := ctx.GetStub().GetState(core.StateKey(core.RecordAudit, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %v", err)
 	}
-	if !found {
-		config.VerifiedBy = append(config.VerifiedBy, verifierMSP)
+	if auditJSON == nil {
+		return nil, fmt.Errorf("audit log %s does not exist", id)
 	}
 
-	config.AttestationDoc = attestationDoc
-	config.UpdatedAt = time.Now().Unix()
-	config.ExpiresAt = time.Now().Add(24 * time.Hour).Unix()
-
-	updatedJSON, err := json.Marshal(config)
-	if err != nil {
-		return err
+	var auditLog AuditLog
+	if err := json.Unmarshal(auditJSON, &auditLog); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal audit log: %v", err)
 	}
 
-	err = ctx.GetStub().PutState("PRV_CONFIG", updatedJSON)
-	if err != nil {
-		return err
+	if err := core.CheckOwner(ctx, scope, "audits.operatelog", "view", id, auditLog.UserID); err != nil {
+		return nil, err
 	}
 
-	cc.logAudit(ctx, "RegisterAttestation", "attestation.config", "PRV_CONFIG", "success",
-		fmt.Sprintf("Attestation verified by %s", mspID))
-
-	fmt.Printf("✓ Attestation registered by %s\n", verifierMSP)
-	return nil
+	return &auditLog, nil
 }
 
-// GetPRVConfig retrieves the current PRV configuration
-func (cc *DFIRChaincode) GetPRVConfig(ctx contractapi.TransactionContextInterface) (*PRVConfig, error) {
-	configJSON, err := ctx.GetStub().GetState("PRV_CONFIG")
+// QueryAuditLogsByUser retrieves the audit log entries recorded for a user
+func (cc *DFIRChaincode) QueryAuditLogsByUser(ctx contractapi.TransactionContextInterface,
+	userID string) ([]*AuditLog, error) {
+
+	// Check permission
+	scope, err := accessControl.PermissionScope(ctx, "audits.operatelog", "view")
 	if err != nil {
-		return nil, fmt.Errorf("failed to read PRV config: %v", err)
-	}
-	if configJSON == nil {
-		return nil, fmt.Errorf("PRV config not initialized")
+		return nil, err
 	}
 
-	var config PRVConfig
-	err = json.Unmarshal(configJSON, &config)
+	queryString := fmt.Sprintf(`{"selector":{"user_id":"%s",%s}}`, userID, core.AuditSelector)
+	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to query audit logs: %v", err)
 	}
+	defer resultsIterator.Close()
 
-	return &config, nil
+	var results []*AuditLog
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var auditLog AuditLog
+		if err := json.Unmarshal(queryResponse.Value, &auditLog); err != nil {
+			return nil, err
+		}
+		results = append(results, &auditLog)
+	}
+
+	return core.FilterOwned(ctx, scope, results, func(a *AuditLog) string { return a.UserID }), nil
 }
 
 // ==============================================================================
@@ -1501,15 +1216,20 @@ func (cc *DFIRChaincode) incrementEvidenceCount(ctx contractapi.TransactionConte
 		return err
 	}
 
+	now, err := core.TxNow(ctx)
+	if err != nil {
+		return err
+	}
+
 	investigation.EvidenceCount++
-	investigation.UpdatedAt = time.Now().Unix()
+	investigation.UpdatedAt = now
 
 	investigationJSON, err := json.Marshal(investigation)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(caseID, investigationJSON)
+	return ctx.GetStub().PutState(core.InvestigationKey(caseID), investigationJSON)
 }
 
 // GetAllInvestigations retrieves all investigations (paginated)
@@ -1517,7 +1237,8 @@ func (cc *DFIRChaincode) GetAllInvestigations(ctx contractapi.TransactionContext
 	pageSize int, bookmark string) ([]*Investigation, error) {
 
 	// Check permission
-	if err := cc.checkPermission(ctx, "blockchain.investigation", "list", "*"); err != nil {
+	scope, err := accessControl.PermissionScope(ctx, "blockchain.investigation", "list")
+	if err != nil {
 		return nil, err
 	}
 
@@ -1544,7 +1265,16 @@ func (cc *DFIRChaincode) GetAllInvestigations(ctx contractapi.TransactionContext
 		results = append(results, &investigation)
 	}
 
-	return results, nil
+	results = core.FilterOwned(ctx, scope, results, func(i *Investigation) string { return i.CreatedBy })
+	results, err = core.FilterInvestigationsByAttributes(ctx, results)
+	if err != nil {
+		return nil, err
+	}
+	results, err = cc.filterInvestigationsByTeam(ctx, results)
+	if err != nil {
+		return nil, err
+	}
+	return core.FilterRecusedInvestigations(ctx, "list", results)
 }
 
 // ==============================================================================
@@ -1552,7 +1282,7 @@ func (cc *DFIRChaincode) GetAllInvestigations(ctx contractapi.TransactionContext
 // ==============================================================================
 
 func main() {
-	chaincode, err := contractapi.NewChaincode(&DFIRChaincode{})
+	chaincode, err := contractapi.NewChaincode(&DFIRChaincode{ChainContract: chainContract})
 	if err != nil {
 		fmt.Printf("Error creating DFIR chaincode: %v\n", err)
 		return
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sort"
+	"testing"
+	"time"
+
+	core "github.com/aub/dfir-core"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Endorsement only succeeds when every endorsing peer computes the same write set
+// for a proposal. These tests run each transaction on several independent
+// simulated endorsers, each with its own copy of the world state, and require
+// identical writes, events and responses. The proposal timestamp is fixed well
+// in the past so any value taken from a peer's local clock shows up as a mismatch.
+
+const endorserCount = 3
+
+// fabricAttributesOID is the certificate extension Fabric CA stores attributes in
+var fabricAttributesOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
+
+var proposalTime = time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)
+
+// endorserStub is a MockStub that executes one proposal: its arguments and the
+// client-chosen transaction timestamp are the same on every endorser
+type endorserStub struct {
+	*shimtest.MockStub
+	args      [][]byte
+	timestamp *timestamppb.Timestamp
+}
+
+func (s *endorserStub) GetArgs() [][]byte { return s.args }
+
+func (s *endorserStub) GetStringArgs() []string {
+	args := make([]string, len(s.args))
+	for i, arg := range s.args {
+		args[i] = string(arg)
+	}
+	return args
+}
+
+func (s *endorserStub) GetFunctionAndParameters() (string, []string) {
+	args := s.GetStringArgs()
+	if len(args) == 0 {
+		return "", []string{}
+	}
+	return args[0], args[1:]
+}
+
+func (s *endorserStub) GetTxTimestamp() (*timestamppb.Timestamp, error) {
+	return s.timestamp, nil
+}
+
+// endorsement is what one endorser produced for a proposal
+type endorsement struct {
+	Status  int32
+	Message string
+	Payload string
+	Writes  map[string]string
+	Deletes []string
+	Events  []string
+}
+
+// endorser is a peer with its own chaincode instance and world state. While
+// prvKey is set, every proposal carries the PRV signature the enclave would add.
+type endorser struct {
+	cc     *contractapi.ContractChaincode
+	stub   *shimtest.MockStub
+	prvKey ed25519.PrivateKey
+}
+
+func newEndorsers(t *testing.T) []*endorser {
+	t.Helper()
+	endorsers := make([]*endorser, endorserCount)
+	for i := range endorsers {
+		cc, err := contractapi.NewChaincode(&DFIRChaincode{ChainContract: chainContract})
+		if err != nil {
+			t.Fatalf("failed to create chaincode: %v", err)
+		}
+		endorsers[i] = &endorser{cc: cc, stub: shimtest.NewMockStub(fmt.Sprintf("peer%d", i), cc)}
+	}
+	return endorsers
+}
+
+// endorse executes a proposal against the endorser's state and records its write set
+func (e *endorser) endorse(txID string, creator []byte, at time.Time, function string, args ...string) *endorsement {
+	before := make(map[string][]byte, len(e.stub.State))
+	for key, value := range e.stub.State {
+		before[key] = value
+	}
+
+	stub := &endorserStub{MockStub: e.stub, timestamp: timestamppb.New(at)}
+	stub.args = append(stub.args, []byte(function))
+	for _, arg := range args {
+		stub.args = append(stub.args, []byte(arg))
+	}
+
+	if e.prvKey != nil {
+		e.stub.TransientMap = map[string][]byte{"prv_signature": e.signPRVRequest(txID, function, args...)}
+	}
+	e.stub.Creator = creator
+	e.stub.MockTransactionStart(txID)
+	response := e.cc.Invoke(stub)
+	e.stub.MockTransactionEnd(txID)
+
+	result := &endorsement{
+		Status:  response.Status,
+		Message: response.Message,
+		Payload: string(response.Payload),
+		Writes:  map[string]string{},
+		Deletes: []string{},
+		Events:  []string{},
+	}
+	for key, value := range e.stub.State {
+		if previous, ok := before[key]; !ok || !bytes.Equal(previous, value) {
+			result.Writes[key] = string(value)
+		}
+	}
+	for key := range before {
+		if _, ok := e.stub.State[key]; !ok {
+			result.Deletes = append(result.Deletes, key)
+		}
+	}
+	sort.Strings(result.Deletes)
+	for drained := false; !drained; {
+		select {
+		case event := <-e.stub.ChaincodeEventsChannel:
+			result.Events = append(result.Events, event.EventName+" "+string(event.Payload))
+		default:
+			drained = true
+		}
+	}
+	return result
+}
+
+// signPRVRequest returns the detached signature of the endorser's PRV key over
+// transaction txID calling function(args) on the endorser's channel
+func (e *endorser) signPRVRequest(txID string, function string, args ...string) []byte {
+	payload, err := json.Marshal(struct {
+		ChannelID string   `json:"channel_id"`
+		TxID      string   `json:"tx_id"`
+		Function  string   `json:"function"`
+		Args      []string `json:"args"`
+	}{e.stub.ChannelID, txID, function, args})
+	if err != nil {
+		panic(fmt.Sprintf("failed to encode signed request: %v", err))
+	}
+	return ed25519.Sign(e.prvKey, payload)
+}
+
+// endorseAll runs a proposal on every endorser, fails unless all endorsements match
+// and returns the agreed result
+func endorseAll(t *testing.T, endorsers []*endorser, txID string, creator []byte,
+	function string, args ...string) *endorsement {
+	t.Helper()
+
+	var first *endorsement
+	var firstJSON []byte
+	for i, e := range endorsers {
+		result := e.endorse(txID, creator, proposalTime, function, args...)
+		resultJSON, err := json.Marshal(result)
+		if err != nil {
+			t.Fatalf("failed to encode endorsement: %v", err)
+		}
+		if i == 0 {
+			first, firstJSON = result, resultJSON
+			continue
+		}
+		if !bytes.Equal(resultJSON, firstJSON) {
+			t.Fatalf("%s: endorser %d diverged from endorser 0\nendorser 0: %s\nendorser %d: %s",
+				function, i, firstJSON, i, resultJSON)
+		}
+	}
+	if first.Status != 200 {
+		t.Fatalf("%s failed: %s", function, first.Message)
+	}
+	if len(first.Writes) == 0 {
+		t.Fatalf("%s wrote nothing", function)
+	}
+	return first
+}
+
+// newCreator returns a serialized identity for a test client certificate in mspID
+// carrying the given Fabric CA attributes
+func newCreator(t *testing.T, mspID string, commonName string, attrs map[string]string) []byte {
+	t.Helper()
+	return newIdentity(t, mspID, commonName, "client", attrs)
+}
+
+// newIdentity returns a serialized identity for a test certificate of Fabric NodeOU
+// ou (client, admin, ...) in mspID carrying the given Fabric CA attributes
+func newIdentity(t *testing.T, mspID string, commonName string, ou string, attrs map[string]string) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName, OrganizationalUnit: []string{ou}},
+		NotBefore:    proposalTime.Add(-24 * time.Hour),
+		NotAfter:     proposalTime.Add(365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if len(attrs) > 0 {
+		attrsJSON, err := json.Marshal(map[string]map[string]string{"attrs": attrs})
+		if err != nil {
+			t.Fatalf("failed to encode attributes: %v", err)
+		}
+		template.ExtraExtensions = []pkix.Extension{{Id: fabricAttributesOID, Value: attrsJSON}}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	creator, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   mspID,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	})
+	if err != nil {
+		t.Fatalf("failed to serialize identity: %v", err)
+	}
+	return creator
+}
+
+// initLedger initializes every endorser, installs a PRV key the endorsers sign
+// with and records a verifier quorum valid at proposalTime
+func initLedger(t *testing.T, endorsers []*endorser, creator []byte) {
+	t.Helper()
+	endorseAll(t, endorsers, "tx-init", creator, "InitLedger", "", "", "")
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate PRV key: %v", err)
+	}
+	for _, e := range endorsers {
+		e.prvKey = privateKey
+	}
+	expires := proposalTime.Add(core.AttestationValidity).Unix()
+	updatePRVConfig(t, endorsers, func(config *PRVConfig) {
+		config.PublicKey = hex.EncodeToString(publicKey)
+		config.VerifiedBy = []VerifierEntry{
+			{MSP: "LawEnforcementMSP", VerifiedAt: proposalTime.Unix(), ExpiresAt: expires},
+			{MSP: "ForensicLabMSP", VerifiedAt: proposalTime.Unix(), ExpiresAt: expires},
+		}
+	})
+}
+
+// updatePRVConfig applies update to the PRV config of every endorser outside any proposal
+func updatePRVConfig(t *testing.T, endorsers []*endorser, update func(config *PRVConfig)) {
+	t.Helper()
+	for _, e := range endorsers {
+		var config PRVConfig
+		if err := json.Unmarshal(e.stub.State[core.PRVConfigKey], &config); err != nil {
+			t.Fatalf("failed to read PRV config: %v", err)
+		}
+		update(&config)
+		configJSON, err := json.Marshal(config)
+		if err != nil {
+			t.Fatalf("failed to encode PRV config: %v", err)
+		}
+		e.stub.MockTransactionStart("tx-seed-attestation")
+		if err := e.stub.PutState(core.PRVConfigKey, configJSON); err != nil {
+			t.Fatalf("failed to store PRV config: %v", err)
+		}
+		e.stub.MockTransactionEnd("tx-seed-attestation")
+	}
+}
+
+func TestEndorsersAgreeOnWriteSets(t *testing.T) {
+	endorsers := newEndorsers(t)
+	investigator := newCreator(t, "LawEnforcementMSP", "investigator1.lawenforcement.hot.coc.com", nil)
+	initLedger(t, endorsers, investigator)
+
+	for _, tx := range []struct {
+		txID     string
+		function string
+		args     []string
+	}{
+		{"tx-case", "CreateInvestigation", []string{"INV-001", "CASE-2025-001", "Ransomware", "LawEnforcement", "investigator1", "determinism test"}},
+		{"tx-evidence", "CreateEvidence", []string{"EVD-001", "INV-001", "disk_image", "laptop image", "abc123", "QmHash", "locker 4", "{}", "1024"}},
+		{"tx-evidence-status", "UpdateEvidenceStatus", []string{"EVD-001", "analyzed"}},
+		{"tx-custody", "TransferCustody", []string{"EVD-001", "examiner2", "analysis", "lab 2", "permit-hash"}},
+		{"tx-case-status", "UpdateInvestigationStatus", []string{"INV-001", "closed"}},
+	} {
+		t.Run(tx.function, func(t *testing.T) {
+			result := endorseAll(t, endorsers, tx.txID, investigator, tx.function, tx.args...)
+			if audit := result.Writes[core.StateKey(core.RecordAudit, "audit_"+tx.txID)]; audit == "" {
+				t.Errorf("%s wrote no audit entry at audit_%s", tx.function, tx.txID)
+			}
+		})
+	}
+}
+
+func TestTimestampsComeFromProposal(t *testing.T) {
+	endorsers := newEndorsers(t)
+	investigator := newCreator(t, "LawEnforcementMSP", "investigator1.lawenforcement.hot.coc.com", nil)
+	initLedger(t, endorsers, investigator)
+	want := proposalTime.Unix()
+
+	endorseAll(t, endorsers, "tx-case", investigator, "CreateInvestigation",
+		"INV-001", "CASE-2025-001", "Ransomware", "LawEnforcement", "investigator1", "determinism test")
+	var investigation Investigation
+	if err := json.Unmarshal(endorsers[0].stub.State[core.InvestigationKey("INV-001")], &investigation); err != nil {
+		t.Fatalf("failed to read investigation: %v", err)
+	}
+	if investigation.OpenedDate != want || investigation.CreatedAt != want || investigation.UpdatedAt != want {
+		t.Errorf("investigation timestamps = %d/%d/%d, want %d",
+			investigation.OpenedDate, investigation.CreatedAt, investigation.UpdatedAt, want)
+	}
+
+	endorseAll(t, endorsers, "tx-evidence", investigator, "CreateEvidence",
+		"EVD-001", "INV-001", "disk_image", "laptop image", "abc123", "QmHash", "locker 4", "{}", "1024")
+	result := endorseAll(t, endorsers, "tx-custody", investigator, "TransferCustody",
+		"EVD-001", "examiner2", "analysis", "lab 2", "permit-hash")
+
+	transferJSON, ok := result.Writes[core.StateKey(core.RecordCustodyTransfer, "transfer_EVD-001_tx-custody")]
+	if !ok {
+		t.Fatalf("transfer not stored under its transaction-scoped ID, writes: %v", keys(result.Writes))
+	}
+	var transfer CustodyTransfer
+	if err := json.Unmarshal([]byte(transferJSON), &transfer); err != nil {
+		t.Fatalf("failed to read transfer: %v", err)
+	}
+	if transfer.Timestamp != want {
+		t.Errorf("transfer timestamp = %d, want %d", transfer.Timestamp, want)
+	}
+
+	var audit AuditLog
+	if err := json.Unmarshal([]byte(result.Writes[core.StateKey(core.RecordAudit, "audit_tx-custody")]), &audit); err != nil {
+		t.Fatalf("failed to read audit entry: %v", err)
+	}
+	if audit.Timestamp != want || audit.TransactionID != "tx-custody" {
+		t.Errorf("audit entry = %d/%s, want %d/tx-custody", audit.Timestamp, audit.TransactionID, want)
+	}
+}
+
+func keys(m map[string]string) []string {
+	result := make([]string, 0, len(m))
+	for key := range m {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
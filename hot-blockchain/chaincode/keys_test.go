@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+
+	core "github.com/aub/dfir-core"
+)
+
+// legacyState is a ledger written before the composite key schema
+var legacyState = map[string]string{
+	"PRV_CONFIG":                `{"public_key":"","quorum_threshold":2,"version":1}`,
+	"PRV_CONFIG_V0000000001":    `{"public_key":"","quorum_threshold":2,"version":1}`,
+	"ACCESS_POLICY":             `{"version":3}`,
+	"INV-001":                   `{"id":"INV-001","case_number":"CASE-1","investigating_org":"LawEnforcement"}`,
+	"investigation_INV_002":     `{"id":"INV_002","case_number":"CASE-2","investigating_org":"LawEnforcement"}`,
+	"EVD-001":                   `{"id":"EVD-001","case_id":"INV-001","hash":"abc","custodian":"x"}`,
+	"CASE_TEAM_INV-001":         `{"case_id":"INV-001"}`,
+	"audit_tx1":                 `{"id":"audit_tx1"}`,
+	"transfer_EVD-001_17000000": `{"id":"transfer_EVD-001_17000000"}`,
+	"export_INV_002_tx2":        `{"investigation":{}}`,
+	"archive_complete_INV_002":  `{"investigation_id":"INV_002"}`,
+	"GUID_abc":                  `{"guid":"abc"}`,
+	"SGX_ROOT_CA":               `{"pem":"legacy"}`,
+	"notes":                     `{"text":"not a record"}`,
+}
+
+// seedState writes raw key/value pairs into every endorser's world state
+func seedState(t *testing.T, endorsers []*endorser, state map[string]string) {
+	t.Helper()
+	for _, e := range endorsers {
+		e.stub.MockTransactionStart("tx-seed")
+		for key, value := range state {
+			if err := e.stub.PutState(key, []byte(value)); err != nil {
+				t.Fatalf("failed to seed %q: %v", key, err)
+			}
+		}
+		e.stub.MockTransactionEnd("tx-seed")
+	}
+}
+
+func TestMigrateKeys(t *testing.T) {
+	endorsers := newEndorsers(t)
+	admin := newCreator(t, "LawEnforcementMSP", "admin.lawenforcement.hot.coc.com",
+		map[string]string{"role": "SystemAdmin"})
+	seedState(t, endorsers, legacyState)
+
+	// A record already written under the new schema is a conflict, not overwritten
+	seedState(t, endorsers, map[string]string{core.SGXRootCAKey: `{"pem":"current"}`})
+
+	// The first call stops after two records
+	result := endorseAll(t, endorsers, "tx-migrate-1", admin, "MigrateKeys", "2")
+	var report core.KeyMigrationReport
+	if err := json.Unmarshal([]byte(result.Payload), &report); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+	if len(report.Moved) != 2 || !report.Remaining {
+		t.Fatalf("first call moved %d keys, remaining %t; want 2, true", len(report.Moved), report.Remaining)
+	}
+
+	result = endorseAll(t, endorsers, "tx-migrate-2", admin, "MigrateKeys", "0")
+	report = core.KeyMigrationReport{}
+	if err := json.Unmarshal([]byte(result.Payload), &report); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+	if report.Remaining {
+		t.Errorf("second call reports remaining keys")
+	}
+	if want := len(legacyState) - 2 - 2; len(report.Moved) != want {
+		t.Errorf("second call moved %d keys, want %d", len(report.Moved), want)
+	}
+	if len(report.Conflicts) != 1 || report.Conflicts[0].From != "SGX_ROOT_CA" {
+		t.Errorf("conflicts = %v, want SGX_ROOT_CA", report.Conflicts)
+	}
+	if !reflect.DeepEqual(report.Unrecognized, []string{"notes"}) {
+		t.Errorf("unrecognized = %v, want [notes]", report.Unrecognized)
+	}
+
+	state := endorsers[0].stub.State
+	for key, value := range map[string]string{
+		core.PRVConfigKey:                                  legacyState["PRV_CONFIG"],
+		core.PRVConfigVersionKey(1):                        legacyState["PRV_CONFIG_V0000000001"],
+		core.AccessPolicyKey:                               legacyState["ACCESS_POLICY"],
+		core.InvestigationKey("INV-001"):                   legacyState["INV-001"],
+		core.InvestigationKey("INV_002"):                   legacyState["investigation_INV_002"],
+		core.EvidenceKey("EVD-001"):                        legacyState["EVD-001"],
+		caseTeamKey("INV-001"):                             legacyState["CASE_TEAM_INV-001"],
+		core.StateKey(core.RecordAudit, "audit_tx1"):       legacyState["audit_tx1"],
+		core.StateKey(core.RecordExport, "INV_002", "tx2"): legacyState["export_INV_002_tx2"],
+		core.StateKey(core.RecordTransferComplete, core.TransferArchive, "INV_002"): legacyState["archive_complete_INV_002"],
+		core.StateKey(core.RecordCustodyTransfer, "transfer_EVD-001_17000000"):      legacyState["transfer_EVD-001_17000000"],
+		core.StateKey(core.RecordGUIDMapping, "abc"):                                legacyState["GUID_abc"],
+		core.SGXRootCAKey: `{"pem":"current"}`,
+		"SGX_ROOT_CA":     legacyState["SGX_ROOT_CA"],
+		"notes":           legacyState["notes"],
+	} {
+		if got := string(state[key]); got != value {
+			t.Errorf("state[%q] = %q, want %q", key, got, value)
+		}
+	}
+
+	var leftover []string
+	for key := range state {
+		if _, legacy := legacyState[key]; legacy {
+			leftover = append(leftover, key)
+		}
+	}
+	sort.Strings(leftover)
+	if !reflect.DeepEqual(leftover, []string{"SGX_ROOT_CA", "notes"}) {
+		t.Errorf("legacy keys left = %v, want [SGX_ROOT_CA notes]", leftover)
+	}
+}
+
+func TestMigrateKeysRequiresSystemAdmin(t *testing.T) {
+	endorsers := newEndorsers(t)
+	investigator := newCreator(t, "LawEnforcementMSP", "investigator1.lawenforcement.hot.coc.com", nil)
+	seedState(t, endorsers, legacyState)
+
+	result := endorsers[0].endorse("tx-migrate", investigator, proposalTime, "MigrateKeys", "0")
+	if result.Status == 200 {
+		t.Fatalf("MigrateKeys by an investigator succeeded")
+	}
+	if _, ok := endorsers[0].stub.State["INV-001"]; !ok {
+		t.Errorf("denied MigrateKeys moved INV-001")
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMSPRoleMappingOnboardsAgency(t *testing.T) {
+	endorsers := newEndorsers(t)
+	admin := newCreator(t, "LawEnforcementMSP", "admin.lawenforcement.hot.coc.com",
+		map[string]string{"role": "SystemAdmin"})
+	investigator := newCreator(t, "LawEnforcementMSP", "investigator1.lawenforcement.hot.coc.com", nil)
+	partner := newCreator(t, "PartnerAgencyMSP", "investigator1.partneragency.hot.coc.com", nil)
+	initLedger(t, endorsers, admin)
+
+	// Members of an unmapped MSP fall back to the default role
+	result := endorsers[0].endorse("tx-case-unmapped", partner, proposalTime, "CreateInvestigation",
+		"INV-001", "CASE-2025-001", "Ransomware", "PartnerAgency", "investigator1", "msp role test")
+	if !strings.Contains(result.Message, "access denied") {
+		t.Errorf("case created by an unmapped MSP: %d %s", result.Status, result.Message)
+	}
+
+	result = endorsers[0].endorse("tx-map-investigator", investigator, proposalTime, "SetMSPRole",
+		"PartnerAgencyMSP", "BlockchainInvestigator")
+	if !strings.Contains(result.Message, "access denied") {
+		t.Errorf("MSP role mapped by an investigator: %d %s", result.Status, result.Message)
+	}
+	result = endorsers[0].endorse("tx-map-unknown", admin, proposalTime, "SetMSPRole",
+		"PartnerAgencyMSP", "BlockchainPartner")
+	if !strings.Contains(result.Message, "not defined in the access policy") {
+		t.Errorf("MSP mapped to an undefined role: %d %s", result.Status, result.Message)
+	}
+
+	endorseAll(t, endorsers, "tx-map", admin, "SetMSPRole", "PartnerAgencyMSP", "BlockchainInvestigator")
+	if !hasRole(effectiveRoles(t, endorsers[0], "tx-roles", partner), "BlockchainInvestigator") {
+		t.Errorf("mapped MSP does not resolve to BlockchainInvestigator")
+	}
+	endorseAll(t, endorsers, "tx-case", partner, "CreateInvestigation",
+		"INV-001", "CASE-2025-001", "Ransomware", "PartnerAgency", "investigator1", "msp role test")
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	core "github.com/aub/dfir-core"
+)
+
+func TestBreakGlassRequiresAdminQuorum(t *testing.T) {
+	endorsers := newEndorsers(t)
+	investigator := newCreator(t, "LawEnforcementMSP", "investigator1.lawenforcement.hot.coc.com", nil)
+	lawEnforcementAdmin := newIdentity(t, "LawEnforcementMSP", "admin.lawenforcement.hot.coc.com", "admin", nil)
+	forensicLabAdmin := newIdentity(t, "ForensicLabMSP", "admin.forensiclab.hot.coc.com", "admin", nil)
+	initLedger(t, endorsers, investigator)
+
+	// Without a verifier quorum the chain is degraded and writes are blocked
+	updatePRVConfig(t, endorsers, func(config *PRVConfig) {
+		config.VerifiedBy = []VerifierEntry{}
+	})
+	caseArgs := []string{"INV-001", "CASE-2025-001", "Ransomware", "LawEnforcement", "investigator1", "break-glass test"}
+	result := endorsers[0].endorse("tx-case-degraded", investigator, proposalTime, "CreateInvestigation", caseArgs...)
+	if !strings.Contains(result.Message, "degraded mode, writes blocked") {
+		t.Errorf("write in degraded mode: %d %s", result.Status, result.Message)
+	}
+
+	result = endorsers[0].endorse("tx-request-client", investigator, proposalTime, "RequestBreakGlass", "evidence intake", "60")
+	if !strings.Contains(result.Message, "only organization administrators") {
+		t.Errorf("break-glass requested by a client: %d %s", result.Status, result.Message)
+	}
+
+	// One organization cannot open a session alone
+	result = endorseAll(t, endorsers, "tx-request", lawEnforcementAdmin, "RequestBreakGlass", "evidence intake", "60")
+	var session core.BreakGlassSession
+	if err := json.Unmarshal([]byte(result.Payload), &session); err != nil {
+		t.Fatalf("failed to decode session: %v", err)
+	}
+	if session.Status != "pending" {
+		t.Errorf("session with one approval is %s, want pending", session.Status)
+	}
+	result = endorsers[0].endorse("tx-approve-again", lawEnforcementAdmin, proposalTime, "ApproveBreakGlass", session.ID)
+	if !strings.Contains(result.Message, "already approved by LawEnforcementMSP") {
+		t.Errorf("second approval by one MSP: %d %s", result.Status, result.Message)
+	}
+	result = endorsers[0].endorse("tx-case-pending", investigator, proposalTime, "CreateInvestigation", caseArgs...)
+	if !strings.Contains(result.Message, "degraded mode, writes blocked") {
+		t.Errorf("write under a pending session: %d %s", result.Status, result.Message)
+	}
+
+	// A second organization's approval activates it and writes are tagged with it
+	endorseAll(t, endorsers, "tx-approve", forensicLabAdmin, "ApproveBreakGlass", session.ID)
+	result = endorseAll(t, endorsers, "tx-case", investigator, "CreateInvestigation", caseArgs...)
+	var tagged core.BreakGlassTransaction
+	if err := json.Unmarshal([]byte(result.Writes[core.StateKey(core.RecordBreakGlassTx, "breakglass_tx_tx-case")]), &tagged); err != nil {
+		t.Fatalf("write under break-glass not tagged, writes: %v: %v", keys(result.Writes), err)
+	}
+	if tagged.SessionID != session.ID || tagged.Function != "CreateInvestigation" {
+		t.Errorf("write tagged with session %s for %s", tagged.SessionID, tagged.Function)
+	}
+}
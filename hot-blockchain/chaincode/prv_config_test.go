@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	core "github.com/aub/dfir-core"
+)
+
+func TestRotatePRVKeyRequiresQuorumOfVerifiers(t *testing.T) {
+	endorsers := newEndorsers(t)
+	admin := newCreator(t, "LawEnforcementMSP", "admin.lawenforcement.hot.coc.com",
+		map[string]string{"role": "SystemAdmin"})
+	investigator := newCreator(t, "LawEnforcementMSP", "investigator1.lawenforcement.hot.coc.com", nil)
+	verifiers := map[string][]byte{}
+	for _, org := range []string{"LawEnforcement", "ForensicLab", "Court"} {
+		verifiers[org] = newCreator(t, org+"MSP", "verifier."+strings.ToLower(org)+".hot.coc.com",
+			map[string]string{"role": "AttestationVerifier"})
+	}
+	initLedger(t, endorsers, admin)
+
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate PRV key: %v", err)
+	}
+	args := []string{hex.EncodeToString(publicKey), strings.Repeat("ab", 32), strings.Repeat("cd", 32), "enclave upgrade"}
+
+	result := endorsers[0].endorse("tx-rotate-investigator", investigator, proposalTime, "RotatePRVKey", args...)
+	if !strings.Contains(result.Message, "only AttestationVerifier identities") {
+		t.Errorf("rotation by an investigator: %d %s", result.Status, result.Message)
+	}
+
+	// Only SystemAdmin revokes verifiers, and a revoked verifier cannot approve
+	result = endorsers[0].endorse("tx-revoke-verifier", verifiers["LawEnforcement"], proposalTime,
+		"RevokeVerifier", "CourtMSP", "key compromise")
+	if !strings.Contains(result.Message, "access denied") {
+		t.Errorf("verifier revoked by a verifier: %d %s", result.Status, result.Message)
+	}
+	endorseAll(t, endorsers, "tx-revoke", admin, "RevokeVerifier", "CourtMSP", "key compromise")
+	result = endorsers[0].endorse("tx-rotate-revoked", verifiers["Court"], proposalTime, "RotatePRVKey", args...)
+	if !strings.Contains(result.Message, "verifier CourtMSP has been revoked") {
+		t.Errorf("rotation by a revoked verifier: %d %s", result.Status, result.Message)
+	}
+
+	// The rotation stays pending until approvals reach the quorum of two
+	result = endorseAll(t, endorsers, "tx-rotate-1", verifiers["LawEnforcement"], "RotatePRVKey", args...)
+	var rotation core.PRVKeyRotation
+	if err := json.Unmarshal([]byte(result.Payload), &rotation); err != nil {
+		t.Fatalf("failed to decode rotation: %v", err)
+	}
+	if rotation.Status != "pending" {
+		t.Errorf("rotation with one approval is %s, want pending", rotation.Status)
+	}
+	result = endorsers[0].endorse("tx-rotate-1-again", verifiers["LawEnforcement"], proposalTime, "RotatePRVKey", args...)
+	if !strings.Contains(result.Message, "already approved by LawEnforcementMSP") {
+		t.Errorf("second approval by one MSP: %d %s", result.Status, result.Message)
+	}
+
+	result = endorseAll(t, endorsers, "tx-rotate-2", verifiers["ForensicLab"], "RotatePRVKey", args...)
+	if err := json.Unmarshal([]byte(result.Payload), &rotation); err != nil {
+		t.Fatalf("failed to decode rotation: %v", err)
+	}
+	if rotation.Status != "applied" {
+		t.Fatalf("rotation with a quorum of approvals is %s, want applied", rotation.Status)
+	}
+	var config PRVConfig
+	if err := json.Unmarshal([]byte(result.Writes[core.PRVConfigKey]), &config); err != nil {
+		t.Fatalf("failed to read PRV config: %v", err)
+	}
+	if config.PublicKey != args[0] || config.Version != rotation.AppliedVersion || len(config.VerifiedBy) != 0 {
+		t.Errorf("PRV config after rotation = key %s, version %d (rotation %d), %d verifiers",
+			config.PublicKey, config.Version, rotation.AppliedVersion, len(config.VerifiedBy))
+	}
+}
+
+func TestInitLedgerRunsOnce(t *testing.T) {
+	endorsers := newEndorsers(t)
+	admin := newCreator(t, "LawEnforcementMSP", "admin.lawenforcement.hot.coc.com",
+		map[string]string{"role": "SystemAdmin"})
+	investigator := newCreator(t, "LawEnforcementMSP", "investigator1.lawenforcement.hot.coc.com", nil)
+	initLedger(t, endorsers, admin)
+	before := string(endorsers[0].stub.State[core.PRVConfigKey])
+
+	// Re-initializing would replace the key and the verifier quorum without RotatePRVKey
+	zero := strings.Repeat("00", 32)
+	for _, creator := range [][]byte{investigator, admin} {
+		result := endorsers[0].endorse("tx-reinit", creator, proposalTime, "InitLedger", zero, zero, zero)
+		if !strings.Contains(result.Message, "PRV config already initialized") {
+			t.Errorf("second InitLedger: %d %s", result.Status, result.Message)
+		}
+	}
+	if after := string(endorsers[0].stub.State[core.PRVConfigKey]); after != before {
+		t.Errorf("PRV config changed by a second InitLedger")
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// setPRVSignature stops the endorsers signing proposals themselves and passes
+// signature in the transient data of the next proposals instead
+func setPRVSignature(endorsers []*endorser, signature []byte) {
+	for _, e := range endorsers {
+		e.prvKey = nil
+		e.stub.TransientMap = map[string][]byte{"prv_signature": signature}
+	}
+}
+
+func TestPRVKeyRequiresSignedRequests(t *testing.T) {
+	endorsers := newEndorsers(t)
+	admin := newCreator(t, "LawEnforcementMSP", "admin.lawenforcement.hot.coc.com",
+		map[string]string{"role": "SystemAdmin"})
+	initLedger(t, endorsers, admin)
+	prvKey := endorsers[0].prvKey
+
+	otherArgs := endorsers[0].signPRVRequest("tx-other-args", "SetTransferTimeout", "60")
+	signed := endorsers[0].signPRVRequest("tx-signed", "SetTransferTimeout", "3600")
+
+	// A valid client certificate alone does not drive sensitive operations
+	setPRVSignature(endorsers, nil)
+	result := endorsers[0].endorse("tx-unsigned", admin, proposalTime, "SetTransferTimeout", "3600")
+	if !strings.Contains(result.Message, `requires a PRV signature in transient field "prv_signature"`) {
+		t.Errorf("unsigned request: %d %s", result.Status, result.Message)
+	}
+
+	// A signature covers the exact arguments it was made for
+	setPRVSignature(endorsers, otherArgs)
+	result = endorsers[0].endorse("tx-other-args", admin, proposalTime, "SetTransferTimeout", "3600")
+	if !strings.Contains(result.Message, "PRV signature does not verify") {
+		t.Errorf("request signed for other arguments: %d %s", result.Status, result.Message)
+	}
+
+	for _, e := range endorsers {
+		e.prvKey = prvKey
+	}
+	endorseAll(t, endorsers, "tx-signed", admin, "SetTransferTimeout", "3600")
+
+	// A captured signature does not authorize the same request in another transaction
+	setPRVSignature(endorsers, signed)
+	result = endorsers[0].endorse("tx-replayed", admin, proposalTime, "SetTransferTimeout", "3600")
+	if !strings.Contains(result.Message, "PRV signature does not verify") {
+		t.Errorf("replayed signature: %d %s", result.Status, result.Message)
+	}
+}
+
+func TestPRVSignatureFailsClosedWithoutKey(t *testing.T) {
+	endorsers := newEndorsers(t)
+	admin := newCreator(t, "LawEnforcementMSP", "admin.lawenforcement.hot.coc.com",
+		map[string]string{"role": "SystemAdmin"})
+	initLedger(t, endorsers, admin)
+
+	// The placeholder key written at deployment does not switch verification off
+	for _, key := range []string{"", strings.Repeat("00", 32)} {
+		updatePRVConfig(t, endorsers, func(config *PRVConfig) {
+			config.PublicKey = key
+		})
+		result := endorsers[0].endorse("tx-no-key", admin, proposalTime, "SetTransferTimeout", "3600")
+		if !strings.Contains(result.Message, "no PRV key is configured") {
+			t.Errorf("request with PRV key %q: %d %s", key, result.Status, result.Message)
+		}
+	}
+}
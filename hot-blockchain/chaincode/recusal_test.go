@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	core "github.com/aub/dfir-core"
+)
+
+func TestRecusalOverridesRoleGrants(t *testing.T) {
+	endorsers := newEndorsers(t)
+	lead := newCreator(t, "LawEnforcementMSP", "investigator1.lawenforcement.hot.coc.com", nil)
+	examiner := newCreator(t, "LawEnforcementMSP", "investigator2.lawenforcement.hot.coc.com", nil)
+	court := newCreator(t, "CourtMSP", "judge1.court.hot.coc.com", nil)
+	initLedger(t, endorsers, lead)
+
+	const subject = "LawEnforcementMSP/CN=investigator2.lawenforcement.hot.coc.com"
+	endorseAll(t, endorsers, "tx-case", lead, "CreateInvestigation",
+		"INV-001", "CASE-2025-001", "Ransomware", "LawEnforcement", "investigator1", "recusal test")
+	endorseAll(t, endorsers, "tx-add", lead, "AddCaseMember", "INV-001", subject, "analyst")
+
+	// Investigators cannot recuse their colleagues
+	result := endorsers[0].endorse("tx-recuse-lead", lead, proposalTime, "AddRecusal",
+		subject, "INV-001", "", "related to a suspect")
+	if !strings.Contains(result.Message, "access denied") {
+		t.Errorf("recusal by an investigator: %d %s", result.Status, result.Message)
+	}
+
+	result = endorseAll(t, endorsers, "tx-recuse", court, "AddRecusal",
+		subject, "INV-001", "", "related to a suspect")
+	var recusal core.Recusal
+	if err := json.Unmarshal([]byte(result.Payload), &recusal); err != nil {
+		t.Fatalf("failed to decode recusal: %v", err)
+	}
+
+	// Policy listeners see the recusal, and the audit entry records it
+	if len(result.Events) != 1 || !strings.HasPrefix(result.Events[0], "AccessPolicyChanged ") ||
+		!strings.Contains(result.Events[0], `"recusal":{"id":"`+recusal.ID+`"`) {
+		t.Errorf("recusal events = %v, want one AccessPolicyChanged event carrying the recusal", result.Events)
+	}
+	var audit AuditLog
+	if err := json.Unmarshal([]byte(result.Writes[core.StateKey(core.RecordAudit, "audit_tx-recuse")]), &audit); err != nil {
+		t.Fatalf("failed to read audit entry: %v", err)
+	}
+	if audit.Resource != "rbac.recusal" || audit.ResourceID != recusal.ID || !strings.Contains(audit.Reason, "related to a suspect") {
+		t.Errorf("recusal audited as %s %s: %s", audit.Resource, audit.ResourceID, audit.Reason)
+	}
+
+	// The recusal bars a team member the analyst role otherwise admits
+	result = endorsers[0].endorse("tx-read-recused", examiner, proposalTime, "ReadInvestigation", "INV-001")
+	if !strings.Contains(result.Message, "recused from case:INV-001") {
+		t.Errorf("read by a recused analyst: %d %s", result.Status, result.Message)
+	}
+
+	endorseAll(t, endorsers, "tx-lift", court, "LiftRecusal", "INV-001", recusal.ID, "relationship ended")
+	if result := endorsers[0].endorse("tx-read-lifted", examiner, proposalTime, "ReadInvestigation", "INV-001"); result.Status != 200 {
+		t.Errorf("read after the recusal was lifted failed: %s", result.Message)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	core "github.com/aub/dfir-core"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ==============================================================================
+// CHUNKED CASE TRANSFER (Court Role Only)
+// ==============================================================================
+//
+// A case with more evidence than fits in one transaction is exported with a
+// manifest instead of its evidence. The cold chain serves the chunks the
+// manifest lists, and the hot chain imports them one transaction at a time
+// between BeginReactivatedCaseImport and FinalizeReactivatedCaseImport, which
+// takes the place of ImportReactivatedCase. Every step may be repeated, so an
+// interrupted import resumes with the chunks GetCaseImportProgress reports
+// missing. Archives chunk the same way with GetExportChunk on this chain.
+
+// BeginReactivatedCaseImport starts the import of a chunked package exported by the
+// cold chain's ExportCaseForReactivation (Court only). The package is verified as
+// ImportReactivatedCase verifies it; beginning again returns the open session.
+func (cc *DFIRChaincode) BeginReactivatedCaseImport(ctx contractapi.TransactionContextInterface,
+	packageJSON string) (*core.ImportSession, error) {
+
+	// Check attestation
+	if err := core.CheckAttestation(ctx); err != nil {
+		return nil, fmt.Errorf("attestation check failed: %v", err)
+	}
+
+	// Check permission (Court role only)
+	if err := accessControl.CheckPermission(ctx, "blockchain.investigation", "reopen", "*"); err != nil {
+		return nil, err
+	}
+
+	session, err := core.ReactivationFlow.BeginImport(ctx, packageJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	// Emit event
+	sessionJSON, _ := json.Marshal(session)
+	ctx.GetStub().SetEvent("CaseImportStarted", sessionJSON)
+
+	// Audit log
+	core.LogAudit(ctx, "begin_reactivated_case_import", "blockchain.investigation", session.InvestigationID,
+		"success", fmt.Sprintf("Chunked import of cold chain export %s begun, %d evidence items in %d chunks",
+			session.SourceTxID, session.Package.Manifest.EvidenceCount, len(session.Package.Manifest.Chunks)))
+
+	return session, nil
+}
+
+// ImportReactivatedCaseChunk stores one chunk of a chunked reactivation import (Court only).
+// chunkJSON is the chunk as returned by the cold chain's GetExportChunk.
+func (cc *DFIRChaincode) ImportReactivatedCaseChunk(ctx contractapi.TransactionContextInterface,
+	investigationID string, exportTxID string, index int, chunkJSON string) (*core.ImportedChunk, error) {
+
+	// Check attestation
+	if err := core.CheckAttestation(ctx); err != nil {
+		return nil, fmt.Errorf("attestation check failed: %v", err)
+	}
+
+	// Check permission (Court role only)
+	if err := accessControl.CheckPermission(ctx, "blockchain.investigation", "reopen", "*"); err != nil {
+		return nil, err
+	}
+
+	chunk, err := core.ReactivationFlow.ImportChunk(ctx, investigationID, exportTxID, index, chunkJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	// Emit event
+	chunkRecordJSON, _ := json.Marshal(chunk)
+	ctx.GetStub().SetEvent("CaseImportChunk", chunkRecordJSON)
+
+	// Audit log
+	core.LogAudit(ctx, "import_reactivated_case_chunk", "blockchain.investigation", investigationID,
+		"success", fmt.Sprintf("Chunk %d of cold chain export %s imported, %d evidence items",
+			index, exportTxID, chunk.EvidenceCount))
+
+	return chunk, nil
+}
+
+// FinalizeReactivatedCaseImport completes a chunked reactivation import once every
+// chunk is stored and reopens the case (Court only). It returns the import
+// record, whose package_hash completes the transfer, as ImportReactivatedCase does.
+func (cc *DFIRChaincode) FinalizeReactivatedCaseImport(ctx contractapi.TransactionContextInterface,
+	investigationID string, exportTxID string) (*core.CaseImport, error) {
+
+	// Check attestation
+	if err := core.CheckAttestation(ctx); err != nil {
+		return nil, fmt.Errorf("attestation check failed: %v", err)
+	}
+
+	// Check permission (Court role only)
+	if err := accessControl.CheckPermission(ctx, "blockchain.investigation", "reopen", "*"); err != nil {
+		return nil, err
+	}
+
+	importRecord, err := core.ReactivationFlow.FinalizeImport(ctx, investigationID, exportTxID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Emit event
+	importJSON, _ := json.Marshal(importRecord)
+	ctx.GetStub().SetEvent("CaseImported", importJSON)
+
+	// Audit log
+	core.LogAudit(ctx, "import_reactivated_case", "blockchain.investigation", investigationID,
+		"success", fmt.Sprintf("Case imported in chunks from cold chain export %s with court order: %s, package hash: %s",
+			exportTxID, importRecord.CourtOrder, importRecord.PackageHash))
+
+	return importRecord, nil
+}
+
+// GetCaseImportProgress returns the chunked import of a cold chain export with the
+// chunks stored so far and those still missing (Court only)
+func (cc *DFIRChaincode) GetCaseImportProgress(ctx contractapi.TransactionContextInterface,
+	investigationID string, exportTxID string) (*core.ImportProgress, error) {
+
+	// Check permission (Court role only)
+	if err := accessControl.CheckPermission(ctx, "blockchain.investigation", "reopen", "*"); err != nil {
+		return nil, err
+	}
+
+	progress, err := core.LoadImportProgress(ctx, investigationID, exportTxID)
+	if err != nil {
+		return nil, err
+	}
+	if progress == nil {
+		return nil, fmt.Errorf("no import of export %s of investigation %s was begun", exportTxID, investigationID)
+	}
+	return progress, nil
+}
+
+// GetExportChunk returns one chunk of a chunked archive export, for the
+// cold chain's ImportArchivedCaseChunk (Court only)
+func (cc *DFIRChaincode) GetExportChunk(ctx contractapi.TransactionContextInterface,
+	investigationID string, exportTxID string, index int) (string, error) {
+
+	// Check permission (Court role only)
+	if err := accessControl.CheckPermission(ctx, "blockchain.investigation", "archive", "*"); err != nil {
+		return "", err
+	}
+
+	return core.ArchiveFlow.ExportChunk(ctx, investigationID, exportTxID, index)
+}
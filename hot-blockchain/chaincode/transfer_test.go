@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	core "github.com/aub/dfir-core"
+)
+
+func TestExportCaseForArchiveExportsOnlyEvidence(t *testing.T) {
+	endorsers := newEndorsers(t)
+	investigator := newCreator(t, "LawEnforcementMSP", "investigator1.lawenforcement.hot.coc.com", nil)
+	court := newCreator(t, "CourtMSP", "judge1.court.hot.coc.com", nil)
+	initLedger(t, endorsers, investigator)
+
+	// Each case gets a team record that carries its case_id alongside the evidence
+	for _, tx := range []struct {
+		txID     string
+		function string
+		args     []string
+	}{
+		{"tx-case-1", "CreateInvestigation", []string{"INV-001", "CASE-2025-001", "Ransomware", "LawEnforcement", "investigator1", "export test"}},
+		{"tx-case-2", "CreateInvestigation", []string{"INV-002", "CASE-2025-002", "Fraud", "LawEnforcement", "investigator1", "export test"}},
+		{"tx-evidence-1", "CreateEvidence", []string{"EVD-001", "INV-001", "disk_image", "laptop image", "abc123", "QmHash1", "locker 4", "{}", "1024"}},
+		{"tx-evidence-2", "CreateEvidence", []string{"EVD-002", "INV-001", "memory_dump", "laptop memory", "def456", "QmHash2", "locker 4", "{}", "2048"}},
+		{"tx-evidence-3", "CreateEvidence", []string{"EVD-003", "INV-002", "disk_image", "server image", "789abc", "QmHash3", "locker 5", "{}", "4096"}},
+		{"tx-close", "UpdateInvestigationStatus", []string{"INV-001", "closed"}},
+	} {
+		endorseAll(t, endorsers, tx.txID, investigator, tx.function, tx.args...)
+	}
+
+	result := endorsers[0].endorse("tx-export-investigator", investigator, proposalTime,
+		"ExportCaseForArchive", "INV-001", "ORDER-1")
+	if !strings.Contains(result.Message, "access denied") {
+		t.Errorf("export by an investigator: %d %s", result.Status, result.Message)
+	}
+
+	result = endorseAll(t, endorsers, "tx-export", court, "ExportCaseForArchive", "INV-001", "ORDER-1")
+	exportPackage, err := core.DecodePackage([]byte(result.Payload))
+	if err != nil {
+		t.Fatalf("exported package does not validate: %v", err)
+	}
+	var ids []string
+	for _, evidence := range exportPackage.Evidence {
+		ids = append(ids, evidence.ID)
+	}
+	if strings.Join(ids, ",") != "EVD-001,EVD-002" {
+		t.Errorf("exported evidence %v, want EVD-001 and EVD-002", ids)
+	}
+}
@@ -0,0 +1,96 @@
+package relayer
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// exportedPackage returns the package an export transaction returned, read from
+// the block that committed it
+func exportedPackage(blockBytes []byte, txID string) ([]byte, error) {
+	block := &common.Block{}
+	if err := proto.Unmarshal(blockBytes, block); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal block: %v", err)
+	}
+	if block.Header == nil || block.Data == nil {
+		return nil, fmt.Errorf("incomplete block")
+	}
+
+	for i, envelopeBytes := range block.Data.Data {
+		envelope := &common.Envelope{}
+		if err := proto.Unmarshal(envelopeBytes, envelope); err != nil {
+			return nil, fmt.Errorf("transaction %d of block %d: failed to unmarshal envelope: %v", i, block.Header.Number, err)
+		}
+		channelHeader, err := envelopeChannelHeader(envelope)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d of block %d: %v", i, block.Header.Number, err)
+		}
+		if channelHeader.TxId != txID {
+			continue
+		}
+
+		if block.Metadata != nil && len(block.Metadata.Metadata) > int(common.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+			flags := block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER]
+			if i < len(flags) && flags[i] != byte(peer.TxValidationCode_VALID) {
+				return nil, fmt.Errorf("export transaction %s was committed as %s",
+					txID, peer.TxValidationCode(flags[i]))
+			}
+		}
+		return transactionResponse(envelope)
+	}
+	return nil, fmt.Errorf("block %d does not contain transaction %s", block.Header.Number, txID)
+}
+
+// envelopeChannelHeader decodes the channel header of a transaction envelope
+func envelopeChannelHeader(envelope *common.Envelope) (*common.ChannelHeader, error) {
+	payload := &common.Payload{}
+	if err := proto.Unmarshal(envelope.Payload, payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal payload: %v", err)
+	}
+	if payload.Header == nil {
+		return nil, fmt.Errorf("payload has no header")
+	}
+	channelHeader := &common.ChannelHeader{}
+	if err := proto.Unmarshal(payload.Header.ChannelHeader, channelHeader); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal channel header: %v", err)
+	}
+	return channelHeader, nil
+}
+
+// transactionResponse returns the chaincode response payload an endorser
+// transaction carries, i.e. the value the invoked function returned
+func transactionResponse(envelope *common.Envelope) ([]byte, error) {
+	payload := &common.Payload{}
+	if err := proto.Unmarshal(envelope.Payload, payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal payload: %v", err)
+	}
+	transaction := &peer.Transaction{}
+	if err := proto.Unmarshal(payload.Data, transaction); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal transaction: %v", err)
+	}
+	if len(transaction.Actions) != 1 {
+		return nil, fmt.Errorf("transaction has %d actions, expected 1", len(transaction.Actions))
+	}
+	actionPayload := &peer.ChaincodeActionPayload{}
+	if err := proto.Unmarshal(transaction.Actions[0].Payload, actionPayload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal chaincode action payload: %v", err)
+	}
+	if actionPayload.Action == nil {
+		return nil, fmt.Errorf("transaction has no endorsed action")
+	}
+	responsePayload := &peer.ProposalResponsePayload{}
+	if err := proto.Unmarshal(actionPayload.Action.ProposalResponsePayload, responsePayload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal proposal response: %v", err)
+	}
+	action := &peer.ChaincodeAction{}
+	if err := proto.Unmarshal(responsePayload.Extension, action); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal chaincode action: %v", err)
+	}
+	if action.Response == nil {
+		return nil, fmt.Errorf("transaction has no chaincode response")
+	}
+	return action.Response.Payload, nil
+}
@@ -0,0 +1,65 @@
+package relayer
+
+import "context"
+
+// ==============================================================================
+// CHAIN ACCESS
+// ==============================================================================
+//
+// The relayer reaches each network through a Chain. Gateway implements it over
+// the Fabric Gateway service of a peer; the tests use an in-memory stand-in.
+
+// Event is a chaincode event committed on a chain
+type Event struct {
+	BlockNumber uint64
+	TxID        string
+	Name        string
+	Payload     []byte
+}
+
+// Transaction is a signed proposal whose ID is fixed before it is submitted.
+// The relayer stores it before submitting, so after a crash it can look up
+// whether the transaction committed and otherwise submit the same proposal
+// again; a chain never commits two transactions with the same ID.
+type Transaction struct {
+	ID       string `json:"id"`
+	Function string `json:"function"`
+	Proposal []byte `json:"proposal"`
+}
+
+// Validation codes of committed transactions, as named by Fabric
+const (
+	TxValid         = "VALID"
+	TxDuplicateTxID = "DUPLICATE_TXID"
+)
+
+// Result is the committed outcome of a transaction
+type Result struct {
+	Code    string // Validation code, TxValid if the transaction took effect
+	Payload []byte // Chaincode response payload
+}
+
+// Chain is one network as seen by the relayer's identity
+type Chain interface {
+	// Events calls handle, in commit order, for each chaincode event committed
+	// from block start on. It returns when ctx ends, the stream fails or handle
+	// returns an error.
+	Events(ctx context.Context, start uint64, handle func(Event) error) error
+
+	// NewTransaction signs a proposal to invoke the chaincode
+	NewTransaction(function string, args ...string) (*Transaction, error)
+
+	// Submit endorses and orders tx and waits until it is committed
+	Submit(ctx context.Context, tx *Transaction) (*Result, error)
+
+	// Result returns the committed outcome of a transaction, or nil if the
+	// chain has not committed it
+	Result(ctx context.Context, txID string) (*Result, error)
+
+	// BlockByTxID returns the serialized block that holds a committed transaction
+	BlockByTxID(ctx context.Context, txID string) ([]byte, error)
+
+	// Query evaluates a function of the chaincode on a peer without submitting
+	// a transaction and returns its response payload
+	Query(ctx context.Context, function string, args ...string) ([]byte, error)
+}
@@ -0,0 +1,125 @@
+// Command dfir-relayer relays case archives from the hot chain to the cold
+// chain and case reactivations back, completing each transfer on its source
+// chain once the target chain has imported it.
+//
+// Usage:
+//
+//	dfir-relayer -config relayer.json
+//	dfir-relayer -config relayer.json -retry-failed
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	relayer "github.com/aub/dfir-relayer"
+)
+
+// config is the relayer.json file
+type config struct {
+	Hot           relayer.GatewayConfig `json:"hot"`
+	Cold          relayer.GatewayConfig `json:"cold"`
+	StateFile     string                `json:"state_file"`
+	Timeout       string                `json:"timeout"` // Per gateway call, e.g. "30s"
+	MaxAttempts   int                   `json:"max_attempts"`
+	RetryDelay    string                `json:"retry_delay"`
+	MaxRetryDelay string                `json:"max_retry_delay"`
+}
+
+func main() {
+	configPath := flag.String("config", "relayer.json", "relayer configuration file")
+	retryFailed := flag.Bool("retry-failed", false, "retry transfers that ran out of attempts")
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "dfir-relayer: ", log.LstdFlags)
+	if err := run(*configPath, *retryFailed, logger); err != nil {
+		logger.Fatal(err)
+	}
+}
+
+func run(configPath string, retryFailed bool, logger *log.Logger) error {
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	relayConfig := relayer.Config{MaxAttempts: cfg.MaxAttempts, Logger: logger}
+	var timeout time.Duration
+	for _, setting := range []struct {
+		name  string
+		value string
+		into  *time.Duration
+	}{
+		{"timeout", cfg.Timeout, &timeout},
+		{"retry_delay", cfg.RetryDelay, &relayConfig.RetryDelay},
+		{"max_retry_delay", cfg.MaxRetryDelay, &relayConfig.MaxRetryDelay},
+	} {
+		if setting.value == "" {
+			continue
+		}
+		if *setting.into, err = time.ParseDuration(setting.value); err != nil {
+			return fmt.Errorf("invalid %s: %v", setting.name, err)
+		}
+	}
+	cfg.Hot.Timeout = timeout
+	cfg.Cold.Timeout = timeout
+
+	store, err := relayer.OpenStore(cfg.StateFile)
+	if err != nil {
+		return err
+	}
+	hot, err := relayer.DialGateway(cfg.Hot)
+	if err != nil {
+		return fmt.Errorf("hot chain: %v", err)
+	}
+	defer hot.Close()
+	cold, err := relayer.DialGateway(cfg.Cold)
+	if err != nil {
+		return fmt.Errorf("cold chain: %v", err)
+	}
+	defer cold.Close()
+
+	r := relayer.New(store, relayConfig, relayer.ArchiveRoute(hot, cold), relayer.ReactivationRoute(cold, hot))
+	if retryFailed {
+		count, err := r.RetryFailed()
+		if err != nil {
+			return err
+		}
+		logger.Printf("retrying %d failed transfers", count)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	logger.Printf("relaying %s/%s <-> %s/%s", cfg.Hot.Endpoint, cfg.Hot.Channel, cfg.Cold.Endpoint, cfg.Cold.Channel)
+	if err := r.Run(ctx); err != nil && err != context.Canceled {
+		return err
+	}
+	logger.Printf("stopped")
+	return nil
+}
+
+func loadConfig(path string) (*config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+	var cfg config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %v", err)
+	}
+	if cfg.StateFile == "" {
+		return nil, fmt.Errorf("state_file is required")
+	}
+	for name, chain := range map[string]relayer.GatewayConfig{"hot": cfg.Hot, "cold": cfg.Cold} {
+		if chain.Endpoint == "" || chain.Channel == "" || chain.Chaincode == "" || chain.MSPID == "" {
+			return nil, fmt.Errorf("%s: endpoint, channel, chaincode and msp_id are required", name)
+		}
+	}
+	return &cfg, nil
+}
@@ -0,0 +1,468 @@
+package relayer
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/gateway"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/hyperledger/fabric-protos-go/orderer"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// ==============================================================================
+// FABRIC GATEWAY
+// ==============================================================================
+//
+// Gateway is a Chain backed by the Gateway service of a Fabric 2.4+ peer. It
+// builds and signs proposals itself, so the transaction ID is known before
+// anything is sent, and reads committed transactions and blocks through the
+// peer's qscc system chaincode.
+//
+// Imports also need a PRV signature bound to their transaction ID (see
+// core/prv_signature.go). With PRVSignCommand set, every transaction carries
+// one: the command gets the signed request JSON on stdin and prints the hex
+// signature the PRV enclave made over it.
+
+// GatewayConfig locates a peer and the relayer's enrolment on its network
+type GatewayConfig struct {
+	Endpoint      string `json:"endpoint"`        // host:port of the peer's gateway
+	TLSRootCert   string `json:"tls_root_cert"`   // PEM file of the peer's TLS CA
+	TLSServerName string `json:"tls_server_name"` // Overrides the host name checked against the peer's certificate
+	Channel       string `json:"channel"`
+	Chaincode     string `json:"chaincode"`
+	MSPID         string `json:"msp_id"`
+	CertPath      string `json:"cert_path"` // PEM file of the relayer's enrolment certificate
+	KeyPath       string `json:"key_path"`  // PEM file of its ECDSA private key
+
+	PRVSignCommand []string `json:"prv_sign_command,omitempty"` // Command that signs requests with the PRV key
+
+	Timeout time.Duration `json:"-"` // Per call, default 30s; Submit waits up to twice as long for the commit
+}
+
+// Gateway is a connection to one network
+type Gateway struct {
+	conn      *grpc.ClientConn
+	client    gateway.GatewayClient
+	channel   string
+	chaincode string
+	creator   []byte // Serialized identity
+	key       *ecdsa.PrivateKey
+	timeout   time.Duration
+	prvSign   []string
+}
+
+// DialGateway connects to the peer of config
+func DialGateway(config GatewayConfig) (*Gateway, error) {
+	certPEM, err := os.ReadFile(config.CertPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate: %v", err)
+	}
+	key, err := loadPrivateKey(config.KeyPath)
+	if err != nil {
+		return nil, err
+	}
+	creator, err := proto.Marshal(&msp.SerializedIdentity{Mspid: config.MSPID, IdBytes: certPEM})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal identity: %v", err)
+	}
+
+	rootPEM, err := os.ReadFile(config.TLSRootCert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TLS root certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(rootPEM) {
+		return nil, fmt.Errorf("no certificates in %s", config.TLSRootCert)
+	}
+	tlsConfig := &tls.Config{RootCAs: roots, ServerName: config.TLSServerName, MinVersion: tls.VersionTLS12}
+	conn, err := grpc.Dial(config.Endpoint, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", config.Endpoint, err)
+	}
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &Gateway{
+		conn:      conn,
+		client:    gateway.NewGatewayClient(conn),
+		channel:   config.Channel,
+		chaincode: config.Chaincode,
+		creator:   creator,
+		key:       key,
+		timeout:   timeout,
+		prvSign:   config.PRVSignCommand,
+	}, nil
+}
+
+// Close closes the connection to the peer
+func (g *Gateway) Close() error {
+	return g.conn.Close()
+}
+
+// Events follows the chaincode events of the configured chaincode
+func (g *Gateway) Events(ctx context.Context, start uint64, handle func(Event) error) error {
+	request, err := proto.Marshal(&gateway.ChaincodeEventsRequest{
+		ChannelId:   g.channel,
+		ChaincodeId: g.chaincode,
+		Identity:    g.creator,
+		StartPosition: &orderer.SeekPosition{
+			Type: &orderer.SeekPosition_Specified{Specified: &orderer.SeekSpecified{Number: start}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal event request: %v", err)
+	}
+	signature, err := g.sign(request)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := g.client.ChaincodeEvents(ctx, &gateway.SignedChaincodeEventsRequest{Request: request, Signature: signature})
+	if err != nil {
+		return fmt.Errorf("failed to open event stream: %v", err)
+	}
+	for {
+		response, err := stream.Recv()
+		if err == io.EOF {
+			return fmt.Errorf("event stream closed by peer")
+		}
+		if err != nil {
+			return err
+		}
+		for _, event := range response.Events {
+			if err := handle(Event{
+				BlockNumber: response.BlockNumber,
+				TxID:        event.TxId,
+				Name:        event.EventName,
+				Payload:     event.Payload,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// NewTransaction signs a proposal to invoke the configured chaincode
+func (g *Gateway) NewTransaction(function string, args ...string) (*Transaction, error) {
+	nonce, txID, err := g.newNonce()
+	if err != nil {
+		return nil, err
+	}
+	var transient map[string][]byte
+	if len(g.prvSign) > 0 {
+		signature, err := g.prvSignature(txID, function, args)
+		if err != nil {
+			return nil, err
+		}
+		transient = map[string][]byte{"prv_signature": signature}
+	}
+
+	proposal, err := g.newProposal(g.chaincode, nonce, txID, transient, function, args...)
+	if err != nil {
+		return nil, err
+	}
+	proposalBytes, err := proto.Marshal(proposal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal proposal: %v", err)
+	}
+	return &Transaction{ID: txID, Function: function, Proposal: proposalBytes}, nil
+}
+
+// Submit endorses tx, sends it to the orderer and waits for its commit status
+func (g *Gateway) Submit(ctx context.Context, tx *Transaction) (*Result, error) {
+	proposal := &peer.SignedProposal{}
+	if err := proto.Unmarshal(tx.Proposal, proposal); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal proposal: %v", err)
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	endorsed, err := g.client.Endorse(callCtx, &gateway.EndorseRequest{
+		TransactionId:       tx.ID,
+		ChannelId:           g.channel,
+		ProposedTransaction: proposal,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("endorsement failed: %v", gatewayError(err))
+	}
+	envelope := endorsed.PreparedTransaction
+	payload, err := transactionResponse(envelope)
+	if err != nil {
+		return nil, err
+	}
+	if envelope.Signature, err = g.sign(envelope.Payload); err != nil {
+		return nil, err
+	}
+
+	if _, err := g.client.Submit(callCtx, &gateway.SubmitRequest{
+		TransactionId:       tx.ID,
+		ChannelId:           g.channel,
+		PreparedTransaction: envelope,
+	}); err != nil {
+		return nil, fmt.Errorf("submit failed: %v", gatewayError(err))
+	}
+
+	request, err := proto.Marshal(&gateway.CommitStatusRequest{
+		TransactionId: tx.ID,
+		ChannelId:     g.channel,
+		Identity:      g.creator,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal commit status request: %v", err)
+	}
+	signature, err := g.sign(request)
+	if err != nil {
+		return nil, err
+	}
+	commitCtx, commitCancel := context.WithTimeout(ctx, 2*g.timeout)
+	defer commitCancel()
+	committed, err := g.client.CommitStatus(commitCtx, &gateway.SignedCommitStatusRequest{Request: request, Signature: signature})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit status: %v", gatewayError(err))
+	}
+	return &Result{Code: committed.Result.String(), Payload: payload}, nil
+}
+
+// Result looks the transaction up with qscc GetTransactionByID
+func (g *Gateway) Result(ctx context.Context, txID string) (*Result, error) {
+	processedBytes, err := g.evaluate(ctx, "qscc", "GetTransactionByID", g.channel, txID)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such transaction ID") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	processed := &peer.ProcessedTransaction{}
+	if err := proto.Unmarshal(processedBytes, processed); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal transaction %s: %v", txID, err)
+	}
+	payload, err := transactionResponse(processed.TransactionEnvelope)
+	if err != nil {
+		return nil, fmt.Errorf("transaction %s: %v", txID, err)
+	}
+	return &Result{Code: peer.TxValidationCode(processed.ValidationCode).String(), Payload: payload}, nil
+}
+
+// BlockByTxID fetches the block with qscc GetBlockByTxID
+func (g *Gateway) BlockByTxID(ctx context.Context, txID string) ([]byte, error) {
+	return g.evaluate(ctx, "qscc", "GetBlockByTxID", g.channel, txID)
+}
+
+// Query evaluates a function of the configured chaincode
+func (g *Gateway) Query(ctx context.Context, function string, args ...string) ([]byte, error) {
+	return g.evaluate(ctx, g.chaincode, function, args...)
+}
+
+// evaluate runs a query on the gateway's peer and returns its response payload
+func (g *Gateway) evaluate(ctx context.Context, chaincode string, function string, args ...string) ([]byte, error) {
+	nonce, txID, err := g.newNonce()
+	if err != nil {
+		return nil, err
+	}
+	proposal, err := g.newProposal(chaincode, nonce, txID, nil, function, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	response, err := g.client.Evaluate(callCtx, &gateway.EvaluateRequest{
+		TransactionId:       txID,
+		ChannelId:           g.channel,
+		ProposedTransaction: proposal,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed: %v", chaincode, function, gatewayError(err))
+	}
+	return response.Result.Payload, nil
+}
+
+// newNonce generates a proposal nonce and the transaction ID Fabric derives from
+// it and the creator
+func (g *Gateway) newNonce() ([]byte, string, error) {
+	nonce := make([]byte, 24)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, "", fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	txHash := sha256.Sum256(append(append([]byte{}, nonce...), g.creator...))
+	return nonce, hex.EncodeToString(txHash[:]), nil
+}
+
+// newProposal builds and signs a proposal for transaction txID to invoke a chaincode
+// on the channel. Transient data is sent to the endorsers but not recorded in the
+// transaction.
+func (g *Gateway) newProposal(chaincode string, nonce []byte, txID string, transient map[string][]byte,
+	function string, args ...string) (*peer.SignedProposal, error) {
+
+	chaincodeID := &peer.ChaincodeID{Name: chaincode}
+	extension, err := proto.Marshal(&peer.ChaincodeHeaderExtension{ChaincodeId: chaincodeID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal header extension: %v", err)
+	}
+	now := time.Now()
+	channelHeader, err := proto.Marshal(&common.ChannelHeader{
+		Type:      int32(common.HeaderType_ENDORSER_TRANSACTION),
+		ChannelId: g.channel,
+		TxId:      txID,
+		Timestamp: &timestamp.Timestamp{Seconds: now.Unix(), Nanos: int32(now.Nanosecond())},
+		Extension: extension,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal channel header: %v", err)
+	}
+	signatureHeader, err := proto.Marshal(&common.SignatureHeader{Creator: g.creator, Nonce: nonce})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal signature header: %v", err)
+	}
+	header, err := proto.Marshal(&common.Header{ChannelHeader: channelHeader, SignatureHeader: signatureHeader})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal header: %v", err)
+	}
+
+	input := [][]byte{[]byte(function)}
+	for _, arg := range args {
+		input = append(input, []byte(arg))
+	}
+	invocation, err := proto.Marshal(&peer.ChaincodeInvocationSpec{
+		ChaincodeSpec: &peer.ChaincodeSpec{
+			Type:        peer.ChaincodeSpec_GOLANG,
+			ChaincodeId: chaincodeID,
+			Input:       &peer.ChaincodeInput{Args: input},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal invocation: %v", err)
+	}
+	payload, err := proto.Marshal(&peer.ChaincodeProposalPayload{Input: invocation, TransientMap: transient})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal proposal payload: %v", err)
+	}
+
+	proposalBytes, err := proto.Marshal(&peer.Proposal{Header: header, Payload: payload})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal proposal: %v", err)
+	}
+	signature, err := g.sign(proposalBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &peer.SignedProposal{ProposalBytes: proposalBytes, Signature: signature}, nil
+}
+
+// prvSignature asks PRVSignCommand to sign the request the chaincode checks:
+// {"channel_id":...,"tx_id":...,"function":...,"args":[...]}
+func (g *Gateway) prvSignature(txID string, function string, args []string) ([]byte, error) {
+	request, err := json.Marshal(struct {
+		ChannelID string   `json:"channel_id"`
+		TxID      string   `json:"tx_id"`
+		Function  string   `json:"function"`
+		Args      []string `json:"args"`
+	}{g.channel, txID, function, append([]string{}, args...)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode PRV request: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, g.prvSign[0], g.prvSign[1:]...)
+	cmd.Stdin = bytes.NewReader(request)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("PRV sign command failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	signature, err := hex.DecodeString(strings.TrimSpace(string(output)))
+	if err != nil || len(signature) == 0 {
+		return nil, fmt.Errorf("PRV sign command did not print a hex signature")
+	}
+	return signature, nil
+}
+
+// ecdsaSignature is the ASN.1 encoding of an ECDSA signature
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// sign signs the SHA-256 digest of message with a low-S ECDSA signature, which Fabric requires
+func (g *Gateway) sign(message []byte) ([]byte, error) {
+	digest := sha256.Sum256(message)
+	r, s, err := ecdsa.Sign(rand.Reader, g.key, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %v", err)
+	}
+	halfOrder := new(big.Int).Rsh(g.key.Params().N, 1)
+	if s.Cmp(halfOrder) > 0 {
+		s.Sub(g.key.Params().N, s)
+	}
+	return asn1.Marshal(ecdsaSignature{R: r, S: s})
+}
+
+// loadPrivateKey reads a PEM ECDSA private key in PKCS#8 or SEC 1 form
+func loadPrivateKey(path string) (*ecdsa.PrivateKey, error) {
+	keyPEM, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %v", err)
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM private key in %s", path)
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %v", path, err)
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key %s is not an ECDSA key", path)
+	}
+	return key, nil
+}
+
+// gatewayError adds the per-peer messages the gateway attaches to an error
+func gatewayError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	var details []string
+	for _, detail := range st.Details() {
+		if errorDetail, ok := detail.(*gateway.ErrorDetail); ok {
+			details = append(details, fmt.Sprintf("%s (%s): %s", errorDetail.Address, errorDetail.MspId, errorDetail.Message))
+		}
+	}
+	if len(details) == 0 {
+		return fmt.Errorf("%s", st.Message())
+	}
+	return fmt.Errorf("%s: %s", st.Message(), strings.Join(details, "; "))
+}
@@ -0,0 +1,32 @@
+package relayer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestPRVSignatureSignsChaincodeRequest(t *testing.T) {
+	if _, err := exec.LookPath("sha256sum"); err != nil {
+		t.Skip("sha256sum not available")
+	}
+	// The "signer" prints the SHA-256 of what it was asked to sign
+	g := &Gateway{channel: "coldchannel", timeout: 5 * time.Second,
+		prvSign: []string{"sh", "-c", "sha256sum | cut -d ' ' -f 1"}}
+
+	signature, err := g.prvSignature("tx-1", "ImportArchivedCase", []string{"INV-001", `{"a":1}`, "ORDER-7"})
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	want := sha256.Sum256([]byte(`{"channel_id":"coldchannel","tx_id":"tx-1","function":"ImportArchivedCase","args":["INV-001","{\"a\":1}","ORDER-7"]}`))
+	if !bytes.Equal(signature, want[:]) {
+		t.Errorf("signed request differs from the one the chaincode verifies")
+	}
+
+	g.prvSign = []string{"sh", "-c", "echo 'no key' >&2; exit 1"}
+	if _, err := g.prvSignature("tx-1", "ImportArchivedCase", nil); err == nil || !bytes.Contains([]byte(err.Error()), []byte("no key")) {
+		t.Errorf("failing sign command gave %v", err)
+	}
+}
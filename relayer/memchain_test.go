@@ -0,0 +1,248 @@
+package relayer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// memChain is an in-memory stand-in for a network behind a Fabric gateway. It
+// commits one transaction per block, keeps real Fabric blocks so the relayer
+// reads export packages from them as it would from qscc, and runs the
+// transactions through a Go function instead of a chaincode.
+type memChain struct {
+	t       *testing.T
+	name    string
+	channel string
+	invoke  func(txID string, function string, args []string) (payload []byte, event *Event, err error)
+	query   func(function string, args []string) ([]byte, error)
+
+	mu        sync.Mutex
+	blocks    [][]byte
+	committed map[string]*Result
+	blockOf   map[string]int
+	events    []Event
+	changed   chan struct{}
+	nextTx    int
+	submitted map[string]int // Committed transactions per function
+
+	// Faults, each consumed by the next Submit
+	failSubmits   int          // Fail before endorsement
+	loseResponses int          // Commit, then report a transport error
+	invalidate    int          // Commit with an MVCC_READ_CONFLICT validation code
+	beforeSubmit  func()       // Runs at the start of every Submit, e.g. to simulate a crash
+	afterCommit   func() error // Runs after every commit; its error is returned by Submit
+}
+
+func newMemChain(t *testing.T, name string) *memChain {
+	return &memChain{
+		t:         t,
+		name:      name,
+		channel:   name + "channel",
+		committed: map[string]*Result{},
+		blockOf:   map[string]int{},
+		changed:   make(chan struct{}),
+		submitted: map[string]int{},
+	}
+}
+
+// memProposal is what the stand-in signs instead of a Fabric proposal
+type memProposal struct {
+	Function string   `json:"function"`
+	Args     []string `json:"args"`
+}
+
+func (c *memChain) NewTransaction(function string, args ...string) (*Transaction, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextTx++
+	proposal, err := json.Marshal(memProposal{Function: function, Args: args})
+	if err != nil {
+		return nil, err
+	}
+	return &Transaction{ID: fmt.Sprintf("%s-tx-%d", c.name, c.nextTx), Function: function, Proposal: proposal}, nil
+}
+
+func (c *memChain) Submit(ctx context.Context, tx *Transaction) (*Result, error) {
+	if c.beforeSubmit != nil {
+		c.beforeSubmit()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if _, ok := c.committed[tx.ID]; ok {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("endorsement failed: duplicate transaction found [%s]", tx.ID)
+	}
+	if c.failSubmits > 0 {
+		c.failSubmits--
+		c.mu.Unlock()
+		return nil, fmt.Errorf("endorsement failed: connection refused")
+	}
+	invalidate := c.invalidate > 0
+	if invalidate {
+		c.invalidate--
+	}
+	loseResponse := c.loseResponses > 0
+	if loseResponse {
+		c.loseResponses--
+	}
+	c.mu.Unlock()
+
+	var proposal memProposal
+	if err := json.Unmarshal(tx.Proposal, &proposal); err != nil {
+		return nil, err
+	}
+
+	code := peer.TxValidationCode_MVCC_READ_CONFLICT
+	var payload []byte
+	var event *Event
+	if !invalidate {
+		var err error
+		payload, event, err = c.invoke(tx.ID, proposal.Function, proposal.Args)
+		if err != nil {
+			return nil, fmt.Errorf("endorsement failed: %v", err)
+		}
+		code = peer.TxValidationCode_VALID
+		c.mu.Lock()
+		c.submitted[proposal.Function]++
+		c.mu.Unlock()
+	}
+	result := c.commit(tx.ID, code, payload, event)
+
+	if c.afterCommit != nil {
+		if err := c.afterCommit(); err != nil {
+			return nil, err
+		}
+	}
+	if loseResponse {
+		return nil, fmt.Errorf("failed to get commit status: connection reset")
+	}
+	return result, nil
+}
+
+// commit appends a block holding one transaction that returned payload and set event
+func (c *memChain) commit(txID string, code peer.TxValidationCode, payload []byte, event *Event) *Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	number := uint64(len(c.blocks))
+	c.blocks = append(c.blocks, c.block(number, txID, code, payload))
+	result := &Result{Code: code.String(), Payload: payload}
+	c.committed[txID] = result
+	c.blockOf[txID] = int(number)
+	if event != nil && code == peer.TxValidationCode_VALID {
+		c.events = append(c.events, Event{BlockNumber: number, TxID: txID, Name: event.Name, Payload: event.Payload})
+	}
+	close(c.changed)
+	c.changed = make(chan struct{})
+	return result
+}
+
+// block builds the Fabric block of one committed transaction
+func (c *memChain) block(number uint64, txID string, code peer.TxValidationCode, payload []byte) []byte {
+	marshal := func(m proto.Message) []byte {
+		data, err := proto.Marshal(m)
+		if err != nil {
+			c.t.Fatalf("failed to marshal %T: %v", m, err)
+		}
+		return data
+	}
+
+	action := marshal(&peer.ChaincodeAction{Response: &peer.Response{Status: 200, Payload: payload}})
+	actionPayload := marshal(&peer.ChaincodeActionPayload{
+		Action: &peer.ChaincodeEndorsedAction{
+			ProposalResponsePayload: marshal(&peer.ProposalResponsePayload{Extension: action}),
+		},
+	})
+	transaction := marshal(&peer.Transaction{Actions: []*peer.TransactionAction{{Payload: actionPayload}}})
+	envelope := marshal(&common.Envelope{Payload: marshal(&common.Payload{
+		Header: &common.Header{ChannelHeader: marshal(&common.ChannelHeader{
+			Type:      int32(common.HeaderType_ENDORSER_TRANSACTION),
+			ChannelId: c.channel,
+			TxId:      txID,
+		})},
+		Data: transaction,
+	})})
+
+	metadata := make([][]byte, len(common.BlockMetadataIndex_name))
+	metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = []byte{byte(code)}
+	return marshal(&common.Block{
+		Header:   &common.BlockHeader{Number: number},
+		Data:     &common.BlockData{Data: [][]byte{envelope}},
+		Metadata: &common.BlockMetadata{Metadata: metadata},
+	})
+}
+
+func (c *memChain) Result(ctx context.Context, txID string) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.committed[txID], nil
+}
+
+func (c *memChain) BlockByTxID(ctx context.Context, txID string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	number, ok := c.blockOf[txID]
+	if !ok {
+		return nil, fmt.Errorf("no such transaction ID [%s] in index", txID)
+	}
+	return c.blocks[number], nil
+}
+
+func (c *memChain) Query(ctx context.Context, function string, args ...string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if c.query == nil {
+		return nil, fmt.Errorf("unknown query %s", function)
+	}
+	return c.query(function, args)
+}
+
+func (c *memChain) Events(ctx context.Context, start uint64, handle func(Event) error) error {
+	next := 0
+	for {
+		c.mu.Lock()
+		pending := c.events[next:]
+		changed := c.changed
+		c.mu.Unlock()
+
+		for _, event := range pending {
+			next++
+			if event.BlockNumber < start {
+				continue
+			}
+			if err := handle(event); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// count returns how many transactions of function took effect
+func (c *memChain) count(function string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.submitted[function]
+}
@@ -0,0 +1,828 @@
+// Package relayer carries cases between the hot and cold chains. It follows the
+// CaseExported events of each source chain, submits the import with the export
+// block as transfer proof to the target chain, and confirms the import back to
+// the source chain with the import block as proof of the package hash the
+// target chain recorded.
+//
+// A package too large for one transaction lists its evidence in chunks. The
+// relayer then begins the import with the package, fetches each chunk from the
+// source chain and imports it in its own transaction, and finalizes the import
+// once every chunk is in; the completion confirms the finalize transaction.
+//
+// A transfer whose deadline passed before it was imported is left alone, as
+// the target chain would refuse it. Once the source chain aborts a transfer
+// (event CaseTransferAborted), the relayer stops its job and records the abort
+// on the target chain with the abort block as proof, after which the target
+// chain refuses the export whatever the timestamp of the import proposal.
+//
+// Progress is kept in a checkpoint file after every step, and every
+// transaction is stored signed before it is submitted, so a relayer restarted
+// after a crash resumes each transfer where it stopped without submitting an
+// import or completion twice.
+package relayer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	core "github.com/aub/dfir-core"
+)
+
+// Chaincode events a source chain emits for a transfer
+const (
+	ExportEvent = "CaseExported"        // The case was exported
+	AbortEvent  = "CaseTransferAborted" // The transfer was aborted
+)
+
+// ChunkQuery is the source chain query that returns one chunk of a chunked export
+const ChunkQuery = "GetExportChunk"
+
+// Route is one direction of case transfer between the chains
+type Route struct {
+	Name             string // core.TransferArchive or core.TransferReactivation
+	Source           Chain
+	Target           Chain
+	ImportFunction   string // Target chain transaction that imports a package
+	BeginFunction    string // Target chain transaction that begins the import of a chunked package
+	ChunkFunction    string // Target chain transaction that imports one chunk
+	FinalizeFunction string // Target chain transaction that completes a chunked import
+	CompleteFunction string // Source chain transaction that confirms the import
+	AbortFunction    string // Target chain transaction that records an abort
+}
+
+// ArchiveRoute relays closed cases from the hot chain to the cold chain
+func ArchiveRoute(hot Chain, cold Chain) Route {
+	return Route{
+		Name:             core.TransferArchive,
+		Source:           hot,
+		Target:           cold,
+		ImportFunction:   "ImportArchivedCase",
+		BeginFunction:    "BeginArchivedCaseImport",
+		ChunkFunction:    "ImportArchivedCaseChunk",
+		FinalizeFunction: "FinalizeArchivedCaseImport",
+		CompleteFunction: "CompleteArchiveTransfer",
+		AbortFunction:    "RecordArchiveAbort",
+	}
+}
+
+// ReactivationRoute relays reactivated cases from the cold chain to the hot chain
+func ReactivationRoute(cold Chain, hot Chain) Route {
+	return Route{
+		Name:             core.TransferReactivation,
+		Source:           cold,
+		Target:           hot,
+		ImportFunction:   "ImportReactivatedCase",
+		BeginFunction:    "BeginReactivatedCaseImport",
+		ChunkFunction:    "ImportReactivatedCaseChunk",
+		FinalizeFunction: "FinalizeReactivatedCaseImport",
+		CompleteFunction: "CompleteReactivationTransfer",
+		AbortFunction:    "RecordReactivationAbort",
+	}
+}
+
+// Config tunes retries
+type Config struct {
+	MaxAttempts   int           // Attempts per job before it is marked failed
+	RetryDelay    time.Duration // Delay before the first retry, doubled per attempt
+	MaxRetryDelay time.Duration
+	Logger        *log.Logger
+}
+
+// DefaultConfig retries a job for about an hour before giving up on it
+var DefaultConfig = Config{
+	MaxAttempts:   20,
+	RetryDelay:    2 * time.Second,
+	MaxRetryDelay: 5 * time.Minute,
+}
+
+// Relayer moves cases along its routes
+type Relayer struct {
+	routes map[string]Route
+	store  *Store
+	config Config
+	now    func() time.Time
+	wake   chan struct{}
+}
+
+// New creates a relayer for routes that keeps its progress in store
+func New(store *Store, config Config, routes ...Route) *Relayer {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = DefaultConfig.MaxAttempts
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = DefaultConfig.RetryDelay
+	}
+	if config.MaxRetryDelay < config.RetryDelay {
+		config.MaxRetryDelay = config.RetryDelay
+	}
+	if config.Logger == nil {
+		config.Logger = log.Default()
+	}
+
+	r := &Relayer{
+		routes: map[string]Route{},
+		store:  store,
+		config: config,
+		now:    time.Now,
+		wake:   make(chan struct{}, 1),
+	}
+	for _, route := range routes {
+		r.routes[route.Name] = route
+	}
+	return r
+}
+
+// Run follows the export events of every route and works through the jobs
+// until ctx ends. Jobs left unfinished by an earlier run are resumed first.
+func (r *Relayer) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, route := range r.routes {
+		wg.Add(1)
+		go func(route Route) {
+			defer wg.Done()
+			r.listen(ctx, route)
+		}(route)
+	}
+
+	r.work(ctx)
+	wg.Wait()
+	return ctx.Err()
+}
+
+// RetryFailed makes the jobs that ran out of attempts due again and returns how many there were
+func (r *Relayer) RetryFailed() (int, error) {
+	count := 0
+	err := r.store.Update(func(state *State) error {
+		for _, job := range state.Jobs {
+			if job.Failed {
+				job.Failed = false
+				job.Attempts = 0
+				job.NextAttempt = time.Time{}
+				count++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	r.signal()
+	return count, nil
+}
+
+// signal wakes the worker
+func (r *Relayer) signal() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// ==============================================================================
+// EVENT INTAKE
+// ==============================================================================
+
+// listen follows a route's source chain from its checkpoint, reconnecting until ctx ends
+func (r *Relayer) listen(ctx context.Context, route Route) {
+	failures := 0
+	for {
+		checkpoint := r.store.Checkpoint(route.Name)
+		skipping := checkpoint.TxID != ""
+		err := route.Source.Events(ctx, checkpoint.Block, func(event Event) error {
+			// Resume after the last event taken on in the checkpoint block
+			if skipping && event.BlockNumber == checkpoint.Block {
+				if event.TxID == checkpoint.TxID {
+					skipping = false
+				}
+				return nil
+			}
+			skipping = false
+			failures = 0
+			return r.handleEvent(route, event)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		failures++
+		delay := r.retryDelay(failures)
+		r.config.Logger.Printf("%s: event stream from block %d ended: %v, reconnecting in %s",
+			route.Name, checkpoint.Block, err, delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// handleEvent records a job for an export event, or stops the job of an
+// aborted transfer, and moves the route's checkpoint past the event
+func (r *Relayer) handleEvent(route Route, event Event) error {
+	if event.Name != ExportEvent && event.Name != AbortEvent {
+		return nil
+	}
+
+	var transfer core.CaseTransfer
+	if err := json.Unmarshal(event.Payload, &transfer); err != nil {
+		r.config.Logger.Printf("%s: ignoring malformed %s event of transaction %s: %v",
+			route.Name, event.Name, event.TxID, err)
+		return nil
+	}
+	if transfer.Direction != route.Name {
+		return nil
+	}
+	if event.Name == AbortEvent {
+		return r.handleAbort(route, event, &transfer)
+	}
+
+	key := JobKey(route.Name, event.TxID)
+	err := r.store.Update(func(state *State) error {
+		if _, seen := state.Jobs[key]; !seen {
+			state.Jobs[key] = &Job{
+				Route:           route.Name,
+				InvestigationID: transfer.InvestigationID,
+				ExportTxID:      event.TxID,
+				PackageHash:     transfer.PackageHash,
+				FormatVersion:   transfer.FormatVersion,
+				ExpiresAt:       transfer.ExpiresAt,
+				Stage:           StageExported,
+				UpdatedAt:       r.now(),
+			}
+		}
+		state.Checkpoints[route.Name] = Checkpoint{Block: event.BlockNumber, TxID: event.TxID}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	r.config.Logger.Printf("%s: case %s exported in transaction %s", route.Name, transfer.InvestigationID, event.TxID)
+	r.signal()
+	return nil
+}
+
+// handleAbort stops the job of a transfer aborted on the source chain and
+// queues the abort for the target chain, even for a job already given up as
+// expired. An import that committed before the abort stays on the target
+// chain; it is logged for the operators to deal with.
+func (r *Relayer) handleAbort(route Route, event Event, transfer *core.CaseTransfer) error {
+	key := JobKey(route.Name, transfer.ExportTxID)
+	var stopped *Job
+	err := r.store.Update(func(state *State) error {
+		job, ok := state.Jobs[key]
+		if !ok {
+			// The export came before the relayer's first checkpoint
+			job = &Job{
+				Route:           route.Name,
+				InvestigationID: transfer.InvestigationID,
+				ExportTxID:      transfer.ExportTxID,
+				PackageHash:     transfer.PackageHash,
+				FormatVersion:   transfer.FormatVersion,
+				ExpiresAt:       transfer.ExpiresAt,
+			}
+			state.Jobs[key] = job
+		}
+		if job.Stage != StageCompleted && job.AbortTxID == "" {
+			job.Stage = StageAborting
+			job.AbortTxID = event.TxID
+			job.LastError = transfer.AbortReason
+			job.Attempts = 0
+			job.NextAttempt = time.Time{}
+			job.Failed = false
+			job.UpdatedAt = r.now()
+			stopped = job
+		}
+		state.Checkpoints[route.Name] = Checkpoint{Block: event.BlockNumber, TxID: event.TxID}
+		return nil
+	})
+	if err != nil || stopped == nil {
+		return err
+	}
+
+	r.config.Logger.Printf("%s: case %s transfer aborted on the source chain in transaction %s: %s",
+		route.Name, transfer.InvestigationID, event.TxID, transfer.AbortReason)
+	if stopped.ImportedHash != "" {
+		r.config.Logger.Printf("%s: case %s was already imported in transaction %s, the target chain keeps its copy",
+			route.Name, transfer.InvestigationID, stopped.TargetTxID())
+	}
+	r.signal()
+	return nil
+}
+
+// ==============================================================================
+// JOB PROCESSING
+// ==============================================================================
+
+// work processes due jobs until ctx ends, sleeping until the next job is due or an event arrives
+func (r *Relayer) work(ctx context.Context) {
+	for {
+		next := r.processDue(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		var timer <-chan time.Time
+		if !next.IsZero() {
+			timer = time.After(next.Sub(r.now()))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.wake:
+		case <-timer:
+		}
+	}
+}
+
+// processDue runs every due job as far as it gets and returns when the earliest
+// job waiting for a retry is due, or the zero time if none is
+func (r *Relayer) processDue(ctx context.Context) time.Time {
+	jobs := r.store.Jobs()
+	sort.SliceStable(jobs, func(i, j int) bool { return jobs[i].NextAttempt.Before(jobs[j].NextAttempt) })
+
+	var next time.Time
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			return next
+		}
+		if job.Done() || job.Failed {
+			continue
+		}
+		if job.NextAttempt.After(r.now()) {
+			if next.IsZero() || job.NextAttempt.Before(next) {
+				next = job.NextAttempt
+			}
+			continue
+		}
+
+		retryAt, err := r.runJob(ctx, JobKey(job.Route, job.ExportTxID))
+		if err != nil {
+			r.config.Logger.Printf("%s: case %s: %v", job.Route, job.InvestigationID, err)
+		}
+		if !retryAt.IsZero() && (next.IsZero() || retryAt.Before(next)) {
+			next = retryAt
+		}
+	}
+	return next
+}
+
+// runJob advances a job until it is done or a step fails. A failed step is
+// recorded on the job and scheduled for retry; runJob returns the retry time,
+// or the zero time once the job is done or has run out of attempts.
+func (r *Relayer) runJob(ctx context.Context, key string) (time.Time, error) {
+	for {
+		job := r.store.Job(key)
+		if job == nil || job.Done() {
+			return time.Time{}, nil
+		}
+		route, ok := r.routes[job.Route]
+		if !ok {
+			return time.Time{}, fmt.Errorf("no route %s configured", job.Route)
+		}
+
+		err := r.step(ctx, route, job)
+		if err == nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			return time.Time{}, err
+		}
+
+		var retryAt time.Time
+		updateErr := r.store.Update(func(state *State) error {
+			stored := state.Jobs[key]
+			stored.Attempts++
+			stored.LastError = err.Error()
+			stored.UpdatedAt = r.now()
+			if stored.Attempts >= r.config.MaxAttempts {
+				stored.Failed = true
+				return nil
+			}
+			stored.NextAttempt = r.now().Add(r.retryDelay(stored.Attempts))
+			retryAt = stored.NextAttempt
+			return nil
+		})
+		if updateErr != nil {
+			return time.Time{}, updateErr
+		}
+		if retryAt.IsZero() {
+			return retryAt, fmt.Errorf("giving up after %d attempts: %v", r.config.MaxAttempts, err)
+		}
+		return retryAt, err
+	}
+}
+
+// step moves a job to its next stage
+func (r *Relayer) step(ctx context.Context, route Route, job *Job) error {
+	switch job.Stage {
+	case StageExported:
+		return r.prepareImport(ctx, route, job)
+	case StageImporting:
+		return r.commitImport(ctx, route, job)
+	case StageChunks:
+		if job.ChunkTx == nil {
+			return r.prepareChunk(ctx, route, job)
+		}
+		return r.commitChunk(ctx, route, job)
+	case StageFinalizing:
+		return r.commitFinalize(ctx, route, job)
+	case StageImported:
+		return r.prepareCompletion(ctx, route, job)
+	case StageCompleting:
+		return r.commitCompletion(ctx, route, job)
+	case StageAborting:
+		if job.AbortTx == nil {
+			return r.prepareAbort(ctx, route, job)
+		}
+		return r.commitAbort(ctx, route, job)
+	default:
+		return fmt.Errorf("unknown job stage %q", job.Stage)
+	}
+}
+
+// prepareImport reads the exported package from the source chain's block,
+// attaches the block as transfer proof and signs the import, or for a chunked
+// package the beginning of its import. A transfer past its deadline is given
+// up, the target chain would refuse it.
+func (r *Relayer) prepareImport(ctx context.Context, route Route, job *Job) error {
+	if expired, err := r.expire(route, job); expired || err != nil {
+		return err
+	}
+
+	block, err := route.Source.BlockByTxID(ctx, job.ExportTxID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch the block of export transaction %s: %v", job.ExportTxID, err)
+	}
+	packageJSON, err := exportedPackage(block, job.ExportTxID)
+	if err != nil {
+		return err
+	}
+	exportPackage, err := core.DecodePackage(packageJSON)
+	if err != nil {
+		return err
+	}
+	hash, err := core.PackageHash(exportPackage)
+	if err != nil {
+		return err
+	}
+	// An export of an older package format committed to the hash of the package
+	// as that release encoded it, which is the JSON its export returned. Once
+	// that matches, the job carries the hash at the current format, which the
+	// source chain recomputes from its export record on completion.
+	if job.FormatVersion != core.PackageFormatVersion {
+		sum := sha256.Sum256(packageJSON)
+		if committed := hex.EncodeToString(sum[:]); committed != job.PackageHash {
+			return fmt.Errorf("export transaction %s returned a package of format version %d with hash %s, its event committed to %s",
+				job.ExportTxID, job.FormatVersion, committed, job.PackageHash)
+		}
+		job.PackageHash = hash
+		job.FormatVersion = core.PackageFormatVersion
+	}
+	if hash != job.PackageHash {
+		return fmt.Errorf("export transaction %s returned a package with hash %s, its event committed to %s",
+			job.ExportTxID, hash, job.PackageHash)
+	}
+
+	exportPackage.Proof = &core.TransferProof{Block: block}
+	provenJSON, err := json.Marshal(exportPackage)
+	if err != nil {
+		return fmt.Errorf("failed to marshal export package: %v", err)
+	}
+	function, chunks := route.ImportFunction, 0
+	if exportPackage.Manifest != nil {
+		function, chunks = route.BeginFunction, len(exportPackage.Manifest.Chunks)
+	}
+	tx, err := route.Target.NewTransaction(function, string(provenJSON))
+	if err != nil {
+		return fmt.Errorf("failed to prepare import: %v", err)
+	}
+
+	return r.updateJob(job, func(stored *Job) {
+		stored.PackageHash = job.PackageHash
+		stored.FormatVersion = job.FormatVersion
+		stored.ImportTx = tx
+		stored.Chunks = chunks
+		stored.Stage = StageImporting
+	})
+}
+
+// expire gives up a job whose transfer is past its deadline, as the target
+// chain would refuse its import. It reports whether it did.
+func (r *Relayer) expire(route Route, job *Job) (bool, error) {
+	if job.ExpiresAt == 0 || r.now().Unix() <= job.ExpiresAt {
+		return false, nil
+	}
+	r.config.Logger.Printf("%s: case %s transfer expired at %s before it was imported, abort it on the source chain",
+		route.Name, job.InvestigationID, time.Unix(job.ExpiresAt, 0).UTC().Format(time.RFC3339))
+	return true, r.updateJob(job, func(stored *Job) {
+		stored.Stage = StageExpired
+	})
+}
+
+// commitImport submits the prepared import, or finds it already committed,
+// and records the package hash the target chain stored. A begun chunked
+// import goes on with its chunks.
+func (r *Relayer) commitImport(ctx context.Context, route Route, job *Job) error {
+	result, err := r.commit(ctx, route.Target, job.ImportTx)
+	if err != nil {
+		return fmt.Errorf("import transaction %s: %v", job.ImportTx.ID, err)
+	}
+	if result.Code != TxValid {
+		// Prepare a fresh import; the chain will not take this transaction ID again
+		if err := r.rollback(job, StageExported); err != nil {
+			return err
+		}
+		return fmt.Errorf("import transaction %s was committed as %s", job.ImportTx.ID, result.Code)
+	}
+
+	if job.Chunks > 0 {
+		r.config.Logger.Printf("%s: case %s import of %d chunks begun in transaction %s",
+			route.Name, job.InvestigationID, job.Chunks, job.ImportTx.ID)
+		return r.updateJob(job, func(stored *Job) {
+			stored.ImportTx.Proposal = nil
+			stored.Stage = StageChunks
+		})
+	}
+
+	var importRecord core.CaseImport
+	if err := json.Unmarshal(result.Payload, &importRecord); err != nil {
+		return fmt.Errorf("failed to unmarshal import record of transaction %s: %v", job.ImportTx.ID, err)
+	}
+
+	r.config.Logger.Printf("%s: case %s imported in transaction %s", route.Name, job.InvestigationID, job.ImportTx.ID)
+	return r.updateJob(job, func(stored *Job) {
+		stored.ImportTx.Proposal = nil // The package and block are on the target chain now
+		stored.ImportedHash = importRecord.PackageHash
+		stored.Stage = StageImported
+	})
+}
+
+// prepareChunk fetches the next chunk of a chunked export from the source chain
+// and signs its import, or once every chunk is imported, signs the finalize
+func (r *Relayer) prepareChunk(ctx context.Context, route Route, job *Job) error {
+	if expired, err := r.expire(route, job); expired || err != nil {
+		return err
+	}
+
+	if job.NextChunk >= job.Chunks {
+		tx, err := route.Target.NewTransaction(route.FinalizeFunction, job.InvestigationID, job.ExportTxID)
+		if err != nil {
+			return fmt.Errorf("failed to prepare finalize: %v", err)
+		}
+		return r.updateJob(job, func(stored *Job) {
+			stored.FinalizeTx = tx
+			stored.Stage = StageFinalizing
+		})
+	}
+
+	index := strconv.Itoa(job.NextChunk)
+	chunkJSON, err := route.Source.Query(ctx, ChunkQuery, job.InvestigationID, job.ExportTxID, index)
+	if err != nil {
+		return fmt.Errorf("failed to fetch chunk %d of export %s: %v", job.NextChunk, job.ExportTxID, err)
+	}
+	tx, err := route.Target.NewTransaction(route.ChunkFunction, job.InvestigationID, job.ExportTxID, index, string(chunkJSON))
+	if err != nil {
+		return fmt.Errorf("failed to prepare import of chunk %d: %v", job.NextChunk, err)
+	}
+	return r.updateJob(job, func(stored *Job) {
+		stored.ChunkTx = tx
+	})
+}
+
+// commitChunk submits the prepared chunk import, or finds it already
+// committed, and moves on to the next chunk
+func (r *Relayer) commitChunk(ctx context.Context, route Route, job *Job) error {
+	result, err := r.commit(ctx, route.Target, job.ChunkTx)
+	if err != nil {
+		return fmt.Errorf("chunk %d transaction %s: %v", job.NextChunk, job.ChunkTx.ID, err)
+	}
+	if result.Code != TxValid {
+		if err := r.rollback(job, StageChunks); err != nil {
+			return err
+		}
+		return fmt.Errorf("chunk %d transaction %s was committed as %s", job.NextChunk, job.ChunkTx.ID, result.Code)
+	}
+
+	return r.updateJob(job, func(stored *Job) {
+		stored.ChunkTx = nil // The chunk is on the target chain now
+		stored.NextChunk++
+	})
+}
+
+// commitFinalize submits the prepared finalize, or finds it already committed,
+// and records the package hash the target chain stored
+func (r *Relayer) commitFinalize(ctx context.Context, route Route, job *Job) error {
+	result, err := r.commit(ctx, route.Target, job.FinalizeTx)
+	if err != nil {
+		return fmt.Errorf("finalize transaction %s: %v", job.FinalizeTx.ID, err)
+	}
+	if result.Code != TxValid {
+		if err := r.rollback(job, StageChunks); err != nil {
+			return err
+		}
+		return fmt.Errorf("finalize transaction %s was committed as %s", job.FinalizeTx.ID, result.Code)
+	}
+
+	var importRecord core.CaseImport
+	if err := json.Unmarshal(result.Payload, &importRecord); err != nil {
+		return fmt.Errorf("failed to unmarshal import record of transaction %s: %v", job.FinalizeTx.ID, err)
+	}
+
+	r.config.Logger.Printf("%s: case %s imported in %d chunks, finalized in transaction %s",
+		route.Name, job.InvestigationID, job.Chunks, job.FinalizeTx.ID)
+	return r.updateJob(job, func(stored *Job) {
+		stored.ImportedHash = importRecord.PackageHash
+		stored.Stage = StageImported
+	})
+}
+
+// prepareCompletion signs the confirmation of the import for the source chain,
+// with the target chain block that committed the import record as proof
+func (r *Relayer) prepareCompletion(ctx context.Context, route Route, job *Job) error {
+	block, err := route.Target.BlockByTxID(ctx, job.TargetTxID())
+	if err != nil {
+		return fmt.Errorf("failed to fetch the block of import transaction %s: %v", job.TargetTxID(), err)
+	}
+	proofJSON, err := json.Marshal(core.TransferProof{Block: block})
+	if err != nil {
+		return fmt.Errorf("failed to marshal import proof: %v", err)
+	}
+	tx, err := route.Source.NewTransaction(route.CompleteFunction, job.InvestigationID, string(proofJSON))
+	if err != nil {
+		return fmt.Errorf("failed to prepare completion: %v", err)
+	}
+	return r.updateJob(job, func(stored *Job) {
+		stored.CompleteTx = tx
+		stored.Stage = StageCompleting
+	})
+}
+
+// commitCompletion submits the prepared completion, or finds it already
+// committed, and records whether the source chain accepted the import
+func (r *Relayer) commitCompletion(ctx context.Context, route Route, job *Job) error {
+	result, err := r.commit(ctx, route.Source, job.CompleteTx)
+	if err != nil {
+		return fmt.Errorf("completion transaction %s: %v", job.CompleteTx.ID, err)
+	}
+	if result.Code != TxValid {
+		if err := r.rollback(job, StageImported); err != nil {
+			return err
+		}
+		return fmt.Errorf("completion transaction %s was committed as %s", job.CompleteTx.ID, result.Code)
+	}
+
+	var transfer core.CaseTransfer
+	if err := json.Unmarshal(result.Payload, &transfer); err != nil {
+		return fmt.Errorf("failed to unmarshal case transfer of transaction %s: %v", job.CompleteTx.ID, err)
+	}
+
+	if transfer.State == core.TransferStateRejected {
+		r.config.Logger.Printf("%s: case %s transfer REJECTED by the source chain: %s",
+			route.Name, job.InvestigationID, transfer.RejectedReason)
+		return r.updateJob(job, func(stored *Job) {
+			stored.LastError = transfer.RejectedReason
+			stored.Stage = StageRejected
+		})
+	}
+
+	r.config.Logger.Printf("%s: case %s transfer completed in transaction %s", route.Name, job.InvestigationID, job.CompleteTx.ID)
+	return r.updateJob(job, func(stored *Job) {
+		stored.LastError = ""
+		stored.Stage = StageCompleted
+	})
+}
+
+// prepareAbort signs the record of an abort for the target chain, with the
+// source chain block that committed the abort as proof
+func (r *Relayer) prepareAbort(ctx context.Context, route Route, job *Job) error {
+	block, err := route.Source.BlockByTxID(ctx, job.AbortTxID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch the block of abort transaction %s: %v", job.AbortTxID, err)
+	}
+	proofJSON, err := json.Marshal(core.TransferProof{Block: block})
+	if err != nil {
+		return fmt.Errorf("failed to marshal abort proof: %v", err)
+	}
+	tx, err := route.Target.NewTransaction(route.AbortFunction, job.InvestigationID, string(proofJSON))
+	if err != nil {
+		return fmt.Errorf("failed to prepare abort record: %v", err)
+	}
+	return r.updateJob(job, func(stored *Job) {
+		stored.AbortTx = tx
+	})
+}
+
+// commitAbort submits the prepared record of an abort, or finds it already
+// committed, after which the target chain refuses the export
+func (r *Relayer) commitAbort(ctx context.Context, route Route, job *Job) error {
+	result, err := r.commit(ctx, route.Target, job.AbortTx)
+	if err != nil {
+		return fmt.Errorf("abort record transaction %s: %v", job.AbortTx.ID, err)
+	}
+	if result.Code != TxValid {
+		if err := r.rollback(job, StageAborting); err != nil {
+			return err
+		}
+		return fmt.Errorf("abort record transaction %s was committed as %s", job.AbortTx.ID, result.Code)
+	}
+
+	r.config.Logger.Printf("%s: case %s abort recorded on the target chain in transaction %s",
+		route.Name, job.InvestigationID, job.AbortTx.ID)
+	return r.updateJob(job, func(stored *Job) {
+		stored.Stage = StageAborted
+	})
+}
+
+// commit returns the committed result of tx, submitting it unless the chain
+// already committed it, e.g. before the relayer last stopped
+func (r *Relayer) commit(ctx context.Context, chain Chain, tx *Transaction) (*Result, error) {
+	result, err := chain.Result(ctx, tx.ID)
+	if err != nil {
+		return nil, err
+	}
+	if result != nil {
+		return result, nil
+	}
+
+	result, err = chain.Submit(ctx, tx)
+	if err != nil {
+		// The transaction may have committed even though the response was lost
+		if committed, lookupErr := chain.Result(ctx, tx.ID); lookupErr == nil && committed != nil {
+			return committed, nil
+		}
+		return nil, err
+	}
+	if result.Code == TxDuplicateTxID {
+		// An earlier submission of the same transaction committed first
+		committed, err := chain.Result(ctx, tx.ID)
+		if err != nil {
+			return nil, err
+		}
+		if committed == nil {
+			return nil, fmt.Errorf("transaction committed as a duplicate but the original is not found")
+		}
+		return committed, nil
+	}
+	return result, nil
+}
+
+// updateJob persists the progress of a job, resetting its attempts, and copies it back into job
+func (r *Relayer) updateJob(job *Job, change func(stored *Job)) error {
+	key := JobKey(job.Route, job.ExportTxID)
+	return r.store.Update(func(state *State) error {
+		stored, ok := state.Jobs[key]
+		if !ok {
+			return fmt.Errorf("job %s not found", key)
+		}
+		change(stored)
+		stored.Attempts = 0
+		stored.NextAttempt = time.Time{}
+		stored.UpdatedAt = r.now()
+		*job = *stored
+		return nil
+	})
+}
+
+// rollback returns a job to the stage that prepares the transaction that did
+// not take effect, keeping its attempt count
+func (r *Relayer) rollback(job *Job, stage string) error {
+	key := JobKey(job.Route, job.ExportTxID)
+	return r.store.Update(func(state *State) error {
+		stored := state.Jobs[key]
+		switch stage {
+		case StageExported:
+			stored.ImportTx = nil
+		case StageChunks:
+			stored.ChunkTx = nil
+			stored.FinalizeTx = nil
+		case StageImported:
+			stored.CompleteTx = nil
+		case StageAborting:
+			stored.AbortTx = nil
+		}
+		stored.Stage = stage
+		stored.UpdatedAt = r.now()
+		return nil
+	})
+}
+
+// retryDelay is the delay before the given attempt, doubling up to MaxRetryDelay
+func (r *Relayer) retryDelay(attempt int) time.Duration {
+	delay := r.config.RetryDelay
+	for i := 1; i < attempt && delay < r.config.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > r.config.MaxRetryDelay {
+		delay = r.config.MaxRetryDelay
+	}
+	return delay
+}
@@ -0,0 +1,921 @@
+package relayer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	core "github.com/aub/dfir-core"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+)
+
+// transferNetwork runs the transfer protocol of the hot and cold chaincodes on
+// two in-memory chains: exports commit to a package hash, imports check the
+// proof block and record the hash they received until the export's deadline
+// or its recorded abort, completions compare the two. Chunked imports check
+// each chunk against the manifest and record the hash on finalize.
+type transferNetwork struct {
+	t    *testing.T
+	hot  *memChain
+	cold *memChain
+
+	mu        sync.Mutex
+	transfers map[string]*core.CaseTransfer  // By direction/case on the source chain
+	imported  map[string]bool                // By target chain/case
+	tamper    bool                           // Importing chain alters the evidence it stores
+	deadline  time.Time                      // Deadline of the next exports, none if zero
+	version   int                            // Package format of the next exports, current if zero
+	chunked   bool                           // Next exports list their evidence in chunks
+	chunks    map[string][]string            // Chunk JSON by export transaction
+	sessions  map[string]*core.ImportSession // Chunked imports by export transaction
+	stored    map[string]map[int]bool        // Imported chunks by export transaction
+	aborted   map[string]string              // Aborts recorded on the target chain, by export transaction
+}
+
+func newTransferNetwork(t *testing.T) *transferNetwork {
+	n := &transferNetwork{
+		t:         t,
+		hot:       newMemChain(t, core.ChainHot),
+		cold:      newMemChain(t, core.ChainCold),
+		transfers: map[string]*core.CaseTransfer{},
+		imported:  map[string]bool{},
+		chunks:    map[string][]string{},
+		sessions:  map[string]*core.ImportSession{},
+		stored:    map[string]map[int]bool{},
+		aborted:   map[string]string{},
+	}
+	n.hot.invoke = func(txID string, function string, args []string) ([]byte, *Event, error) {
+		switch function {
+		case "CompleteArchiveTransfer":
+			return n.completeTransfer(core.ArchiveFlow, args)
+		case "ImportReactivatedCase":
+			return n.importCase(core.ReactivationFlow, txID, args)
+		case "BeginReactivatedCaseImport":
+			return n.beginImport(txID, args)
+		case "ImportReactivatedCaseChunk":
+			return n.importChunk(txID, args)
+		case "FinalizeReactivatedCaseImport":
+			return n.finalizeImport(core.ReactivationFlow, txID, args)
+		case "RecordReactivationAbort":
+			return n.recordAbort(core.ReactivationFlow, args)
+		}
+		return nil, nil, fmt.Errorf("unknown function %s", function)
+	}
+	n.cold.invoke = func(txID string, function string, args []string) ([]byte, *Event, error) {
+		switch function {
+		case "ImportArchivedCase":
+			return n.importCase(core.ArchiveFlow, txID, args)
+		case "BeginArchivedCaseImport":
+			return n.beginImport(txID, args)
+		case "ImportArchivedCaseChunk":
+			return n.importChunk(txID, args)
+		case "FinalizeArchivedCaseImport":
+			return n.finalizeImport(core.ArchiveFlow, txID, args)
+		case "RecordArchiveAbort":
+			return n.recordAbort(core.ArchiveFlow, args)
+		case "CompleteReactivationTransfer":
+			return n.completeTransfer(core.ReactivationFlow, args)
+		}
+		return nil, nil, fmt.Errorf("unknown function %s", function)
+	}
+	n.hot.query = n.exportChunk
+	n.cold.query = n.exportChunk
+	return n
+}
+
+// chain returns the stand-in of a chain name
+func (n *transferNetwork) chain(name string) *memChain {
+	if name == core.ChainHot {
+		return n.hot
+	}
+	return n.cold
+}
+
+// export commits the export of a case on the flow's source chain and returns its transaction ID
+func (n *transferNetwork) export(flow core.TransferFlow, caseID string) string {
+	source := n.chain(flow.SourceChain)
+	tx, err := source.NewTransaction("Export", caseID)
+	if err != nil {
+		n.t.Fatalf("failed to prepare export: %v", err)
+	}
+
+	exportPackage := flow.NewPackage(
+		core.Investigation{ID: caseID, CaseNumber: "CASE-" + caseID, Status: flow.ExportStatus},
+		[]core.Evidence{{ID: caseID + "-EVD-1", CaseID: caseID, Hash: "abc"}},
+		"ORDER-1", "court", 5000, tx.ID)
+	if !n.deadline.IsZero() {
+		exportPackage.ExpiresAt = n.deadline.Unix()
+	}
+	if n.chunked {
+		exportPackage.Evidence = []core.Evidence{}
+		exportPackage.Manifest = n.chunk(caseID, tx.ID)
+	}
+	packageJSON := mustMarshal(n.t, exportPackage)
+	hash, err := core.PackageHash(exportPackage)
+	if err != nil {
+		n.t.Fatalf("failed to hash package: %v", err)
+	}
+	transfer := &core.CaseTransfer{
+		InvestigationID: caseID,
+		Direction:       flow.Direction,
+		ExportTxID:      tx.ID,
+		PackageHash:     hash,
+		FormatVersion:   core.PackageFormatVersion,
+		PriorStatus:     flow.ExportStatus,
+		State:           core.TransferStateExported,
+		ExpiresAt:       exportPackage.ExpiresAt,
+	}
+	if n.version != 0 {
+		// An older release hashed the package as it encoded it. The version
+		// before the current one only differs in format_version.
+		current := fmt.Sprintf(`"format_version":%d`, core.PackageFormatVersion)
+		packageJSON = bytes.Replace(packageJSON, []byte(current), []byte(fmt.Sprintf(`"format_version":%d`, n.version)), 1)
+		sum := sha256.Sum256(packageJSON)
+		transfer.PackageHash = hex.EncodeToString(sum[:])
+		transfer.FormatVersion = n.version
+	}
+	eventJSON := mustMarshal(n.t, transfer)
+
+	// The source chain compares the import with the hash at the current format
+	transfer.PackageHash = hash
+	transfer.FormatVersion = core.PackageFormatVersion
+	n.mu.Lock()
+	n.transfers[flow.Direction+"/"+caseID] = transfer
+	n.mu.Unlock()
+	source.commit(tx.ID, 0, packageJSON, &Event{Name: ExportEvent, Payload: eventJSON})
+	return tx.ID
+}
+
+// abort commits the abort of a case transfer on the flow's source chain
+func (n *transferNetwork) abort(flow core.TransferFlow, caseID string, reason string) {
+	source := n.chain(flow.SourceChain)
+	tx, err := source.NewTransaction("AbortCaseTransfer", caseID, reason)
+	if err != nil {
+		n.t.Fatalf("failed to prepare abort: %v", err)
+	}
+
+	n.mu.Lock()
+	transfer := n.transfers[flow.Direction+"/"+caseID]
+	transfer.State = core.TransferStateAborted
+	transfer.AbortReason = reason
+	transfer.AbortTxID = tx.ID
+	transferJSON := mustMarshal(n.t, transfer)
+	n.mu.Unlock()
+	source.commit(tx.ID, 0, transferJSON, &Event{Name: AbortEvent, Payload: transferJSON})
+}
+
+// chunk splits five evidence items of a case into chunks of two and returns their manifest
+func (n *transferNetwork) chunk(caseID string, exportTxID string) *core.TransferManifest {
+	manifest := &core.TransferManifest{EvidenceCount: 5}
+	var evidence []core.Evidence
+	for i := 1; i <= manifest.EvidenceCount; i++ {
+		evidence = append(evidence, core.Evidence{ID: fmt.Sprintf("%s-EVD-%d", caseID, i), CaseID: caseID, Hash: "abc"})
+	}
+	for start := 0; start < len(evidence); start += 2 {
+		end := start + 2
+		if end > len(evidence) {
+			end = len(evidence)
+		}
+		chunkJSON := mustMarshal(n.t, evidence[start:end])
+		hash := sha256.Sum256(chunkJSON)
+		chunk := core.ManifestChunk{Index: len(manifest.Chunks), Hash: hex.EncodeToString(hash[:])}
+		for _, item := range evidence[start:end] {
+			chunk.EvidenceIDs = append(chunk.EvidenceIDs, item.ID)
+		}
+		manifest.Chunks = append(manifest.Chunks, chunk)
+		n.mu.Lock()
+		n.chunks[exportTxID] = append(n.chunks[exportTxID], string(chunkJSON))
+		n.mu.Unlock()
+	}
+	return manifest
+}
+
+// exportChunk is the source chain's GetExportChunk
+func (n *transferNetwork) exportChunk(function string, args []string) ([]byte, error) {
+	if function != ChunkQuery {
+		return nil, fmt.Errorf("unknown query %s", function)
+	}
+	index, _ := strconv.Atoi(args[2])
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if index >= len(n.chunks[args[1]]) {
+		return nil, fmt.Errorf("export %s has no chunk %d", args[1], index)
+	}
+	return []byte(n.chunks[args[1]][index]), nil
+}
+
+// beginImport is the target chain's BeginImport
+func (n *transferNetwork) beginImport(txID string, args []string) ([]byte, *Event, error) {
+	received, err := n.provenPackage(args[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	hash, err := core.PackageHash(received)
+	if err != nil {
+		return nil, nil, err
+	}
+	session := &core.ImportSession{
+		InvestigationID: received.Investigation.ID,
+		SourceTxID:      received.TransferTxID,
+		PackageHash:     hash,
+		Package:         *received,
+		StartTxID:       txID,
+	}
+	n.mu.Lock()
+	n.sessions[received.TransferTxID] = session
+	n.stored[received.TransferTxID] = map[int]bool{}
+	n.mu.Unlock()
+	return mustMarshal(n.t, session), nil, nil
+}
+
+// importChunk is the target chain's ImportChunk
+func (n *transferNetwork) importChunk(txID string, args []string) ([]byte, *Event, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	session, ok := n.sessions[args[1]]
+	if !ok {
+		return nil, nil, fmt.Errorf("no import of export %s was begun", args[1])
+	}
+	index, _ := strconv.Atoi(args[2])
+	hash := sha256.Sum256([]byte(args[3]))
+	if chunks := session.Package.Manifest.Chunks; index >= len(chunks) || chunks[index].Hash != hex.EncodeToString(hash[:]) {
+		return nil, nil, fmt.Errorf("chunk %d of export %s does not match the manifest", index, args[1])
+	}
+	n.stored[args[1]][index] = true
+	return mustMarshal(n.t, core.ImportedChunk{InvestigationID: args[0], SourceTxID: args[1], Index: index, ImportTxID: txID}), nil, nil
+}
+
+// finalizeImport is the target chain's FinalizeImport
+func (n *transferNetwork) finalizeImport(flow core.TransferFlow, txID string, args []string) ([]byte, *Event, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	session, ok := n.sessions[args[1]]
+	if !ok {
+		return nil, nil, fmt.Errorf("no import of export %s was begun", args[1])
+	}
+	if imported, total := len(n.stored[args[1]]), len(session.Package.Manifest.Chunks); imported != total {
+		return nil, nil, fmt.Errorf("import of export %s is missing %d of %d chunks", args[1], total-imported, total)
+	}
+	n.imported[flow.TargetChain+"/"+args[0]] = true
+	return mustMarshal(n.t, core.CaseImport{
+		InvestigationID: args[0],
+		SourceChain:     session.Package.SourceChain,
+		SourceTxID:      args[1],
+		ImportTxID:      txID,
+		EvidenceCount:   session.Package.Manifest.EvidenceCount,
+		PackageHash:     session.PackageHash,
+	}), nil, nil
+}
+
+// provenPackage decodes a package for import; it must match the export
+// transaction in its proof block and be within its deadline
+func (n *transferNetwork) provenPackage(packageJSON string) (*core.CaseExportPackage, error) {
+	exportPackage, err := core.DecodePackage([]byte(packageJSON))
+	if err != nil {
+		return nil, err
+	}
+	if exportPackage.Proof == nil {
+		return nil, fmt.Errorf("export package %s carries no transfer proof", exportPackage.TransferTxID)
+	}
+	provenJSON, err := exportedPackage(exportPackage.Proof.Block, exportPackage.TransferTxID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transfer proof: %v", err)
+	}
+	proven, err := core.DecodePackage(provenJSON)
+	if err != nil {
+		return nil, err
+	}
+	received := *exportPackage
+	received.Proof = nil
+	if !reflect.DeepEqual(*proven, received) {
+		return nil, fmt.Errorf("invalid transfer proof: package differs from the export record")
+	}
+	if received.ExpiresAt != 0 && time.Now().Unix() > received.ExpiresAt {
+		return nil, fmt.Errorf("transfer %s expired", received.TransferTxID)
+	}
+	return &received, nil
+}
+
+// importCase is the target chain's import: the package must match the export
+// transaction in its proof block
+func (n *transferNetwork) importCase(flow core.TransferFlow, txID string, args []string) ([]byte, *Event, error) {
+	exportPackage, err := n.provenPackage(args[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	received := *exportPackage
+
+	caseID := exportPackage.Investigation.ID
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if abortTxID, ok := n.aborted[exportPackage.TransferTxID]; ok {
+		return nil, nil, fmt.Errorf("transfer %s was aborted on the %s chain in transaction %s",
+			exportPackage.TransferTxID, flow.SourceChain, abortTxID)
+	}
+	key := flow.TargetChain + "/" + caseID
+	if n.imported[key] && !flow.AllowExisting {
+		return nil, nil, fmt.Errorf("investigation %s already exists on %s chain", caseID, flow.TargetChain)
+	}
+	n.imported[key] = true
+
+	if n.tamper {
+		received.Evidence = []core.Evidence{{ID: caseID + "-EVD-1", CaseID: caseID, Hash: "tampered"}}
+	}
+	hash, err := core.PackageHash(&received)
+	if err != nil {
+		return nil, nil, err
+	}
+	return mustMarshal(n.t, core.CaseImport{
+		InvestigationID: caseID,
+		SourceChain:     exportPackage.SourceChain,
+		SourceTxID:      exportPackage.TransferTxID,
+		ImportTxID:      txID,
+		EvidenceCount:   len(received.Evidence),
+		PackageHash:     hash,
+	}), nil, nil
+}
+
+// completeTransfer is the source chain's completion: the import record is read
+// from the target chain block in the proof
+func (n *transferNetwork) completeTransfer(flow core.TransferFlow, args []string) ([]byte, *Event, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	transfer, ok := n.transfers[flow.Direction+"/"+args[0]]
+	if !ok {
+		return nil, nil, fmt.Errorf("no export of investigation %s for %s", args[0], flow.Direction)
+	}
+	if transfer.State != core.TransferStateExported {
+		return nil, nil, fmt.Errorf("transfer of investigation %s is already %s", args[0], transfer.State)
+	}
+
+	var proof core.TransferProof
+	if err := json.Unmarshal([]byte(args[1]), &proof); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal import proof: %v", err)
+	}
+	importRecord, err := importedRecord(proof.Block)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid transfer proof: %v", err)
+	}
+	if importRecord.InvestigationID != args[0] || importRecord.SourceTxID != transfer.ExportTxID {
+		return nil, nil, fmt.Errorf("invalid transfer proof: block does not commit an import of export %s", transfer.ExportTxID)
+	}
+	transfer.Settle(importRecord, 6000)
+	return mustMarshal(n.t, transfer), nil, nil
+}
+
+// recordAbort is the target chain's record of an abort: the aborted transfer
+// is read from the source chain block in the proof
+func (n *transferNetwork) recordAbort(flow core.TransferFlow, args []string) ([]byte, *Event, error) {
+	var proof core.TransferProof
+	if err := json.Unmarshal([]byte(args[1]), &proof); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal abort proof: %v", err)
+	}
+	txID, transferJSON, err := blockResult(proof.Block)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid transfer proof: %v", err)
+	}
+	var transfer core.CaseTransfer
+	if err := json.Unmarshal(transferJSON, &transfer); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal case transfer: %v", err)
+	}
+	if transfer.State != core.TransferStateAborted || transfer.AbortTxID != txID ||
+		transfer.InvestigationID != args[0] || transfer.Direction != flow.Direction {
+		return nil, nil, fmt.Errorf("invalid transfer proof: block does not commit the abort of investigation %s", args[0])
+	}
+
+	n.mu.Lock()
+	n.aborted[transfer.ExportTxID] = txID
+	n.mu.Unlock()
+	return transferJSON, nil, nil
+}
+
+// importedRecord returns the import record an import or finalize transaction
+// returned, read from the only transaction of a stand-in block
+func importedRecord(blockBytes []byte) (*core.CaseImport, error) {
+	txID, importJSON, err := blockResult(blockBytes)
+	if err != nil {
+		return nil, err
+	}
+	var importRecord core.CaseImport
+	if err := json.Unmarshal(importJSON, &importRecord); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal import record: %v", err)
+	}
+	if importRecord.ImportTxID != txID {
+		return nil, fmt.Errorf("import record of transaction %s names transaction %s", txID, importRecord.ImportTxID)
+	}
+	return &importRecord, nil
+}
+
+// blockResult returns the ID and result of the only transaction of a stand-in block
+func blockResult(blockBytes []byte) (string, []byte, error) {
+	block := &common.Block{}
+	if err := proto.Unmarshal(blockBytes, block); err != nil {
+		return "", nil, fmt.Errorf("failed to unmarshal block: %v", err)
+	}
+	if block.Data == nil || len(block.Data.Data) != 1 {
+		return "", nil, fmt.Errorf("block does not hold one transaction")
+	}
+	envelope := &common.Envelope{}
+	if err := proto.Unmarshal(block.Data.Data[0], envelope); err != nil {
+		return "", nil, fmt.Errorf("failed to unmarshal envelope: %v", err)
+	}
+	channelHeader, err := envelopeChannelHeader(envelope)
+	if err != nil {
+		return "", nil, err
+	}
+	result, err := exportedPackage(blockBytes, channelHeader.TxId)
+	if err != nil {
+		return "", nil, err
+	}
+	return channelHeader.TxId, result, nil
+}
+
+// transferState returns the state of a case transfer on its source chain
+func (n *transferNetwork) transferState(flow core.TransferFlow, caseID string) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.transfers[flow.Direction+"/"+caseID].State
+}
+
+// newTestRelayer relays both directions of n with the state in path
+func newTestRelayer(t *testing.T, n *transferNetwork, path string, logs io.Writer) *Relayer {
+	t.Helper()
+	store, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	return New(store, Config{
+		MaxAttempts:   5,
+		RetryDelay:    time.Millisecond,
+		MaxRetryDelay: 10 * time.Millisecond,
+		Logger:        log.New(logs, "", 0),
+	}, ArchiveRoute(n.hot, n.cold), ReactivationRoute(n.cold, n.hot))
+}
+
+// takeExport hands the export event of txID on a chain to the relayer
+func takeExport(t *testing.T, r *Relayer, route string, chain *memChain, txID string) {
+	t.Helper()
+	chain.mu.Lock()
+	var found *Event
+	for i := range chain.events {
+		if chain.events[i].TxID == txID {
+			found = &chain.events[i]
+		}
+	}
+	chain.mu.Unlock()
+	if found == nil {
+		t.Fatalf("no export event for transaction %s", txID)
+	}
+	if err := r.handleEvent(r.routes[route], *found); err != nil {
+		t.Fatalf("failed to handle export event: %v", err)
+	}
+}
+
+// runUntil runs the relayer until every listed job is done
+func runUntil(t *testing.T, r *Relayer, keys ...string) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- r.Run(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		finished := 0
+		for _, key := range keys {
+			if job := r.store.Job(key); job != nil && job.Done() {
+				finished++
+			}
+		}
+		if finished == len(keys) {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	for _, key := range keys {
+		t.Errorf("job %s: %+v", key, r.store.Job(key))
+	}
+	t.Fatalf("jobs did not finish in time")
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	return data
+}
+
+func TestRelayArchiveAndReactivation(t *testing.T) {
+	n := newTransferNetwork(t)
+	r := newTestRelayer(t, n, filepath.Join(t.TempDir(), "relayer.json"), io.Discard)
+
+	archiveTx := n.export(core.ArchiveFlow, "INV-001")
+	reactivationTx := n.export(core.ReactivationFlow, "INV-002")
+	archiveKey := JobKey(core.TransferArchive, archiveTx)
+	reactivationKey := JobKey(core.TransferReactivation, reactivationTx)
+	runUntil(t, r, archiveKey, reactivationKey)
+
+	for _, check := range []struct {
+		flow core.TransferFlow
+		key  string
+		id   string
+	}{
+		{core.ArchiveFlow, archiveKey, "INV-001"},
+		{core.ReactivationFlow, reactivationKey, "INV-002"},
+	} {
+		job := r.store.Job(check.key)
+		if job.Stage != StageCompleted {
+			t.Errorf("%s: job stage %s, want %s", check.flow.Direction, job.Stage, StageCompleted)
+		}
+		if job.ImportedHash != job.PackageHash {
+			t.Errorf("%s: imported hash %s, exported %s", check.flow.Direction, job.ImportedHash, job.PackageHash)
+		}
+		if state := n.transferState(check.flow, check.id); state != core.TransferStateCompleted {
+			t.Errorf("%s: source chain transfer state %s", check.flow.Direction, state)
+		}
+		if job.ImportTx.Proposal != nil {
+			t.Errorf("%s: committed import proposal kept in the checkpoint file", check.flow.Direction)
+		}
+	}
+	if n.cold.count("ImportArchivedCase") != 1 || n.hot.count("CompleteArchiveTransfer") != 1 {
+		t.Errorf("archive: %d imports, %d completions", n.cold.count("ImportArchivedCase"), n.hot.count("CompleteArchiveTransfer"))
+	}
+	if n.hot.count("ImportReactivatedCase") != 1 || n.cold.count("CompleteReactivationTransfer") != 1 {
+		t.Errorf("reactivation: %d imports, %d completions", n.hot.count("ImportReactivatedCase"), n.cold.count("CompleteReactivationTransfer"))
+	}
+	if checkpoint := r.store.Checkpoint(core.TransferArchive); checkpoint.TxID != archiveTx {
+		t.Errorf("archive checkpoint %+v, want transaction %s", checkpoint, archiveTx)
+	}
+}
+
+func TestRelayOlderFormatExport(t *testing.T) {
+	n := newTransferNetwork(t)
+	n.version = core.PackageFormatVersion - 1
+	r := newTestRelayer(t, n, filepath.Join(t.TempDir(), "relayer.json"), io.Discard)
+
+	relayedTx := n.export(core.ArchiveFlow, "INV-001")
+	relayedKey := JobKey(core.TransferArchive, relayedTx)
+	takeExport(t, r, core.TransferArchive, n.hot, relayedTx)
+	runUntil(t, r, relayedKey)
+	job := r.store.Job(relayedKey)
+	if job.Stage != StageCompleted || job.FormatVersion != core.PackageFormatVersion || job.ImportedHash != job.PackageHash {
+		t.Errorf("unexpected job for an older format export: %+v", job)
+	}
+
+	// The event's commitment is still checked against the package
+	forgedTx := n.export(core.ArchiveFlow, "INV-002")
+	forgedKey := JobKey(core.TransferArchive, forgedTx)
+	takeExport(t, r, core.TransferArchive, n.hot, forgedTx)
+	if err := r.store.Update(func(state *State) error {
+		state.Jobs[forgedKey].PackageHash = "forged"
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to update job: %v", err)
+	}
+	r.processDue(context.Background())
+	job = r.store.Job(forgedKey)
+	if job.Stage != StageExported || !strings.Contains(job.LastError, "its event committed to forged") {
+		t.Errorf("unexpected job for a package that does not match its commitment: %+v", job)
+	}
+	if count := n.cold.count("ImportArchivedCase"); count != 1 {
+		t.Errorf("%d imports, want 1", count)
+	}
+}
+
+func TestRelayerResumesAfterCrash(t *testing.T) {
+	crashes := []struct {
+		name  string
+		crash func(cold *memChain, stop context.CancelFunc)
+	}{
+		{"before the import is submitted", func(cold *memChain, stop context.CancelFunc) {
+			cold.beforeSubmit = stop
+		}},
+		{"after the import committed", func(cold *memChain, stop context.CancelFunc) {
+			cold.afterCommit = func() error {
+				stop()
+				return context.Canceled
+			}
+		}},
+	}
+
+	for _, tc := range crashes {
+		t.Run(tc.name, func(t *testing.T) {
+			n := newTransferNetwork(t)
+			path := filepath.Join(t.TempDir(), "relayer.json")
+			r := newTestRelayer(t, n, path, io.Discard)
+			exportTx := n.export(core.ArchiveFlow, "INV-001")
+			key := JobKey(core.TransferArchive, exportTx)
+			takeExport(t, r, core.TransferArchive, n.hot, exportTx)
+
+			ctx, stop := context.WithCancel(context.Background())
+			tc.crash(n.cold, stop)
+			r.processDue(ctx)
+			crashed := r.store.Job(key)
+			if crashed.Stage != StageImporting || crashed.ImportTx == nil || crashed.Attempts != 0 {
+				t.Fatalf("unexpected job after crash: %+v", crashed)
+			}
+
+			// A new process picks the job up from the checkpoint file
+			n.cold.beforeSubmit, n.cold.afterCommit = nil, nil
+			resumed := newTestRelayer(t, n, path, io.Discard)
+			resumed.processDue(context.Background())
+
+			job := resumed.store.Job(key)
+			if job.Stage != StageCompleted {
+				t.Fatalf("job stage %s after resuming, want %s (%s)", job.Stage, StageCompleted, job.LastError)
+			}
+			if job.ImportTx.ID != crashed.ImportTx.ID {
+				t.Errorf("resumed with import %s, prepared %s", job.ImportTx.ID, crashed.ImportTx.ID)
+			}
+			if count := n.cold.count("ImportArchivedCase"); count != 1 {
+				t.Errorf("case imported %d times", count)
+			}
+		})
+	}
+}
+
+func TestRelayerRecoversLostSubmitResponse(t *testing.T) {
+	n := newTransferNetwork(t)
+	r := newTestRelayer(t, n, filepath.Join(t.TempDir(), "relayer.json"), io.Discard)
+	exportTx := n.export(core.ArchiveFlow, "INV-001")
+	takeExport(t, r, core.TransferArchive, n.hot, exportTx)
+
+	n.cold.loseResponses = 1
+	n.hot.loseResponses = 1
+	r.processDue(context.Background())
+
+	job := r.store.Job(JobKey(core.TransferArchive, exportTx))
+	if job.Stage != StageCompleted {
+		t.Fatalf("job stage %s, want %s (%s)", job.Stage, StageCompleted, job.LastError)
+	}
+	if n.cold.count("ImportArchivedCase") != 1 || n.hot.count("CompleteArchiveTransfer") != 1 {
+		t.Errorf("%d imports, %d completions", n.cold.count("ImportArchivedCase"), n.hot.count("CompleteArchiveTransfer"))
+	}
+}
+
+func TestRelayerRetriesFailedSteps(t *testing.T) {
+	n := newTransferNetwork(t)
+	r := newTestRelayer(t, n, filepath.Join(t.TempDir(), "relayer.json"), io.Discard)
+	clock := time.Unix(1700000000, 0)
+	r.now = func() time.Time { return clock }
+
+	exportTx := n.export(core.ArchiveFlow, "INV-001")
+	key := JobKey(core.TransferArchive, exportTx)
+	takeExport(t, r, core.TransferArchive, n.hot, exportTx)
+
+	n.cold.failSubmits = 1
+	next := r.processDue(context.Background())
+	job := r.store.Job(key)
+	if job.Attempts != 1 || job.Stage != StageImporting || !strings.Contains(job.LastError, "connection refused") {
+		t.Fatalf("unexpected job after a failed import: %+v", job)
+	}
+	if !next.Equal(clock.Add(time.Millisecond)) {
+		t.Errorf("next attempt at %v, want %v", next, clock.Add(time.Millisecond))
+	}
+
+	// Not due yet
+	r.processDue(context.Background())
+	if job := r.store.Job(key); job.Attempts != 1 {
+		t.Fatalf("job retried before it was due: %+v", job)
+	}
+
+	// An import committed as invalid is prepared again under a new transaction ID
+	firstImport := job.ImportTx.ID
+	n.cold.invalidate = 1
+	clock = clock.Add(time.Second)
+	r.processDue(context.Background())
+	job = r.store.Job(key)
+	if job.Attempts != 2 || job.Stage != StageExported || !strings.Contains(job.LastError, "MVCC_READ_CONFLICT") {
+		t.Fatalf("unexpected job after an invalid import: %+v", job)
+	}
+
+	clock = clock.Add(time.Second)
+	r.processDue(context.Background())
+	job = r.store.Job(key)
+	if job.Stage != StageCompleted {
+		t.Fatalf("job stage %s, want %s (%s)", job.Stage, StageCompleted, job.LastError)
+	}
+	if job.ImportTx.ID == firstImport {
+		t.Errorf("invalid import transaction %s was reused", firstImport)
+	}
+	if count := n.cold.count("ImportArchivedCase"); count != 1 {
+		t.Errorf("case imported %d times", count)
+	}
+}
+
+func TestRelayerGivesUpAfterMaxAttempts(t *testing.T) {
+	n := newTransferNetwork(t)
+	r := newTestRelayer(t, n, filepath.Join(t.TempDir(), "relayer.json"), io.Discard)
+	clock := time.Unix(1700000000, 0)
+	r.now = func() time.Time { return clock }
+
+	exportTx := n.export(core.ArchiveFlow, "INV-001")
+	key := JobKey(core.TransferArchive, exportTx)
+	takeExport(t, r, core.TransferArchive, n.hot, exportTx)
+
+	n.cold.failSubmits = 100
+	for i := 0; i < 10; i++ {
+		r.processDue(context.Background())
+		clock = clock.Add(time.Minute)
+	}
+	job := r.store.Job(key)
+	if !job.Failed || job.Attempts != 5 {
+		t.Fatalf("unexpected job after repeated failures: %+v", job)
+	}
+
+	n.cold.failSubmits = 0
+	count, err := r.RetryFailed()
+	if err != nil || count != 1 {
+		t.Fatalf("RetryFailed = %d, %v", count, err)
+	}
+	r.processDue(context.Background())
+	if job := r.store.Job(key); job.Stage != StageCompleted {
+		t.Fatalf("job stage %s after retrying, want %s (%s)", job.Stage, StageCompleted, job.LastError)
+	}
+}
+
+func TestRelayerRecordsRejectedTransfer(t *testing.T) {
+	n := newTransferNetwork(t)
+	n.tamper = true
+	r := newTestRelayer(t, n, filepath.Join(t.TempDir(), "relayer.json"), io.Discard)
+
+	exportTx := n.export(core.ArchiveFlow, "INV-001")
+	key := JobKey(core.TransferArchive, exportTx)
+	takeExport(t, r, core.TransferArchive, n.hot, exportTx)
+	r.processDue(context.Background())
+
+	job := r.store.Job(key)
+	if job.Stage != StageRejected || !strings.Contains(job.LastError, "package hash mismatch") {
+		t.Fatalf("unexpected job for a tampered import: %+v", job)
+	}
+	if state := n.transferState(core.ArchiveFlow, "INV-001"); state != core.TransferStateRejected {
+		t.Errorf("source chain transfer state %s, want %s", state, core.TransferStateRejected)
+	}
+
+	// A rejected transfer is final
+	r.processDue(context.Background())
+	if count := n.hot.count("CompleteArchiveTransfer"); count != 1 {
+		t.Errorf("completion submitted %d times", count)
+	}
+}
+
+func TestRelayerResumesEventsAfterCheckpoint(t *testing.T) {
+	n := newTransferNetwork(t)
+	path := filepath.Join(t.TempDir(), "relayer.json")
+
+	first := n.export(core.ArchiveFlow, "INV-001")
+	runUntil(t, newTestRelayer(t, n, path, io.Discard), JobKey(core.TransferArchive, first))
+
+	// Exports committed while the relayer was down
+	second := n.export(core.ArchiveFlow, "INV-002")
+	third := n.export(core.ArchiveFlow, "INV-003")
+
+	var logs bytes.Buffer
+	resumed := newTestRelayer(t, n, path, &logs)
+	runUntil(t, resumed, JobKey(core.TransferArchive, second), JobKey(core.TransferArchive, third))
+
+	if strings.Contains(logs.String(), first) {
+		t.Errorf("event before the checkpoint was taken on again:\n%s", logs.String())
+	}
+	if count := n.cold.count("ImportArchivedCase"); count != 3 {
+		t.Errorf("%d imports, want 3", count)
+	}
+	if jobs := resumed.store.Jobs(); len(jobs) != 3 {
+		t.Errorf("%d jobs, want 3", len(jobs))
+	}
+}
+
+func TestRelayerStopsAbortedAndExpiredTransfers(t *testing.T) {
+	n := newTransferNetwork(t)
+	var logs bytes.Buffer
+	r := newTestRelayer(t, n, filepath.Join(t.TempDir(), "relayer.json"), &logs)
+
+	// Past its deadline a transfer is not imported
+	n.deadline = time.Now().Add(-time.Minute)
+	expiredTx := n.export(core.ArchiveFlow, "INV-001")
+	expiredKey := JobKey(core.TransferArchive, expiredTx)
+	takeExport(t, r, core.TransferArchive, n.hot, expiredTx)
+	r.processDue(context.Background())
+	if job := r.store.Job(expiredKey); job.Stage != StageExpired || job.ImportTx != nil {
+		t.Errorf("unexpected job for an expired transfer: %+v", job)
+	}
+
+	// A transfer aborted while its import is failing is given up
+	n.deadline = time.Now().Add(time.Hour)
+	abortedTx := n.export(core.ArchiveFlow, "INV-002")
+	abortedKey := JobKey(core.TransferArchive, abortedTx)
+	takeExport(t, r, core.TransferArchive, n.hot, abortedTx)
+	n.cold.failSubmits = 100
+	r.processDue(context.Background())
+	if job := r.store.Job(abortedKey); job.Stage != StageImporting || job.Attempts != 1 {
+		t.Fatalf("unexpected job after a failed import: %+v", job)
+	}
+
+	// Both aborts are recorded on the cold chain once it is back
+	n.abort(core.ArchiveFlow, "INV-001", "deadline passed")
+	n.abort(core.ArchiveFlow, "INV-002", "cold chain unreachable")
+	n.hot.mu.Lock()
+	events := append([]Event(nil), n.hot.events...)
+	n.hot.mu.Unlock()
+	for _, event := range events {
+		if event.Name == AbortEvent {
+			if err := r.handleEvent(r.routes[core.TransferArchive], event); err != nil {
+				t.Fatalf("failed to handle abort event: %v", err)
+			}
+		}
+	}
+	n.cold.failSubmits = 0
+	runUntil(t, r, expiredKey, abortedKey)
+	job := r.store.Job(abortedKey)
+	if job.Stage != StageAborted || job.LastError != "cold chain unreachable" || job.AbortTx == nil {
+		t.Errorf("unexpected job after the abort: %+v", job)
+	}
+	if job := r.store.Job(expiredKey); job.Stage != StageAborted || job.AbortTx == nil {
+		t.Errorf("unexpected expired job after the abort: %+v", job)
+	}
+	if count := n.cold.count("ImportArchivedCase"); count != 0 {
+		t.Errorf("%d imports of aborted or expired transfers", count)
+	}
+	if count := n.cold.count("RecordArchiveAbort"); count != 2 {
+		t.Errorf("%d abort records, want 2", count)
+	}
+
+	if checkpoint := r.store.Checkpoint(core.TransferArchive); checkpoint.TxID == abortedTx {
+		t.Errorf("checkpoint %+v was not moved past the abort event", checkpoint)
+	}
+	if !strings.Contains(logs.String(), "abort it on the source chain") {
+		t.Errorf("expired transfer not logged:\n%s", logs.String())
+	}
+}
+
+func TestRelayChunkedTransferResumesBetweenChunks(t *testing.T) {
+	n := newTransferNetwork(t)
+	n.chunked = true
+	path := filepath.Join(t.TempDir(), "relayer.json")
+	r := newTestRelayer(t, n, path, io.Discard)
+	exportTx := n.export(core.ArchiveFlow, "INV-001")
+	key := JobKey(core.TransferArchive, exportTx)
+	takeExport(t, r, core.TransferArchive, n.hot, exportTx)
+
+	// Stop after the second chunk committed and before its response arrived
+	ctx, stop := context.WithCancel(context.Background())
+	n.cold.afterCommit = func() error {
+		if n.cold.count("ImportArchivedCaseChunk") == 2 {
+			stop()
+			return context.Canceled
+		}
+		return nil
+	}
+	r.processDue(ctx)
+	crashed := r.store.Job(key)
+	if crashed.Stage != StageChunks || crashed.Chunks != 3 || crashed.NextChunk != 1 || crashed.ChunkTx == nil {
+		t.Fatalf("unexpected job after crash: %+v", crashed)
+	}
+
+	n.cold.afterCommit = nil
+	n.cold.invalidate = 1 // The next chunk must be prepared again
+	resumed := newTestRelayer(t, n, path, io.Discard)
+	runUntil(t, resumed, key)
+
+	job := resumed.store.Job(key)
+	if job.Stage != StageCompleted || job.ImportedHash != job.PackageHash {
+		t.Fatalf("unexpected job after resuming: %+v", job)
+	}
+	if job.ChunkTx != nil || job.NextChunk != 3 {
+		t.Errorf("job left at chunk %d with transaction %+v", job.NextChunk, job.ChunkTx)
+	}
+	for function, want := range map[string]int{
+		"BeginArchivedCaseImport":    1,
+		"ImportArchivedCaseChunk":    3,
+		"FinalizeArchivedCaseImport": 1,
+		"ImportArchivedCase":         0,
+	} {
+		if count := n.cold.count(function); count != want {
+			t.Errorf("%s committed %d times, want %d", function, count, want)
+		}
+	}
+	n.mu.Lock()
+	targetTxID := n.transfers[core.TransferArchive+"/INV-001"].TargetTxID
+	n.mu.Unlock()
+	if targetTxID != job.FinalizeTx.ID {
+		t.Errorf("completion confirmed transaction %s, want the finalize %s", targetTxID, job.FinalizeTx.ID)
+	}
+}
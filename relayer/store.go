@@ -0,0 +1,230 @@
+package relayer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ==============================================================================
+// CHECKPOINT STORE
+// ==============================================================================
+//
+// Everything the relayer knows lives in one JSON file: per route, the last
+// transfer event it took on, and per transfer, the stage it reached and the
+// signed transactions it prepared. Each change is written to a temporary file
+// and renamed over the old one, so a crash leaves either the old or the new
+// state on disk, never a mix.
+
+// Job stages, in order
+const (
+	StageExported   = "exported"   // Export committed on the source chain
+	StageImporting  = "importing"  // Import transaction prepared for the target chain
+	StageChunks     = "chunks"     // Chunked import begun, chunks being imported
+	StageFinalizing = "finalizing" // Every chunk imported, finalize transaction prepared
+	StageImported   = "imported"   // Import committed on the target chain
+	StageCompleting = "completing" // Completion transaction prepared for the source chain
+	StageCompleted  = "completed"  // Source chain confirmed the transfer
+	StageRejected   = "rejected"   // Source chain flagged the imported package as changed in flight
+	StageExpired    = "expired"    // Deadline passed before the import, the transfer can only be aborted
+	StageAborting   = "aborting"   // Transfer aborted on the source chain, abort being recorded on the target chain
+	StageAborted    = "aborted"    // Abort recorded on the target chain
+)
+
+// Job is the relayer's progress on one case transfer
+type Job struct {
+	Route           string `json:"route"` // core.TransferArchive or core.TransferReactivation
+	InvestigationID string `json:"investigation_id"`
+	ExportTxID      string `json:"export_tx_id"`
+	PackageHash     string `json:"package_hash"`   // Hash the export committed to
+	FormatVersion   int    `json:"format_version"` // Package format PackageHash was computed at
+	ExpiresAt       int64  `json:"expires_at"`     // Deadline of the import, 0 if none
+	Stage           string `json:"stage"`
+
+	ImportTx     *Transaction `json:"import_tx,omitempty"`     // Begins the import of a chunked package
+	ImportedHash string       `json:"imported_hash,omitempty"` // Hash the target chain recorded
+	CompleteTx   *Transaction `json:"complete_tx,omitempty"`
+
+	Chunks     int          `json:"chunks,omitempty"`     // Chunks of a chunked package, 0 if imported whole
+	NextChunk  int          `json:"next_chunk,omitempty"` // First chunk not yet imported
+	ChunkTx    *Transaction `json:"chunk_tx,omitempty"`   // Import of chunk NextChunk
+	FinalizeTx *Transaction `json:"finalize_tx,omitempty"`
+
+	AbortTxID string       `json:"abort_tx_id,omitempty"` // Source chain transaction that aborted the transfer
+	AbortTx   *Transaction `json:"abort_tx,omitempty"`    // Records the abort on the target chain
+
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	Failed      bool      `json:"failed"` // Gave up after MaxAttempts, see LastError
+	LastError   string    `json:"last_error,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Done reports whether the job reached a final stage
+func (j *Job) Done() bool {
+	switch j.Stage {
+	case StageCompleted, StageRejected, StageExpired, StageAborted:
+		return true
+	}
+	return false
+}
+
+// TargetTxID is the target chain transaction that wrote the import record:
+// the import, or the finalize transaction of a chunked import
+func (j *Job) TargetTxID() string {
+	if j.FinalizeTx != nil {
+		return j.FinalizeTx.ID
+	}
+	if j.ImportTx != nil {
+		return j.ImportTx.ID
+	}
+	return ""
+}
+
+// Checkpoint is the position of the last event a route took on
+type Checkpoint struct {
+	Block uint64 `json:"block"`
+	TxID  string `json:"tx_id"`
+}
+
+// State is the content of the checkpoint file
+type State struct {
+	Checkpoints map[string]Checkpoint `json:"checkpoints"` // By route
+	Jobs        map[string]*Job       `json:"jobs"`        // By JobKey
+}
+
+// JobKey identifies the job of an export transaction on a route
+func JobKey(route string, exportTxID string) string {
+	return route + "/" + exportTxID
+}
+
+// Store keeps the relayer's state in a checkpoint file
+type Store struct {
+	path  string
+	mu    sync.Mutex
+	state *State
+}
+
+// OpenStore loads the checkpoint file at path, starting empty if it does not exist
+func OpenStore(path string) (*Store, error) {
+	state := &State{}
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read checkpoint file: %v", err)
+	default:
+		if err := json.Unmarshal(data, state); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal checkpoint file %s: %v", path, err)
+		}
+	}
+	if state.Checkpoints == nil {
+		state.Checkpoints = map[string]Checkpoint{}
+	}
+	if state.Jobs == nil {
+		state.Jobs = map[string]*Job{}
+	}
+	return &Store{path: path, state: state}, nil
+}
+
+// Checkpoint returns the checkpoint of a route
+func (s *Store) Checkpoint(route string) Checkpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.Checkpoints[route]
+}
+
+// Job returns a copy of a job, or nil if there is none
+func (s *Store) Job(key string) *Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.state.Jobs[key]
+	if !ok {
+		return nil
+	}
+	copied := *job
+	return &copied
+}
+
+// Jobs returns copies of all jobs ordered by key
+func (s *Store) Jobs() []*Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.state.Jobs))
+	for key := range s.state.Jobs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	jobs := make([]*Job, 0, len(keys))
+	for _, key := range keys {
+		copied := *s.state.Jobs[key]
+		jobs = append(jobs, &copied)
+	}
+	return jobs
+}
+
+// Update applies fn to a copy of the state and persists the result. If fn or
+// the write fails, the state is left unchanged.
+func (s *Store) Update(fn func(state *State) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(s.state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal relayer state: %v", err)
+	}
+	next := &State{}
+	if err := json.Unmarshal(data, next); err != nil {
+		return fmt.Errorf("failed to copy relayer state: %v", err)
+	}
+	if err := fn(next); err != nil {
+		return err
+	}
+
+	data, err = json.MarshalIndent(next, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal relayer state: %v", err)
+	}
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return err
+	}
+	s.state = next
+	return nil
+}
+
+// writeFileAtomic replaces path with data so that a crash leaves the old or the new content
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create checkpoint file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write checkpoint file: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync checkpoint file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close checkpoint file: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace checkpoint file: %v", err)
+	}
+
+	// Persist the rename itself
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package relayer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStoreUpdatePersistsAtomically(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "relayer.json")
+	store, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("failed to open empty store: %v", err)
+	}
+
+	job := &Job{Route: "archive", InvestigationID: "INV-001", ExportTxID: "tx-1", Stage: StageImporting,
+		ImportTx: &Transaction{ID: "tx-2", Function: "ImportArchivedCase", Proposal: []byte{1, 2, 3}}}
+	if err := store.Update(func(state *State) error {
+		state.Jobs[JobKey(job.Route, job.ExportTxID)] = job
+		state.Checkpoints["archive"] = Checkpoint{Block: 7, TxID: "tx-1"}
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to update store: %v", err)
+	}
+
+	// A failed update changes neither the store nor the file
+	before, _ := os.ReadFile(path)
+	err = store.Update(func(state *State) error {
+		state.Jobs["archive/tx-1"].Stage = StageCompleted
+		state.Checkpoints["archive"] = Checkpoint{Block: 9}
+		return fmt.Errorf("interrupted")
+	})
+	if err == nil {
+		t.Fatalf("failed update reported success")
+	}
+	after, _ := os.ReadFile(path)
+	if string(before) != string(after) {
+		t.Errorf("failed update changed the checkpoint file")
+	}
+	if got := store.Job("archive/tx-1"); got.Stage != StageImporting {
+		t.Errorf("failed update changed the job stage to %s", got.Stage)
+	}
+
+	reopened, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	got := reopened.Job("archive/tx-1")
+	if got == nil || got.Stage != StageImporting || got.ImportTx.ID != "tx-2" || string(got.ImportTx.Proposal) != "\x01\x02\x03" {
+		t.Errorf("reopened job %+v", got)
+	}
+	if checkpoint := reopened.Checkpoint("archive"); checkpoint != (Checkpoint{Block: 7, TxID: "tx-1"}) {
+		t.Errorf("reopened checkpoint %+v", checkpoint)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
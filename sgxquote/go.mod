@@ -0,0 +1,3 @@
+module github.com/aub/dfir-sgxquote
+
+go 1.21
@@ -0,0 +1,212 @@
+// Package sgxquote parses and verifies Intel SGX ECDSA (DCAP) quotes, versions
+// 3 and 4. Both chaincodes use it to check the attestation documents submitted
+// to RegisterAttestation before a verifier counts towards the quorum.
+//
+// Verification covers the quote itself: the PCK certificate chain in the QE
+// certification data must chain to a trusted root, the PCK key must have signed
+// the quoting enclave's report, that report must bind the attestation key, and
+// the attestation key must have signed the quote header and report body.
+// Revocation (PCK CRLs), QE identity and platform TCB status from Intel's
+// collateral are not evaluated; callers apply their own measurement and
+// security-version policy to the parsed report body.
+//
+// The chaincodes vendor this module, so re-run `go mod vendor` in each
+// chaincode after changing it.
+package sgxquote
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+)
+
+// Quote and certification data constants from the Intel SGX ECDSA Quote Library
+const (
+	AttestationKeyECDSAP256 = 2 // Header.AttestationKeyType for ECDSA-256-with-P-256
+
+	TEETypeSGX = 0x00000000 // Header.TEEType (version 4)
+
+	CertTypePCKCertChain   = 5 // PEM PCK leaf, intermediate and root certificates
+	CertTypeQEReportCert   = 6 // QE report certification data (version 4)
+	headerSize             = 48
+	reportBodySize         = 384
+	ecdsaSignatureSize     = 64
+	ecdsaPublicKeySize     = 64
+	signedQuoteSize        = headerSize + reportBodySize
+	signatureDataLenOffset = signedQuoteSize
+)
+
+// Header is the 48-byte quote header
+type Header struct {
+	Version            uint16
+	AttestationKeyType uint16
+	TEEType            uint32 // Reserved (zero) in version 3
+	QESVN              uint16 // Reserved in version 4
+	PCESVN             uint16 // Reserved in version 4
+	QEVendorID         [16]byte
+	UserData           [20]byte
+}
+
+// ReportBody is the 384-byte SGX enclave report body
+type ReportBody struct {
+	CPUSVN     [16]byte
+	MiscSelect uint32
+	Attributes [16]byte
+	MREnclave  [32]byte
+	MRSigner   [32]byte
+	ConfigID   [64]byte
+	ISVProdID  uint16
+	ISVSVN     uint16
+	ConfigSVN  uint16
+	ISVFamily  [16]byte
+	ReportData [64]byte
+}
+
+// MREnclaveHex returns MRENCLAVE as lowercase hex
+func (r *ReportBody) MREnclaveHex() string {
+	return hex.EncodeToString(r.MREnclave[:])
+}
+
+// MRSignerHex returns MRSIGNER as lowercase hex
+func (r *ReportBody) MRSignerHex() string {
+	return hex.EncodeToString(r.MRSigner[:])
+}
+
+// Quote is a parsed ECDSA quote
+type Quote struct {
+	Header Header
+	Body   ReportBody
+
+	Signature      []byte // ECDSA r||s over the header and report body
+	AttestationKey []byte // Raw P-256 x||y
+
+	QEReport          ReportBody
+	QEReportSignature []byte // ECDSA r||s by the PCK key over the QE report
+	QEAuthData        []byte
+
+	CertType uint16
+	CertData []byte // PEM certificate chain when CertType is CertTypePCKCertChain
+
+	signed      []byte // Header and report body as signed by the attestation key
+	qeReportRaw []byte
+}
+
+// Parse decodes a version 3 or version 4 SGX ECDSA quote
+func Parse(raw []byte) (*Quote, error) {
+	if len(raw) < signedQuoteSize+4 {
+		return nil, fmt.Errorf("quote is %d bytes, shorter than header and report body", len(raw))
+	}
+
+	q := &Quote{signed: raw[:signedQuoteSize]}
+	q.Header = parseHeader(raw[:headerSize])
+	if q.Header.AttestationKeyType != AttestationKeyECDSAP256 {
+		return nil, fmt.Errorf("unsupported attestation key type %d", q.Header.AttestationKeyType)
+	}
+	switch q.Header.Version {
+	case 3:
+	case 4:
+		if q.Header.TEEType != TEETypeSGX {
+			return nil, fmt.Errorf("unsupported TEE type %#x", q.Header.TEEType)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported quote version %d", q.Header.Version)
+	}
+	q.Body = parseReportBody(raw[headerSize:signedQuoteSize])
+
+	sigLen := binary.LittleEndian.Uint32(raw[signatureDataLenOffset:])
+	sig := raw[signatureDataLenOffset+4:]
+	if uint64(len(sig)) < uint64(sigLen) {
+		return nil, fmt.Errorf("signature data is %d bytes, header declares %d", len(sig), sigLen)
+	}
+	r := &reader{buf: sig[:sigLen]}
+
+	q.Signature = r.bytes(ecdsaSignatureSize)
+	q.AttestationKey = r.bytes(ecdsaPublicKeySize)
+
+	// Version 4 wraps the QE report in a certification data envelope of type 6
+	if q.Header.Version == 4 {
+		certType := r.uint16()
+		certLen := r.uint32()
+		if r.err == nil && certType != CertTypeQEReportCert {
+			return nil, fmt.Errorf("unsupported version 4 certification data type %d", certType)
+		}
+		r = &reader{buf: r.bytes(int(certLen)), err: r.err}
+	}
+
+	q.qeReportRaw = r.bytes(reportBodySize)
+	q.QEReportSignature = r.bytes(ecdsaSignatureSize)
+	q.QEAuthData = r.bytes(int(r.uint16()))
+	q.CertType = r.uint16()
+	q.CertData = r.bytes(int(r.uint32()))
+	if r.err != nil {
+		return nil, fmt.Errorf("failed to parse signature data: %v", r.err)
+	}
+	q.QEReport = parseReportBody(q.qeReportRaw)
+
+	return q, nil
+}
+
+// parseHeader decodes the 48-byte header
+func parseHeader(b []byte) Header {
+	var h Header
+	h.Version = binary.LittleEndian.Uint16(b[0:])
+	h.AttestationKeyType = binary.LittleEndian.Uint16(b[2:])
+	h.TEEType = binary.LittleEndian.Uint32(b[4:])
+	h.QESVN = binary.LittleEndian.Uint16(b[8:])
+	h.PCESVN = binary.LittleEndian.Uint16(b[10:])
+	copy(h.QEVendorID[:], b[12:28])
+	copy(h.UserData[:], b[28:48])
+	return h
+}
+
+// parseReportBody decodes the 384-byte report body
+func parseReportBody(b []byte) ReportBody {
+	var r ReportBody
+	copy(r.CPUSVN[:], b[0:16])
+	r.MiscSelect = binary.LittleEndian.Uint32(b[16:])
+	copy(r.Attributes[:], b[48:64])
+	copy(r.MREnclave[:], b[64:96])
+	copy(r.MRSigner[:], b[128:160])
+	copy(r.ConfigID[:], b[192:256])
+	r.ISVProdID = binary.LittleEndian.Uint16(b[256:])
+	r.ISVSVN = binary.LittleEndian.Uint16(b[258:])
+	r.ConfigSVN = binary.LittleEndian.Uint16(b[260:])
+	copy(r.ISVFamily[:], b[304:320])
+	copy(r.ReportData[:], b[320:384])
+	return r
+}
+
+// reader consumes little-endian fields and remembers the first overrun
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.buf) {
+		r.err = fmt.Errorf("field of %d bytes overruns %d remaining", n, len(r.buf))
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *reader) uint16() uint16 {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
+
+func (r *reader) uint32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
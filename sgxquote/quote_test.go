@@ -0,0 +1,160 @@
+package sgxquote
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Fixtures come from testdata/genfixtures and are signed by a test PKI valid 2024-2049
+var fixtureTime = time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	return data
+}
+
+func TestParseFixtures(t *testing.T) {
+	wantEnclave := sha256.Sum256([]byte("dfir-prv-enclave"))
+	wantSigner := sha256.Sum256([]byte("dfir-prv-signer"))
+
+	for _, tc := range []struct {
+		file    string
+		version uint16
+	}{
+		{"quote_v3.bin", 3},
+		{"quote_v4.bin", 4},
+	} {
+		t.Run(tc.file, func(t *testing.T) {
+			q, err := Parse(readFixture(t, tc.file))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if q.Header.Version != tc.version {
+				t.Errorf("version = %d, want %d", q.Header.Version, tc.version)
+			}
+			if got := q.Body.MREnclaveHex(); got != hex.EncodeToString(wantEnclave[:]) {
+				t.Errorf("MRENCLAVE = %s", got)
+			}
+			if got := q.Body.MRSignerHex(); got != hex.EncodeToString(wantSigner[:]) {
+				t.Errorf("MRSIGNER = %s", got)
+			}
+			if q.Body.ISVSVN != 2 {
+				t.Errorf("ISVSVN = %d, want 2", q.Body.ISVSVN)
+			}
+			chain, err := q.PCKCertChain()
+			if err != nil {
+				t.Fatalf("PCKCertChain: %v", err)
+			}
+			if len(chain) != 3 {
+				t.Errorf("chain has %d certificates, want 3", len(chain))
+			}
+		})
+	}
+}
+
+func TestVerifyFixtures(t *testing.T) {
+	root, err := ParseRootCertificate(readFixture(t, "root_ca.pem"))
+	if err != nil {
+		t.Fatalf("ParseRootCertificate: %v", err)
+	}
+
+	for _, file := range []string{"quote_v3.bin", "quote_v4.bin"} {
+		t.Run(file, func(t *testing.T) {
+			q, err := Parse(readFixture(t, file))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if err := q.Verify(root, fixtureTime); err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	root, err := ParseRootCertificate(readFixture(t, "root_ca.pem"))
+	if err != nil {
+		t.Fatalf("ParseRootCertificate: %v", err)
+	}
+	untrusted, err := ParseRootCertificate(readFixture(t, "untrusted_root_ca.pem"))
+	if err != nil {
+		t.Fatalf("ParseRootCertificate: %v", err)
+	}
+
+	for _, file := range []string{"quote_v3.bin", "quote_v4.bin"} {
+		raw := readFixture(t, file)
+
+		for _, tc := range []struct {
+			name      string
+			mutate    func([]byte)
+			untrusted bool
+			at        time.Time
+			want      string
+		}{
+			{"tampered MRENCLAVE", func(b []byte) { b[headerSize+64] ^= 1 }, false, fixtureTime, "quote signature is invalid"},
+			{"tampered header", func(b []byte) { b[10] ^= 1 }, false, fixtureTime, "quote signature is invalid"},
+			{"tampered quote signature", func(b []byte) { b[signatureDataLenOffset+4] ^= 1 }, false, fixtureTime, "quote signature is invalid"},
+			{"tampered QE report", func(b []byte) { b[qeReportOffset(b)+320] ^= 1 }, false, fixtureTime, "QE report signature is invalid"},
+			{"untrusted root", nil, true, fixtureTime, "not trusted"},
+			{"expired chain", nil, false, time.Date(2051, 1, 1, 0, 0, 0, 0, time.UTC), "not trusted"},
+		} {
+			t.Run(file+"/"+tc.name, func(t *testing.T) {
+				b := append([]byte{}, raw...)
+				if tc.mutate != nil {
+					tc.mutate(b)
+				}
+				q, err := Parse(b)
+				if err != nil {
+					t.Fatalf("Parse: %v", err)
+				}
+				trusted := root
+				if tc.untrusted {
+					trusted = untrusted
+				}
+				err = q.Verify(trusted, tc.at)
+				if err == nil || !strings.Contains(err.Error(), tc.want) {
+					t.Errorf("Verify = %v, want error containing %q", err, tc.want)
+				}
+			})
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	raw := readFixture(t, "quote_v3.bin")
+
+	for _, tc := range []struct {
+		name   string
+		mutate func([]byte) []byte
+		want   string
+	}{
+		{"truncated", func(b []byte) []byte { return b[:len(b)-10] }, "signature data"},
+		{"short", func(b []byte) []byte { return b[:100] }, "shorter than header"},
+		{"version 2", func(b []byte) []byte { b[0] = 2; return b }, "unsupported quote version"},
+		{"EPID key", func(b []byte) []byte { b[2] = 0; return b }, "unsupported attestation key type"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse(tc.mutate(append([]byte{}, raw...)))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Parse = %v, want error containing %q", err, tc.want)
+			}
+		})
+	}
+}
+
+// qeReportOffset returns the offset of the QE report in a fixture quote
+func qeReportOffset(b []byte) int {
+	offset := signatureDataLenOffset + 4 + ecdsaSignatureSize + ecdsaPublicKeySize
+	if b[0] == 4 {
+		offset += 6 // certification data type and size
+	}
+	return offset
+}
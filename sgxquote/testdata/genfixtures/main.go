@@ -0,0 +1,187 @@
+// Command genfixtures writes the recorded quotes used by the sgxquote tests.
+// The quotes follow the DCAP v3 and v4 layouts but are signed by a throwaway
+// test PKI instead of Intel's, so no SGX hardware is needed. Regenerate with
+//
+//	go run ./testdata/genfixtures
+//
+// from the sgxquote directory.
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	notBefore = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	notAfter  = time.Date(2049, 12, 31, 0, 0, 0, 0, time.UTC)
+)
+
+// Measurements of the fixture PRV enclave
+var (
+	mrEnclave = sha256.Sum256([]byte("dfir-prv-enclave"))
+	mrSigner  = sha256.Sum256([]byte("dfir-prv-signer"))
+)
+
+const isvSVN = 2
+
+func main() {
+	dir := "testdata"
+
+	rootKey, root := newCA("Test SGX Root CA", nil, nil)
+	interKey, inter := newCA("Test SGX PCK Platform CA", root, rootKey)
+	pckKey := newKey()
+	pck := newCert(&x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "Test SGX PCK Certificate"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}, inter, &pckKey.PublicKey, interKey)
+
+	_, otherRoot := newCA("Untrusted SGX Root CA", nil, nil)
+
+	chain := append(append(pemCert(pck), pemCert(inter)...), pemCert(root)...)
+	attestationKey := newKey()
+
+	write(filepath.Join(dir, "root_ca.pem"), pemCert(root))
+	write(filepath.Join(dir, "untrusted_root_ca.pem"), pemCert(otherRoot))
+	write(filepath.Join(dir, "quote_v3.bin"), buildQuote(3, attestationKey, pckKey, chain))
+	write(filepath.Join(dir, "quote_v4.bin"), buildQuote(4, attestationKey, pckKey, chain))
+}
+
+// buildQuote assembles a quote of the given version over the fixture enclave
+func buildQuote(version uint16, attestationKey, pckKey *ecdsa.PrivateKey, chain []byte) []byte {
+	header := make([]byte, 48)
+	binary.LittleEndian.PutUint16(header[0:], version)
+	binary.LittleEndian.PutUint16(header[2:], 2) // ECDSA-256-with-P-256
+	if version == 3 {
+		binary.LittleEndian.PutUint16(header[8:], 8)   // QE SVN
+		binary.LittleEndian.PutUint16(header[10:], 13) // PCE SVN
+	}
+	copy(header[12:28], []byte("\x93\x9a\x72\x33\xf7\x9c\x4c\xa9\x94\x0a\x0d\xb3\x95\x7f\x06\x07"))
+
+	body := make([]byte, 384)
+	copy(body[64:96], mrEnclave[:])
+	copy(body[128:160], mrSigner[:])
+	binary.LittleEndian.PutUint16(body[256:], 1) // ISV ProdID
+	binary.LittleEndian.PutUint16(body[258:], isvSVN)
+	copy(body[320:], []byte("dfir prv report data"))
+
+	signed := append(append([]byte{}, header...), body...)
+	attKey := rawKey(&attestationKey.PublicKey)
+	authData := []byte("fixture-qe-auth-data")
+
+	// QE report binds the attestation key and auth data
+	qeReport := make([]byte, 384)
+	binding := sha256.Sum256(append(append([]byte{}, attKey...), authData...))
+	copy(qeReport[320:352], binding[:])
+
+	var qeCert []byte
+	qeCert = append(qeCert, qeReport...)
+	qeCert = append(qeCert, sign(pckKey, qeReport)...)
+	qeCert = appendUint16(qeCert, uint16(len(authData)))
+	qeCert = append(qeCert, authData...)
+	qeCert = appendUint16(qeCert, 5) // PCK cert chain
+	qeCert = appendUint32(qeCert, uint32(len(chain)))
+	qeCert = append(qeCert, chain...)
+
+	var sig []byte
+	sig = append(sig, sign(attestationKey, signed)...)
+	sig = append(sig, attKey...)
+	if version == 4 {
+		sig = appendUint16(sig, 6) // QE report certification data
+		sig = appendUint32(sig, uint32(len(qeCert)))
+	}
+	sig = append(sig, qeCert...)
+
+	quote := appendUint32(signed, uint32(len(sig)))
+	return append(quote, sig...)
+}
+
+func newKey() *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return key
+}
+
+func newCA(name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*ecdsa.PrivateKey, *x509.Certificate) {
+	key := newKey()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if parent == nil {
+		return key, newCert(template, template, &key.PublicKey, key)
+	}
+	return key, newCert(template, parent, &key.PublicKey, parentKey)
+}
+
+func newCert(template, parent *x509.Certificate, pub *ecdsa.PublicKey, signer *ecdsa.PrivateKey) *x509.Certificate {
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, signer)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return cert
+}
+
+func pemCert(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+// sign returns a raw r||s signature over SHA-256 of data
+func sign(key *ecdsa.PrivateKey, data []byte) []byte {
+	digest := sha256.Sum256(data)
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return sig
+}
+
+// rawKey returns the x||y encoding of a P-256 public key
+func rawKey(pub *ecdsa.PublicKey) []byte {
+	raw := make([]byte, 64)
+	pub.X.FillBytes(raw[:32])
+	pub.Y.FillBytes(raw[32:])
+	return raw
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return binary.LittleEndian.AppendUint16(b, v)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return binary.LittleEndian.AppendUint32(b, v)
+}
+
+func write(path string, data []byte) {
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,139 @@
+package sgxquote
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// ParseRootCertificate decodes a single PEM root certificate, e.g. the Intel
+// SGX Root CA or a test root
+func ParseRootCertificate(rootPEM []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(rootPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("root certificate is not a PEM certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse root certificate: %v", err)
+	}
+	return cert, nil
+}
+
+// PCKCertChain returns the certificates of the QE certification data, leaf first
+func (q *Quote) PCKCertChain() ([]*x509.Certificate, error) {
+	if q.CertType != CertTypePCKCertChain {
+		return nil, fmt.Errorf("unsupported certification data type %d", q.CertType)
+	}
+
+	var chain []*x509.Certificate
+	rest := q.CertData
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse PCK certificate chain: %v", err)
+		}
+		chain = append(chain, cert)
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("PCK certificate chain is empty")
+	}
+	return chain, nil
+}
+
+// Verify checks the quote signature and the QE certification chain against
+// root as of now. The root must be trusted by the caller; a root bundled in the
+// quote is ignored.
+func (q *Quote) Verify(root *x509.Certificate, now time.Time) error {
+	chain, err := q.PCKCertChain()
+	if err != nil {
+		return err
+	}
+
+	// The PCK leaf must chain to the trusted root
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		if !cert.Equal(root) {
+			intermediates.AddCert(cert)
+		}
+	}
+	_, err = chain[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return fmt.Errorf("PCK certificate chain is not trusted: %v", err)
+	}
+
+	// The PCK key signs the quoting enclave's report
+	pckKey, ok := chain[0].PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("PCK certificate does not carry an ECDSA key")
+	}
+	if !verifyRaw(pckKey, q.qeReportRaw, q.QEReportSignature) {
+		return fmt.Errorf("QE report signature is invalid")
+	}
+
+	// The QE report binds the attestation key and QE authentication data
+	binding := sha256.Sum256(append(append([]byte{}, q.AttestationKey...), q.QEAuthData...))
+	if !bytes.Equal(q.QEReport.ReportData[:32], binding[:]) ||
+		!bytes.Equal(q.QEReport.ReportData[32:], make([]byte, 32)) {
+		return fmt.Errorf("QE report does not bind the attestation key")
+	}
+
+	// The attestation key signs the header and enclave report body
+	attestationKey, err := rawPublicKey(q.AttestationKey)
+	if err != nil {
+		return err
+	}
+	if !verifyRaw(attestationKey, q.signed, q.Signature) {
+		return fmt.Errorf("quote signature is invalid")
+	}
+
+	return nil
+}
+
+// rawPublicKey decodes a 64-byte x||y P-256 public key
+func rawPublicKey(raw []byte) (*ecdsa.PublicKey, error) {
+	if len(raw) != ecdsaPublicKeySize {
+		return nil, fmt.Errorf("attestation key is %d bytes, expected %d", len(raw), ecdsaPublicKeySize)
+	}
+	key := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(raw[:32]),
+		Y:     new(big.Int).SetBytes(raw[32:]),
+	}
+	if !key.Curve.IsOnCurve(key.X, key.Y) {
+		return nil, fmt.Errorf("attestation key is not a P-256 point")
+	}
+	return key, nil
+}
+
+// verifyRaw checks a 64-byte r||s ECDSA signature over SHA-256 of data
+func verifyRaw(key *ecdsa.PublicKey, data []byte, sig []byte) bool {
+	if len(sig) != ecdsaSignatureSize {
+		return false
+	}
+	digest := sha256.Sum256(data)
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	return ecdsa.Verify(key, digest[:], r, s)
+}